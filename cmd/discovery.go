@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/homedir"
+
+	"github.com/yashirook/vaptest/pkg/target"
+)
+
+var (
+	kubeconfigPath    string
+	kubeContext       string
+	discoveryCacheDir string
+	discoveryOutput   string
+)
+
+// illegalCacheDirChars matches the characters kubectl replaces when it names a discovery cache directory.
+var illegalCacheDirChars = regexp.MustCompile(`[^(\w/.)]`)
+
+func exportDiscovery(cmd *cobra.Command, args []string) {
+	cacheDir := discoveryCacheDir
+	if cacheDir == "" {
+		dir, err := defaultDiscoveryCacheDir()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to locate discovery cache: %w", err))
+			os.Exit(1)
+		}
+		cacheDir = dir
+	}
+
+	resourceLists, err := target.LoadDiscoveryCacheDir(cacheDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to read discovery cache: %w", err))
+		os.Exit(1)
+	}
+
+	out := os.Stdout
+	if discoveryOutput != "" {
+		f, err := os.Create(discoveryOutput)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to create output file: %w", err))
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := target.WriteDiscoverySnapshot(out, resourceLists); err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to write discovery snapshot: %w", err))
+		os.Exit(1)
+	}
+}
+
+// defaultDiscoveryCacheDir returns the directory kubectl uses to cache discovery for the kubeconfig's cluster.
+func defaultDiscoveryCacheDir() (string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfigPath
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return "", err
+	}
+
+	host := strings.Replace(strings.Replace(config.Host, "https://", "", 1), "http://", "", 1)
+	return filepath.Join(homedir.HomeDir(), ".kube", "cache", "discovery", illegalCacheDirChars.ReplaceAllString(host, "_")), nil
+}
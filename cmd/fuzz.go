@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/yashirook/vaptest/pkg/fuzz"
+	"github.com/yashirook/vaptest/pkg/loader"
+	"github.com/yashirook/vaptest/pkg/output"
+	"github.com/yashirook/vaptest/pkg/target"
+	"github.com/yashirook/vaptest/pkg/validation"
+)
+
+var (
+	crdPaths       []string
+	fuzzSeed       int64
+	fuzzIterations int
+	fixturesDir    string
+)
+
+func runFuzz(cmd *cobra.Command, args []string) {
+	if len(policyPaths) == 0 {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("--policies is required"))
+		os.Exit(1)
+	}
+
+	ldr := loader.NewLoader(scheme)
+	ldr.Strict = strict
+	policies, bindings, err := ldr.LoadPolicyFromPaths(policyPaths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load policy objects: %w", err))
+		os.Exit(1)
+	}
+	if errs := validation.ValidatePolicyObjects(policies, bindings, scheme, ldr.Source); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("invalid policy object: %w", err))
+		}
+		os.Exit(1)
+	}
+	params, err := ldr.LoadObjectFromPaths(paramPaths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load params: %w", err))
+		os.Exit(1)
+	}
+	for _, w := range ldr.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", w)
+	}
+	// The scheme does not know CustomResourceDefinitions, so they are loaded as unstructured objects
+	// without a warning
+	crds, err := loader.NewLoader(scheme).LoadObjectFromPaths(crdPaths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load CustomResourceDefinitions: %w", err))
+		os.Exit(1)
+	}
+
+	fuzzer := fuzz.NewFuzzer(policies, bindings, scheme)
+	fuzzer.Params = params
+	fuzzer.CRDs = crds
+	fuzzer.Defaulting = defaulting
+	fuzzer.Iterations = fuzzIterations
+	fuzzer.Seed = fuzzSeed
+	if fuzzer.Seed == 0 {
+		fuzzer.Seed = time.Now().UnixNano()
+	}
+	if discoveryPath != "" {
+		fuzzer.RESTMapper, err = target.LoadDiscoverySnapshot(discoveryPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load discovery snapshot: %w", err))
+			os.Exit(1)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := fuzzer.Run(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to fuzz policies: %w", err))
+		os.Exit(1)
+	}
+	for _, w := range fuzzer.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", w)
+	}
+
+	fixtures := map[string]string{}
+	if fixturesDir != "" && len(report.Findings) > 0 {
+		if err := os.MkdirAll(fixturesDir, 0o755); err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to create fixture directory: %w", err))
+			os.Exit(1)
+		}
+		for _, finding := range report.Findings {
+			path := filepath.Join(fixturesDir, finding.FixtureName())
+			if err := writeFixture(path, finding); err != nil {
+				fmt.Fprintln(os.Stderr, fmt.Errorf("failed to write fixture: %w", err))
+				os.Exit(1)
+			}
+			fixtures[finding.FixtureName()] = path
+		}
+	}
+
+	formatter := output.NewFuzzReportFormatter()
+	formatter.Output(report, fuzzer.Seed, fixtures)
+
+	if len(report.Findings) > 0 {
+		os.Exit(1)
+	}
+}
+
+func writeFixture(path string, finding fuzz.Finding) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return finding.WriteFixture(f)
+}
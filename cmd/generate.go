@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/yashirook/vaptest/pkg/casegen"
+	"github.com/yashirook/vaptest/pkg/target"
+	"github.com/yashirook/vaptest/pkg/testsuite"
+)
+
+var (
+	seedPaths      []string
+	paramPaths     []string
+	generateOutput string
+	suiteName      string
+)
+
+func generateCases(cmd *cobra.Command, args []string) {
+	if len(policyPaths) == 0 || len(seedPaths) == 0 {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("--policies and --seeds are required"))
+		os.Exit(1)
+	}
+
+	runner := testsuite.NewRunner(scheme)
+	runner.Strict = strict
+	runner.Defaulting = defaulting
+	if discoveryPath != "" {
+		var err error
+		runner.RESTMapper, err = target.LoadDiscoverySnapshot(discoveryPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load discovery snapshot: %w", err))
+			os.Exit(1)
+		}
+	}
+
+	name := suiteName
+	if name == "" {
+		name = "generated cases of " + strings.Join(policyPaths, ", ")
+	}
+	draft := &testsuite.Suite{
+		Name:      name,
+		Policies:  policyPaths,
+		Params:    paramPaths,
+		Resources: seedPaths,
+		Path:      generateOutput,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	suite, gaps, err := casegen.NewGenerator(runner).Generate(ctx, draft)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to generate test cases: %w", err))
+		os.Exit(1)
+	}
+	for _, w := range runner.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", w)
+	}
+	for _, gap := range gaps {
+		fmt.Fprintf(os.Stderr, "no case generated for %s\n", gap)
+	}
+	if len(suite.Tests) == 0 {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("no test cases generated from the seeds %v", seedPaths))
+		os.Exit(1)
+	}
+
+	out := os.Stdout
+	if generateOutput != "" {
+		if err := os.MkdirAll(filepath.Dir(generateOutput), 0o755); err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to create output directory: %w", err))
+			os.Exit(1)
+		}
+		f, err := os.Create(generateOutput)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to create output file: %w", err))
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := suite.Write(out); err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to write test suite: %w", err))
+		os.Exit(1)
+	}
+}
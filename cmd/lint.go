@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/yashirook/vaptest/pkg/lint"
+	"github.com/yashirook/vaptest/pkg/loader"
+	"github.com/yashirook/vaptest/pkg/output"
+	"github.com/yashirook/vaptest/pkg/target"
+)
+
+var failOn string
+
+func runLint(cmd *cobra.Command, args []string) {
+	if len(policyPaths) == 0 {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("--policies is required"))
+		os.Exit(1)
+	}
+	threshold := lint.Severity(failOn)
+	if threshold.Rank() == 0 && failOn != "none" {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("--fail-on must be error, warning or none, got %q", failOn))
+		os.Exit(1)
+	}
+
+	// 不正なポリシーも検査するため、ValidatePolicyObjects では検証しない
+	ldr := loader.NewLoader(scheme)
+	ldr.Strict = strict
+	policies, bindings, err := ldr.LoadPolicyFromPaths(policyPaths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load policy objects: %w", err))
+		os.Exit(1)
+	}
+	for _, w := range ldr.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", w)
+	}
+
+	linter := lint.NewLinter(policies, bindings, scheme)
+	linter.Source = ldr.Source
+	if discoveryPath != "" {
+		linter.RESTMapper, err = target.LoadDiscoverySnapshot(discoveryPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load discovery snapshot: %w", err))
+			os.Exit(1)
+		}
+	}
+	findings := linter.Lint()
+
+	formatter := output.NewLintReportFormatter()
+	formatter.Output(findings, len(policies), len(bindings))
+
+	if threshold.Rank() == 0 {
+		return
+	}
+	for _, finding := range findings {
+		if finding.Severity.Rank() >= threshold.Rank() {
+			os.Exit(1)
+		}
+	}
+}
+
+// lintRules lists the rules of the linter for the help of the lint command.
+func lintRules() string {
+	var b strings.Builder
+	for _, rule := range lint.Rules {
+		fmt.Fprintf(&b, "  %-27s %-8s %s\n", rule.ID, rule.Severity, rule.Description)
+	}
+	return b.String()
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/yashirook/vaptest/pkg/mutation"
+	"github.com/yashirook/vaptest/pkg/output"
+	"github.com/yashirook/vaptest/pkg/target"
+	"github.com/yashirook/vaptest/pkg/testsuite"
+)
+
+var minScore float64
+
+func mutate(cmd *cobra.Command, args []string) {
+	suites, err := testsuite.LoadFromPaths(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load test suites: %w", err))
+		os.Exit(1)
+	}
+	if len(suites) == 0 {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("no test suites found in %v", args))
+		os.Exit(1)
+	}
+
+	runner := testsuite.NewRunner(scheme)
+	runner.Strict = strict
+	runner.Defaulting = defaulting
+	if discoveryPath != "" {
+		runner.RESTMapper, err = target.LoadDiscoverySnapshot(discoveryPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load discovery snapshot: %w", err))
+			os.Exit(1)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	results, err := mutation.NewTester(runner).Run(ctx, suites)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to run mutation testing: %w", err))
+		os.Exit(1)
+	}
+	for _, w := range runner.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", w)
+	}
+
+	formatter := output.NewMutationReportFormatter()
+	formatter.Output(results)
+
+	if _, _, percent := mutation.Score(results); percent < minScore {
+		fmt.Fprintf(os.Stderr, "mutation score %.1f%% is below the minimum of %.1f%%\n", percent, minScore)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/yashirook/vaptest/pkg/loader"
+	"github.com/yashirook/vaptest/pkg/repl"
+	"github.com/yashirook/vaptest/pkg/target"
+)
+
+var (
+	objectPath          string
+	oldObjectPath       string
+	namespaceObjectPath string
+	kubernetesVersion   string
+)
+
+func runREPL(cmd *cobra.Command, args []string) {
+	if objectPath == "" {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("--object is required"))
+		os.Exit(1)
+	}
+	compatibilityVersion, err := repl.CompatibilityVersion(kubernetesVersion)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	ldr := loader.NewLoader(scheme)
+	ldr.Strict = strict
+	object, err := loadSingleObject(ldr, objectPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load object: %w", err))
+		os.Exit(1)
+	}
+	var oldObject, namespaceObject runtime.Object
+	if oldObjectPath != "" {
+		if oldObject, err = loadSingleObject(ldr, oldObjectPath); err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load old object: %w", err))
+			os.Exit(1)
+		}
+	}
+	if namespaceObjectPath != "" {
+		if namespaceObject, err = loadSingleObject(ldr, namespaceObjectPath); err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load namespace object: %w", err))
+			os.Exit(1)
+		}
+	}
+	params, err := ldr.LoadObjectFromPaths(paramPaths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load params: %w", err))
+		os.Exit(1)
+	}
+	if len(params) > 1 {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("--params must contain a single object, got %d", len(params)))
+		os.Exit(1)
+	}
+	for _, w := range ldr.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", w)
+	}
+
+	var opts []target.Option
+	if defaulting {
+		opts = append(opts, target.WithDefaulting(scheme))
+	}
+	mapper := target.StaticRESTMapper(scheme)
+	if discoveryPath != "" {
+		mapper, err = target.LoadDiscoverySnapshot(discoveryPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load discovery snapshot: %w", err))
+			os.Exit(1)
+		}
+	}
+	info, err := target.NewTargetInfoWithMapper(object, mapper, opts...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to create target info: %w", err))
+		os.Exit(1)
+	}
+
+	session := repl.NewSession(*info, scheme)
+	session.CompatibilityVersion = compatibilityVersion
+	if oldObject != nil {
+		// 古いオブジェクトも保存時と同じくデフォルト値が設定されたものとする
+		old, err := target.NewTargetInfoWithMapper(oldObject, mapper, opts...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to create target info of old object: %w", err))
+			os.Exit(1)
+		}
+		session.OldObject = old.Object
+	}
+	if len(params) == 1 {
+		session.Params, err = runtime.DefaultUnstructuredConverter.ToUnstructured(params[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to convert params: %w", err))
+			os.Exit(1)
+		}
+	}
+	if namespaceObject != nil {
+		ns, ok := namespaceObject.(*corev1.Namespace)
+		if !ok {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("--namespace-object must be a Namespace, got %s", namespaceObject.GetObjectKind().GroupVersionKind().Kind))
+			os.Exit(1)
+		}
+		session.Namespace = ns
+	}
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		err = session.RunTerminal(os.Stdin, os.Stdout)
+	} else {
+		err = session.Run(os.Stdin, os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to run REPL: %w", err))
+		os.Exit(1)
+	}
+}
+
+// loadSingleObject loads the manifest at the path, which must contain a single object.
+func loadSingleObject(ldr *loader.Loader, path string) (runtime.Object, error) {
+	objects, err := ldr.LoadObjectFromPaths([]string{path})
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) != 1 {
+		return nil, fmt.Errorf("%s must contain a single object, got %d", path, len(objects))
+	}
+	return objects[0], nil
+}
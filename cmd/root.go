@@ -2,19 +2,33 @@ package cmd
 
 import (
 	"os"
+	goruntime "runtime"
 
+	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
-	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
-	admissionregistrationv1beta "k8s.io/api/admissionregistration/v1beta1"
-	appsv1 "k8s.io/api/apps/v1"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/runtime"
+	"github.com/yashirook/vaptest/pkg/defaults"
+	"github.com/yashirook/vaptest/pkg/fuzz"
+	"github.com/yashirook/vaptest/pkg/lint"
+	"github.com/yashirook/vaptest/pkg/output"
+	"k8s.io/klog/v2"
 )
 
 var (
-	targetPaths []string
-	policyPaths []string
-	scheme      = runtime.NewScheme()
+	targetPaths   []string
+	policyPaths   []string
+	discoveryPath string
+	concurrency   int
+	defaulting    bool
+	strict        bool
+	engine        string
+	snapshotPath  string
+	updateSnaps   bool
+	coverageOn    bool
+	coverageFmt   string
+	coverageOut   string
+	minCoverage   float64
+	verbose       bool
+	scheme        = defaults.NewScheme()
 )
 
 var rootCmd = &cobra.Command{
@@ -22,6 +36,12 @@ var rootCmd = &cobra.Command{
 	Short: "vaptest is a tool for testing Kubernetes ValidationAdmissionPolicies",
 	Long: `vaptest is a CLI tool intended for testing Kubernetes ValidationAdmissionPolicies
 and ValidationAdmissionPolicyBindings against actual Kubernetes manifests.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// The upstream engine runs apiserver components that log to klog
+		if !verbose {
+			klog.SetLogger(logr.Discard())
+		}
+	},
 }
 
 var versionCmd = &cobra.Command{
@@ -36,6 +56,71 @@ var validateCmd = &cobra.Command{
 	Run:   validate,
 }
 
+var testCmd = &cobra.Command{
+	Use:   "test [suite files or directories]",
+	Short: "Run test suites checking the expected results of policies against resources",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   runTests,
+}
+
+var mutateCmd = &cobra.Command{
+	Use:   "mutate [suite files or directories]",
+	Short: "Run test suites against mutants of their policies and report the mutants no test case noticed",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   mutate,
+}
+
+var generateCmd = &cobra.Command{
+	Use:   "generate-cases",
+	Short: "Generate a test suite with resources that pass and violate each validation of policies, derived from seed manifests",
+	Args:  cobra.NoArgs,
+	Run:   generateCases,
+}
+
+var fuzzCmd = &cobra.Command{
+	Use:   "fuzz",
+	Short: "Evaluate policies against random objects conforming to the schemas of the kinds they match and report the objects their expressions do not handle",
+	Args:  cobra.NoArgs,
+	Run:   runFuzz,
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check policies and bindings for mistakes without evaluating them against resources",
+	Long: `Check policies and bindings for mistakes without evaluating them against resources.
+
+Rules (disable them for a policy and its bindings, or for a binding, with the
+vaptest/lint-disable annotation, a comma separated list of rule IDs):
+` + lintRules(),
+	Args: cobra.NoArgs,
+	Run:  runLint,
+}
+
+var replCmd = &cobra.Command{
+	Use:   "repl",
+	Short: "Evaluate CEL expressions interactively against a manifest, in the environment of the apiserver",
+	Long: `Evaluate CEL expressions interactively against a manifest, in the environment of the apiserver.
+
+The object of the manifest is bound to object, with oldObject, params, request,
+namespaceObject and authorizer bound as for an admission request. The type, the
+value and the cost of each expression are printed. Tab completes the fields of
+the variables from their values and their schemas. When the standard input is
+not a terminal, expressions are read from it one per line.`,
+	Args: cobra.NoArgs,
+	Run:  runREPL,
+}
+
+var discoveryCmd = &cobra.Command{
+	Use:   "discovery",
+	Short: "Manage API discovery snapshots used to resolve resources",
+}
+
+var discoveryExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a kubeconfig's cached API discovery as a discovery snapshot file",
+	Run:   exportDiscovery,
+}
+
 // Execute executes the root command.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
@@ -47,14 +132,67 @@ func init() {
 	// Cobra settings
 	validateCmd.Flags().StringSliceVarP(&targetPaths, "targets", "t", []string{}, "Path to the target Kubernetes manifests to validate")
 	validateCmd.Flags().StringSliceVarP(&policyPaths, "policies", "p", []string{}, "Path to the ValidatingAdmissionPolicy and ValidatingAdmissionPolicyBinding manifests to validate")
+	validateCmd.Flags().StringVar(&discoveryPath, "discovery", "", "Path to an API discovery snapshot (kubectl api-resources output, discovery JSON or vaptest discovery export output) used to resolve resources")
+	validateCmd.Flags().BoolVar(&strict, "strict", false, "Reject unknown fields, duplicate fields and wrong types in manifests")
+	validateCmd.Flags().BoolVar(&defaulting, "defaulting", true, "Apply Kubernetes API defaulting to targets before evaluation")
+	validateCmd.Flags().StringVar(&engine, "engine", engineNative, "Evaluation engine: native, upstream (the apiserver ValidatingAdmissionPolicy plugin), or compare to run both and report disagreements")
+	validateCmd.Flags().IntVar(&concurrency, "concurrency", goruntime.GOMAXPROCS(0), "Number of targets evaluated in parallel")
+	validateCmd.Flags().StringVar(&snapshotPath, "snapshot", "", "Path to a golden file of the results; the run fails if the results differ from it or it does not exist")
+	validateCmd.Flags().BoolVar(&updateSnaps, "update-snapshots", false, "Write the golden file given by --snapshot with the current results")
+	validateCmd.Flags().BoolVar(&watchMode, "watch", false, "Watch the policy and target files, including new files in the directories, and validate again when they change; only the native engine is supported")
+	testCmd.Flags().StringVar(&discoveryPath, "discovery", "", "Path to an API discovery snapshot (kubectl api-resources output, discovery JSON or vaptest discovery export output) used to resolve resources")
+	testCmd.Flags().BoolVar(&strict, "strict", false, "Reject unknown fields, duplicate fields and wrong types in manifests")
+	testCmd.Flags().BoolVar(&defaulting, "defaulting", true, "Apply Kubernetes API defaulting to resources before evaluation")
+	testCmd.Flags().BoolVar(&coverageOn, "coverage", false, "Report how often each match condition, variable and validation of the policies evaluated to true, false or an error")
+	testCmd.Flags().StringVar(&coverageFmt, "coverage-format", output.CoverageFormatText, "Format of the coverage report: text, json or lcov")
+	testCmd.Flags().StringVar(&coverageOut, "coverage-output", "", "Path to the file the coverage report is written to (defaults to stdout)")
+	testCmd.Flags().Float64Var(&minCoverage, "min-coverage", 0, "Fail when the percentage of expressions reached by the test suites is below this value; implies --coverage")
+	mutateCmd.Flags().StringVar(&discoveryPath, "discovery", "", "Path to an API discovery snapshot (kubectl api-resources output, discovery JSON or vaptest discovery export output) used to resolve resources")
+	mutateCmd.Flags().BoolVar(&strict, "strict", false, "Reject unknown fields, duplicate fields and wrong types in manifests")
+	mutateCmd.Flags().BoolVar(&defaulting, "defaulting", true, "Apply Kubernetes API defaulting to resources before evaluation")
+	mutateCmd.Flags().Float64Var(&minScore, "min-score", 0, "Fail when the percentage of mutants killed by the test suites is below this value")
+	generateCmd.Flags().StringSliceVarP(&policyPaths, "policies", "p", []string{}, "Path to the ValidatingAdmissionPolicy and ValidatingAdmissionPolicyBinding manifests to generate test cases for")
+	generateCmd.Flags().StringSliceVarP(&seedPaths, "seeds", "s", []string{}, "Path to the manifests of the resources the generated resources are derived from")
+	generateCmd.Flags().StringSliceVar(&paramPaths, "params", []string{}, "Path to the manifests of the params referenced by the bindings")
+	generateCmd.Flags().StringVarP(&generateOutput, "output", "o", "", "Path to the test suite file to write (defaults to stdout); paths in the suite are relative to its directory")
+	generateCmd.Flags().StringVar(&suiteName, "name", "", "Name of the generated test suite")
+	generateCmd.Flags().StringVar(&discoveryPath, "discovery", "", "Path to an API discovery snapshot (kubectl api-resources output, discovery JSON or vaptest discovery export output) used to resolve resources")
+	generateCmd.Flags().BoolVar(&strict, "strict", false, "Reject unknown fields, duplicate fields and wrong types in manifests")
+	generateCmd.Flags().BoolVar(&defaulting, "defaulting", true, "Apply Kubernetes API defaulting to resources before evaluation")
+	fuzzCmd.Flags().StringSliceVarP(&policyPaths, "policies", "p", []string{}, "Path to the ValidatingAdmissionPolicy and ValidatingAdmissionPolicyBinding manifests to fuzz")
+	fuzzCmd.Flags().StringSliceVar(&paramPaths, "params", []string{}, "Path to the manifests of the params referenced by the bindings")
+	fuzzCmd.Flags().StringSliceVar(&crdPaths, "crds", []string{}, "Path to the CustomResourceDefinitions of the custom resources matched by the policies")
+	fuzzCmd.Flags().Int64Var(&fuzzSeed, "seed", 0, "Seed of the random objects, to reproduce a run (defaults to a seed derived from the current time)")
+	fuzzCmd.Flags().IntVar(&fuzzIterations, "iterations", fuzz.DefaultIterations, "Number of random objects generated for each kind")
+	fuzzCmd.Flags().StringVar(&fixturesDir, "fixtures", "fuzz-fixtures", "Directory the shrunk objects revealing findings are written to; empty disables writing them")
+	fuzzCmd.Flags().StringVar(&discoveryPath, "discovery", "", "Path to an API discovery snapshot (kubectl api-resources output, discovery JSON or vaptest discovery export output) used to resolve resources")
+	fuzzCmd.Flags().BoolVar(&strict, "strict", false, "Reject unknown fields, duplicate fields and wrong types in manifests")
+	fuzzCmd.Flags().BoolVar(&defaulting, "defaulting", true, "Apply Kubernetes API defaulting to the random objects before evaluation")
+	lintCmd.Flags().StringSliceVarP(&policyPaths, "policies", "p", []string{}, "Path to the ValidatingAdmissionPolicy and ValidatingAdmissionPolicyBinding manifests to lint")
+	lintCmd.Flags().StringVar(&failOn, "fail-on", string(lint.SeverityError), "Fail when a finding has this severity or a more serious one: error, warning or none")
+	lintCmd.Flags().StringVar(&discoveryPath, "discovery", "", "Path to an API discovery snapshot (kubectl api-resources output, discovery JSON or vaptest discovery export output) used to resolve resources")
+	lintCmd.Flags().BoolVar(&strict, "strict", false, "Reject unknown fields, duplicate fields and wrong types in manifests")
+	replCmd.Flags().StringVar(&objectPath, "object", "", "Path to the manifest of the object bound to object")
+	replCmd.Flags().StringVar(&oldObjectPath, "old-object", "", "Path to the manifest of the object bound to oldObject; the request becomes an UPDATE")
+	replCmd.Flags().StringSliceVar(&paramPaths, "params", []string{}, "Path to the manifest of the object bound to params")
+	replCmd.Flags().StringVar(&namespaceObjectPath, "namespace-object", "", "Path to the manifest of the Namespace bound to namespaceObject (defaults to a namespace with only its name label)")
+	replCmd.Flags().StringVar(&kubernetesVersion, "kubernetes-version", "", "Kubernetes version whose CEL libraries expressions may use, e.g. 1.30 (defaults to the version of the validator)")
+	replCmd.Flags().StringVar(&discoveryPath, "discovery", "", "Path to an API discovery snapshot (kubectl api-resources output, discovery JSON or vaptest discovery export output) used to resolve resources")
+	replCmd.Flags().BoolVar(&strict, "strict", false, "Reject unknown fields, duplicate fields and wrong types in manifests")
+	replCmd.Flags().BoolVar(&defaulting, "defaulting", true, "Apply Kubernetes API defaulting to the object and the old object")
+	discoveryExportCmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "Path to the kubeconfig file (defaults to the standard kubeconfig loading rules)")
+	discoveryExportCmd.Flags().StringVar(&kubeContext, "context", "", "The kubeconfig context whose cluster discovery is exported (defaults to the current context)")
+	discoveryExportCmd.Flags().StringVar(&discoveryCacheDir, "cache-dir", "", "Path to the discovery cache directory of the cluster (defaults to ~/.kube/cache/discovery/<host>)")
+	discoveryExportCmd.Flags().StringVarP(&discoveryOutput, "output", "o", "", "Path to the snapshot file to write (defaults to stdout)")
+	discoveryCmd.AddCommand(discoveryExportCmd)
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Show the logs of the apiserver components run by the upstream engine")
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(validateCmd)
-
-	// Register target Kubernetes API types
-	_ = appsv1.AddToScheme(scheme)
-	_ = corev1.AddToScheme(scheme)
-
-	// Register policy API types
-	_ = admissionregistrationv1.AddToScheme(scheme)
-	_ = admissionregistrationv1beta.AddToScheme(scheme)
+	rootCmd.AddCommand(testCmd)
+	rootCmd.AddCommand(mutateCmd)
+	rootCmd.AddCommand(generateCmd)
+	rootCmd.AddCommand(fuzzCmd)
+	rootCmd.AddCommand(lintCmd)
+	rootCmd.AddCommand(replCmd)
+	rootCmd.AddCommand(discoveryCmd)
 }
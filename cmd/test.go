@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/yashirook/vaptest/pkg/coverage"
+	"github.com/yashirook/vaptest/pkg/output"
+	"github.com/yashirook/vaptest/pkg/target"
+	"github.com/yashirook/vaptest/pkg/testsuite"
+)
+
+func runTests(cmd *cobra.Command, args []string) {
+	suites, err := testsuite.LoadFromPaths(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load test suites: %w", err))
+		os.Exit(1)
+	}
+	if len(suites) == 0 {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("no test suites found in %v", args))
+		os.Exit(1)
+	}
+
+	if !slices.Contains(output.CoverageFormats, coverageFmt) {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("unknown coverage format %q: must be one of %v", coverageFmt, output.CoverageFormats))
+		os.Exit(1)
+	}
+
+	runner := testsuite.NewRunner(scheme)
+	runner.Strict = strict
+	runner.Defaulting = defaulting
+	if coverageOn || minCoverage > 0 {
+		runner.Coverage = coverage.New()
+	}
+	if discoveryPath != "" {
+		runner.RESTMapper, err = target.LoadDiscoverySnapshot(discoveryPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load discovery snapshot: %w", err))
+			os.Exit(1)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var results []testsuite.CaseResult
+	for _, suite := range suites {
+		suiteResults, err := runner.Run(ctx, suite)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to run test suite %s: %w", suite.Path, err))
+			os.Exit(1)
+		}
+		results = append(results, suiteResults...)
+	}
+	for _, w := range runner.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", w)
+	}
+
+	formatter := output.NewTestReportFormatter()
+	formatter.Output(results)
+
+	if runner.Coverage != nil {
+		reportCoverage(runner.Coverage)
+	}
+
+	for _, result := range results {
+		if !result.Passed() {
+			os.Exit(1)
+		}
+	}
+	if runner.Coverage != nil && runner.Coverage.Percent() < minCoverage {
+		fmt.Fprintf(os.Stderr, "coverage %.1f%% is below the minimum of %.1f%%\n", runner.Coverage.Percent(), minCoverage)
+		for _, e := range runner.Coverage.Unreached() {
+			fmt.Fprintf(os.Stderr, "  never reached: policy %s, binding %s, %s: %s\n", e.Policy, e.Binding, e.Path(), e.Expression)
+		}
+		os.Exit(1)
+	}
+}
+
+// reportCoverage prints the coverage report, or writes it to --coverage-output.
+func reportCoverage(profile *coverage.Profile) {
+	formatter := output.NewCoverageFormatter(coverageFmt)
+	if coverageOut == "" {
+		fmt.Println()
+	} else {
+		file, err := os.Create(coverageOut)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to write coverage report: %w", err))
+			os.Exit(1)
+		}
+		defer file.Close()
+		formatter.Writer = file
+	}
+	if err := formatter.Output(profile); err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to write coverage report: %w", err))
+		os.Exit(1)
+	}
+}
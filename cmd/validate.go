@@ -1,27 +1,67 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/signal"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/yashirook/vaptest/pkg/loader"
 	"github.com/yashirook/vaptest/pkg/output"
+	"github.com/yashirook/vaptest/pkg/snapshot"
 	"github.com/yashirook/vaptest/pkg/target"
+	"github.com/yashirook/vaptest/pkg/validation"
 	"github.com/yashirook/vaptest/pkg/validator"
 )
 
+const (
+	engineNative   = "native"
+	engineUpstream = "upstream"
+	engineCompare  = "compare"
+)
+
 func validate(cmd *cobra.Command, args []string) {
+	switch engine {
+	case engineNative, engineUpstream, engineCompare:
+	default:
+		fmt.Fprintln(os.Stderr, fmt.Errorf("unknown engine %q: must be %s, %s or %s", engine, engineNative, engineUpstream, engineCompare))
+		os.Exit(1)
+	}
+	if watchMode {
+		watchValidate()
+		return
+	}
 
 	ldr := loader.NewLoader(scheme)
+	ldr.Strict = strict
 	targetObjects, err := ldr.LoadObjectFromPaths(targetPaths)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load target manifests: %w", err))
 		os.Exit(1)
 	}
+	for _, w := range ldr.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", w)
+	}
+
+	var opts []target.Option
+	if defaulting {
+		opts = append(opts, target.WithDefaulting(scheme))
+	}
 
-	targets, err := target.NewTargetInfoList(targetObjects, scheme)
+	mapper := target.StaticRESTMapper(scheme)
+	if discoveryPath != "" {
+		mapper, err = target.LoadDiscoverySnapshot(discoveryPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load discovery snapshot: %w", err))
+			os.Exit(1)
+		}
+	}
+	targets, err := target.NewTargetInfoListWithMapper(targetObjects, mapper, opts...)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to create target info list: %w", err))
 		os.Exit(1)
@@ -32,19 +72,103 @@ func validate(cmd *cobra.Command, args []string) {
 		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load policy objects: %w", err))
 		os.Exit(1)
 	}
+	if errs := validation.ValidatePolicyObjects(policies, bindings, scheme, ldr.Source); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("invalid policy object: %w", err))
+		}
+		os.Exit(1)
+	}
 
-	validator, err := validator.NewValidator(targets, policies, bindings, scheme)
+	nativeValidator, err := validator.NewValidator(targets, policies, bindings, scheme)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to create validator: %w", err))
 		os.Exit(1)
 	}
+	nativeValidator.Concurrency = concurrency
+	nativeValidator.RESTMapper = mapper
 
-	results, err := validator.Validate()
+	upstreamValidator, err := validator.NewUpstreamValidator(targets, policies, bindings, scheme)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, fmt.Errorf("validation error: %w", err))
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to create validator: %w", err))
 		os.Exit(1)
 	}
+	upstreamValidator.RESTMapper = mapper
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var results []validator.ValidationResult
+	var disagreements []validator.Disagreement
+	switch engine {
+	case engineNative:
+		results = runEngine(ctx, &nativeValidator)
+	case engineUpstream:
+		results = runEngine(ctx, &upstreamValidator)
+	case engineCompare:
+		results = runEngine(ctx, &nativeValidator)
+		disagreements = validator.CompareResults(results, runEngine(ctx, &upstreamValidator), policies, bindings)
+		for _, policy := range policies {
+			if features := validator.IgnoredFeatures(policy, bindings); len(features) > 0 {
+				fmt.Fprintf(os.Stderr, "policy %s not compared: it uses %s, which the native engine ignores\n", policy.Name, strings.Join(features, ", "))
+			}
+		}
+	}
 
 	formatter := output.NewTableFormatter()
 	formatter.Output(results)
+
+	if len(disagreements) > 0 {
+		for _, d := range disagreements {
+			fmt.Fprintf(os.Stderr, "engine disagreement: %v\n", d)
+		}
+		os.Exit(1)
+	}
+
+	if snapshotPath != "" {
+		checkSnapshot(results)
+	}
+}
+
+// checkSnapshot compares the results with the golden file, exiting when they differ or the file does not exist.
+// The file is written instead when --update-snapshots is set.
+func checkSnapshot(results []validator.ValidationResult) {
+	current := snapshot.New(results)
+	if updateSnaps {
+		if err := current.Write(snapshotPath); err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to write snapshot: %w", err))
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "snapshot written: %s\n", snapshotPath)
+		return
+	}
+
+	golden, err := snapshot.Load(snapshotPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "snapshot not found: %s, run with --update-snapshots to write it\n", snapshotPath)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load snapshot: %w", err))
+		os.Exit(1)
+	}
+	changes := golden.Diff(current)
+	if len(changes) == 0 {
+		return
+	}
+	for _, change := range changes {
+		fmt.Fprintf(os.Stderr, "snapshot mismatch: %v\n", change)
+		fmt.Fprint(os.Stderr, change.Diff)
+	}
+	fmt.Fprintf(os.Stderr, "results differ from snapshot %s, run with --update-snapshots to accept the changes\n", snapshotPath)
+	os.Exit(1)
+}
+
+// runEngine evaluates the policies with the engine, exiting on errors.
+func runEngine(ctx context.Context, e validator.Engine) []validator.ValidationResult {
+	results, err := e.ValidateContext(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("validation error: %w", err))
+		os.Exit(1)
+	}
+	return results
 }
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+
+	"github.com/yashirook/vaptest/pkg/output"
+	"github.com/yashirook/vaptest/pkg/target"
+	"github.com/yashirook/vaptest/pkg/watch"
+)
+
+var watchMode bool
+
+// watchValidate validates the targets against the policies, then again each time their files change,
+// until interrupted.
+func watchValidate() {
+	if engine != engineNative {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("--watch supports only the %s engine", engineNative))
+		os.Exit(1)
+	}
+	if snapshotPath != "" {
+		fmt.Fprintln(os.Stderr, fmt.Errorf("--snapshot cannot be used with --watch"))
+		os.Exit(1)
+	}
+
+	session := watch.NewSession(scheme, policyPaths, targetPaths)
+	session.Strict = strict
+	session.Defaulting = defaulting
+	if discoveryPath != "" {
+		var err error
+		session.RESTMapper, err = target.LoadDiscoverySnapshot(discoveryPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, fmt.Errorf("failed to load discovery snapshot: %w", err))
+			os.Exit(1)
+		}
+	}
+
+	watcher, err := watch.NewWatcher(append(slices.Clone(policyPaths), targetPaths...))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer watcher.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	formatter := output.NewWatchFormatter()
+	formatter.Terminal = isTerminal(os.Stdout)
+	formatter.Output(session.Run(nil))
+	for {
+		changed, err := watcher.Next(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		formatter.Output(session.Run(changed))
+	}
+}
+
+// isTerminal reports whether the file is a terminal rather than a pipe or a regular file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,414 @@
+package casegen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/cel-go/common/ast"
+	"github.com/yashirook/vaptest/pkg/loader"
+	"github.com/yashirook/vaptest/pkg/target"
+	"github.com/yashirook/vaptest/pkg/testsuite"
+	"github.com/yashirook/vaptest/pkg/validator"
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Gap is a validation outcome no variant of the seeds produced.
+type Gap struct {
+	Policy string
+	// Index is the index of the validation in spec.validations.
+	Index int
+	// Holds is the outcome looked for: the validation evaluating to true, or to false.
+	Holds bool
+	// Reason explains why the outcome is missing from the generated suite.
+	Reason string
+}
+
+func (g Gap) String() string {
+	outcome := "violated"
+	if g.Holds {
+		outcome = "holding"
+	}
+	return fmt.Sprintf("policy %s spec.validations[%d] %s: %s", g.Policy, g.Index, outcome, g.Reason)
+}
+
+// Generator synthesizes test cases of policies from seed resources.
+type Generator struct {
+	// Runner evaluates the generated suite. Its scheme, RESTMapper, strictness and defaulting also apply to
+	// the evaluation of the variants.
+	Runner *testsuite.Runner
+}
+
+// NewGenerator creates a Generator evaluating with the runner.
+func NewGenerator(runner *testsuite.Runner) *Generator {
+	return &Generator{Runner: runner}
+}
+
+// variant is a seed edited to give a validation an outcome.
+type variant struct {
+	seed   int
+	edits  int
+	object runtime.Object
+	patch  []byte
+}
+
+// goal is a validation outcome looked for, with the variants that may produce it.
+type goal struct {
+	policy   string
+	index    int
+	holds    bool
+	variants []int
+}
+
+// Generate returns a suite of the policies, bindings, params and resources of the draft with a case for each
+// validation holding and a case for it being violated, each on the resource needing the fewest edits of its
+// seed. The edits are derived from the shape of the expression of the validation and kept only if evaluating
+// the edited resource gives the validation the outcome; they are written as merge patches of the seed.
+// Outcomes no variant produced are returned as gaps. The expected result of each case is the actual one, and
+// the suite passes as generated.
+func (g *Generator) Generate(ctx context.Context, draft *testsuite.Suite) (*testsuite.Suite, []Gap, error) {
+	ldr := loader.NewLoader(g.Runner.Scheme)
+	ldr.Strict = g.Runner.Strict
+	policies, bindings, err := ldr.LoadPolicyFromPaths(append(slices.Clone(draft.Policies), draft.Bindings...))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load policy objects: %w", err)
+	}
+	params, err := ldr.LoadObjectFromPaths(draft.Params)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load params: %w", err)
+	}
+	seeds, err := ldr.LoadObjectFromPaths(draft.Resources)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load seed resources: %w", err)
+	}
+	if len(policies) == 0 {
+		return nil, nil, fmt.Errorf("no policies found in %v", draft.Policies)
+	}
+	if len(seeds) == 0 {
+		return nil, nil, fmt.Errorf("no seed resources found in %v", draft.Resources)
+	}
+
+	mapper := g.Runner.RESTMapper
+	if mapper == nil {
+		mapper = target.StaticRESTMapper(g.Runner.Scheme)
+	}
+	var opts []target.Option
+	if g.Runner.Defaulting {
+		opts = append(opts, target.WithDefaulting(g.Runner.Scheme))
+	}
+	seedTargets, err := target.NewTargetInfoListWithMapper(seeds, mapper, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create target info list: %w", err)
+	}
+	seedContents := make([]map[string]any, len(seeds))
+	for i, seed := range seeds {
+		if seedContents[i], err = runtime.DefaultUnstructuredConverter.ToUnstructured(seed); err != nil {
+			return nil, nil, fmt.Errorf("failed to convert seed %s: %w", describe(seedTargets[i]), err)
+		}
+	}
+
+	// 候補のバリアントをすべて一つのバリデータでまとめて評価する
+	var variants []variant
+	var targets target.TargetInfoList
+	var goals []*goal
+	validationCounts := map[string]int{}
+	for _, policy := range policies {
+		validationCounts[policy.Name] = len(policy.Spec.Validations)
+		s := &synthesizer{variables: map[string]ast.Expr{}}
+		for _, v := range policy.Spec.Variables {
+			if e, ok := parse(v.Expression); ok {
+				s.variables[v.Name] = e
+			}
+		}
+		expressions := make([]ast.Expr, len(policy.Spec.Validations))
+		for i, validation := range policy.Spec.Validations {
+			expressions[i], _ = parse(validation.Expression)
+		}
+		// 各バリアントを評価対象に加え、そのインデックスを返す
+		evaluate := func(seed int, alternatives []alternative) []int {
+			var indices []int
+			for _, a := range alternatives {
+				v, err := newVariant(seeds[seed], seedContents[seed], a, g.Runner.Scheme)
+				if err != nil {
+					continue
+				}
+				info, err := target.NewTargetInfoWithMapper(v.object, mapper, opts...)
+				if err != nil {
+					continue
+				}
+				v.seed = seed
+				indices = append(indices, len(variants))
+				variants = append(variants, v)
+				targets = append(targets, *info)
+			}
+			return indices
+		}
+
+		// すべての検証を同時に満たすバリアントは、どの検証が成り立つケースにも使える
+		// 他の検証を満たしたまま一つの検証だけに違反するバリアントも試す
+		holding := func(except int) []alternative {
+			alternatives := []alternative{{}}
+			for i, e := range expressions {
+				if e != nil && i != except {
+					alternatives = combine(alternatives, s.synthesize(e, true, nil))
+				}
+			}
+			return alternatives
+		}
+		var passing []int
+		for j := range seeds {
+			s.object = seedContents[j]
+			passing = append(passing, evaluate(j, holding(-1))...)
+		}
+		for i, e := range expressions {
+			for _, holds := range []bool{true, false} {
+				goal := &goal{policy: policy.Name, index: i, holds: holds}
+				goals = append(goals, goal)
+				if holds {
+					goal.variants = append(goal.variants, passing...)
+				}
+				for j := range seeds {
+					s.object = seedContents[j]
+					alternatives := []alternative{{}}
+					if e != nil {
+						alternatives = append(alternatives, s.synthesize(e, holds, nil)...)
+						if !holds {
+							alternatives = append(alternatives, combine(holding(i), s.synthesize(e, false, nil))...)
+						}
+					}
+					goal.variants = append(goal.variants, evaluate(j, alternatives)...)
+				}
+			}
+		}
+	}
+
+	var results [][]validator.ValidationResult
+	if len(targets) > 0 {
+		v, err := validator.NewUpstreamValidator(targets, policies, bindings, g.Runner.Scheme)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create validator: %w", err)
+		}
+		v.Params = params
+		v.RESTMapper = mapper
+		if results, err = v.ValidatePerTargetContext(ctx); err != nil {
+			return nil, nil, fmt.Errorf("validation error: %w", err)
+		}
+	}
+	evaluated := map[string]bool{}
+	for _, goal := range goals {
+		for _, i := range goal.variants {
+			evaluated[goal.policy] = evaluated[goal.policy] || validator.FindResult(results[i], goal.policy) != nil
+		}
+	}
+
+	var gaps []Gap
+	var cases []testsuite.Case
+	var indexes [][]int
+	for _, goal := range goals {
+		if !evaluated[goal.policy] {
+			gaps = append(gaps, Gap{Policy: goal.policy, Index: goal.index, Holds: goal.holds, Reason: "the policy is not evaluated for any seed resource, check its bindings and match constraints"})
+			continue
+		}
+		best := -1
+		for _, i := range goal.variants {
+			if achieves(results[i], goal) && (best < 0 || slices.Compare(cost(results[i], variants[i], goal), cost(results[best], variants[best], goal)) < 0) {
+				best = i
+			}
+		}
+		if best < 0 {
+			gaps = append(gaps, Gap{Policy: goal.policy, Index: goal.index, Holds: goal.holds, Reason: "no variant of the seed resources has this outcome"})
+			continue
+		}
+		c := newCase(goal, variants[best], seedTargets[variants[best].seed])
+		// 同じリソースで複数の検証が成り立つ場合は一つのケースにまとめる
+		if goal.holds {
+			if j := slices.IndexFunc(cases, func(other testsuite.Case) bool { return sameResource(other, c) }); j >= 0 && len(cases[j].Validations) == 0 {
+				indexes[j] = append(indexes[j], goal.index)
+				continue
+			}
+		}
+		cases = append(cases, c)
+		indexes = append(indexes, []int{goal.index})
+	}
+	for i := range cases {
+		cases[i].Name = caseName(cases[i], indexes[i], validationCounts[cases[i].Policy])
+	}
+
+	suite := &testsuite.Suite{
+		TypeMeta:  draft.TypeMeta,
+		Name:      draft.Name,
+		Policies:  draft.Policies,
+		Bindings:  draft.Bindings,
+		Params:    draft.Params,
+		Resources: draft.Resources,
+		Path:      draft.Path,
+	}
+	suite.APIVersion, suite.Kind = testsuite.APIVersion, testsuite.Kind
+	if len(cases) == 0 {
+		return suite, gaps, nil
+	}
+
+	// 生成したケースをテストと同じ手順で実行し、実際の結果を期待値とする
+	suite.Tests = cases
+	caseResults, err := g.Runner.Run(ctx, suite)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to run the generated suite: %w", err)
+	}
+	suite.Tests = nil
+	for i, result := range caseResults {
+		c := cases[i]
+		if result.Err != nil || result.Diff != "" {
+			reason := "the generated case does not pass"
+			if result.Err != nil {
+				reason = result.Err.Error()
+			}
+			for _, index := range indexes[i] {
+				gaps = append(gaps, Gap{Policy: c.Policy, Index: index, Holds: len(c.Validations) == 0, Reason: reason})
+			}
+			continue
+		}
+		c.Expect = result.Actual
+		suite.Tests = append(suite.Tests, c)
+	}
+	return suite, gaps, nil
+}
+
+// newVariant returns the seed with the edits applied, and a merge patch turning the seed into it.
+// A typed seed is decoded into its type, which fails for values of the wrong type.
+func newVariant(seed runtime.Object, content map[string]any, edits alternative, scheme *runtime.Scheme) (variant, error) {
+	edited := apply(content, edits)
+	gvk := seed.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		kinds, _, err := scheme.ObjectKinds(seed)
+		if err != nil {
+			return variant{}, err
+		}
+		gvk = kinds[0]
+	}
+
+	var object runtime.Object = &unstructured.Unstructured{Object: edited}
+	if _, isUnstructured := seed.(runtime.Unstructured); !isUnstructured {
+		typed, err := scheme.New(gvk)
+		if err != nil {
+			return variant{}, err
+		}
+		doc, err := json.Marshal(edited)
+		if err != nil {
+			return variant{}, err
+		}
+		if err := json.Unmarshal(doc, typed); err != nil {
+			return variant{}, err
+		}
+		typed.GetObjectKind().SetGroupVersionKind(gvk)
+		if edited, err = runtime.DefaultUnstructuredConverter.ToUnstructured(typed); err != nil {
+			return variant{}, err
+		}
+		object = typed
+	}
+
+	original, err := json.Marshal(content)
+	if err != nil {
+		return variant{}, err
+	}
+	modified, err := json.Marshal(edited)
+	if err != nil {
+		return variant{}, err
+	}
+	patch, err := jsonpatch.CreateMergePatch(original, modified)
+	if err != nil {
+		return variant{}, err
+	}
+	if string(patch) == "{}" {
+		patch = nil
+	}
+	return variant{edits: len(edits), object: object, patch: patch}, nil
+}
+
+// achieves reports whether the policy was evaluated for the variant with the validation of the goal evaluating
+// to the outcome of the goal.
+func achieves(results []validator.ValidationResult, goal *goal) bool {
+	result := validator.FindResult(results, goal.policy)
+	if result == nil {
+		return false
+	}
+	for _, e := range result.ValidationErrors {
+		if e.Index == goal.index {
+			return !goal.holds && !e.EvaluationError
+		}
+	}
+	return goal.holds
+}
+
+// cost ranks the variants achieving a goal: those evaluating every validation of the policy come first, as a
+// case expecting an error says little about the validation, then those failing fewer other validations, then
+// those with fewer edits.
+func cost(results []validator.ValidationResult, v variant, goal *goal) []int {
+	var errors, failures int
+	for _, e := range validator.FindResult(results, goal.policy).ValidationErrors {
+		switch {
+		case e.EvaluationError:
+			errors++
+		case e.Index != goal.index:
+			failures++
+		}
+	}
+	return []int{errors, failures, v.edits}
+}
+
+func newCase(goal *goal, v variant, seed target.TargetInfo) testsuite.Case {
+	c := testsuite.Case{
+		Policy:   goal.policy,
+		Resource: testsuite.ResourceRef{Kind: seed.Kind, Name: seed.ResourceName, Namespace: seed.Namespace},
+	}
+	if v.patch != nil {
+		c.Patches = []testsuite.Patch{{Type: testsuite.PatchTypeMerge, Patch: v.patch}}
+	}
+	if !goal.holds {
+		index := goal.index
+		c.Validations = []testsuite.ValidationAssertion{{Index: &index}}
+	}
+	return c
+}
+
+func sameResource(a, b testsuite.Case) bool {
+	if a.Policy != b.Policy || a.Resource != b.Resource || len(a.Patches) != len(b.Patches) {
+		return false
+	}
+	return len(a.Patches) == 0 || string(a.Patches[0].Patch) == string(b.Patches[0].Patch)
+}
+
+// caseName names a case after the validations it covers and its resource, e.g.
+// "replica-limit: spec.validations[0] is violated by Deployment web".
+func caseName(c testsuite.Case, indexes []int, validations int) string {
+	names := make([]string, len(indexes))
+	for i, index := range indexes {
+		names[i] = fmt.Sprintf("spec.validations[%d]", index)
+	}
+	covered := strings.Join(names, ", ")
+	outcome := "is violated by"
+	switch {
+	case len(c.Validations) > 0:
+	case len(indexes) == validations && validations > 1:
+		covered, outcome = "all validations", "hold for"
+	case len(indexes) == 1:
+		outcome = "holds for"
+	default:
+		outcome = "hold for"
+	}
+	resource := c.Resource.Kind + " " + c.Resource.Name
+	if c.Resource.Namespace != "" {
+		resource = c.Resource.Kind + " " + c.Resource.Namespace + "/" + c.Resource.Name
+	}
+	if len(c.Patches) > 0 {
+		resource = "patched " + resource
+	}
+	return fmt.Sprintf("%s: %s %s %s", c.Policy, covered, outcome, resource)
+}
+
+func describe(t target.TargetInfo) string {
+	return testsuite.ResourceRef{Kind: t.Kind, Name: t.ResourceName, Namespace: t.Namespace}.String()
+}
@@ -0,0 +1,79 @@
+package casegen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yashirook/vaptest/pkg/defaults"
+	"github.com/yashirook/vaptest/pkg/testsuite"
+)
+
+func newTestRunner(t *testing.T) *testsuite.Runner {
+	return testsuite.NewRunner(defaults.NewScheme())
+}
+
+func TestGeneratorGenerate(t *testing.T) {
+	runner := newTestRunner(t)
+	draft := &testsuite.Suite{
+		Name:      "generated",
+		Policies:  []string{"testdata/policy.yaml"},
+		Resources: []string{"testdata/seeds.yaml"},
+	}
+	suite, gaps, err := NewGenerator(runner).Generate(context.Background(), draft)
+	require.NoError(t, err)
+
+	type generated struct {
+		name    string
+		expect  testsuite.Result
+		patched bool
+	}
+	var got []generated
+	for _, c := range suite.Tests {
+		got = append(got, generated{c.Name, c.Expect, len(c.Patches) > 0})
+	}
+	assert.Equal(t, []generated{
+		// すべての検証を満たすようにラベル、イメージ、minReadySecondsを補う
+		{"deployment-rules: all validations hold for patched Deployment default/web", testsuite.ResultPass, true},
+		{"deployment-rules: spec.validations[0] is violated by patched Deployment default/web", testsuite.ResultDeny, true},
+		{"deployment-rules: spec.validations[1] is violated by patched Deployment default/web", testsuite.ResultDeny, true},
+		{"deployment-rules: spec.validations[2] is violated by patched Deployment default/web", testsuite.ResultDeny, true},
+		{"deployment-rules: spec.validations[3] is violated by patched Deployment default/web", testsuite.ResultDeny, true},
+		{"deployment-rules: spec.validations[4] is violated by patched Deployment default/web", testsuite.ResultDeny, true},
+	}, got)
+	assert.JSONEq(t, `{"spec":{"replicas":6,"minReadySeconds":1,"template":{"spec":{"containers":[{"name":"web","image":"registry.example.com/nginx:1.27","resources":{}}]}}},"metadata":{"labels":{"team":"example"}}}`,
+		string(suite.Tests[1].Patches[0].Patch))
+	require.Len(t, suite.Tests[1].Validations, 1)
+	assert.Equal(t, 0, *suite.Tests[1].Validations[0].Index)
+
+	// バインディングのないポリシーは評価されない
+	assert.Equal(t, []Gap{
+		{Policy: "unbound", Index: 0, Holds: true, Reason: "the policy is not evaluated for any seed resource, check its bindings and match constraints"},
+		{Policy: "unbound", Index: 0, Holds: false, Reason: "the policy is not evaluated for any seed resource, check its bindings and match constraints"},
+	}, gaps)
+
+	// 生成したスイートはそのまま成功する
+	results, err := runner.Run(context.Background(), suite)
+	require.NoError(t, err)
+	for _, result := range results {
+		assert.True(t, result.Passed(), result.Case.Name)
+	}
+}
+
+func TestGeneratorGenerateGaps(t *testing.T) {
+	draft := &testsuite.Suite{
+		Policies:  []string{"testdata/gaps.yaml"},
+		Resources: []string{"testdata/seeds.yaml"},
+	}
+	suite, gaps, err := NewGenerator(newTestRunner(t)).Generate(context.Background(), draft)
+	require.NoError(t, err)
+
+	require.Len(t, suite.Tests, 1)
+	assert.Equal(t, "name-length: spec.validations[0] holds for Deployment default/web", suite.Tests[0].Name)
+	// フィールド同士の比較は式の形からは変更方法がわからない
+	assert.Equal(t, []Gap{
+		{Policy: "name-length", Index: 0, Holds: false, Reason: "no variant of the seed resources has this outcome"},
+	}, gaps)
+	assert.Equal(t, "policy name-length spec.validations[0] violated: no variant of the seed resources has this outcome", gaps[0].String())
+}
@@ -0,0 +1,86 @@
+package casegen
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// lookup returns the value at the path of the object, or nil.
+func lookup(object map[string]any, path []any) any {
+	var current any = object
+	for _, elem := range path {
+		switch key := elem.(type) {
+		case string:
+			m, ok := current.(map[string]any)
+			if !ok {
+				return nil
+			}
+			current = m[key]
+		case int:
+			list, ok := current.([]any)
+			if !ok || key >= len(list) {
+				return nil
+			}
+			current = list[key]
+		}
+	}
+	return current
+}
+
+// apply returns a copy of the object with the edits applied in order. Missing maps and list elements on the
+// way to a field are created.
+func apply(object map[string]any, edits alternative) map[string]any {
+	edited := deepCopy(object).(map[string]any)
+	for _, e := range edits {
+		if e.ifAbsent && lookup(edited, e.path) != nil {
+			continue
+		}
+		if e.remove {
+			remove(edited, e.path)
+			continue
+		}
+		edited = set(edited, e.path, deepCopy(e.value)).(map[string]any)
+	}
+	return edited
+}
+
+// set returns the value with the value at the path replaced.
+func set(current any, path []any, value any) any {
+	if len(path) == 0 {
+		return value
+	}
+	switch key := path[0].(type) {
+	case string:
+		m, ok := current.(map[string]any)
+		if !ok {
+			m = map[string]any{}
+		}
+		m[key] = set(m[key], path[1:], value)
+		return m
+	case int:
+		list, _ := current.([]any)
+		for len(list) <= key {
+			list = append(list, map[string]any{})
+		}
+		list[key] = set(list[key], path[1:], value)
+		return list
+	}
+	return current
+}
+
+// remove deletes the field at the path, if it exists. List elements are not removed.
+func remove(object map[string]any, path []any) {
+	if len(path) == 0 {
+		return
+	}
+	key, ok := path[len(path)-1].(string)
+	if !ok {
+		return
+	}
+	if parent, ok := lookup(object, path[:len(path)-1]).(map[string]any); ok {
+		delete(parent, key)
+	}
+}
+
+func deepCopy(value any) any {
+	return runtime.DeepCopyJSONValue(value)
+}
@@ -0,0 +1,555 @@
+package casegen
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/yashirook/vaptest/pkg/celparse"
+)
+
+// maxAlternatives bounds the alternatives kept for an expression, as conjunctions multiply them.
+const maxAlternatives = 16
+
+// edit changes the value at a path of an object. A path is a list of field names and list indexes.
+type edit struct {
+	path   []any
+	value  any
+	remove bool
+	// ifAbsent leaves an existing value as is, e.g. for has(), which only needs the field to exist.
+	ifAbsent bool
+}
+
+// alternative is a set of edits expected to give an expression the wanted value.
+type alternative []edit
+
+func parse(expression string) (ast.Expr, bool) {
+	parsed, ok := celparse.Parse(expression)
+	if !ok {
+		return nil, false
+	}
+	return parsed.Expr(), true
+}
+
+// synthesizer derives edits of an object from the shape of a boolean expression. It knows the common shapes of
+// policy expressions: has(), comparisons of fields with literals, size(), string predicates, in, all() and
+// exists() over lists, and their combinations with !, &&, || and ?:. Other expressions yield no alternatives.
+// The alternatives are guesses checked by evaluating the edited object.
+type synthesizer struct {
+	object map[string]any
+	// variables are the expressions of the variables of the policy, by name.
+	variables map[string]ast.Expr
+}
+
+// synthesize returns the alternatives of edits for the expression to evaluate to want, fewest edits first.
+// An expression of an unknown shape leaves the object as is, in case it already has the wanted value.
+// scope maps iteration variables of comprehensions to the paths of the elements they range over.
+func (s *synthesizer) synthesize(e ast.Expr, want bool, scope map[string][]any) []alternative {
+	alternatives := s.alternatives(e, want, scope)
+	if len(alternatives) == 0 {
+		return []alternative{{}}
+	}
+	slices.SortStableFunc(alternatives, func(a, b alternative) int { return len(a) - len(b) })
+	if len(alternatives) > maxAlternatives {
+		alternatives = alternatives[:maxAlternatives]
+	}
+	return alternatives
+}
+
+func (s *synthesizer) alternatives(e ast.Expr, want bool, scope map[string][]any) []alternative {
+	if v, ok := s.variable(e); ok {
+		return s.synthesize(v, want, scope)
+	}
+	switch e.Kind() {
+	case ast.SelectKind:
+		sel := e.AsSelect()
+		if sel.IsTestOnly() {
+			path, ok := s.path(sel.Operand(), scope)
+			if !ok {
+				return nil
+			}
+			path = appendPath(path, sel.FieldName())
+			if !want {
+				return []alternative{{{path: path, remove: true}}}
+			}
+			// 値の型は式からはわからないため、よく使われる型をそれぞれ試す
+			var alternatives []alternative
+			for _, value := range []any{map[string]any{}, "example", int64(1), true, []any{}} {
+				alternatives = append(alternatives, alternative{{path: path, value: value, ifAbsent: true}})
+			}
+			return alternatives
+		}
+		// bool型のフィールドをそのまま条件にした式
+		if path, ok := s.path(e, scope); ok {
+			return []alternative{{{path: path, value: want}}}
+		}
+	case ast.ComprehensionKind:
+		return s.comprehension(e.AsComprehension(), want, scope)
+	case ast.CallKind:
+		return s.call(e.AsCall(), want, scope)
+	}
+	return nil
+}
+
+func (s *synthesizer) call(call ast.CallExpr, want bool, scope map[string][]any) []alternative {
+	args := call.Args()
+	switch call.FunctionName() {
+	case operators.LogicalNot:
+		return s.synthesize(args[0], !want, scope)
+	case operators.LogicalAnd:
+		if want {
+			return combine(s.synthesize(args[0], true, scope), s.synthesize(args[1], true, scope))
+		}
+		return append(s.synthesize(args[0], false, scope), s.synthesize(args[1], false, scope)...)
+	case operators.LogicalOr:
+		if want {
+			return append(s.synthesize(args[0], true, scope), s.synthesize(args[1], true, scope)...)
+		}
+		return combine(s.synthesize(args[0], false, scope), s.synthesize(args[1], false, scope))
+	case operators.Conditional:
+		return append(
+			combine(s.synthesize(args[0], true, scope), s.synthesize(args[1], want, scope)),
+			combine(s.synthesize(args[0], false, scope), s.synthesize(args[2], want, scope))...)
+	case operators.Equals, operators.NotEquals, operators.Less, operators.LessEquals, operators.Greater, operators.GreaterEquals:
+		return s.comparison(call.FunctionName(), args[0], args[1], want, scope)
+	case operators.In:
+		return s.in(args[0], args[1], want, scope)
+	case "startsWith", "endsWith", "contains", "matches":
+		if !call.IsMemberFunction() || len(args) != 1 {
+			return nil
+		}
+		path, ok := s.path(call.Target(), scope)
+		literal, isString := stringLiteral(args[0])
+		if !ok || !isString {
+			return nil
+		}
+		current, _ := lookup(s.object, path).(string)
+		if holds, ok := stringPredicate(call.FunctionName(), current, literal); ok && holds == want {
+			if _, exists := lookup(s.object, path).(string); exists {
+				return []alternative{{}}
+			}
+		}
+		// 現在の値に近い値を優先する
+		candidates := []string{
+			literal + current, current + literal,
+			strings.TrimPrefix(current, literal), strings.TrimSuffix(current, literal), strings.ReplaceAll(current, literal, ""),
+			literal, literal + "-example", "example-" + literal, "", "example",
+		}
+		if call.FunctionName() == "matches" {
+			// 正規表現そのものは値の候補にならない
+			candidates = []string{"example", "example-0", "Example_0", ""}
+		}
+		for _, candidate := range candidates {
+			if holds, ok := stringPredicate(call.FunctionName(), candidate, literal); ok && holds == want {
+				return []alternative{{{path: path, value: candidate}}}
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// comparison returns the alternatives of a comparison of a field, or the size of a field, with a literal.
+func (s *synthesizer) comparison(function string, lhs, rhs ast.Expr, want bool, scope map[string][]any) []alternative {
+	literal, ok := literalValue(rhs)
+	if !ok {
+		literal, ok = literalValue(lhs)
+		if !ok {
+			return nil
+		}
+		lhs = rhs
+		function = mirrored[function]
+	}
+
+	if target, ok := sizeTarget(lhs); ok {
+		path, ok := s.path(target, scope)
+		n, isInt := literal.(int64)
+		if !ok || !isInt {
+			return nil
+		}
+		if holds, ok := compare(function, sizeOf(lookup(s.object, path)), n); ok && holds == want {
+			return []alternative{{}}
+		}
+		for _, size := range []int64{n, n + 1, n - 1, 0} {
+			if holds, _ := compare(function, size, n); size >= 0 && holds == want {
+				return []alternative{{{path: path, value: resize(lookup(s.object, path), int(size))}}}
+			}
+		}
+		return nil
+	}
+
+	path, ok := s.path(lhs, scope)
+	if !ok {
+		return nil
+	}
+	if holds, ok := compare(function, lookup(s.object, path), literal); ok && holds == want {
+		return []alternative{{}}
+	}
+	for _, candidate := range nearby(literal) {
+		if holds, ok := compare(function, candidate, literal); ok && holds == want {
+			return []alternative{{{path: path, value: candidate}}}
+		}
+	}
+	return nil
+}
+
+var mirrored = map[string]string{
+	operators.Equals:        operators.Equals,
+	operators.NotEquals:     operators.NotEquals,
+	operators.Less:          operators.Greater,
+	operators.LessEquals:    operators.GreaterEquals,
+	operators.Greater:       operators.Less,
+	operators.GreaterEquals: operators.LessEquals,
+}
+
+// in returns the alternatives of a field in a list literal, or of a key in a map field.
+func (s *synthesizer) in(element, collection ast.Expr, want bool, scope map[string][]any) []alternative {
+	if key, ok := stringLiteral(element); ok {
+		path, ok := s.path(collection, scope)
+		if !ok {
+			return nil
+		}
+		path = appendPath(path, key)
+		if want {
+			return []alternative{{{path: path, value: "example", ifAbsent: true}}}
+		}
+		return []alternative{{{path: path, remove: true}}}
+	}
+
+	path, ok := s.path(element, scope)
+	if !ok || collection.Kind() != ast.ListKind {
+		return nil
+	}
+	var values []any
+	for _, e := range collection.AsList().Elements() {
+		if v, ok := literalValue(e); ok {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	if want {
+		return []alternative{{{path: path, value: values[0]}}}
+	}
+	for _, candidate := range nearby(values[0]) {
+		if !slices.Contains(values, candidate) {
+			return []alternative{{{path: path, value: candidate}}}
+		}
+	}
+	return nil
+}
+
+// comprehension returns the alternatives of all() and exists() over a list field. The predicate is applied to
+// each element, and a list without elements gets one to falsify all() or satisfy exists().
+func (s *synthesizer) comprehension(c ast.ComprehensionExpr, want bool, scope map[string][]any) []alternative {
+	step := c.LoopStep()
+	if step.Kind() != ast.CallKind || len(step.AsCall().Args()) != 2 {
+		return nil
+	}
+	accumulator := step.AsCall().Args()[0]
+	if accumulator.Kind() != ast.IdentKind || accumulator.AsIdent() != c.AccuVar() {
+		return nil
+	}
+	var universal bool
+	switch step.AsCall().FunctionName() {
+	case operators.LogicalAnd:
+		universal = true
+	case operators.LogicalOr:
+		universal = false
+	default:
+		return nil
+	}
+	predicate := step.AsCall().Args()[1]
+	path, ok := s.path(c.IterRange(), scope)
+	if !ok {
+		return nil
+	}
+	list, isList := lookup(s.object, path).([]any)
+	if lookup(s.object, path) != nil && !isList {
+		return nil
+	}
+
+	element := func(i int) map[string][]any {
+		inner := make(map[string][]any, len(scope)+1)
+		for name, p := range scope {
+			inner[name] = p
+		}
+		inner[c.IterVar()] = appendPath(path, i)
+		return inner
+	}
+	// all()がtrue、またはexists()がfalseになるには、すべての要素で述語が同じ値になる必要がある
+	if universal == want {
+		all := []alternative{{}}
+		for i := range list {
+			all = combine(all, s.synthesize(predicate, want, element(i)))
+		}
+		return all
+	}
+	if len(list) == 0 {
+		created := alternative{{path: path, value: []any{map[string]any{}}}}
+		return combine([]alternative{created}, s.synthesize(predicate, want, element(0)))
+	}
+	var some []alternative
+	for i := range list {
+		some = append(some, s.synthesize(predicate, want, element(i))...)
+	}
+	return some
+}
+
+// variable returns the expression of the variable the expression refers to, e.g. variables.replicas.
+func (s *synthesizer) variable(e ast.Expr) (ast.Expr, bool) {
+	if e.Kind() != ast.SelectKind || e.AsSelect().IsTestOnly() {
+		return nil, false
+	}
+	operand := e.AsSelect().Operand()
+	if operand.Kind() != ast.IdentKind || operand.AsIdent() != "variables" {
+		return nil, false
+	}
+	v, ok := s.variables[e.AsSelect().FieldName()]
+	return v, ok
+}
+
+// path returns the path of the field the expression selects from the object, e.g. [spec replicas] for
+// object.spec.replicas, resolving iteration variables and variables that select a field.
+func (s *synthesizer) path(e ast.Expr, scope map[string][]any) ([]any, bool) {
+	if v, ok := s.variable(e); ok {
+		return s.path(v, scope)
+	}
+	switch e.Kind() {
+	case ast.IdentKind:
+		if e.AsIdent() == "object" {
+			return []any{}, true
+		}
+		p, ok := scope[e.AsIdent()]
+		return p, ok
+	case ast.SelectKind:
+		if e.AsSelect().IsTestOnly() {
+			return nil, false
+		}
+		p, ok := s.path(e.AsSelect().Operand(), scope)
+		return appendPath(p, e.AsSelect().FieldName()), ok
+	case ast.CallKind:
+		call := e.AsCall()
+		if call.FunctionName() != operators.Index {
+			return nil, false
+		}
+		p, ok := s.path(call.Args()[0], scope)
+		if !ok {
+			return nil, false
+		}
+		switch v, _ := literalValue(call.Args()[1]); v := v.(type) {
+		case string:
+			return appendPath(p, v), true
+		case int64:
+			return appendPath(p, int(v)), true
+		}
+	}
+	return nil, false
+}
+
+// sizeTarget returns the argument of size(x) or x.size().
+func sizeTarget(e ast.Expr) (ast.Expr, bool) {
+	if e.Kind() != ast.CallKind || e.AsCall().FunctionName() != "size" {
+		return nil, false
+	}
+	call := e.AsCall()
+	switch {
+	case call.IsMemberFunction() && len(call.Args()) == 0:
+		return call.Target(), true
+	case !call.IsMemberFunction() && len(call.Args()) == 1:
+		return call.Args()[0], true
+	}
+	return nil, false
+}
+
+// combine returns the alternatives applying an alternative of a and then one of b.
+func combine(a, b []alternative) []alternative {
+	var combined []alternative
+	for _, x := range a {
+		for _, y := range b {
+			if len(combined) == maxAlternatives {
+				return combined
+			}
+			combined = append(combined, append(slices.Clone(x), y...))
+		}
+	}
+	return combined
+}
+
+func appendPath(path []any, elem any) []any {
+	return append(slices.Clone(path), elem)
+}
+
+func literalValue(e ast.Expr) (any, bool) {
+	if e.Kind() != ast.LiteralKind {
+		return nil, false
+	}
+	switch v := e.AsLiteral().(type) {
+	case types.Int:
+		return int64(v), true
+	case types.Uint:
+		return int64(v), true
+	case types.Double:
+		return float64(v), true
+	case types.String:
+		return string(v), true
+	case types.Bool:
+		return bool(v), true
+	}
+	return nil, false
+}
+
+func stringLiteral(e ast.Expr) (string, bool) {
+	v, ok := literalValue(e)
+	s, isString := v.(string)
+	return s, ok && isString
+}
+
+// nearby returns values next to the literal, to satisfy or falsify comparisons with it.
+func nearby(literal any) []any {
+	switch v := literal.(type) {
+	case int64:
+		return []any{v, v + 1, v - 1}
+	case float64:
+		return []any{v, v + 1, v - 1}
+	case string:
+		return []any{v, v + "-example", ""}
+	case bool:
+		return []any{v, !v}
+	}
+	return nil
+}
+
+// compare evaluates the comparison of two values of the same type, reporting false as ok if they are not.
+func compare(function string, a, b any) (bool, bool) {
+	var c int
+	switch a := a.(type) {
+	case int64:
+		b, ok := b.(int64)
+		if !ok {
+			return false, false
+		}
+		c = cmpOrdered(a, b)
+	case float64:
+		b, ok := b.(float64)
+		if !ok {
+			return false, false
+		}
+		c = cmpOrdered(a, b)
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return false, false
+		}
+		c = strings.Compare(a, b)
+	case bool:
+		b, ok := b.(bool)
+		if !ok || (function != operators.Equals && function != operators.NotEquals) {
+			return false, false
+		}
+		if a != b {
+			c = 1
+		}
+	default:
+		return false, false
+	}
+	switch function {
+	case operators.Equals:
+		return c == 0, true
+	case operators.NotEquals:
+		return c != 0, true
+	case operators.Less:
+		return c < 0, true
+	case operators.LessEquals:
+		return c <= 0, true
+	case operators.Greater:
+		return c > 0, true
+	case operators.GreaterEquals:
+		return c >= 0, true
+	}
+	return false, false
+}
+
+func cmpOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func stringPredicate(function, s, arg string) (bool, bool) {
+	switch function {
+	case "startsWith":
+		return strings.HasPrefix(s, arg), true
+	case "endsWith":
+		return strings.HasSuffix(s, arg), true
+	case "contains":
+		return strings.Contains(s, arg), true
+	case "matches":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return false, false
+		}
+		return re.MatchString(s), true
+	}
+	return false, false
+}
+
+// sizeOf returns the size of a list, map or string, or nil for other values.
+func sizeOf(value any) any {
+	switch v := value.(type) {
+	case []any:
+		return int64(len(v))
+	case map[string]any:
+		return int64(len(v))
+	case string:
+		return int64(len([]rune(v)))
+	}
+	return nil
+}
+
+// resize returns the value with the size: a list keeps its elements and repeats its last one, a string is cut or
+// padded and a map keeps its entries and gets new ones. Values of other types become lists.
+func resize(value any, size int) any {
+	switch v := value.(type) {
+	case string:
+		if len(v) >= size {
+			return v[:size]
+		}
+		return v + strings.Repeat("x", size-len(v))
+	case map[string]any:
+		resized := map[string]any{}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys[:min(size, len(keys))] {
+			resized[key] = v[key]
+		}
+		for i := 0; len(resized) < size; i++ {
+			resized["example-"+string(rune('a'+i%26))+strings.Repeat("x", i/26)] = "example"
+		}
+		return resized
+	}
+	list, _ := value.([]any)
+	if len(list) >= size {
+		return slices.Clone(list[:size])
+	}
+	var last any = map[string]any{}
+	if len(list) > 0 {
+		last = list[len(list)-1]
+	}
+	resized := slices.Clone(list)
+	for len(resized) < size {
+		resized = append(resized, deepCopy(last))
+	}
+	return resized
+}
@@ -0,0 +1,200 @@
+package casegen
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+func TestSynthesize(t *testing.T) {
+	const seed = `
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  replicas: 3
+  containers:
+  - name: web
+    image: nginx
+  - name: sidecar
+    image: registry.example.com/proxy
+`
+	tests := []struct {
+		name       string
+		expression string
+		holds      bool
+		// want is the object edited by the first alternative, as YAML. Empty means no edit is needed.
+		want string
+	}{
+		{
+			name:       "既に満たしている比較は変更しない",
+			expression: "object.spec.replicas <= 5",
+			holds:      true,
+		},
+		{
+			name:       "比較を満たさない値にする",
+			expression: "object.spec.replicas <= 5",
+			holds:      false,
+			want:       "spec: {replicas: 6}",
+		},
+		{
+			name:       "リテラルが左辺の比較",
+			expression: "1 < object.spec.replicas",
+			holds:      false,
+			want:       "spec: {replicas: 1}",
+		},
+		{
+			name:       "has()を満たすフィールドを追加する",
+			expression: "has(object.spec.paused)",
+			holds:      true,
+			want:       "spec: {paused: {}}",
+		},
+		{
+			name:       "has()を満たさないようにフィールドを削除する",
+			expression: "has(object.metadata.labels)",
+			holds:      false,
+			want:       "metadata: {labels: null}",
+		},
+		{
+			name:       "マップのキー",
+			expression: "'team' in object.metadata.labels",
+			holds:      true,
+			want:       "metadata: {labels: {app: web, team: example}}",
+		},
+		{
+			name:       "リストに含まれない値",
+			expression: "object.metadata.name in ['web', 'api']",
+			holds:      false,
+			want:       "metadata: {name: web-example}",
+		},
+		{
+			name:       "文字列の述語",
+			expression: "object.metadata.name.startsWith('app-')",
+			holds:      true,
+			want:       "metadata: {name: app-web}",
+		},
+		{
+			name:       "サイズの比較",
+			expression: "size(object.metadata.labels) >= 2",
+			holds:      true,
+			want:       "metadata: {labels: {app: web, example-a: example}}",
+		},
+		{
+			name:       "all()はすべての要素で述語を満たす",
+			expression: "object.spec.containers.all(c, c.image.startsWith('registry.example.com/'))",
+			holds:      true,
+			want:       "spec: {containers: [{name: web, image: registry.example.com/nginx}, {name: sidecar, image: registry.example.com/proxy}]}",
+		},
+		{
+			name:       "exists()は一つの要素で述語を満たす",
+			expression: "object.spec.containers.exists(c, c.name == 'proxy')",
+			holds:      true,
+			want:       "spec: {containers: [{name: proxy, image: nginx}, {name: sidecar, image: registry.example.com/proxy}]}",
+		},
+		{
+			name:       "論理積の否定はどちらかを満たさなければよい",
+			expression: "!(object.spec.replicas > 1 && has(object.metadata.labels))",
+			holds:      true,
+			want:       "spec: {replicas: 1}",
+		},
+		{
+			name:       "変数を展開する",
+			expression: "variables.replicas < 3",
+			holds:      true,
+			want:       "spec: {replicas: 2}",
+		},
+		{
+			name:       "式の形がわからない場合は変更しない",
+			expression: "object.metadata.name == object.spec.containers[0].name",
+			holds:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object := unmarshal(t, seed)
+			variable, ok := parse("object.spec.replicas")
+			require.True(t, ok)
+			s := &synthesizer{object: object, variables: map[string]ast.Expr{"replicas": variable}}
+			expression, ok := parse(tt.expression)
+			require.True(t, ok)
+
+			alternatives := s.synthesize(expression, tt.holds, nil)
+			require.NotEmpty(t, alternatives)
+			edited := apply(object, alternatives[0])
+			if tt.want == "" {
+				assert.Equal(t, object, edited)
+				return
+			}
+			want := unmarshal(t, tt.want)
+			for key, value := range want {
+				// 削除を表すnullは、フィールドがないことを確認する
+				if fields, ok := value.(map[string]any); ok {
+					for field, v := range fields {
+						if v == nil {
+							assert.NotContains(t, edited[key], field)
+							delete(fields, field)
+						}
+					}
+				}
+			}
+			assertSubset(t, want, edited)
+			if !isVariable(tt.expression) {
+				assert.Equal(t, tt.holds, evaluate(t, tt.expression, edited))
+			}
+		})
+	}
+}
+
+// unmarshal decodes YAML as unstructured objects do, with integers as int64.
+func unmarshal(t *testing.T, data string) map[string]any {
+	t.Helper()
+	doc, err := sigsyaml.YAMLToJSON([]byte(data))
+	require.NoError(t, err)
+	var object map[string]any
+	require.NoError(t, utiljson.Unmarshal(doc, &object))
+	return object
+}
+
+// assertSubset asserts that the fields of want have the same values in got.
+func assertSubset(t *testing.T, want, got map[string]any) {
+	t.Helper()
+	for key, value := range want {
+		if fields, ok := value.(map[string]any); ok {
+			nested, _ := got[key].(map[string]any)
+			assertSubset(t, fields, nested)
+			continue
+		}
+		assert.Equal(t, normalize(t, value), normalize(t, got[key]), key)
+	}
+}
+
+func normalize(t *testing.T, value any) any {
+	data, err := sigsyaml.Marshal(value)
+	require.NoError(t, err)
+	var normalized any
+	require.NoError(t, sigsyaml.Unmarshal(data, &normalized))
+	return normalized
+}
+
+func isVariable(expression string) bool {
+	return len(expression) > len("variables.") && expression[:len("variables.")] == "variables."
+}
+
+func evaluate(t *testing.T, expression string, object map[string]any) bool {
+	t.Helper()
+	env, err := cel.NewEnv(cel.Variable("object", cel.DynType))
+	require.NoError(t, err)
+	compiled, issues := env.Compile(expression)
+	require.NoError(t, issues.Err())
+	program, err := env.Program(compiled)
+	require.NoError(t, err)
+	value, _, err := program.Eval(map[string]any{"object": normalize(t, object)})
+	require.NoError(t, err)
+	return value.Value().(bool)
+}
@@ -0,0 +1,27 @@
+// Package celparse parses CEL expressions of policies without type-checking them, for code that only looks at
+// their syntax, such as the mutations and the synthesized test cases of an expression.
+package celparse
+
+import (
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/parser"
+)
+
+// celParser parses expressions with the macros and the optional syntax of Kubernetes.
+var celParser = func() *parser.Parser {
+	p, err := parser.NewParser(parser.Macros(parser.AllMacros...), parser.EnableOptionalSyntax(true))
+	if err != nil {
+		panic(err)
+	}
+	return p
+}()
+
+// Parse parses the expression, reporting whether it is syntactically valid.
+func Parse(expression string) (*ast.AST, bool) {
+	parsed, issues := celParser.Parse(common.NewTextSource(expression))
+	if issues != nil && len(issues.GetErrors()) > 0 {
+		return nil, false
+	}
+	return parsed, true
+}
@@ -0,0 +1,28 @@
+package celparse
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name       string
+		expression string
+		expected   bool
+	}{
+		{name: "マクロを展開する", expression: "object.spec.containers.all(c, has(c.image))", expected: true},
+		{name: "オプショナル構文を使える", expression: "object.?metadata.?labels.orValue({}).size() > 0", expected: true},
+		{name: "型検査はしない", expression: "object.spec.replicas + 'a'", expected: true},
+		{name: "構文エラー", expression: "object.spec.replicas <=", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parsed, ok := Parse(tc.expression)
+			assert.Equal(t, tc.expected, ok)
+			assert.Equal(t, tc.expected, parsed != nil)
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package coverage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	yaml "sigs.k8s.io/yaml/goyaml.v3"
+)
+
+// Kind is the kind of a policy expression.
+type Kind string
+
+const (
+	KindMatchCondition Kind = "matchCondition"
+	KindVariable       Kind = "variable"
+	KindValidation     Kind = "validation"
+)
+
+// field returns the field of the policy spec holding expressions of the kind.
+func (k Kind) field() string {
+	switch k {
+	case KindMatchCondition:
+		return "matchConditions"
+	case KindVariable:
+		return "variables"
+	default:
+		return "validations"
+	}
+}
+
+// Expression is the coverage of an expression of a policy evaluated through a binding.
+type Expression struct {
+	Policy string `json:"policy"`
+	// Binding is empty for a policy without bindings, which is never evaluated.
+	Binding string `json:"binding,omitempty"`
+	Kind    Kind   `json:"kind"`
+	Index   int    `json:"index"`
+	// Name is the name of a match condition or variable.
+	Name       string `json:"name,omitempty"`
+	Expression string `json:"expression"`
+	// True, False and Error count the evaluations of the expression by result. A variable evaluated to a value
+	// other than a bool counts as true.
+	True  int `json:"true"`
+	False int `json:"false"`
+	Error int `json:"error"`
+	// Conditions are the boolean sub-expressions deciding the branches of the expression.
+	Conditions []*Condition `json:"conditions,omitempty"`
+	// Source and Line locate the first line of the expression in the file the policy was loaded from, if known.
+	Source string `json:"source,omitempty"`
+	Line   int    `json:"line,omitempty"`
+}
+
+// Condition is a boolean sub-expression deciding a branch of an expression: an operand of a logical operator,
+// the condition of a ternary operator or the predicate of a comprehension. Each of its results is a branch.
+type Condition struct {
+	// Operator is &&, ||, ?: or the comprehension macro, e.g. all or exists.
+	Operator string `json:"operator"`
+	Text     string `json:"text"`
+	// Start and End are the offsets of the condition in the expression, in runes.
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// True and False count the results of the condition. A predicate is counted for each element.
+	True  int `json:"true"`
+	False int `json:"false"`
+	// Line is the line of the condition in the file the policy was loaded from, if known.
+	Line int `json:"line,omitempty"`
+}
+
+// Covered reports whether the condition evaluated to both true and false.
+func (c *Condition) Covered() bool {
+	return c.True > 0 && c.False > 0
+}
+
+// Missing describes the branches of the condition never taken, e.g. "never false".
+func (c *Condition) Missing() string {
+	switch {
+	case c.True == 0 && c.False == 0:
+		return "never evaluated"
+	case c.True == 0:
+		return "never true"
+	case c.False == 0:
+		return "never false"
+	}
+	return ""
+}
+
+// Highlight returns the expression with the conditions not covered enclosed in « and ».
+func (e *Expression) Highlight() string {
+	text := []rune(e.Expression)
+	opening := make([]int, len(text)+1)
+	closing := make([]int, len(text)+1)
+	for _, c := range e.Conditions {
+		if !c.Covered() && c.Start >= 0 && c.End <= len(text) && c.Start < c.End {
+			opening[c.Start]++
+			closing[c.End]++
+		}
+	}
+	var b strings.Builder
+	for i := 0; i <= len(text); i++ {
+		b.WriteString(strings.Repeat("»", closing[i]))
+		b.WriteString(strings.Repeat("«", opening[i]))
+		if i < len(text) {
+			b.WriteRune(text[i])
+		}
+	}
+	return b.String()
+}
+
+// Reached reports whether the expression was evaluated at least once.
+func (e *Expression) Reached() bool {
+	return e.True+e.False+e.Error > 0
+}
+
+// Path returns the path of the expression in the policy, e.g. spec.validations[0].
+func (e *Expression) Path() string {
+	return fmt.Sprintf("spec.%s[%d]", e.Kind.field(), e.Index)
+}
+
+// Profile is the coverage of the expressions of policies, accumulated over evaluations.
+// Expressions are kept in the order they were registered.
+type Profile struct {
+	Expressions []*Expression
+}
+
+// New returns an empty profile.
+func New() *Profile {
+	return &Profile{}
+}
+
+// Register returns the expression of the profile with the policy, binding, kind, index and text of e,
+// adding e if there is none, so that evaluations of the same policy in several runs are counted together.
+func (p *Profile) Register(e Expression) *Expression {
+	for _, registered := range p.Expressions {
+		if registered.Policy == e.Policy && registered.Binding == e.Binding && registered.Kind == e.Kind &&
+			registered.Index == e.Index && registered.Expression == e.Expression {
+			return registered
+		}
+	}
+	p.Expressions = append(p.Expressions, &e)
+	return &e
+}
+
+// Summary returns the number of reached expressions and of all expressions.
+func (p *Profile) Summary() (reached, total int) {
+	for _, e := range p.Expressions {
+		if e.Reached() {
+			reached++
+		}
+	}
+	return reached, len(p.Expressions)
+}
+
+// Percent returns the percentage of reached expressions, 100 for an empty profile.
+func (p *Profile) Percent() float64 {
+	reached, total := p.Summary()
+	if total == 0 {
+		return 100
+	}
+	return float64(reached) * 100 / float64(total)
+}
+
+// BranchSummary returns the number of branches of the conditions of all expressions taken, and of all branches.
+func (p *Profile) BranchSummary() (taken, total int) {
+	for _, e := range p.Expressions {
+		for _, c := range e.Conditions {
+			total += 2
+			if c.True > 0 {
+				taken++
+			}
+			if c.False > 0 {
+				taken++
+			}
+		}
+	}
+	return taken, total
+}
+
+// Unreached returns the expressions never evaluated.
+func (p *Profile) Unreached() []*Expression {
+	var unreached []*Expression
+	for _, e := range p.Expressions {
+		if !e.Reached() {
+			unreached = append(unreached, e)
+		}
+	}
+	return unreached
+}
+
+// SetSource records the file the policy was loaded from, and the lines of its expressions and their conditions
+// in the file. Expressions already located are left as is.
+func (p *Profile) SetSource(policy, path string) error {
+	var locations map[string]location
+	for _, e := range p.Expressions {
+		if e.Policy != policy || e.Source != "" {
+			continue
+		}
+		if locations == nil {
+			var err error
+			if locations, err = expressionLocations(path, policy); err != nil {
+				return fmt.Errorf("failed to locate expressions of policy %s in %s: %w", policy, path, err)
+			}
+		}
+		loc := locations[e.Path()]
+		e.Source = path
+		e.Line = loc.line
+		for _, c := range e.Conditions {
+			c.Line = loc.line
+			if loc.literal && c.Start <= len([]rune(e.Expression)) {
+				// リテラルブロックでは式の改行がファイルの改行と一致する
+				c.Line += strings.Count(string([]rune(e.Expression)[:c.Start]), "\n")
+			}
+		}
+	}
+	return nil
+}
+
+// location is the position of an expression in a file. The lines of a literal block scalar are
+// the lines of the expression.
+type location struct {
+	line    int
+	literal bool
+}
+
+// expressionLocations returns the locations of the expressions of the policy in the file, keyed by path.
+func expressionLocations(path, policy string) (map[string]location, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("policy not found")
+		} else if err != nil {
+			return nil, err
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		root := doc.Content[0]
+		if value(root, "kind") == nil || value(root, "kind").Value != "ValidatingAdmissionPolicy" {
+			continue
+		}
+		if name := value(value(root, "metadata"), "name"); name == nil || name.Value != policy {
+			continue
+		}
+
+		locations := map[string]location{}
+		spec := value(root, "spec")
+		for _, kind := range []Kind{KindMatchCondition, KindVariable, KindValidation} {
+			items := value(spec, kind.field())
+			if items == nil || items.Kind != yaml.SequenceNode {
+				continue
+			}
+			for i, item := range items.Content {
+				if expression := value(item, "expression"); expression != nil {
+					e := Expression{Kind: kind, Index: i}
+					loc := location{line: expression.Line, literal: expression.Style&yaml.LiteralStyle != 0}
+					if expression.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+						// ブロックスカラーの内容はインジケーターの次の行から始まる
+						loc.line++
+					}
+					locations[e.Path()] = loc
+				}
+			}
+		}
+		return locations, nil
+	}
+}
+
+// value returns the value of the key of a mapping node, or nil.
+func value(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package coverage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileRegister(t *testing.T) {
+	p := New()
+	first := p.Register(Expression{Policy: "p", Binding: "b", Kind: KindValidation, Index: 0, Expression: "true"})
+	first.True++
+
+	// 同じポリシー、バインディング、式は一つにまとめて数える
+	again := p.Register(Expression{Policy: "p", Binding: "b", Kind: KindValidation, Index: 0, Expression: "true"})
+	again.False++
+	assert.Same(t, first, again)
+
+	other := p.Register(Expression{Policy: "p", Binding: "other", Kind: KindValidation, Index: 0, Expression: "true"})
+	assert.NotSame(t, first, other)
+
+	reached, total := p.Summary()
+	assert.Equal(t, 1, reached)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, 50.0, p.Percent())
+	assert.Equal(t, []*Expression{other}, p.Unreached())
+	assert.Equal(t, 100.0, New().Percent(), "式のないプロファイルは100%とする")
+}
+
+func TestProfileSetSource(t *testing.T) {
+	p := New()
+	for _, e := range []Expression{
+		{Policy: "replica-limit", Kind: KindMatchCondition, Index: 0},
+		{Policy: "replica-limit", Kind: KindVariable, Index: 0},
+		{Policy: "replica-limit", Kind: KindValidation, Index: 0},
+		{Policy: "replica-limit", Kind: KindValidation, Index: 1,
+			Expression: "has(object.metadata.labels) &&\n'app' in object.metadata.labels\n",
+			Conditions: []*Condition{{Start: 0, End: 27}, {Start: 31, End: 63}}},
+		{Policy: "require-labels", Kind: KindValidation, Index: 0},
+	} {
+		p.Register(e)
+	}
+
+	require.NoError(t, p.SetSource("replica-limit", "testdata/policy.yaml"))
+	var lines []int
+	for _, e := range p.Expressions {
+		lines = append(lines, e.Line)
+	}
+	// 他のポリシーの式は変更しない
+	assert.Equal(t, []int{24, 27, 31, 33, 0}, lines)
+	// リテラルブロックの条件は式の中の行に対応する
+	assert.Equal(t, 33, p.Expressions[3].Conditions[0].Line)
+	assert.Equal(t, 34, p.Expressions[3].Conditions[1].Line)
+	assert.Equal(t, "testdata/policy.yaml", p.Expressions[0].Source)
+	assert.Empty(t, p.Expressions[4].Source)
+
+	assert.Error(t, p.SetSource("require-labels", "testdata/missing.yaml"))
+}
+
+func TestExpressionHighlight(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []*Condition
+		want       string
+	}{
+		{
+			name:       "すべての分岐を通った条件は強調しない",
+			conditions: []*Condition{{Start: 0, End: 1, True: 1, False: 1}, {Start: 5, End: 18, True: 1, False: 1}},
+			want:       "a || b && c.all(x, x)",
+		},
+		{
+			name:       "未カバーの条件を強調する",
+			conditions: []*Condition{{Start: 0, End: 1, False: 2}, {Start: 5, End: 21, True: 1, False: 1}},
+			want:       "«a» || b && c.all(x, x)",
+		},
+		{
+			name:       "入れ子の条件",
+			conditions: []*Condition{{Start: 5, End: 21, True: 1}, {Start: 5, End: 6, True: 1}, {Start: 10, End: 21, True: 1}},
+			want:       "a || ««b» && «c.all(x, x)»»",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Expression{Expression: "a || b && c.all(x, x)", Conditions: tt.conditions}
+			assert.Equal(t, tt.want, e.Highlight())
+		})
+	}
+}
+
+func TestConditionMissing(t *testing.T) {
+	assert.Equal(t, "never evaluated", (&Condition{}).Missing())
+	assert.Equal(t, "never true", (&Condition{False: 1}).Missing())
+	assert.Equal(t, "never false", (&Condition{True: 1}).Missing())
+	assert.Empty(t, (&Condition{True: 1, False: 1}).Missing())
+}
+
+func TestProfileBranchSummary(t *testing.T) {
+	p := New()
+	p.Register(Expression{Policy: "p", Kind: KindValidation, Index: 0, Expression: "a || b",
+		Conditions: []*Condition{{True: 1, False: 2}, {False: 1}}})
+	p.Register(Expression{Policy: "p", Kind: KindValidation, Index: 1, Expression: "a"})
+	taken, total := p.BranchSummary()
+	assert.Equal(t, 3, taken)
+	assert.Equal(t, 4, total)
+}
@@ -0,0 +1,63 @@
+package defaults
+
+import (
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func SetObjectDefaults_ValidatingAdmissionPolicy(in *admissionregistrationv1.ValidatingAdmissionPolicy) {
+	SetDefaults_ValidatingAdmissionPolicySpec(&in.Spec)
+	if in.Spec.MatchConstraints != nil {
+		setObjectDefaults_MatchResources(in.Spec.MatchConstraints)
+	}
+}
+
+func SetObjectDefaults_ValidatingAdmissionPolicyBinding(in *admissionregistrationv1.ValidatingAdmissionPolicyBinding) {
+	if in.Spec.ParamRef != nil {
+		SetDefaults_ParamRef(in.Spec.ParamRef)
+	}
+	if in.Spec.MatchResources != nil {
+		setObjectDefaults_MatchResources(in.Spec.MatchResources)
+	}
+}
+
+func setObjectDefaults_MatchResources(in *admissionregistrationv1.MatchResources) {
+	SetDefaults_MatchResources(in)
+	for i := range in.ResourceRules {
+		SetDefaults_Rule(&in.ResourceRules[i].Rule)
+	}
+	for i := range in.ExcludeResourceRules {
+		SetDefaults_Rule(&in.ExcludeResourceRules[i].Rule)
+	}
+}
+
+func SetDefaults_ValidatingAdmissionPolicySpec(obj *admissionregistrationv1.ValidatingAdmissionPolicySpec) {
+	if obj.FailurePolicy == nil {
+		obj.FailurePolicy = ptr.To(admissionregistrationv1.Fail)
+	}
+}
+
+func SetDefaults_MatchResources(obj *admissionregistrationv1.MatchResources) {
+	if obj.MatchPolicy == nil {
+		obj.MatchPolicy = ptr.To(admissionregistrationv1.Equivalent)
+	}
+	if obj.NamespaceSelector == nil {
+		obj.NamespaceSelector = &metav1.LabelSelector{}
+	}
+	if obj.ObjectSelector == nil {
+		obj.ObjectSelector = &metav1.LabelSelector{}
+	}
+}
+
+func SetDefaults_ParamRef(obj *admissionregistrationv1.ParamRef) {
+	if obj.ParameterNotFoundAction == nil {
+		obj.ParameterNotFoundAction = ptr.To(admissionregistrationv1.DenyAction)
+	}
+}
+
+func SetDefaults_Rule(obj *admissionregistrationv1.Rule) {
+	if obj.Scope == nil {
+		obj.Scope = ptr.To(admissionregistrationv1.AllScopes)
+	}
+}
@@ -0,0 +1,332 @@
+package defaults
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	defaultVolumeMode                    int32 = 0644
+	defaultTerminationGracePeriod        int64 = 30
+	defaultServiceAccountTokenExpiry     int64 = 3600
+	defaultClientIPServiceAffinitySecs   int32 = 10800
+	namespaceNameLabel                         = "kubernetes.io/metadata.name"
+	defaultProbeTimeoutSeconds           int32 = 1
+	defaultProbePeriodSeconds            int32 = 10
+	defaultProbeSuccessThreshold         int32 = 1
+	defaultProbeFailureThreshold         int32 = 3
+	defaultHTTPGetActionPath                   = "/"
+	defaultObjectFieldSelectorAPIVersion       = "v1"
+)
+
+func SetObjectDefaults_Pod(in *corev1.Pod) {
+	SetDefaults_Pod(in)
+	SetObjectDefaults_PodSpec(&in.Spec)
+}
+
+func SetObjectDefaults_PodTemplate(in *corev1.PodTemplate) {
+	SetObjectDefaults_PodTemplateSpec(&in.Template)
+}
+
+func SetObjectDefaults_ReplicationController(in *corev1.ReplicationController) {
+	SetDefaults_ReplicationController(in)
+	if in.Spec.Template != nil {
+		SetObjectDefaults_PodTemplateSpec(in.Spec.Template)
+	}
+}
+
+// SetObjectDefaults_PodTemplateSpec defaults the pod template of a workload.
+func SetObjectDefaults_PodTemplateSpec(in *corev1.PodTemplateSpec) {
+	SetObjectDefaults_PodSpec(&in.Spec)
+}
+
+// SetObjectDefaults_PodSpec defaults a pod spec and everything nested in it.
+func SetObjectDefaults_PodSpec(in *corev1.PodSpec) {
+	SetDefaults_PodSpec(in)
+	for i := range in.Volumes {
+		SetDefaults_Volume(&in.Volumes[i])
+	}
+	for i := range in.InitContainers {
+		setObjectDefaults_Container(&in.InitContainers[i])
+	}
+	for i := range in.Containers {
+		setObjectDefaults_Container(&in.Containers[i])
+	}
+	for i := range in.EphemeralContainers {
+		c := corev1.Container(in.EphemeralContainers[i].EphemeralContainerCommon)
+		setObjectDefaults_Container(&c)
+		in.EphemeralContainers[i].EphemeralContainerCommon = corev1.EphemeralContainerCommon(c)
+	}
+}
+
+func setObjectDefaults_Container(in *corev1.Container) {
+	SetDefaults_Container(in)
+	for i := range in.Ports {
+		SetDefaults_ContainerPort(&in.Ports[i])
+	}
+	for i := range in.Env {
+		if in.Env[i].ValueFrom != nil && in.Env[i].ValueFrom.FieldRef != nil {
+			SetDefaults_ObjectFieldSelector(in.Env[i].ValueFrom.FieldRef)
+		}
+	}
+	for _, probe := range []*corev1.Probe{in.LivenessProbe, in.ReadinessProbe, in.StartupProbe} {
+		if probe != nil {
+			SetDefaults_Probe(probe)
+		}
+	}
+	if in.Lifecycle != nil {
+		for _, handler := range []*corev1.LifecycleHandler{in.Lifecycle.PostStart, in.Lifecycle.PreStop} {
+			if handler != nil && handler.HTTPGet != nil {
+				SetDefaults_HTTPGetAction(handler.HTTPGet)
+			}
+		}
+	}
+}
+
+func SetDefaults_Pod(obj *corev1.Pod) {
+	// If limits are specified, but requests are not, default requests to limits.
+	for i := range obj.Spec.Containers {
+		defaultRequestsFromLimits(&obj.Spec.Containers[i].Resources)
+	}
+	for i := range obj.Spec.InitContainers {
+		defaultRequestsFromLimits(&obj.Spec.InitContainers[i].Resources)
+	}
+	if obj.Spec.EnableServiceLinks == nil {
+		obj.Spec.EnableServiceLinks = ptr.To(corev1.DefaultEnableServiceLinks)
+	}
+}
+
+func defaultRequestsFromLimits(resources *corev1.ResourceRequirements) {
+	if resources.Limits == nil {
+		return
+	}
+	if resources.Requests == nil {
+		resources.Requests = make(corev1.ResourceList)
+	}
+	for key, value := range resources.Limits {
+		if _, exists := resources.Requests[key]; !exists {
+			resources.Requests[key] = value.DeepCopy()
+		}
+	}
+}
+
+func SetDefaults_PodSpec(obj *corev1.PodSpec) {
+	if obj.DNSPolicy == "" {
+		obj.DNSPolicy = corev1.DNSClusterFirst
+	}
+	if obj.RestartPolicy == "" {
+		obj.RestartPolicy = corev1.RestartPolicyAlways
+	}
+	if obj.HostNetwork {
+		defaultHostNetworkPorts(&obj.Containers)
+		defaultHostNetworkPorts(&obj.InitContainers)
+	}
+	if obj.SecurityContext == nil {
+		obj.SecurityContext = &corev1.PodSecurityContext{}
+	}
+	if obj.TerminationGracePeriodSeconds == nil {
+		obj.TerminationGracePeriodSeconds = ptr.To(defaultTerminationGracePeriod)
+	}
+	if obj.SchedulerName == "" {
+		obj.SchedulerName = corev1.DefaultSchedulerName
+	}
+}
+
+// defaultHostNetworkPorts sets hostPort to containerPort for pods in the host network.
+func defaultHostNetworkPorts(containers *[]corev1.Container) {
+	for i := range *containers {
+		for j := range (*containers)[i].Ports {
+			if (*containers)[i].Ports[j].HostPort == 0 {
+				(*containers)[i].Ports[j].HostPort = (*containers)[i].Ports[j].ContainerPort
+			}
+		}
+	}
+}
+
+func SetDefaults_Container(obj *corev1.Container) {
+	if obj.ImagePullPolicy == "" {
+		// Pull the image every time when the tag is latest (or omitted), otherwise only when missing.
+		if imageTag(obj.Image) == "latest" {
+			obj.ImagePullPolicy = corev1.PullAlways
+		} else {
+			obj.ImagePullPolicy = corev1.PullIfNotPresent
+		}
+	}
+	if obj.TerminationMessagePath == "" {
+		obj.TerminationMessagePath = corev1.TerminationMessagePathDefault
+	}
+	if obj.TerminationMessagePolicy == "" {
+		obj.TerminationMessagePolicy = corev1.TerminationMessageReadFile
+	}
+}
+
+// imageTag returns the tag of an image reference, "latest" when omitted and "" for digest references.
+func imageTag(image string) string {
+	if strings.Contains(image, "@") {
+		return ""
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return "latest"
+}
+
+func SetDefaults_ContainerPort(obj *corev1.ContainerPort) {
+	if obj.Protocol == "" {
+		obj.Protocol = corev1.ProtocolTCP
+	}
+}
+
+func SetDefaults_ObjectFieldSelector(obj *corev1.ObjectFieldSelector) {
+	if obj.APIVersion == "" {
+		obj.APIVersion = defaultObjectFieldSelectorAPIVersion
+	}
+}
+
+func SetDefaults_Probe(obj *corev1.Probe) {
+	if obj.TimeoutSeconds == 0 {
+		obj.TimeoutSeconds = defaultProbeTimeoutSeconds
+	}
+	if obj.PeriodSeconds == 0 {
+		obj.PeriodSeconds = defaultProbePeriodSeconds
+	}
+	if obj.SuccessThreshold == 0 {
+		obj.SuccessThreshold = defaultProbeSuccessThreshold
+	}
+	if obj.FailureThreshold == 0 {
+		obj.FailureThreshold = defaultProbeFailureThreshold
+	}
+	if obj.HTTPGet != nil {
+		SetDefaults_HTTPGetAction(obj.HTTPGet)
+	}
+}
+
+func SetDefaults_HTTPGetAction(obj *corev1.HTTPGetAction) {
+	if obj.Path == "" {
+		obj.Path = defaultHTTPGetActionPath
+	}
+	if obj.Scheme == "" {
+		obj.Scheme = corev1.URISchemeHTTP
+	}
+}
+
+func SetDefaults_Volume(obj *corev1.Volume) {
+	if obj.VolumeSource == (corev1.VolumeSource{}) {
+		obj.VolumeSource = corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+	}
+	if obj.Secret != nil && obj.Secret.DefaultMode == nil {
+		obj.Secret.DefaultMode = ptr.To(defaultVolumeMode)
+	}
+	if obj.ConfigMap != nil && obj.ConfigMap.DefaultMode == nil {
+		obj.ConfigMap.DefaultMode = ptr.To(defaultVolumeMode)
+	}
+	if obj.DownwardAPI != nil {
+		if obj.DownwardAPI.DefaultMode == nil {
+			obj.DownwardAPI.DefaultMode = ptr.To(defaultVolumeMode)
+		}
+		for i := range obj.DownwardAPI.Items {
+			if obj.DownwardAPI.Items[i].FieldRef != nil {
+				SetDefaults_ObjectFieldSelector(obj.DownwardAPI.Items[i].FieldRef)
+			}
+		}
+	}
+	if obj.Projected != nil {
+		if obj.Projected.DefaultMode == nil {
+			obj.Projected.DefaultMode = ptr.To(defaultVolumeMode)
+		}
+		for i := range obj.Projected.Sources {
+			token := obj.Projected.Sources[i].ServiceAccountToken
+			if token != nil && token.ExpirationSeconds == nil {
+				token.ExpirationSeconds = ptr.To(defaultServiceAccountTokenExpiry)
+			}
+		}
+	}
+	if obj.HostPath != nil && obj.HostPath.Type == nil {
+		obj.HostPath.Type = ptr.To(corev1.HostPathUnset)
+	}
+	if obj.ISCSI != nil && obj.ISCSI.ISCSIInterface == "" {
+		obj.ISCSI.ISCSIInterface = "default"
+	}
+}
+
+func SetDefaults_ReplicationController(obj *corev1.ReplicationController) {
+	var labels map[string]string
+	if obj.Spec.Template != nil {
+		labels = obj.Spec.Template.Labels
+	}
+	// TODO: support templates defined elsewhere when we support them in the API
+	if labels != nil {
+		if len(obj.Spec.Selector) == 0 {
+			obj.Spec.Selector = labels
+		}
+		if len(obj.Labels) == 0 {
+			obj.Labels = labels
+		}
+	}
+	if obj.Spec.Replicas == nil {
+		obj.Spec.Replicas = ptr.To[int32](1)
+	}
+}
+
+func SetDefaults_Service(obj *corev1.Service) {
+	if obj.Spec.SessionAffinity == "" {
+		obj.Spec.SessionAffinity = corev1.ServiceAffinityNone
+	}
+	if obj.Spec.SessionAffinity == corev1.ServiceAffinityNone {
+		obj.Spec.SessionAffinityConfig = nil
+	}
+	if obj.Spec.SessionAffinity == corev1.ServiceAffinityClientIP {
+		if obj.Spec.SessionAffinityConfig == nil || obj.Spec.SessionAffinityConfig.ClientIP == nil || obj.Spec.SessionAffinityConfig.ClientIP.TimeoutSeconds == nil {
+			obj.Spec.SessionAffinityConfig = &corev1.SessionAffinityConfig{
+				ClientIP: &corev1.ClientIPConfig{TimeoutSeconds: ptr.To(defaultClientIPServiceAffinitySecs)},
+			}
+		}
+	}
+	if obj.Spec.Type == "" {
+		obj.Spec.Type = corev1.ServiceTypeClusterIP
+	}
+	for i := range obj.Spec.Ports {
+		sp := &obj.Spec.Ports[i]
+		if sp.Protocol == "" {
+			sp.Protocol = corev1.ProtocolTCP
+		}
+		if sp.TargetPort == intstr.FromInt32(0) || sp.TargetPort == intstr.FromString("") {
+			sp.TargetPort = intstr.FromInt32(sp.Port)
+		}
+	}
+	if (obj.Spec.Type == corev1.ServiceTypeNodePort || obj.Spec.Type == corev1.ServiceTypeLoadBalancer) && obj.Spec.ExternalTrafficPolicy == "" {
+		obj.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyCluster
+	}
+	if obj.Spec.InternalTrafficPolicy == nil && obj.Spec.Type != corev1.ServiceTypeExternalName {
+		obj.Spec.InternalTrafficPolicy = ptr.To(corev1.ServiceInternalTrafficPolicyCluster)
+	}
+	if obj.Spec.Type == corev1.ServiceTypeLoadBalancer && obj.Spec.AllocateLoadBalancerNodePorts == nil {
+		obj.Spec.AllocateLoadBalancerNodePorts = ptr.To(true)
+	}
+}
+
+func SetDefaults_Secret(obj *corev1.Secret) {
+	if obj.Type == "" {
+		obj.Type = corev1.SecretTypeOpaque
+	}
+}
+
+func SetDefaults_Namespace(obj *corev1.Namespace) {
+	// The namespace name label is set for every namespace so that it can be selected by name.
+	if len(obj.Name) > 0 {
+		if obj.Labels == nil {
+			obj.Labels = map[string]string{}
+		}
+		obj.Labels[namespaceNameLabel] = obj.Name
+	}
+}
+
+func SetDefaults_PersistentVolumeClaimSpec(obj *corev1.PersistentVolumeClaimSpec) {
+	if obj.VolumeMode == nil {
+		obj.VolumeMode = ptr.To(corev1.PersistentVolumeFilesystem)
+	}
+}
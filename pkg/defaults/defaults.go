@@ -0,0 +1,60 @@
+// Package defaults registers the Kubernetes API defaulting functions of built-in types to a scheme.
+//
+// The apiserver runs defaulting before admission, so policies see fields such as spec.replicas
+// or imagePullPolicy already filled in. The defaulting functions are not part of client-go, so
+// this package ports the upstream ones (k8s.io/kubernetes/pkg/apis/*/v1/defaults.go, as of v1.31)
+// for the commonly used core, apps, batch, networking and admissionregistration types.
+package defaults
+
+import (
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// NewScheme returns a scheme with the built-in Kubernetes API types and their defaulting functions.
+func NewScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = AddToScheme(scheme)
+	return scheme
+}
+
+// AddToScheme registers the defaulting functions to the scheme.
+func AddToScheme(scheme *runtime.Scheme) error {
+	scheme.AddTypeDefaultingFunc(&corev1.Pod{}, func(obj interface{}) { SetObjectDefaults_Pod(obj.(*corev1.Pod)) })
+	scheme.AddTypeDefaultingFunc(&corev1.PodTemplate{}, func(obj interface{}) { SetObjectDefaults_PodTemplate(obj.(*corev1.PodTemplate)) })
+	scheme.AddTypeDefaultingFunc(&corev1.ReplicationController{}, func(obj interface{}) {
+		SetObjectDefaults_ReplicationController(obj.(*corev1.ReplicationController))
+	})
+	scheme.AddTypeDefaultingFunc(&corev1.Service{}, func(obj interface{}) { SetDefaults_Service(obj.(*corev1.Service)) })
+	scheme.AddTypeDefaultingFunc(&corev1.Secret{}, func(obj interface{}) { SetDefaults_Secret(obj.(*corev1.Secret)) })
+	scheme.AddTypeDefaultingFunc(&corev1.Namespace{}, func(obj interface{}) { SetDefaults_Namespace(obj.(*corev1.Namespace)) })
+	scheme.AddTypeDefaultingFunc(&corev1.PersistentVolumeClaim{}, func(obj interface{}) {
+		SetDefaults_PersistentVolumeClaimSpec(&obj.(*corev1.PersistentVolumeClaim).Spec)
+	})
+
+	scheme.AddTypeDefaultingFunc(&appsv1.Deployment{}, func(obj interface{}) { SetObjectDefaults_Deployment(obj.(*appsv1.Deployment)) })
+	scheme.AddTypeDefaultingFunc(&appsv1.DaemonSet{}, func(obj interface{}) { SetObjectDefaults_DaemonSet(obj.(*appsv1.DaemonSet)) })
+	scheme.AddTypeDefaultingFunc(&appsv1.StatefulSet{}, func(obj interface{}) { SetObjectDefaults_StatefulSet(obj.(*appsv1.StatefulSet)) })
+	scheme.AddTypeDefaultingFunc(&appsv1.ReplicaSet{}, func(obj interface{}) { SetObjectDefaults_ReplicaSet(obj.(*appsv1.ReplicaSet)) })
+
+	scheme.AddTypeDefaultingFunc(&batchv1.Job{}, func(obj interface{}) { SetObjectDefaults_Job(obj.(*batchv1.Job)) })
+	scheme.AddTypeDefaultingFunc(&batchv1.CronJob{}, func(obj interface{}) { SetObjectDefaults_CronJob(obj.(*batchv1.CronJob)) })
+
+	scheme.AddTypeDefaultingFunc(&admissionregistrationv1.ValidatingAdmissionPolicy{}, func(obj interface{}) {
+		SetObjectDefaults_ValidatingAdmissionPolicy(obj.(*admissionregistrationv1.ValidatingAdmissionPolicy))
+	})
+	scheme.AddTypeDefaultingFunc(&admissionregistrationv1.ValidatingAdmissionPolicyBinding{}, func(obj interface{}) {
+		SetObjectDefaults_ValidatingAdmissionPolicyBinding(obj.(*admissionregistrationv1.ValidatingAdmissionPolicyBinding))
+	})
+
+	scheme.AddTypeDefaultingFunc(&networkingv1.NetworkPolicy{}, func(obj interface{}) {
+		SetDefaults_NetworkPolicy(obj.(*networkingv1.NetworkPolicy))
+	})
+	return nil
+}
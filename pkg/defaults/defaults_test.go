@@ -0,0 +1,115 @@
+package defaults
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+)
+
+func TestImagePullPolicy(t *testing.T) {
+	testCases := []struct {
+		image    string
+		expected corev1.PullPolicy
+	}{
+		{image: "nginx", expected: corev1.PullAlways},
+		{image: "nginx:latest", expected: corev1.PullAlways},
+		{image: "nginx:1.27", expected: corev1.PullIfNotPresent},
+		{image: "registry.example.com:5000/nginx", expected: corev1.PullAlways},
+		{image: "registry.example.com:5000/nginx:1.27", expected: corev1.PullIfNotPresent},
+		{image: "nginx@sha256:0123456789abcdef", expected: corev1.PullIfNotPresent},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.image, func(t *testing.T) {
+			c := &corev1.Container{Image: tc.image}
+			SetDefaults_Container(c)
+			assert.Equal(t, tc.expected, c.ImagePullPolicy)
+		})
+	}
+}
+
+func TestAddToScheme(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, AddToScheme(scheme))
+
+	testCases := []struct {
+		name   string
+		obj    runtime.Object
+		verify func(t *testing.T, obj runtime.Object)
+	}{
+		{
+			name: "deployment",
+			obj:  &appsv1.Deployment{},
+			verify: func(t *testing.T, obj runtime.Object) {
+				d := obj.(*appsv1.Deployment)
+				assert.Equal(t, ptr.To[int32](1), d.Spec.Replicas)
+				assert.Equal(t, appsv1.RollingUpdateDeploymentStrategyType, d.Spec.Strategy.Type)
+				assert.Equal(t, ptr.To(intstr.FromString("25%")), d.Spec.Strategy.RollingUpdate.MaxSurge)
+				assert.Equal(t, corev1.RestartPolicyAlways, d.Spec.Template.Spec.RestartPolicy)
+			},
+		},
+		{
+			name: "pod",
+			obj: &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:  "app",
+				Ports: []corev1.ContainerPort{{ContainerPort: 80}},
+				LivenessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+					HTTPGet: &corev1.HTTPGetAction{Port: intstr.FromInt32(80)},
+				}},
+			}}}},
+			verify: func(t *testing.T, obj runtime.Object) {
+				p := obj.(*corev1.Pod)
+				c := p.Spec.Containers[0]
+				assert.Equal(t, ptr.To(true), p.Spec.EnableServiceLinks)
+				assert.Equal(t, corev1.ProtocolTCP, c.Ports[0].Protocol)
+				assert.Equal(t, int32(10), c.LivenessProbe.PeriodSeconds)
+				assert.Equal(t, "/", c.LivenessProbe.HTTPGet.Path)
+				assert.Equal(t, corev1.URISchemeHTTP, c.LivenessProbe.HTTPGet.Scheme)
+			},
+		},
+		{
+			name: "service",
+			obj:  &corev1.Service{Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort, Ports: []corev1.ServicePort{{Port: 8080}}}},
+			verify: func(t *testing.T, obj runtime.Object) {
+				s := obj.(*corev1.Service)
+				assert.Equal(t, intstr.FromInt32(8080), s.Spec.Ports[0].TargetPort)
+				assert.Equal(t, corev1.ProtocolTCP, s.Spec.Ports[0].Protocol)
+				assert.Equal(t, corev1.ServiceExternalTrafficPolicyCluster, s.Spec.ExternalTrafficPolicy)
+			},
+		},
+		{
+			name: "namespace",
+			obj:  &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+			verify: func(t *testing.T, obj runtime.Object) {
+				assert.Equal(t, "team-a", obj.(*corev1.Namespace).Labels[namespaceNameLabel])
+			},
+		},
+		{
+			name: "cronjob",
+			obj:  &batchv1.CronJob{},
+			verify: func(t *testing.T, obj runtime.Object) {
+				c := obj.(*batchv1.CronJob)
+				assert.Equal(t, batchv1.AllowConcurrent, c.Spec.ConcurrencyPolicy)
+				assert.Equal(t, ptr.To[int32](6), c.Spec.JobTemplate.Spec.BackoffLimit)
+				assert.Equal(t, ptr.To(batchv1.NonIndexedCompletion), c.Spec.JobTemplate.Spec.CompletionMode)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheme.Default(tc.obj)
+			tc.verify(t, tc.obj)
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package defaults
+
+import (
+	"math"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	defaultRevisionHistoryLimit       int32 = 10
+	defaultProgressDeadlineSeconds    int32 = 600
+	defaultJobBackoffLimit            int32 = 6
+	defaultSuccessfulJobsHistoryLimit int32 = 3
+	defaultFailedJobsHistoryLimit     int32 = 1
+)
+
+func SetObjectDefaults_Deployment(in *appsv1.Deployment) {
+	SetDefaults_Deployment(in)
+	SetObjectDefaults_PodTemplateSpec(&in.Spec.Template)
+}
+
+func SetObjectDefaults_DaemonSet(in *appsv1.DaemonSet) {
+	SetDefaults_DaemonSet(in)
+	SetObjectDefaults_PodTemplateSpec(&in.Spec.Template)
+}
+
+func SetObjectDefaults_StatefulSet(in *appsv1.StatefulSet) {
+	SetDefaults_StatefulSet(in)
+	SetObjectDefaults_PodTemplateSpec(&in.Spec.Template)
+	for i := range in.Spec.VolumeClaimTemplates {
+		SetDefaults_PersistentVolumeClaimSpec(&in.Spec.VolumeClaimTemplates[i].Spec)
+	}
+}
+
+func SetObjectDefaults_ReplicaSet(in *appsv1.ReplicaSet) {
+	SetDefaults_ReplicaSet(in)
+	SetObjectDefaults_PodTemplateSpec(&in.Spec.Template)
+}
+
+func SetObjectDefaults_Job(in *batchv1.Job) {
+	SetDefaults_Job(in)
+	SetObjectDefaults_PodTemplateSpec(&in.Spec.Template)
+}
+
+func SetObjectDefaults_CronJob(in *batchv1.CronJob) {
+	SetDefaults_CronJob(in)
+	setDefaults_JobSpec(&in.Spec.JobTemplate.Spec)
+	SetObjectDefaults_PodTemplateSpec(&in.Spec.JobTemplate.Spec.Template)
+}
+
+func SetDefaults_Deployment(obj *appsv1.Deployment) {
+	// Set DeploymentSpec.Replicas to 1 if it is not set.
+	if obj.Spec.Replicas == nil {
+		obj.Spec.Replicas = ptr.To[int32](1)
+	}
+	strategy := &obj.Spec.Strategy
+	// Set default DeploymentStrategyType as RollingUpdate.
+	if strategy.Type == "" {
+		strategy.Type = appsv1.RollingUpdateDeploymentStrategyType
+	}
+	if strategy.Type == appsv1.RollingUpdateDeploymentStrategyType {
+		if strategy.RollingUpdate == nil {
+			strategy.RollingUpdate = &appsv1.RollingUpdateDeployment{}
+		}
+		if strategy.RollingUpdate.MaxUnavailable == nil {
+			// Set default MaxUnavailable as 25% by default.
+			strategy.RollingUpdate.MaxUnavailable = ptr.To(intstr.FromString("25%"))
+		}
+		if strategy.RollingUpdate.MaxSurge == nil {
+			// Set default MaxSurge as 25% by default.
+			strategy.RollingUpdate.MaxSurge = ptr.To(intstr.FromString("25%"))
+		}
+	}
+	if obj.Spec.RevisionHistoryLimit == nil {
+		obj.Spec.RevisionHistoryLimit = ptr.To(defaultRevisionHistoryLimit)
+	}
+	if obj.Spec.ProgressDeadlineSeconds == nil {
+		obj.Spec.ProgressDeadlineSeconds = ptr.To(defaultProgressDeadlineSeconds)
+	}
+}
+
+func SetDefaults_DaemonSet(obj *appsv1.DaemonSet) {
+	updateStrategy := &obj.Spec.UpdateStrategy
+	if updateStrategy.Type == "" {
+		updateStrategy.Type = appsv1.RollingUpdateDaemonSetStrategyType
+	}
+	if updateStrategy.Type == appsv1.RollingUpdateDaemonSetStrategyType {
+		if updateStrategy.RollingUpdate == nil {
+			updateStrategy.RollingUpdate = &appsv1.RollingUpdateDaemonSet{}
+		}
+		if updateStrategy.RollingUpdate.MaxUnavailable == nil {
+			// Set default MaxUnavailable as 1 by default.
+			updateStrategy.RollingUpdate.MaxUnavailable = ptr.To(intstr.FromInt32(1))
+		}
+		if updateStrategy.RollingUpdate.MaxSurge == nil {
+			// Set default MaxSurge as 0 by default.
+			updateStrategy.RollingUpdate.MaxSurge = ptr.To(intstr.FromInt32(0))
+		}
+	}
+	if obj.Spec.RevisionHistoryLimit == nil {
+		obj.Spec.RevisionHistoryLimit = ptr.To(defaultRevisionHistoryLimit)
+	}
+}
+
+func SetDefaults_StatefulSet(obj *appsv1.StatefulSet) {
+	if len(obj.Spec.PodManagementPolicy) == 0 {
+		obj.Spec.PodManagementPolicy = appsv1.OrderedReadyPodManagement
+	}
+	if obj.Spec.UpdateStrategy.Type == "" {
+		obj.Spec.UpdateStrategy.Type = appsv1.RollingUpdateStatefulSetStrategyType
+		if obj.Spec.UpdateStrategy.RollingUpdate == nil {
+			// UpdateStrategy.RollingUpdate will take default values below.
+			obj.Spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateStatefulSetStrategy{}
+		}
+	}
+	if obj.Spec.UpdateStrategy.Type == appsv1.RollingUpdateStatefulSetStrategyType &&
+		obj.Spec.UpdateStrategy.RollingUpdate != nil && obj.Spec.UpdateStrategy.RollingUpdate.Partition == nil {
+		obj.Spec.UpdateStrategy.RollingUpdate.Partition = ptr.To[int32](0)
+	}
+	if obj.Spec.PersistentVolumeClaimRetentionPolicy == nil {
+		obj.Spec.PersistentVolumeClaimRetentionPolicy = &appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{}
+	}
+	if len(obj.Spec.PersistentVolumeClaimRetentionPolicy.WhenDeleted) == 0 {
+		obj.Spec.PersistentVolumeClaimRetentionPolicy.WhenDeleted = appsv1.RetainPersistentVolumeClaimRetentionPolicyType
+	}
+	if len(obj.Spec.PersistentVolumeClaimRetentionPolicy.WhenScaled) == 0 {
+		obj.Spec.PersistentVolumeClaimRetentionPolicy.WhenScaled = appsv1.RetainPersistentVolumeClaimRetentionPolicyType
+	}
+	if obj.Spec.Replicas == nil {
+		obj.Spec.Replicas = ptr.To[int32](1)
+	}
+	if obj.Spec.RevisionHistoryLimit == nil {
+		obj.Spec.RevisionHistoryLimit = ptr.To(defaultRevisionHistoryLimit)
+	}
+}
+
+func SetDefaults_ReplicaSet(obj *appsv1.ReplicaSet) {
+	if obj.Spec.Replicas == nil {
+		obj.Spec.Replicas = ptr.To[int32](1)
+	}
+}
+
+func SetDefaults_Job(obj *batchv1.Job) {
+	setDefaults_JobSpec(&obj.Spec)
+	labels := obj.Spec.Template.Labels
+	if labels != nil && len(obj.Labels) == 0 {
+		obj.Labels = labels
+	}
+}
+
+func setDefaults_JobSpec(spec *batchv1.JobSpec) {
+	// For a non-parallel job, you can leave both `.spec.completions` and
+	// `.spec.parallelism` unset. When both are unset, both are defaulted to 1.
+	if spec.Completions == nil && spec.Parallelism == nil {
+		spec.Completions = ptr.To[int32](1)
+		spec.Parallelism = ptr.To[int32](1)
+	}
+	if spec.Parallelism == nil {
+		spec.Parallelism = ptr.To[int32](1)
+	}
+	if spec.BackoffLimit == nil {
+		if spec.BackoffLimitPerIndex != nil {
+			spec.BackoffLimit = ptr.To[int32](math.MaxInt32)
+		} else {
+			spec.BackoffLimit = ptr.To(defaultJobBackoffLimit)
+		}
+	}
+	if spec.CompletionMode == nil {
+		spec.CompletionMode = ptr.To(batchv1.NonIndexedCompletion)
+	}
+	if spec.Suspend == nil {
+		spec.Suspend = ptr.To(false)
+	}
+	if spec.PodReplacementPolicy == nil {
+		if spec.PodFailurePolicy != nil {
+			spec.PodReplacementPolicy = ptr.To(batchv1.Failed)
+		} else {
+			spec.PodReplacementPolicy = ptr.To(batchv1.TerminatingOrFailed)
+		}
+	}
+}
+
+func SetDefaults_CronJob(obj *batchv1.CronJob) {
+	if obj.Spec.ConcurrencyPolicy == "" {
+		obj.Spec.ConcurrencyPolicy = batchv1.AllowConcurrent
+	}
+	if obj.Spec.Suspend == nil {
+		obj.Spec.Suspend = ptr.To(false)
+	}
+	if obj.Spec.SuccessfulJobsHistoryLimit == nil {
+		obj.Spec.SuccessfulJobsHistoryLimit = ptr.To(defaultSuccessfulJobsHistoryLimit)
+	}
+	if obj.Spec.FailedJobsHistoryLimit == nil {
+		obj.Spec.FailedJobsHistoryLimit = ptr.To(defaultFailedJobsHistoryLimit)
+	}
+}
+
+func SetDefaults_NetworkPolicy(obj *networkingv1.NetworkPolicy) {
+	// Set NetworkPolicyPort's Protocol to TCP
+	for _, rule := range obj.Spec.Ingress {
+		defaultNetworkPolicyPorts(rule.Ports)
+	}
+	for _, rule := range obj.Spec.Egress {
+		defaultNetworkPolicyPorts(rule.Ports)
+	}
+
+	if len(obj.Spec.PolicyTypes) == 0 {
+		// Any policy that does not specify policyTypes implies at least "Ingress".
+		obj.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+		if len(obj.Spec.Egress) != 0 {
+			obj.Spec.PolicyTypes = append(obj.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+		}
+	}
+}
+
+func defaultNetworkPolicyPorts(ports []networkingv1.NetworkPolicyPort) {
+	for i := range ports {
+		if ports[i].Protocol == nil {
+			ports[i].Protocol = ptr.To(corev1.ProtocolTCP)
+		}
+	}
+}
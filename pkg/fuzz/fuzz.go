@@ -0,0 +1,639 @@
+// Package fuzz evaluates ValidatingAdmissionPolicies against random objects conforming to the schemas of the
+// kinds they match, looking for objects their expressions do not handle.
+package fuzz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/yashirook/vaptest/pkg/target"
+	"github.com/yashirook/vaptest/pkg/validator"
+	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	plugincel "k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/admission/plugin/policy/validating"
+	"k8s.io/apiserver/pkg/cel/environment"
+	"sigs.k8s.io/yaml"
+)
+
+// FindingType is the kind of problem an object revealed in an expression.
+type FindingType string
+
+const (
+	// FindingEvalError is an expression failing with a runtime error, e.g. on a missing field.
+	FindingEvalError FindingType = "eval-error"
+	// FindingNonBool is a validation evaluating to a value other than a bool, which the apiserver denies.
+	FindingNonBool FindingType = "non-bool"
+	// FindingCostOverrun is an expression exceeding the runtime cost budget.
+	FindingCostOverrun FindingType = "cost-overrun"
+	// FindingInconsistent is a validation with different outcomes for semantically equal objects, e.g. objects
+	// whose lists keyed by name are in a different order.
+	FindingInconsistent FindingType = "inconsistent"
+	// findingCompileError is a validation failing to compile, which fails for every object and is reported as a
+	// warning rather than a finding.
+	findingCompileError FindingType = "compile-error"
+)
+
+const (
+	// DefaultIterations is the default number of objects generated for each kind.
+	DefaultIterations = 100
+	// batchSize is the number of objects evaluated by a validator at once.
+	batchSize = 50
+	// maxShrinkRounds bounds the edits made to shrink a failing object, and maxShrinkEvaluations the objects
+	// evaluated to find them, as objects overrunning the cost budget take long to evaluate.
+	maxShrinkRounds      = 100
+	maxShrinkEvaluations = 400
+	// maxShrinkCandidates bounds the simplifications of an object tried in a round. They are evaluated in chunks
+	// doubling in size up to maxShrinkChunk, as the first one still revealing the finding is taken.
+	maxShrinkCandidates = 200
+	maxShrinkChunk      = 32
+)
+
+// Finding is a problem of a validation revealed by an object, shrunk to a minimal reproducer.
+type Finding struct {
+	Type   FindingType
+	Policy string
+	// Index is the index of the validation in spec.validations.
+	Index      int
+	Expression string
+	// Message is the message of the failure, or the outcomes for the object and its equivalent.
+	Message string
+	Kind    schema.GroupVersionKind
+	// Object is the object revealing the problem and Equivalent, for FindingInconsistent, the semantically
+	// equal object with a different outcome.
+	Object     map[string]any
+	Equivalent map[string]any
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s of policy %s spec.validations[%d]", f.Type, f.Policy, f.Index)
+}
+
+// FixtureName returns the name of the fixture file of the finding.
+func (f Finding) FixtureName() string {
+	return fmt.Sprintf("%s-%d-%s.yaml", f.Policy, f.Index, f.Type)
+}
+
+// WriteFixture writes the objects of the finding as a YAML manifest, with a comment describing the finding.
+func (f Finding) WriteFixture(w io.Writer) error {
+	fmt.Fprintf(w, "# %s\n", f)
+	fmt.Fprintf(w, "# expression: %s\n", strings.Join(strings.Fields(f.Expression), " "))
+	for _, line := range strings.Split(f.Message, "\n") {
+		fmt.Fprintf(w, "# %s\n", line)
+	}
+	objects := []map[string]any{f.Object}
+	if f.Equivalent != nil {
+		objects = append(objects, f.Equivalent)
+	}
+	for i, object := range objects {
+		if i > 0 {
+			fmt.Fprintln(w, "---")
+		}
+		doc, err := yaml.Marshal(object)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Report is the outcome of a fuzzing run.
+type Report struct {
+	Findings []Finding
+	// Kinds are the kinds objects were generated for, and Objects the number of objects evaluated.
+	Kinds   []schema.GroupVersionKind
+	Objects int
+}
+
+// Fuzzer evaluates policies through their bindings against random objects of the kinds matched by the resource
+// rules of the policies for CREATE. Objects conform to the OpenAPI schema of their kind, derived from its Go type
+// for built-in kinds and from the CustomResourceDefinition for custom kinds, and are biased towards edge values
+// such as empty lists, empty strings and integer limits. The status of objects is not generated.
+type Fuzzer struct {
+	Policies []*v1.ValidatingAdmissionPolicy
+	Bindings []*v1.ValidatingAdmissionPolicyBinding
+	Params   []runtime.Object
+	// CRDs are the CustomResourceDefinitions of the custom kinds matched by the policies.
+	CRDs   []runtime.Object
+	Scheme *runtime.Scheme
+	// RESTMapper resolves resources. Nil means the built-in API catalog.
+	RESTMapper meta.RESTMapper
+	// Defaulting applies Kubernetes API defaulting to objects before evaluation.
+	Defaulting bool
+	// Seed makes the generated objects reproducible, and Iterations is the number of objects of each kind.
+	Seed       int64
+	Iterations int
+	// Warnings are the resource rules and kinds no objects were generated for, and the validations failing to
+	// compile.
+	Warnings []error
+}
+
+// NewFuzzer creates a Fuzzer of the policies and bindings with the default number of iterations.
+func NewFuzzer(policies []*v1.ValidatingAdmissionPolicy, bindings []*v1.ValidatingAdmissionPolicyBinding, scheme *runtime.Scheme) *Fuzzer {
+	return &Fuzzer{Policies: policies, Bindings: bindings, Scheme: scheme, Iterations: DefaultIterations}
+}
+
+// sample is a generated object and, if any, a semantically equal object.
+type sample struct {
+	kind       *Kind
+	content    map[string]any
+	object     map[string]any
+	target     target.TargetInfo
+	equivalent map[string]any
+	// equivalentTarget is the target of equivalent, if any.
+	equivalentTarget *target.TargetInfo
+}
+
+// findingKey identifies a finding regardless of the object revealing it.
+type findingKey struct {
+	typ    FindingType
+	policy string
+	index  int
+}
+
+// Run generates objects of each kind matched by the policies, evaluates the policies against them and returns
+// the problems found, each with the object revealing it shrunk to a minimal reproducer: no field can be removed
+// or set to its zero value without the problem going away, unless shrinking ran out of evaluations. Each problem
+// of a validation is reported once. Validations failing to compile are reported as warnings.
+func (f *Fuzzer) Run(ctx context.Context) (*Report, error) {
+	mapper, kinds, err := f.kinds()
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{}
+	for _, kind := range kinds {
+		report.Kinds = append(report.Kinds, kind.GVK)
+	}
+	policies := f.probedPolicies()
+	if len(kinds) == 0 || len(policies) == 0 {
+		return report, nil
+	}
+
+	var opts []target.Option
+	if f.Defaulting {
+		opts = append(opts, target.WithDefaulting(f.Scheme))
+	}
+	build := func(kind *Kind, content map[string]any) (*sample, error) {
+		return f.newSample(kind, content, mapper, opts)
+	}
+
+	var expressions []string
+	for _, policy := range policies {
+		for _, condition := range policy.Spec.MatchConditions {
+			expressions = append(expressions, condition.Expression)
+		}
+		for _, variable := range policy.Spec.Variables {
+			expressions = append(expressions, variable.Expression)
+		}
+		for _, validation := range policy.Spec.Validations {
+			expressions = append(expressions, validation.Expression, validation.MessageExpression)
+		}
+	}
+	gen := newGenerator(f.Seed, selectedFields(expressions))
+	found := map[findingKey]bool{}
+	for i := range kinds {
+		kind := &kinds[i]
+		for generated := 0; generated < f.Iterations; {
+			var samples []*sample
+			for ; generated < f.Iterations && len(samples) < batchSize; generated++ {
+				content, _ := gen.value(kind.Schema, 0).(map[string]any)
+				s, err := build(kind, content)
+				if err != nil {
+					// Goの型に変換できない値は生成し直さず、次のオブジェクトに進む
+					continue
+				}
+				samples = append(samples, s)
+			}
+			report.Objects += len(samples)
+			observed, err := f.evaluate(ctx, policies, samples, mapper)
+			if err != nil {
+				return nil, err
+			}
+			for j, findings := range observed {
+				for _, finding := range findings {
+					key := findingKey{finding.Type, finding.Policy, finding.Index}
+					if found[key] {
+						continue
+					}
+					found[key] = true
+					if finding.Type == findingCompileError {
+						f.Warnings = append(f.Warnings, fmt.Errorf("policy %s spec.validations[%d]: %s", finding.Policy, finding.Index, finding.Message))
+						continue
+					}
+					shrunk, err := f.shrink(ctx, policies, samples[j], finding, build, mapper)
+					if err != nil {
+						return nil, err
+					}
+					report.Findings = append(report.Findings, shrunk)
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+// kinds returns the RESTMapper resolving the built-in kinds and those of the CRDs, and the kinds to generate.
+func (f *Fuzzer) kinds() (meta.RESTMapper, []Kind, error) {
+	mapper := f.RESTMapper
+	if mapper == nil {
+		mapper = target.StaticRESTMapper(f.Scheme)
+	}
+	customKinds, mappings, err := crdKinds(f.CRDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(mappings) > 0 {
+		crdMapper := meta.NewDefaultRESTMapper(nil)
+		for _, m := range mappings {
+			scope := meta.RESTScopeRoot
+			if m.namespaced {
+				scope = meta.RESTScopeNamespace
+			}
+			singular := m.resource.GroupVersion().WithResource(strings.ToLower(m.gvk.Kind))
+			crdMapper.AddSpecific(m.gvk, m.resource, singular, scope)
+		}
+		mapper = meta.MultiRESTMapper{crdMapper, mapper}
+	}
+
+	var kinds []Kind
+	seen := map[schema.GroupVersionKind]bool{}
+	for _, policy := range f.boundPolicies() {
+		constraints := policy.Spec.MatchConstraints
+		if constraints == nil {
+			continue
+		}
+		for _, rule := range constraints.ResourceRules {
+			if !slices.Contains(rule.Operations, v1.Create) && !slices.Contains(rule.Operations, v1.OperationAll) {
+				continue
+			}
+			for _, group := range rule.APIGroups {
+				for _, version := range rule.APIVersions {
+					for _, resource := range rule.Resources {
+						if group == "*" || resource == "*" || strings.Contains(resource, "/") {
+							f.Warnings = append(f.Warnings, fmt.Errorf("policy %s: resource rule %s/%s %s is not fuzzed, as only plain resources are", policy.Name, group, version, resource))
+							continue
+						}
+						gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: resource}
+						if version == "*" {
+							gvr.Version = ""
+						}
+						gvks, err := mapper.KindsFor(gvr)
+						if err != nil {
+							f.Warnings = append(f.Warnings, fmt.Errorf("policy %s: %w", policy.Name, err))
+							continue
+						}
+						for _, gvk := range gvks {
+							if seen[gvk] {
+								continue
+							}
+							seen[gvk] = true
+							kind, ok := f.kind(gvk, customKinds, mapper)
+							if !ok {
+								f.Warnings = append(f.Warnings, fmt.Errorf("no schema for %s, pass its CustomResourceDefinition with --crds", gvk))
+								continue
+							}
+							kinds = append(kinds, kind)
+						}
+					}
+				}
+			}
+		}
+	}
+	return mapper, kinds, nil
+}
+
+// kind returns the kind of the CRD of the GVK, or of its Go type in the scheme.
+func (f *Fuzzer) kind(gvk schema.GroupVersionKind, customKinds []Kind, mapper meta.RESTMapper) (Kind, bool) {
+	for _, kind := range customKinds {
+		if kind.GVK == gvk {
+			return kind, true
+		}
+	}
+	obj, err := f.Scheme.New(gvk)
+	if err != nil {
+		return Kind{}, false
+	}
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return Kind{}, false
+	}
+	return Kind{
+		GVK:        gvk,
+		Namespaced: mapping.Scope.Name() == meta.RESTScopeNameNamespace,
+		Schema:     schemaOfType(reflect.TypeOf(obj).Elem()),
+		Typed:      true,
+	}, true
+}
+
+// boundPolicies returns the policies with bindings, which are the policies the apiserver evaluates.
+func (f *Fuzzer) boundPolicies() []*v1.ValidatingAdmissionPolicy {
+	var policies []*v1.ValidatingAdmissionPolicy
+	for _, policy := range f.Policies {
+		bound := slices.ContainsFunc(f.Bindings, func(b *v1.ValidatingAdmissionPolicyBinding) bool {
+			return b.Spec.PolicyName == policy.Name
+		})
+		if bound {
+			policies = append(policies, policy)
+		}
+	}
+	return policies
+}
+
+// probedPolicy is a copy of a policy with a probe validation appended for each validation type-checked to a
+// type other than bool, holding when the validation evaluates to a bool. The apiserver does not compile such
+// validations, but evaluating their probes tells which objects they would not handle.
+type probedPolicy struct {
+	*v1.ValidatingAdmissionPolicy
+	// validations is the number of validations of the policy, and probed the indexes of the probed ones.
+	validations int
+	probed      []int
+}
+
+// probedPolicies returns the bound policies with probes.
+func (f *Fuzzer) probedPolicies() []probedPolicy {
+	envSet := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion(), false)
+	var policies []probedPolicy
+	for _, policy := range f.boundPolicies() {
+		p := probedPolicy{ValidatingAdmissionPolicy: policy.DeepCopy(), validations: len(policy.Spec.Validations)}
+		if compiler, err := plugincel.NewCompositedCompiler(envSet); err == nil {
+			p.probed = nonBoolValidations(compiler, policy)
+		}
+		for _, i := range p.probed {
+			p.Spec.Validations = append(p.Spec.Validations, v1.Validation{
+				Expression: "type(dyn(\n" + policy.Spec.Validations[i].Expression + "\n)) == bool",
+			})
+		}
+		policies = append(policies, p)
+	}
+	return policies
+}
+
+// nonBoolValidations returns the indexes of the validations of the policy that compile as expressions of a type
+// other than bool, in the environment of the apiserver with the variables of the policy.
+func nonBoolValidations(compiler *plugincel.CompositedCompiler, policy *v1.ValidatingAdmissionPolicy) []int {
+	options := plugincel.OptionalVariableDeclarations{HasParams: policy.Spec.ParamKind != nil, HasAuthorizer: true}
+	for _, variable := range policy.Spec.Variables {
+		compiler.CompileAndStoreVariable(&validating.Variable{Name: variable.Name, Expression: variable.Expression}, options, environment.StoredExpressions)
+	}
+	var indexes []int
+	for i, validation := range policy.Spec.Validations {
+		asBool := compiler.CompileCELExpression(&validating.ValidationCondition{Expression: validation.Expression}, options, environment.StoredExpressions)
+		asAny := compiler.CompileCELExpression(&validating.Variable{Expression: validation.Expression}, options, environment.StoredExpressions)
+		if asBool.Error != nil && asAny.Error == nil {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// newSample returns the sample of the generated content of the kind, named and placed in the default namespace.
+// The content of a typed kind is decoded into its Go type, which fails for values the type cannot hold.
+func (f *Fuzzer) newSample(kind *Kind, content map[string]any, mapper meta.RESTMapper, opts []target.Option) (*sample, error) {
+	s := &sample{kind: kind, content: content}
+	var err error
+	if s.object, s.target, err = f.newObject(kind, content, mapper, opts); err != nil {
+		return nil, err
+	}
+	if reordered, ok := reorder(kind.Schema, content); ok {
+		equivalent, info, err := f.newObject(kind, reordered.(map[string]any), mapper, opts)
+		if err == nil {
+			s.equivalent, s.equivalentTarget = equivalent, &info
+		}
+	}
+	return s, nil
+}
+
+func (f *Fuzzer) newObject(kind *Kind, content map[string]any, mapper meta.RESTMapper, opts []target.Option) (map[string]any, target.TargetInfo, error) {
+	object := with(content, "apiVersion", kind.GVK.GroupVersion().String())
+	object["kind"] = kind.GVK.Kind
+	metadata, _ := object["metadata"].(map[string]any)
+	metadata = with(metadata, "name", "fuzz")
+	if kind.Namespaced {
+		metadata["namespace"] = "default"
+	}
+	object["metadata"] = metadata
+
+	var obj runtime.Object = &unstructured.Unstructured{Object: object}
+	if kind.Typed {
+		typed, err := f.Scheme.New(kind.GVK)
+		if err != nil {
+			return nil, target.TargetInfo{}, err
+		}
+		doc, err := json.Marshal(object)
+		if err != nil {
+			return nil, target.TargetInfo{}, err
+		}
+		if err := json.Unmarshal(doc, typed); err != nil {
+			return nil, target.TargetInfo{}, err
+		}
+		typed.GetObjectKind().SetGroupVersionKind(kind.GVK)
+		obj = typed
+	}
+	info, err := target.NewTargetInfoWithMapper(obj, mapper, opts...)
+	if err != nil {
+		return nil, target.TargetInfo{}, err
+	}
+	return object, *info, nil
+}
+
+// evaluate evaluates the policies against the samples and returns the findings of each sample, without objects.
+func (f *Fuzzer) evaluate(ctx context.Context, policies []probedPolicy, samples []*sample, mapper meta.RESTMapper) ([][]Finding, error) {
+	var targets target.TargetInfoList
+	equivalents := make([]int, len(samples))
+	for i, s := range samples {
+		targets = append(targets, s.target)
+		equivalents[i] = -1
+		if s.equivalentTarget != nil {
+			equivalents[i] = len(targets)
+			targets = append(targets, *s.equivalentTarget)
+		}
+	}
+	if len(targets) == 0 {
+		return make([][]Finding, len(samples)), nil
+	}
+	var evaluated []*v1.ValidatingAdmissionPolicy
+	for _, policy := range policies {
+		evaluated = append(evaluated, policy.ValidatingAdmissionPolicy)
+	}
+	v := validator.UpstreamValidator{
+		TargetInfoList: targets,
+		Policies:       evaluated,
+		PolicyBindings: f.Bindings,
+		Scheme:         f.Scheme,
+		Params:         f.Params,
+		RESTMapper:     mapper,
+	}
+	results, err := v.ValidatePerTargetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := make([][]Finding, len(samples))
+	next := 0
+	for i, s := range samples {
+		targetResults := results[next]
+		next++
+		var equivalentResults []validator.ValidationResult
+		if equivalents[i] >= 0 {
+			equivalentResults = results[next]
+			next++
+		}
+		for _, policy := range policies {
+			result := validator.FindResult(targetResults, policy.Name)
+			if result == nil {
+				continue
+			}
+			findings[i] = append(findings[i], observe(policy, result)...)
+			if equivalent := validator.FindResult(equivalentResults, policy.Name); equivalent != nil {
+				findings[i] = append(findings[i], compare(policy, result, equivalent)...)
+			}
+		}
+		for j := range findings[i] {
+			findings[i][j].Kind = s.kind.GVK
+		}
+	}
+	return findings, nil
+}
+
+// observe returns the findings of the result of a policy with probes. Failures about the configuration of the
+// policy, such as missing params, are not problems of expressions and are ignored.
+func observe(policy probedPolicy, result *validator.ValidationResult) []Finding {
+	n := policy.validations
+	var findings []Finding
+	for _, e := range result.ValidationErrors {
+		if e.Index < 0 || strings.HasPrefix(e.Message, "failed to configure ") {
+			continue
+		}
+		index := e.Index
+		typ := FindingEvalError
+		message := e.Message
+		switch {
+		case e.Index >= n && e.EvaluationError:
+			// プローブ自体の評価エラーは元のバリデーションで報告される
+			continue
+		case e.Index >= n:
+			index = policy.probed[e.Index-n]
+			typ = FindingNonBool
+			message = "evaluated to a value that is not a bool"
+		case !e.EvaluationError:
+			continue
+		case strings.HasPrefix(e.Message, "compilation error: "):
+			typ = findingCompileError
+		case strings.Contains(e.Message, "cost limit exceeded") || strings.Contains(e.Message, "cost budget") || strings.Contains(e.Message, "cost could not be calculated"):
+			typ = FindingCostOverrun
+		}
+		findings = append(findings, Finding{
+			Type:       typ,
+			Policy:     policy.Name,
+			Index:      index,
+			Expression: policy.Spec.Validations[index].Expression,
+			Message:    message,
+		})
+	}
+	return findings
+}
+
+// compare returns the findings of the validations with different outcomes for semantically equal objects.
+func compare(policy probedPolicy, result, equivalent *validator.ValidationResult) []Finding {
+	n := policy.validations
+	var findings []Finding
+	for i := 0; i < n; i++ {
+		got, want := outcome(result, i), outcome(equivalent, i)
+		if got == want {
+			continue
+		}
+		findings = append(findings, Finding{
+			Type:       FindingInconsistent,
+			Policy:     policy.Name,
+			Index:      i,
+			Expression: policy.Spec.Validations[i].Expression,
+			Message:    fmt.Sprintf("%s for the object, %s for the equivalent object with reordered lists", got, want),
+		})
+	}
+	return findings
+}
+
+// outcome returns how the validation at the index evaluated: "allowed", "denied" or "error".
+func outcome(result *validator.ValidationResult, index int) string {
+	for _, e := range result.ValidationErrors {
+		if e.Index != index {
+			continue
+		}
+		if e.EvaluationError {
+			return "error"
+		}
+		return "denied"
+	}
+	return "allowed"
+}
+
+// shrink simplifies the object of the sample one edit at a time for as long as it still reveals the finding,
+// trying the edits of shallower fields first, and returns the finding with the simplest object. Only the
+// validation of the finding and its probe are evaluated, for at most maxShrinkEvaluations objects.
+func (f *Fuzzer) shrink(ctx context.Context, policies []probedPolicy, s *sample, found Finding, build func(*Kind, map[string]any) (*sample, error), mapper meta.RESTMapper) (Finding, error) {
+	var policy []probedPolicy
+	for _, p := range policies {
+		if p.Name != found.Policy {
+			continue
+		}
+		shrinking := probedPolicy{ValidatingAdmissionPolicy: p.DeepCopy(), validations: 1}
+		shrinking.Spec.Validations = []v1.Validation{p.Spec.Validations[found.Index]}
+		if i := slices.Index(p.probed, found.Index); i >= 0 {
+			shrinking.Spec.Validations = append(shrinking.Spec.Validations, p.Spec.Validations[p.validations+i])
+			shrinking.probed = []int{0}
+		}
+		policy = append(policy, shrinking)
+	}
+	reveals := func(findings []Finding) bool {
+		return slices.ContainsFunc(findings, func(finding Finding) bool { return finding.Type == found.Type })
+	}
+
+	evaluations := 0
+	for round := 0; round < maxShrinkRounds && evaluations < maxShrinkEvaluations; round++ {
+		var candidates []*sample
+		for _, value := range simplifications(s.kind.Schema, s.content) {
+			content, _ := value.(map[string]any)
+			candidate, err := build(s.kind, content)
+			if err != nil {
+				continue
+			}
+			candidates = append(candidates, candidate)
+			if len(candidates) == maxShrinkCandidates {
+				break
+			}
+		}
+		simpler := -1
+		for start, size := 0, 1; start < len(candidates) && simpler < 0 && evaluations < maxShrinkEvaluations; start, size = start+size, min(2*size, maxShrinkChunk) {
+			chunk := candidates[start:min(start+size, len(candidates))]
+			evaluations += len(chunk)
+			observed, err := f.evaluate(ctx, policy, chunk, mapper)
+			if err != nil {
+				return Finding{}, err
+			}
+			if i := slices.IndexFunc(observed, reveals); i >= 0 {
+				simpler = start + i
+			}
+		}
+		if simpler < 0 {
+			break
+		}
+		s = candidates[simpler]
+	}
+
+	found.Object = s.object
+	if found.Type == FindingInconsistent {
+		found.Equivalent = s.equivalent
+	}
+	return found, nil
+}
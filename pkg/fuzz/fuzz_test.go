@@ -0,0 +1,170 @@
+package fuzz
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yashirook/vaptest/pkg/defaults"
+	"github.com/yashirook/vaptest/pkg/loader"
+	"github.com/yashirook/vaptest/pkg/validator"
+	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func loadObjects(t *testing.T, path string) []runtime.Object {
+	t.Helper()
+	objects, err := loader.NewLoader(defaults.NewScheme()).LoadObjectFromPaths([]string{path})
+	require.NoError(t, err)
+	return objects
+}
+
+func newTestFuzzer(t *testing.T, path string) *Fuzzer {
+	t.Helper()
+	scheme := defaults.NewScheme()
+	policies, bindings, err := loader.NewLoader(scheme).LoadPolicyFromPaths([]string{path})
+	require.NoError(t, err)
+	f := NewFuzzer(policies, bindings, scheme)
+	f.Seed = 1
+	f.Iterations = 50
+	return f
+}
+
+type summary struct {
+	typ    FindingType
+	policy string
+	index  int
+}
+
+func summarize(findings []Finding) []summary {
+	var got []summary
+	for _, f := range findings {
+		got = append(got, summary{f.Type, f.Policy, f.Index})
+	}
+	return got
+}
+
+func TestFuzzerRun(t *testing.T) {
+	f := newTestFuzzer(t, "testdata/policy.yaml")
+	report, err := f.Run(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []schema.GroupVersionKind{{Group: "apps", Version: "v1", Kind: "Deployment"}}, report.Kinds)
+	assert.Equal(t, 50, report.Objects)
+	assert.Equal(t, []summary{
+		{FindingEvalError, "deployment-rules", 0},
+		{FindingNonBool, "deployment-rules", 1},
+		{FindingInconsistent, "deployment-rules", 0},
+	}, summarize(report.Findings))
+	require.Len(t, f.Warnings, 1)
+	assert.EqualError(t, f.Warnings[0], "policy deployment-rules spec.validations[1]: compilation error: must evaluate to bool")
+
+	// 縮小後のオブジェクトには問題の再現に必要なフィールドだけが残る
+	metadata := map[string]any{"name": "fuzz", "namespace": "default"}
+	assert.Equal(t, map[string]any{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": metadata}, report.Findings[0].Object)
+	assert.Contains(t, report.Findings[0].Message, "resulted in error: ")
+	assert.Equal(t, "evaluated to a value that is not a bool", report.Findings[1].Message)
+	labels := report.Findings[1].Object["metadata"].(map[string]any)["labels"]
+	assert.Len(t, labels, 1)
+	assert.Contains(t, labels, "app")
+
+	inconsistent := report.Findings[2]
+	containers := func(object map[string]any) []any {
+		return object["spec"].(map[string]any)["template"].(map[string]any)["spec"].(map[string]any)["containers"].([]any)
+	}
+	require.Len(t, containers(inconsistent.Object), 2)
+	assert.Equal(t, containers(inconsistent.Object)[0], containers(inconsistent.Equivalent)[1])
+	assert.Equal(t, containers(inconsistent.Object)[1], containers(inconsistent.Equivalent)[0])
+	assert.Equal(t, "allowed for the object, error for the equivalent object with reordered lists", inconsistent.Message)
+
+	again, err := newTestFuzzer(t, "testdata/policy.yaml").Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, report, again, "同じシードでは同じ結果になる")
+}
+
+func TestFuzzerRunCRD(t *testing.T) {
+	f := newTestFuzzer(t, "testdata/widget-policy.yaml")
+	report, err := f.Run(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, report.Kinds)
+	assert.ErrorContains(t, f.Warnings[0], "no matches for example.com/, Resource=widgets")
+
+	f = newTestFuzzer(t, "testdata/widget-policy.yaml")
+	f.CRDs = loadObjects(t, "testdata/crd.yaml")
+	report, err = f.Run(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, f.Warnings)
+	assert.Equal(t, []schema.GroupVersionKind{{Group: "example.com", Version: "v1", Kind: "Widget"}}, report.Kinds)
+	assert.Equal(t, []summary{
+		{FindingEvalError, "widget-rules", 0},
+		{FindingInconsistent, "widget-rules", 0},
+	}, summarize(report.Findings))
+	// 必須のsizeは残り、最小値を下回らない
+	assert.Equal(t, map[string]any{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]any{"name": "fuzz", "namespace": "default"},
+		"spec":       map[string]any{"size": int64(1)},
+	}, report.Findings[0].Object)
+}
+
+func TestObserve(t *testing.T) {
+	policy := probedPolicy{
+		ValidatingAdmissionPolicy: &v1.ValidatingAdmissionPolicy{Spec: v1.ValidatingAdmissionPolicySpec{Validations: []v1.Validation{
+			{Expression: "a"}, {Expression: "b"}, {Expression: "c"}, {Expression: "d"}, {Expression: "type(dyn(\nc\n)) == bool"},
+		}}},
+		validations: 4,
+		probed:      []int{2},
+	}
+	policy.Name = "policy"
+	result := &validator.ValidationResult{ValidationErrors: []validator.ValidationError{
+		{Index: 0, Message: "expression 'a' resulted in error: no such key: spec", EvaluationError: true},
+		{Index: 1, Message: "expression 'b' resulted in error: operation cancelled: actual cost limit exceeded", EvaluationError: true},
+		{Index: 2, Message: "compilation error: must evaluate to bool", EvaluationError: true},
+		// 値がfalseの場合や設定の誤りは問題として報告しない
+		{Index: 3, Message: "failed expression: d"},
+		{Index: 3, Message: "failed to configure policy: failed to find params to validate", EvaluationError: true},
+		{Index: 4, Message: "failed expression: type(dyn(\nc\n)) == bool"},
+		{Index: -1, Message: "expression 'x' resulted in error: no such key: y", EvaluationError: true},
+	}}
+
+	assert.Equal(t, []Finding{
+		{Type: FindingEvalError, Policy: "policy", Index: 0, Expression: "a", Message: "expression 'a' resulted in error: no such key: spec"},
+		{Type: FindingCostOverrun, Policy: "policy", Index: 1, Expression: "b", Message: "expression 'b' resulted in error: operation cancelled: actual cost limit exceeded"},
+		{Type: findingCompileError, Policy: "policy", Index: 2, Expression: "c", Message: "compilation error: must evaluate to bool"},
+		{Type: FindingNonBool, Policy: "policy", Index: 2, Expression: "c", Message: "evaluated to a value that is not a bool"},
+	}, observe(policy, result))
+}
+
+func TestFindingWriteFixture(t *testing.T) {
+	finding := Finding{
+		Type:       FindingInconsistent,
+		Policy:     "policy",
+		Index:      1,
+		Expression: "object.spec.containers[0].name ==\n  'app'\n",
+		Message:    "allowed for the object, denied for the equivalent object with reordered lists",
+		Object:     map[string]any{"apiVersion": "v1", "kind": "Pod", "metadata": map[string]any{"name": "fuzz"}},
+		Equivalent: map[string]any{"apiVersion": "v1", "kind": "Pod", "metadata": map[string]any{"name": "fuzz"}, "spec": map[string]any{}},
+	}
+	assert.Equal(t, "policy-1-inconsistent.yaml", finding.FixtureName())
+
+	var buf bytes.Buffer
+	require.NoError(t, finding.WriteFixture(&buf))
+	assert.Equal(t, `# inconsistent of policy policy spec.validations[1]
+# expression: object.spec.containers[0].name == 'app'
+# allowed for the object, denied for the equivalent object with reordered lists
+apiVersion: v1
+kind: Pod
+metadata:
+  name: fuzz
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: fuzz
+spec: {}
+`, buf.String())
+}
@@ -0,0 +1,235 @@
+package fuzz
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+)
+
+// Edge values random values are biased towards, as expressions most often mishandle them.
+var (
+	edgeStrings    = []string{"", "a", "0", "-", " ", "true", "null", "ü", "a.b/c", strings.Repeat("x", 253)}
+	edgeQuantities = []string{"0", "1", "-1", "100m", "1.5", "1Gi", "1e3", "128974848", "9223372036854775807"}
+	labelKeys      = []string{"app", "team", "example.com/owner", "kubernetes.io/name", "a"}
+	dates          = []string{"1970-01-01T00:00:00Z", "2024-02-29T12:00:00Z", "9999-12-31T23:59:59Z"}
+	durations      = []string{"0s", "1s", "1h30m", "-1m"}
+)
+
+// maxPatternAttempts bounds the random strings tried for a string with a pattern.
+const maxPatternAttempts = 32
+
+// generator generates random values conforming to schemas.
+type generator struct {
+	rand *rand.Rand
+	// focus are the names of the fields the expressions select, which are more likely to be set.
+	focus map[string]bool
+}
+
+func newGenerator(seed int64, focus map[string]bool) *generator {
+	return &generator{rand: rand.New(rand.NewPCG(uint64(seed), 0)), focus: focus}
+}
+
+// selectedField matches a field selection or a map index with a constant key in an expression.
+var selectedField = regexp.MustCompile(`\.\s*([A-Za-z_][A-Za-z0-9_]*)|\[\s*'([^']*)'\s*\]`)
+
+// selectedFields returns the names of the fields selected by the expressions. Fields of list elements are
+// selected through macro variables, so names are collected regardless of the path they are selected on.
+func selectedFields(expressions []string) map[string]bool {
+	fields := map[string]bool{}
+	for _, expression := range expressions {
+		for _, match := range selectedField.FindAllStringSubmatch(expression, -1) {
+			fields[match[1]+match[2]] = true
+		}
+	}
+	return fields
+}
+
+// chance reports true once in n times.
+func (g *generator) chance(n int) bool {
+	return g.rand.IntN(n) == 0
+}
+
+// value returns a random value of the schema, as unstructured JSON. Optional fields are less likely to be set
+// the deeper they are, so that objects stay small enough for their shape to matter, unless the expressions
+// select them.
+func (g *generator) value(s *Schema, depth int) any {
+	if s.Nullable && g.chance(8) {
+		return nil
+	}
+	if len(s.Enum) > 0 {
+		return s.Enum[g.rand.IntN(len(s.Enum))]
+	}
+	switch s.Type {
+	case TypeObject:
+		object := map[string]any{}
+		for _, p := range s.Properties {
+			if p.Required || (g.focus[p.Name] && !g.chance(4)) || g.chance(2+depth) {
+				object[p.Name] = g.value(p.Schema, depth+1)
+			}
+		}
+		return object
+	case TypeArray:
+		n := g.size(s)
+		array := make([]any, n)
+		for i := range array {
+			array[i] = g.value(s.Items, depth+1)
+		}
+		return array
+	case TypeMap:
+		n := g.size(s)
+		m := map[string]any{}
+		for i := 0; i < n; i++ {
+			m[g.key(s.Format, i)] = g.value(s.Items, depth+1)
+		}
+		return m
+	case TypeString:
+		return g.string(s)
+	case TypeInteger:
+		return g.integer(s)
+	case TypeNumber:
+		return g.number(s)
+	case TypeBoolean:
+		return g.chance(2)
+	case TypeQuantity:
+		return edgeQuantities[g.rand.IntN(len(edgeQuantities))]
+	case TypeIntOrString:
+		if g.chance(2) {
+			return int64(g.rand.IntN(101))
+		}
+		return []string{"0", "1", "50%", "100%", "http"}[g.rand.IntN(5)]
+	}
+	return g.any(depth)
+}
+
+// size returns the number of elements of an array or a map: mostly zero to three, sometimes many.
+func (g *generator) size(s *Schema) int {
+	n := g.rand.IntN(4)
+	if g.chance(16) {
+		n = 64
+	}
+	if s.MinItems != nil && int64(n) < *s.MinItems {
+		n = int(*s.MinItems)
+	}
+	if s.MaxItems != nil && int64(n) > *s.MaxItems {
+		n = int(*s.MaxItems)
+	}
+	return n
+}
+
+// key returns the i-th key of a map, distinct from the previous ones.
+func (g *generator) key(format string, i int) string {
+	if format == "label-key" {
+		key := labelKeys[g.rand.IntN(len(labelKeys))]
+		if i > 0 {
+			key = fmt.Sprintf("%s-%d", key, i)
+		}
+		return key
+	}
+	if g.chance(4) {
+		return fmt.Sprintf("key.%d", i)
+	}
+	return fmt.Sprintf("k%d", i)
+}
+
+func (g *generator) string(s *Schema) string {
+	for attempt := 0; ; attempt++ {
+		v := g.randomString(s)
+		if s.MinLength != nil && int64(len(v)) < *s.MinLength {
+			v += strings.Repeat("a", int(*s.MinLength)-len(v))
+		}
+		if s.MaxLength != nil && int64(len(v)) > *s.MaxLength {
+			v = v[:*s.MaxLength]
+		}
+		if s.Pattern == nil || s.Pattern.MatchString(v) || attempt == maxPatternAttempts {
+			return v
+		}
+	}
+}
+
+func (g *generator) randomString(s *Schema) string {
+	switch s.Format {
+	case "date-time":
+		return dates[g.rand.IntN(len(dates))]
+	case "duration":
+		return durations[g.rand.IntN(len(durations))]
+	case "byte":
+		return base64.StdEncoding.EncodeToString([]byte(edgeStrings[g.rand.IntN(len(edgeStrings))]))
+	case "label-value":
+		return fmt.Sprintf("x%d", g.rand.IntN(100))
+	case "label-key":
+		return labelKeys[g.rand.IntN(len(labelKeys))]
+	}
+	if g.chance(2) {
+		return edgeStrings[g.rand.IntN(len(edgeStrings))]
+	}
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789-."
+	b := make([]byte, 1+g.rand.IntN(12))
+	for i := range b {
+		b[i] = letters[g.rand.IntN(len(letters))]
+	}
+	return string(b)
+}
+
+func (g *generator) integer(s *Schema) int64 {
+	lo, hi := int64(math.MinInt64), int64(math.MaxInt64)
+	if s.Bits == 32 {
+		lo, hi = math.MinInt32, math.MaxInt32
+	}
+	if s.Minimum != nil && int64(*s.Minimum) > lo {
+		lo = int64(*s.Minimum)
+	}
+	if s.Maximum != nil && int64(*s.Maximum) < hi {
+		hi = int64(*s.Maximum)
+	}
+	var v int64
+	switch g.rand.IntN(6) {
+	case 0:
+		v = lo
+	case 1:
+		v = hi
+	case 2:
+		v = 0
+	case 3:
+		v = -1
+	default:
+		v = int64(g.rand.IntN(10))
+	}
+	return min(max(v, lo), hi)
+}
+
+func (g *generator) number(s *Schema) float64 {
+	v := []float64{0, 0.5, -1.5, 1, 1e300}[g.rand.IntN(5)]
+	if s.Minimum != nil {
+		v = max(v, *s.Minimum)
+	}
+	if s.Maximum != nil {
+		v = min(v, *s.Maximum)
+	}
+	return v
+}
+
+// any returns a random JSON value of any type.
+func (g *generator) any(depth int) any {
+	switch g.rand.IntN(6) {
+	case 0:
+		return nil
+	case 1:
+		return edgeStrings[g.rand.IntN(len(edgeStrings))]
+	case 2:
+		return int64(g.rand.IntN(3) - 1)
+	case 3:
+		return g.chance(2)
+	case 4:
+		if depth > 4 {
+			return []any{}
+		}
+		return []any{g.any(depth + 1)}
+	}
+	if depth > 4 {
+		return map[string]any{}
+	}
+	return map[string]any{"k": g.any(depth + 1)}
+}
@@ -0,0 +1,371 @@
+package fuzz
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+)
+
+// Type is the type of the values a schema describes.
+type Type string
+
+const (
+	TypeObject   Type = "object"
+	TypeArray    Type = "array"
+	TypeMap      Type = "map"
+	TypeString   Type = "string"
+	TypeInteger  Type = "integer"
+	TypeNumber   Type = "number"
+	TypeBoolean  Type = "boolean"
+	TypeQuantity Type = "quantity"
+	// TypeIntOrString is an integer or a string, as intstr.IntOrString or x-kubernetes-int-or-string.
+	TypeIntOrString Type = "int-or-string"
+	// TypeAny is any JSON value, as runtime.RawExtension or x-kubernetes-preserve-unknown-fields.
+	TypeAny Type = "any"
+)
+
+// Schema describes the values of a field, derived from a Go type of the scheme or from the OpenAPI v3 schema
+// of a CRD. It keeps only what random values and shrunk values must respect.
+type Schema struct {
+	Type       Type
+	Properties []Property
+	// Items is the schema of the elements of an array and of the values of a map.
+	Items *Schema
+	// Keyed reports that the order of the elements of an array does not matter, e.g. a list keyed by a
+	// patch merge key or of x-kubernetes-list-type map or set.
+	Keyed    bool
+	Enum     []any
+	Format   string
+	Pattern  *regexp.Regexp
+	Nullable bool
+	// Minimum and Maximum bound integers and numbers. Bits is the size of integers, 32 or 64.
+	Minimum, Maximum *float64
+	Bits             int
+	// MinLength and MaxLength bound strings, MinItems and MaxItems arrays and maps.
+	MinLength, MaxLength *int64
+	MinItems, MaxItems   *int64
+}
+
+// Property is a field of an object.
+type Property struct {
+	Name     string
+	Schema   *Schema
+	Required bool
+}
+
+// maxSchemaDepth bounds the depth of schemas derived from Go types, which may be recursive.
+const maxSchemaDepth = 16
+
+var (
+	quantityType    = reflect.TypeOf(resource.Quantity{})
+	intOrStringType = reflect.TypeOf(intstr.IntOrString{})
+	timeType        = reflect.TypeOf(metav1.Time{})
+	microTimeType   = reflect.TypeOf(metav1.MicroTime{})
+	durationType    = reflect.TypeOf(metav1.Duration{})
+	rawType         = reflect.TypeOf(runtime.RawExtension{})
+	objectMetaType  = reflect.TypeOf(metav1.ObjectMeta{})
+	bytesType       = reflect.TypeOf([]byte{})
+)
+
+// schemaOfType returns the schema of the JSON encoding of a Go type of the scheme. The status of objects is
+// left out, as the apiserver resets it before admission of most resources, and the metadata is limited to the
+// fields policies usually read.
+func schemaOfType(t reflect.Type) *Schema {
+	s := typeSchema(t, 0)
+	if s.Type == TypeObject {
+		properties := s.Properties[:0]
+		for _, p := range s.Properties {
+			if p.Name != "status" && p.Name != "apiVersion" && p.Name != "kind" {
+				properties = append(properties, p)
+			}
+		}
+		s.Properties = properties
+	}
+	return s
+}
+
+func typeSchema(t reflect.Type, depth int) *Schema {
+	if depth > maxSchemaDepth {
+		return &Schema{Type: TypeAny}
+	}
+	switch t {
+	case quantityType:
+		return &Schema{Type: TypeQuantity}
+	case intOrStringType:
+		return &Schema{Type: TypeIntOrString}
+	case timeType, microTimeType:
+		return &Schema{Type: TypeString, Format: "date-time"}
+	case durationType:
+		return &Schema{Type: TypeString, Format: "duration"}
+	case rawType:
+		return &Schema{Type: TypeAny}
+	case objectMetaType:
+		return metadataSchema()
+	case bytesType:
+		return &Schema{Type: TypeString, Format: "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := *typeSchema(t.Elem(), depth)
+		s.Nullable = true
+		return &s
+	case reflect.Struct:
+		s := &Schema{Type: TypeObject}
+		addFields(s, t, depth)
+		return s
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: TypeArray, Items: typeSchema(t.Elem(), depth+1)}
+	case reflect.Map:
+		return &Schema{Type: TypeMap, Items: typeSchema(t.Elem(), depth+1)}
+	case reflect.String:
+		return &Schema{Type: TypeString}
+	case reflect.Bool:
+		return &Schema{Type: TypeBoolean}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: TypeInteger, Bits: 32}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: TypeInteger, Bits: 64}
+	case reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: TypeInteger, Bits: 32, Minimum: ptr.To(0.0)}
+	case reflect.Uint32, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: TypeInteger, Bits: 64, Minimum: ptr.To(0.0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: TypeNumber}
+	}
+	return &Schema{Type: TypeAny}
+}
+
+// addFields adds the JSON fields of the struct type, including those of inlined structs. Fields without
+// omitempty are required: the Go types of the API mark optional fields with omitempty, and the encoding of
+// objects always has the others.
+func addFields(s *Schema, t reflect.Type, depth int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(options, "inline") || (field.Anonymous && name == "") {
+			if field.Type.Kind() == reflect.Struct {
+				addFields(s, field.Type, depth)
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldSchema := typeSchema(field.Type, depth+1)
+		if fieldSchema.Type == TypeArray && field.Tag.Get("patchMergeKey") != "" {
+			keyed := *fieldSchema
+			keyed.Keyed = true
+			fieldSchema = &keyed
+		}
+		required := !slices.Contains(strings.Split(options, ","), "omitempty")
+		s.Properties = append(s.Properties, Property{Name: name, Schema: fieldSchema, Required: required})
+	}
+}
+
+// metadataSchema returns the schema of the metadata of generated objects. The name and namespace are set by the
+// fuzzer, and the fields managed by the apiserver are left out.
+func metadataSchema() *Schema {
+	labels := &Schema{Type: TypeMap, Items: &Schema{Type: TypeString, Format: "label-value"}, Format: "label-key"}
+	return &Schema{Type: TypeObject, Properties: []Property{
+		{Name: "labels", Schema: labels},
+		{Name: "annotations", Schema: &Schema{Type: TypeMap, Items: &Schema{Type: TypeString}, Format: "label-key"}},
+		{Name: "finalizers", Schema: &Schema{Type: TypeArray, Items: &Schema{Type: TypeString, Format: "label-key"}}},
+	}}
+}
+
+// schemaOfOpenAPI returns the schema of an OpenAPI v3 schema of a CRD, as unstructured JSON. The metadata is
+// replaced by that of other kinds, as the apiserver ignores the metadata schema of CRDs beyond the name.
+func schemaOfOpenAPI(openAPI map[string]any) *Schema {
+	s := openAPISchema(openAPI, 0)
+	if s.Type == TypeObject {
+		properties := s.Properties[:0]
+		for _, p := range s.Properties {
+			if p.Name != "status" && p.Name != "apiVersion" && p.Name != "kind" && p.Name != "metadata" {
+				properties = append(properties, p)
+			}
+		}
+		s.Properties = append([]Property{{Name: "metadata", Schema: metadataSchema()}}, properties...)
+	}
+	return s
+}
+
+func openAPISchema(o map[string]any, depth int) *Schema {
+	s := &Schema{Type: Type(stringField(o, "type")), Format: stringField(o, "format")}
+	if depth > maxSchemaDepth {
+		return &Schema{Type: TypeAny}
+	}
+	if b, _ := o["x-kubernetes-int-or-string"].(bool); b {
+		s.Type = TypeIntOrString
+	}
+	if b, _ := o["x-kubernetes-preserve-unknown-fields"].(bool); b && s.Type == "" {
+		s.Type = TypeAny
+	}
+	s.Nullable, _ = o["nullable"].(bool)
+	if enum, ok := o["enum"].([]any); ok {
+		s.Enum = enum
+	}
+	if pattern, ok := o["pattern"].(string); ok {
+		s.Pattern, _ = regexp.Compile(pattern)
+	}
+	s.Minimum, s.Maximum = numberField(o, "minimum"), numberField(o, "maximum")
+	s.MinLength, s.MaxLength = intField(o, "minLength"), intField(o, "maxLength")
+	s.MinItems, s.MaxItems = intField(o, "minItems"), intField(o, "maxItems")
+	if s.MinItems == nil {
+		s.MinItems = intField(o, "minProperties")
+	}
+	if s.MaxItems == nil {
+		s.MaxItems = intField(o, "maxProperties")
+	}
+	if s.Format == "int32" {
+		s.Bits = 32
+	}
+
+	switch s.Type {
+	case "object":
+		properties, _ := o["properties"].(map[string]any)
+		if additional, ok := o["additionalProperties"].(map[string]any); ok && len(properties) == 0 {
+			s.Type = TypeMap
+			s.Items = openAPISchema(additional, depth+1)
+			break
+		}
+		if len(properties) == 0 {
+			if b, _ := o["x-kubernetes-preserve-unknown-fields"].(bool); b {
+				s.Type = TypeAny
+			}
+		}
+		required := map[string]bool{}
+		if names, ok := o["required"].([]any); ok {
+			for _, name := range names {
+				if n, ok := name.(string); ok {
+					required[n] = true
+				}
+			}
+		}
+		for _, name := range sortedKeys(properties) {
+			property, _ := properties[name].(map[string]any)
+			s.Properties = append(s.Properties, Property{Name: name, Schema: openAPISchema(property, depth+1), Required: required[name]})
+		}
+	case "array":
+		items, _ := o["items"].(map[string]any)
+		s.Items = openAPISchema(items, depth+1)
+		switch stringField(o, "x-kubernetes-list-type") {
+		case "map", "set":
+			s.Keyed = true
+		}
+	case "string", "integer", "number", "boolean", TypeIntOrString, TypeAny:
+	default:
+		s.Type = TypeAny
+	}
+	return s
+}
+
+// Kind is a kind of objects to generate.
+type Kind struct {
+	GVK schema.GroupVersionKind
+	// Namespaced reports whether the objects are namespaced.
+	Namespaced bool
+	Schema     *Schema
+	// Typed reports whether the kind is a Go type of the scheme, whose objects are encoded through the type.
+	Typed bool
+}
+
+// crdKinds returns the kinds of the served versions of the CustomResourceDefinitions with a schema, and their
+// resource mappings.
+func crdKinds(crds []runtime.Object) ([]Kind, []mapping, error) {
+	var kinds []Kind
+	var mappings []mapping
+	for _, obj := range crds {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		crd := &unstructured.Unstructured{Object: content}
+		if crd.GetKind() != "CustomResourceDefinition" {
+			return nil, nil, fmt.Errorf("%s %s is not a CustomResourceDefinition", crd.GetKind(), crd.GetName())
+		}
+		group, _, _ := unstructured.NestedString(content, "spec", "group")
+		kind, _, _ := unstructured.NestedString(content, "spec", "names", "kind")
+		plural, _, _ := unstructured.NestedString(content, "spec", "names", "plural")
+		scope, _, _ := unstructured.NestedString(content, "spec", "scope")
+		versions, _, _ := unstructured.NestedSlice(content, "spec", "versions")
+		for _, v := range versions {
+			version, _ := v.(map[string]any)
+			if served, _ := version["served"].(bool); !served {
+				continue
+			}
+			name := stringField(version, "name")
+			gvk := schema.GroupVersionKind{Group: group, Version: name, Kind: kind}
+			mappings = append(mappings, mapping{gvk: gvk, resource: schema.GroupVersionResource{Group: group, Version: name, Resource: plural}, namespaced: scope != "Cluster"})
+			openAPI, found, _ := unstructured.NestedMap(version, "schema", "openAPIV3Schema")
+			if !found {
+				continue
+			}
+			kinds = append(kinds, Kind{GVK: gvk, Namespaced: scope != "Cluster", Schema: schemaOfOpenAPI(openAPI)})
+		}
+	}
+	return kinds, mappings, nil
+}
+
+// mapping is the resource of a kind defined by a CRD.
+type mapping struct {
+	gvk        schema.GroupVersionKind
+	resource   schema.GroupVersionResource
+	namespaced bool
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func stringField(o map[string]any, key string) string {
+	s, _ := o[key].(string)
+	return s
+}
+
+func numberField(o map[string]any, key string) *float64 {
+	switch v := o[key].(type) {
+	case int64:
+		f := float64(v)
+		return &f
+	case float64:
+		return &v
+	case json.Number:
+		f, err := v.Float64()
+		if err == nil {
+			return &f
+		}
+	}
+	return nil
+}
+
+func intField(o map[string]any, key string) *int64 {
+	if f := numberField(o, key); f != nil {
+		i := int64(*f)
+		return &i
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package fuzz
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+)
+
+// propertyOf returns the schema of the property at the path, descending into the items of arrays and maps.
+func propertyOf(t *testing.T, s *Schema, path ...string) *Schema {
+	t.Helper()
+	for _, name := range path {
+		for s.Items != nil {
+			s = s.Items
+		}
+		var next *Schema
+		for _, p := range s.Properties {
+			if p.Name == name {
+				next = p.Schema
+			}
+		}
+		require.NotNil(t, next, "property %s of %v", name, path)
+		s = next
+	}
+	return s
+}
+
+func names(s *Schema) []string {
+	var names []string
+	for _, p := range s.Properties {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func TestSchemaOfType(t *testing.T) {
+	s := schemaOfType(reflect.TypeOf(appsv1.Deployment{}))
+
+	assert.Equal(t, []string{"metadata", "spec"}, names(s), "statusとTypeMetaは生成しない")
+	assert.Equal(t, []string{"labels", "annotations", "finalizers"}, names(propertyOf(t, s, "metadata")))
+
+	replicas := propertyOf(t, s, "spec", "replicas")
+	assert.Equal(t, TypeInteger, replicas.Type)
+	assert.Equal(t, 32, replicas.Bits)
+	assert.True(t, replicas.Nullable, "ポインタのフィールドはnullを取れる")
+
+	containers := propertyOf(t, s, "spec", "template", "spec", "containers")
+	assert.Equal(t, TypeArray, containers.Type)
+	assert.True(t, containers.Keyed, "patchMergeKeyのあるリストは順序に意味がない")
+	assert.False(t, propertyOf(t, s, "spec", "template", "spec", "containers", "args").Keyed)
+
+	assert.Equal(t, TypeQuantity, propertyOf(t, s, "spec", "template", "spec", "containers", "resources", "limits").Items.Type)
+	assert.Equal(t, TypeIntOrString, propertyOf(t, s, "spec", "strategy", "rollingUpdate", "maxSurge").Type)
+	assert.Equal(t, TypeMap, propertyOf(t, s, "spec", "selector", "matchLabels").Type)
+
+	// omitemptyのないフィールドは必須とする
+	required := func(s *Schema) map[string]bool {
+		r := map[string]bool{}
+		for _, p := range s.Properties {
+			r[p.Name] = p.Required
+		}
+		return r
+	}
+	spec := propertyOf(t, s, "spec")
+	assert.True(t, required(spec)["selector"])
+	assert.True(t, required(spec)["template"])
+	assert.False(t, required(spec)["replicas"])
+	container := propertyOf(t, s, "spec", "template", "spec", "containers")
+	assert.True(t, required(container.Items)["name"])
+	assert.False(t, required(container.Items)["image"])
+
+	for _, simplified := range simplifications(spec, map[string]any{"selector": map[string]any{}, "replicas": int64(3)}) {
+		assert.Contains(t, simplified, "selector", "必須のフィールドは削除しない")
+	}
+}
+
+func TestCRDKinds(t *testing.T) {
+	crds := loadObjects(t, "testdata/crd.yaml")
+	kinds, mappings, err := crdKinds(crds)
+	require.NoError(t, err)
+
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	require.Len(t, kinds, 1, "提供されないバージョンは生成しない")
+	assert.Equal(t, gvk, kinds[0].GVK)
+	assert.True(t, kinds[0].Namespaced)
+	assert.False(t, kinds[0].Typed)
+	assert.Equal(t, []mapping{{gvk: gvk, resource: gvk.GroupVersion().WithResource("widgets"), namespaced: true}}, mappings)
+
+	s := kinds[0].Schema
+	assert.Equal(t, []string{"metadata", "spec"}, names(s), "statusは生成せず、metadataは組み込みの種類と同じにする")
+	assert.Equal(t, []Property{
+		{Name: "mode", Schema: &Schema{Type: TypeString, Enum: []any{"fast", "safe"}}},
+		{Name: "parts", Schema: propertyOf(t, s, "spec", "parts")},
+		{Name: "size", Schema: &Schema{Type: TypeInteger, Minimum: ptr.To(1.0), Maximum: ptr.To(10.0)}, Required: true},
+		{Name: "tags", Schema: &Schema{Type: TypeMap, Items: &Schema{Type: TypeString}}},
+	}, propertyOf(t, s, "spec").Properties)
+
+	parts := propertyOf(t, s, "spec", "parts")
+	assert.True(t, parts.Keyed)
+	assert.Equal(t, "^[a-z]+$", propertyOf(t, s, "spec", "parts", "name").Pattern.String())
+
+	_, _, err = crdKinds([]runtime.Object{crds[0], loadObjects(t, "testdata/widget-policy.yaml")[0]})
+	assert.ErrorContains(t, err, "ValidatingAdmissionPolicy widget-rules is not a CustomResourceDefinition")
+}
@@ -0,0 +1,258 @@
+package fuzz
+
+import (
+	"slices"
+	"sort"
+)
+
+// step is a value one edit simpler than another, with the depth of the edited field.
+type step struct {
+	depth int
+	value any
+}
+
+// simplifications returns the values one edit simpler than the value of the schema, edits of shallower fields
+// first: removing an optional field, all elements, half of them or one, or replacing a scalar with its zero value, or a
+// number with its minimum when zero is out of bounds. Every value
+// still conforms to the schema. Unchanged parts are shared with the value, which must not be modified.
+func simplifications(s *Schema, v any) []any {
+	steps := simplify(s, v, 0)
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].depth < steps[j].depth })
+	values := make([]any, len(steps))
+	for i, st := range steps {
+		values[i] = st.value
+	}
+	return values
+}
+
+func simplify(s *Schema, v any, depth int) []step {
+	if v == nil {
+		return nil
+	}
+	if len(s.Enum) > 0 {
+		if v != s.Enum[0] {
+			return []step{{depth, s.Enum[0]}}
+		}
+		return nil
+	}
+	var steps []step
+	switch s.Type {
+	case TypeObject:
+		object, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		for _, p := range s.Properties {
+			if _, ok := object[p.Name]; ok && !p.Required {
+				steps = append(steps, step{depth, without(object, p.Name)})
+			}
+		}
+		for _, p := range s.Properties {
+			field, ok := object[p.Name]
+			if !ok {
+				continue
+			}
+			for _, st := range simplify(p.Schema, field, depth+1) {
+				steps = append(steps, step{st.depth, with(object, p.Name, st.value)})
+			}
+		}
+	case TypeArray:
+		array, ok := v.([]any)
+		if !ok {
+			return nil
+		}
+		if s.MinItems == nil || int64(len(array)) > *s.MinItems {
+			if len(array) > 1 && (s.MinItems == nil || *s.MinItems == 0) {
+				steps = append(steps, step{depth, []any{}})
+			}
+			for _, r := range removals(len(array), s.MinItems) {
+				steps = append(steps, step{depth, slices.Delete(slices.Clone(array), r[0], r[1])})
+			}
+		}
+		for i, element := range array {
+			for _, st := range simplify(s.Items, element, depth+1) {
+				edited := slices.Clone(array)
+				edited[i] = st.value
+				steps = append(steps, step{st.depth, edited})
+			}
+		}
+	case TypeMap:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		keys := sortedKeys(m)
+		if s.MinItems == nil || int64(len(m)) > *s.MinItems {
+			if len(m) > 1 && (s.MinItems == nil || *s.MinItems == 0) {
+				steps = append(steps, step{depth, map[string]any{}})
+			}
+			for _, r := range removals(len(keys), s.MinItems) {
+				steps = append(steps, step{depth, only(m, slices.Delete(slices.Clone(keys), r[0], r[1]))})
+			}
+		}
+		for _, key := range keys {
+			for _, st := range simplify(s.Items, m[key], depth+1) {
+				steps = append(steps, step{st.depth, with(m, key, st.value)})
+			}
+		}
+	case TypeString:
+		if v != "" && conforms(s, "") {
+			steps = append(steps, step{depth, ""})
+		}
+	case TypeInteger, TypeNumber:
+		switch {
+		case conforms(s, 0.0):
+			if !isZero(v) {
+				steps = append(steps, step{depth, int64(0)})
+			}
+		case s.Minimum != nil && v != any(int64(*s.Minimum)) && v != any(*s.Minimum):
+			steps = append(steps, step{depth, int64(*s.Minimum)})
+		}
+	case TypeBoolean:
+		if v == true {
+			steps = append(steps, step{depth, false})
+		}
+	case TypeQuantity:
+		if v != "0" {
+			steps = append(steps, step{depth, "0"})
+		}
+	case TypeIntOrString:
+		if !isZero(v) {
+			steps = append(steps, step{depth, int64(0)})
+		}
+	case TypeAny:
+		steps = append(steps, step{depth, nil})
+	}
+	return steps
+}
+
+// removals returns the ranges of elements to remove from a list of n elements keeping at least minItems: halves,
+// then quarters and so on down to single elements, so that long lists shrink in few edits.
+func removals(n int, minItems *int64) [][2]int {
+	var ranges [][2]int
+	for size := n / 2; size >= 1; size /= 2 {
+		if minItems != nil && int64(n-size) < *minItems {
+			continue
+		}
+		for start := 0; start < n; start += size {
+			ranges = append(ranges, [2]int{start, min(start+size, n)})
+		}
+		if size == 1 {
+			break
+		}
+	}
+	return ranges
+}
+
+// conforms reports whether the string or number conforms to the bounds of the schema.
+func conforms(s *Schema, v any) bool {
+	switch v := v.(type) {
+	case string:
+		if s.MinLength != nil && int64(len(v)) < *s.MinLength {
+			return false
+		}
+		if s.Pattern != nil && !s.Pattern.MatchString(v) {
+			return false
+		}
+		return s.Format == ""
+	case float64:
+		return (s.Minimum == nil || v >= *s.Minimum) && (s.Maximum == nil || v <= *s.Maximum)
+	}
+	return false
+}
+
+func isZero(v any) bool {
+	switch v := v.(type) {
+	case int64:
+		return v == 0
+	case float64:
+		return v == 0
+	}
+	return false
+}
+
+// reorder returns the value with the elements of the lists whose order does not matter reversed, and whether
+// any list was changed. The value is semantically equal to the original one.
+func reorder(s *Schema, v any) (any, bool) {
+	switch s.Type {
+	case TypeObject:
+		object, ok := v.(map[string]any)
+		if !ok {
+			return v, false
+		}
+		changed := false
+		for _, p := range s.Properties {
+			field, ok := object[p.Name]
+			if !ok {
+				continue
+			}
+			if reordered, ok := reorder(p.Schema, field); ok {
+				object = with(object, p.Name, reordered)
+				changed = true
+			}
+		}
+		return object, changed
+	case TypeArray:
+		array, ok := v.([]any)
+		if !ok {
+			return v, false
+		}
+		changed := false
+		edited := slices.Clone(array)
+		for i, element := range array {
+			if reordered, ok := reorder(s.Items, element); ok {
+				edited[i] = reordered
+				changed = true
+			}
+		}
+		if s.Keyed && len(edited) > 1 {
+			slices.Reverse(edited)
+			changed = true
+		}
+		return edited, changed
+	case TypeMap:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return v, false
+		}
+		changed := false
+		for _, key := range sortedKeys(m) {
+			if reordered, ok := reorder(s.Items, m[key]); ok {
+				m = with(m, key, reordered)
+				changed = true
+			}
+		}
+		return m, changed
+	}
+	return v, false
+}
+
+// with returns a shallow copy of the map with the key set to the value.
+func with(m map[string]any, key string, value any) map[string]any {
+	copied := make(map[string]any, len(m)+1)
+	for k, v := range m {
+		copied[k] = v
+	}
+	copied[key] = value
+	return copied
+}
+
+// without returns a shallow copy of the map without the key.
+func without(m map[string]any, key string) map[string]any {
+	copied := make(map[string]any, len(m))
+	for k, v := range m {
+		if k != key {
+			copied[k] = v
+		}
+	}
+	return copied
+}
+
+// only returns a copy of the map with only the keys.
+func only(m map[string]any, keys []string) map[string]any {
+	copied := make(map[string]any, len(keys))
+	for _, key := range keys {
+		copied[key] = m[key]
+	}
+	return copied
+}
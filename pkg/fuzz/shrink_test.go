@@ -0,0 +1,110 @@
+package fuzz
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/ptr"
+)
+
+func TestSimplifications(t *testing.T) {
+	s := &Schema{Type: TypeObject, Properties: []Property{
+		{Name: "name", Schema: &Schema{Type: TypeString, Pattern: regexp.MustCompile("^[a-z]+$")}, Required: true},
+		{Name: "replicas", Schema: &Schema{Type: TypeInteger, Minimum: ptr.To(1.0)}},
+		{Name: "ports", Schema: &Schema{Type: TypeArray, Items: &Schema{Type: TypeInteger}, MinItems: ptr.To[int64](1)}},
+		{Name: "labels", Schema: &Schema{Type: TypeMap, Items: &Schema{Type: TypeString}}},
+		{Name: "paused", Schema: &Schema{Type: TypeBoolean}},
+	}}
+	v := map[string]any{
+		"name":     "web",
+		"replicas": int64(3),
+		"ports":    []any{int64(80), int64(0)},
+		"labels":   map[string]any{"a": "x", "b": ""},
+		"paused":   true,
+	}
+
+	assert.Equal(t, []any{
+		// 浅いフィールドの削除から試す
+		map[string]any{"name": "web", "ports": []any{int64(80), int64(0)}, "labels": map[string]any{"a": "x", "b": ""}, "paused": true},
+		map[string]any{"name": "web", "replicas": int64(3), "labels": map[string]any{"a": "x", "b": ""}, "paused": true},
+		map[string]any{"name": "web", "replicas": int64(3), "ports": []any{int64(80), int64(0)}, "paused": true},
+		map[string]any{"name": "web", "replicas": int64(3), "ports": []any{int64(80), int64(0)}, "labels": map[string]any{"a": "x", "b": ""}},
+		// パターンに合わない空文字列にはせず、0が最小値未満なら最小値にする。要素は最小数まで減らす
+		map[string]any{"name": "web", "replicas": int64(1), "ports": []any{int64(80), int64(0)}, "labels": map[string]any{"a": "x", "b": ""}, "paused": true},
+		map[string]any{"name": "web", "replicas": int64(3), "ports": []any{int64(0)}, "labels": map[string]any{"a": "x", "b": ""}, "paused": true},
+		map[string]any{"name": "web", "replicas": int64(3), "ports": []any{int64(80)}, "labels": map[string]any{"a": "x", "b": ""}, "paused": true},
+		map[string]any{"name": "web", "replicas": int64(3), "ports": []any{int64(80), int64(0)}, "labels": map[string]any{}, "paused": true},
+		map[string]any{"name": "web", "replicas": int64(3), "ports": []any{int64(80), int64(0)}, "labels": map[string]any{"b": ""}, "paused": true},
+		map[string]any{"name": "web", "replicas": int64(3), "ports": []any{int64(80), int64(0)}, "labels": map[string]any{"a": "x"}, "paused": true},
+		map[string]any{"name": "web", "replicas": int64(3), "ports": []any{int64(80), int64(0)}, "labels": map[string]any{"a": "x", "b": ""}, "paused": false},
+		// 要素の値の単純化は一段深い
+		map[string]any{"name": "web", "replicas": int64(3), "ports": []any{int64(0), int64(0)}, "labels": map[string]any{"a": "x", "b": ""}, "paused": true},
+		map[string]any{"name": "web", "replicas": int64(3), "ports": []any{int64(80), int64(0)}, "labels": map[string]any{"a": "", "b": ""}, "paused": true},
+	}, simplifications(s, v))
+	assert.Equal(t, "web", v["name"], "元の値は変更しない")
+	assert.Len(t, v["ports"], 2)
+}
+
+func TestRemovals(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        int
+		minItems *int64
+		want     [][2]int
+	}{
+		{
+			name: "半分、4分の1、1要素の順に削除する",
+			n:    4,
+			want: [][2]int{{0, 2}, {2, 4}, {0, 1}, {1, 2}, {2, 3}, {3, 4}},
+		},
+		{
+			name: "端数は最後の範囲に含める",
+			n:    5,
+			want: [][2]int{{0, 2}, {2, 4}, {4, 5}, {0, 1}, {1, 2}, {2, 3}, {3, 4}, {4, 5}},
+		},
+		{
+			name:     "最小要素数を下回る削除はしない",
+			n:        4,
+			minItems: ptr.To[int64](3),
+			want:     [][2]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}},
+		},
+		{
+			name: "空のリスト",
+			n:    0,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, removals(tt.n, tt.minItems))
+		})
+	}
+}
+
+func TestReorder(t *testing.T) {
+	container := &Schema{Type: TypeObject, Properties: []Property{
+		{Name: "name", Schema: &Schema{Type: TypeString}},
+		{Name: "args", Schema: &Schema{Type: TypeArray, Items: &Schema{Type: TypeString}}},
+		{Name: "env", Schema: &Schema{Type: TypeArray, Items: &Schema{Type: TypeString}, Keyed: true}},
+	}}
+	s := &Schema{Type: TypeObject, Properties: []Property{
+		{Name: "containers", Schema: &Schema{Type: TypeArray, Items: container, Keyed: true}},
+	}}
+
+	v := map[string]any{"containers": []any{
+		map[string]any{"name": "a", "args": []any{"1", "2"}, "env": []any{"X", "Y"}},
+		map[string]any{"name": "b"},
+	}}
+	got, changed := reorder(s, v)
+	assert.True(t, changed)
+	assert.Equal(t, map[string]any{"containers": []any{
+		map[string]any{"name": "b"},
+		// 順序に意味のあるargsはそのまま
+		map[string]any{"name": "a", "args": []any{"1", "2"}, "env": []any{"Y", "X"}},
+	}}, got)
+	assert.Equal(t, "a", v["containers"].([]any)[0].(map[string]any)["name"], "元の値は変更しない")
+
+	_, changed = reorder(s, map[string]any{"containers": []any{map[string]any{"name": "a", "args": []any{"1", "2"}}}})
+	assert.False(t, changed, "要素が一つなら並べ替えられない")
+}
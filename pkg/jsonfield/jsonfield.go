@@ -0,0 +1,41 @@
+// Package jsonfield looks up the fields of Go API types by their JSON names, as the fields of objects are named in
+// manifests and CEL expressions.
+package jsonfield
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Field returns the field of the struct with the JSON name, including the fields of inlined structs.
+func Field(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		if tag[0] == name {
+			return f, true
+		}
+		if f.Anonymous && tag[0] == "" && f.Type.Kind() == reflect.Struct {
+			if inlined, ok := Field(f.Type, name); ok {
+				return inlined, true
+			}
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// Names returns the JSON names of the fields of the struct, including the fields of inlined structs.
+func Names(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		switch {
+		case f.Anonymous && tag[0] == "" && f.Type.Kind() == reflect.Struct:
+			names = append(names, Names(f.Type)...)
+		case tag[0] != "" && tag[0] != "-":
+			names = append(names, tag[0])
+		}
+	}
+	return names
+}
@@ -0,0 +1,37 @@
+package jsonfield
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestField(t *testing.T) {
+	deployment := reflect.TypeFor[appsv1.Deployment]()
+
+	testCases := []struct {
+		name       string
+		field      string
+		expected   string
+		expectedOK bool
+	}{
+		{name: "JSON名でフィールドを探す", field: "spec", expected: "Spec", expectedOK: true},
+		{name: "インライン化された構造体のフィールドを探す", field: "kind", expected: "Kind", expectedOK: true},
+		{name: "Goのフィールド名では見つからない", field: "Spec"},
+		{name: "存在しないフィールド", field: "replicas"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, ok := Field(deployment, tc.field)
+			assert.Equal(t, tc.expectedOK, ok)
+			assert.Equal(t, tc.expected, f.Name)
+		})
+	}
+}
+
+func TestNames(t *testing.T) {
+	assert.Equal(t, []string{"kind", "apiVersion", "metadata", "spec", "status"}, Names(reflect.TypeFor[appsv1.Deployment]()))
+}
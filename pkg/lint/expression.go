@@ -0,0 +1,332 @@
+package lint
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+	"github.com/google/cel-go/parser"
+	"k8s.io/apiserver/pkg/cel/environment"
+)
+
+// inputs are the variables of policy expressions. They are unknown to the linter, so an expression whose value
+// does not depend on them is constant.
+var inputs = []string{"object", "oldObject", "params", "request", "namespaceObject", "variables", "authorizer"}
+
+// env is the CEL environment of the apiserver, with the Kubernetes libraries. Expressions are parsed but not
+// type-checked, as the linter does not know the types of the inputs.
+var env = sync.OnceValue(func() *cel.Env {
+	return environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion(), false).NewExpressionsEnv()
+})
+
+// parse parses the expression, returning false if it does not parse.
+func parse(expression string) (*cel.Ast, bool) {
+	parsed, issues := env().Parse(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, false
+	}
+	return parsed, true
+}
+
+// referencedVariables returns the names of the variables the expression references as variables.<name>.
+func referencedVariables(expression string) []string {
+	parsed, ok := parse(expression)
+	if !ok {
+		return nil
+	}
+	var names []string
+	ast.PostOrderVisit(parsed.NativeRep().Expr(), ast.NewExprVisitor(func(e ast.Expr) {
+		if e.Kind() != ast.SelectKind {
+			return
+		}
+		operand := e.AsSelect().Operand()
+		if operand.Kind() == ast.IdentKind && operand.AsIdent() == "variables" {
+			names = append(names, e.AsSelect().FieldName())
+		}
+	}))
+	return names
+}
+
+// normalize returns the expression formatted from its AST, so that expressions differing only in spacing,
+// parentheses and comments are equal.
+func normalize(expression string) (string, bool) {
+	parsed, ok := parse(expression)
+	if !ok {
+		return "", false
+	}
+	native := parsed.NativeRep()
+	normalized, err := parser.Unparse(native.Expr(), native.SourceInfo())
+	if err != nil {
+		return "", false
+	}
+	return normalized, true
+}
+
+// constantValue returns the value of the expression if it is the same for all requests: the expression does not
+// depend on the inputs once partially evaluated, e.g. 1 < 2 or object.x || true, or it compares an expression
+// with itself, e.g. object.x == object.x.
+func constantValue(expression string) (bool, bool) {
+	parsed, ok := parse(expression)
+	if !ok {
+		return false, false
+	}
+	if value, ok := partialValue(parsed); ok {
+		return value, true
+	}
+	native := parsed.NativeRep()
+	return tautology(native.Expr(), native.SourceInfo())
+}
+
+// partialValue evaluates the expression with all inputs unknown, returning its value if it is a known bool.
+func partialValue(parsed *cel.Ast) (bool, bool) {
+	program, err := env().Program(parsed, cel.EvalOptions(cel.OptPartialEval))
+	if err != nil {
+		return false, false
+	}
+	patterns := make([]*interpreter.AttributePattern, len(inputs))
+	for i, input := range inputs {
+		patterns[i] = cel.AttributePattern(input)
+	}
+	vars, err := cel.PartialVars(map[string]any{}, patterns...)
+	if err != nil {
+		return false, false
+	}
+	out, _, err := program.Eval(vars)
+	if err != nil || types.IsUnknown(out) {
+		return false, false
+	}
+	value, ok := out.Value().(bool)
+	return value, ok
+}
+
+// selfComparisons are the values of comparisons of an expression with itself.
+var selfComparisons = map[string]bool{
+	operators.Equals:        true,
+	operators.LessEquals:    true,
+	operators.GreaterEquals: true,
+	operators.NotEquals:     false,
+	operators.Less:          false,
+	operators.Greater:       false,
+}
+
+// tautology returns the value of a logical expression that is the same whatever the value of its operands:
+// comparisons of an expression with itself, and conjunctions and disjunctions of an expression and its negation.
+func tautology(e ast.Expr, info *ast.SourceInfo) (bool, bool) {
+	if e.Kind() != ast.CallKind {
+		return false, false
+	}
+	call := e.AsCall()
+	args := call.Args()
+	if value, ok := selfComparisons[call.FunctionName()]; ok && len(args) == 2 {
+		if same(args[0], args[1], info) {
+			return value, true
+		}
+		return false, false
+	}
+	switch call.FunctionName() {
+	case operators.LogicalNot:
+		if value, ok := tautology(args[0], info); ok {
+			return !value, true
+		}
+	case operators.LogicalAnd, operators.LogicalOr:
+		or := call.FunctionName() == operators.LogicalOr
+		left, leftOK := tautology(args[0], info)
+		right, rightOK := tautology(args[1], info)
+		switch {
+		case leftOK && left == or, rightOK && right == or:
+			return or, true
+		case leftOK && rightOK:
+			return left, true
+		case negates(args[0], args[1], info) || negates(args[1], args[0], info):
+			return or, true
+		}
+	}
+	return false, false
+}
+
+// same reports whether the expressions are written the same.
+func same(a, b ast.Expr, info *ast.SourceInfo) bool {
+	x, err := parser.Unparse(a, info)
+	if err != nil {
+		return false
+	}
+	y, err := parser.Unparse(b, info)
+	return err == nil && x == y
+}
+
+// negates reports whether a is the negation of b, e.g. !has(object.x) and has(object.x).
+func negates(a, b ast.Expr, info *ast.SourceInfo) bool {
+	if a.Kind() != ast.CallKind || a.AsCall().FunctionName() != operators.LogicalNot {
+		return false
+	}
+	return same(a.AsCall().Args()[0], b, info)
+}
+
+// accessPath is a field of the object, as the names of the fields and map keys selected from it, starting with
+// object, e.g. [object metadata labels app].
+type accessPath []string
+
+var identifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// String formats the path as CEL, selecting keys that are not identifiers with an index, e.g.
+// object.metadata.labels['app.kubernetes.io/name'].
+func (p accessPath) String() string {
+	var b strings.Builder
+	for i, segment := range p {
+		switch {
+		case i == 0:
+			b.WriteString(segment)
+		case identifier.MatchString(segment):
+			b.WriteString("." + segment)
+		default:
+			b.WriteString("['" + strings.ReplaceAll(segment, "'", `\'`) + "']")
+		}
+	}
+	return b.String()
+}
+
+// covers reports whether the path is the prefix of the other path, or equal to it.
+func (p accessPath) covers(other accessPath) bool {
+	if len(p) > len(other) {
+		return false
+	}
+	for i := range p {
+		if p[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// accesses are the fields of the object an expression accesses, and those it tests the presence of with has()
+// or the in operator.
+type accesses struct {
+	paths  []accessPath
+	guards []accessPath
+}
+
+// objectAccesses returns the fields of the object the expression accesses and tests. Only the longest path of a
+// chain of selections is returned, e.g. object.spec.replicas and not object.spec. Selections with the optional
+// syntax, e.g. object.?spec, need no guard and end the chain.
+func objectAccesses(expression string) accesses {
+	var result accesses
+	parsed, ok := parse(expression)
+	if !ok {
+		return result
+	}
+	var walk func(e ast.Expr)
+	walk = func(e ast.Expr) {
+		switch e.Kind() {
+		case ast.SelectKind:
+			sel := e.AsSelect()
+			if sel.IsTestOnly() {
+				if operand, ok := objectPath(sel.Operand()); ok {
+					// has() は最後のフィールドだけを調べるので、それまでのフィールドは存在する必要がある
+					result.paths = append(result.paths, operand)
+					result.guards = append(result.guards, append(slices.Clone(operand), sel.FieldName()))
+					return
+				}
+			} else if path, ok := objectPath(e); ok {
+				result.paths = append(result.paths, path)
+				return
+			}
+		case ast.CallKind:
+			call := e.AsCall()
+			args := call.Args()
+			switch call.FunctionName() {
+			case operators.Index:
+				if path, ok := objectPath(e); ok {
+					result.paths = append(result.paths, path)
+					return
+				}
+			case operators.In:
+				if key, ok := stringLiteral(args[0]); ok {
+					if path, ok := objectPath(args[1]); ok {
+						result.paths = append(result.paths, path)
+						result.guards = append(result.guards, append(slices.Clone(path), key))
+						return
+					}
+				}
+			}
+		}
+		for _, child := range children(e) {
+			walk(child)
+		}
+	}
+	walk(parsed.NativeRep().Expr())
+	return result
+}
+
+// objectPath returns the path of a chain of selections and indexes with string literals from object.
+func objectPath(e ast.Expr) (accessPath, bool) {
+	switch e.Kind() {
+	case ast.IdentKind:
+		if e.AsIdent() == "object" {
+			return accessPath{"object"}, true
+		}
+	case ast.SelectKind:
+		sel := e.AsSelect()
+		if sel.IsTestOnly() {
+			return nil, false
+		}
+		if operand, ok := objectPath(sel.Operand()); ok {
+			return append(operand, sel.FieldName()), true
+		}
+	case ast.CallKind:
+		call := e.AsCall()
+		if call.FunctionName() != operators.Index {
+			return nil, false
+		}
+		key, ok := stringLiteral(call.Args()[1])
+		if !ok {
+			return nil, false
+		}
+		if operand, ok := objectPath(call.Args()[0]); ok {
+			return append(operand, key), true
+		}
+	}
+	return nil, false
+}
+
+func stringLiteral(e ast.Expr) (string, bool) {
+	if e.Kind() != ast.LiteralKind {
+		return "", false
+	}
+	s, ok := e.AsLiteral().(types.String)
+	return string(s), ok
+}
+
+// children returns the sub-expressions of the expression.
+func children(e ast.Expr) []ast.Expr {
+	var children []ast.Expr
+	switch e.Kind() {
+	case ast.CallKind:
+		call := e.AsCall()
+		if call.IsMemberFunction() {
+			children = append(children, call.Target())
+		}
+		children = append(children, call.Args()...)
+	case ast.ComprehensionKind:
+		c := e.AsComprehension()
+		children = append(children, c.IterRange(), c.AccuInit(), c.LoopCondition(), c.LoopStep(), c.Result())
+	case ast.ListKind:
+		children = append(children, e.AsList().Elements()...)
+	case ast.MapKind:
+		for _, entry := range e.AsMap().Entries() {
+			children = append(children, entry.AsMapEntry().Key(), entry.AsMapEntry().Value())
+		}
+	case ast.StructKind:
+		for _, field := range e.AsStruct().Fields() {
+			children = append(children, field.AsStructField().Value())
+		}
+	case ast.SelectKind:
+		children = append(children, e.AsSelect().Operand())
+	}
+	return children
+}
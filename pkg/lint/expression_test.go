@@ -0,0 +1,92 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstantValue(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       bool
+		constant   bool
+	}{
+		{name: "リテラルの比較", expression: "1 == 1", want: true, constant: true},
+		{name: "リテラルのマクロ", expression: "[1, 2].all(x, x > 1)", want: false, constant: true},
+		{name: "短絡評価で決まる論理和", expression: "object.spec.replicas > 0 || true", want: true, constant: true},
+		{name: "短絡評価で決まる論理積", expression: "false && object.spec.replicas > 0", want: false, constant: true},
+		{name: "自身との比較", expression: "object.spec.replicas != object.spec.replicas", want: false, constant: true},
+		{name: "式とその否定の論理和", expression: "has(object.spec) || !has(object.spec)", want: true, constant: true},
+		{name: "式とその否定の論理積", expression: "!has(object.spec) && has(object.spec)", want: false, constant: true},
+		{name: "入力に依存する", expression: "object.spec.replicas <= 5"},
+		{name: "変数に依存する", expression: "variables.limit > 0"},
+		{name: "リクエストに依存する", expression: "request.operation == 'CREATE' || true == false"},
+		{name: "真偽値でない", expression: "'a' + 'b'"},
+		{name: "構文エラー", expression: "1 =="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, constant := constantValue(tt.expression)
+			assert.Equal(t, tt.constant, constant)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestObjectAccesses(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		paths      []string
+		guards     []string
+	}{
+		{
+			name:       "選択の連鎖は最長のパスだけ",
+			expression: "object.spec.replicas <= 5 && object.metadata.name != ''",
+			paths:      []string{"object.spec.replicas", "object.metadata.name"},
+		},
+		{
+			name:       "has()とin",
+			expression: "has(object.metadata.labels) && 'app.kubernetes.io/name' in object.metadata.labels",
+			paths:      []string{"object.metadata", "object.metadata.labels"},
+			guards:     []string{"object.metadata.labels", "object.metadata.labels['app.kubernetes.io/name']"},
+		},
+		{
+			name:       "文字列リテラルでの添字",
+			expression: "object.metadata.labels['team'] == 'a' && object.spec.template.spec.containers[0].image != ''",
+			paths:      []string{"object.metadata.labels.team", "object.spec.template.spec.containers"},
+		},
+		{
+			name:       "object以外は対象外",
+			expression: "oldObject.spec.replicas == params.spec.replicas",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := objectAccesses(tt.expression)
+			var paths, guards []string
+			for _, p := range got.paths {
+				paths = append(paths, p.String())
+			}
+			for _, g := range got.guards {
+				guards = append(guards, g.String())
+			}
+			assert.Equal(t, tt.paths, paths)
+			assert.Equal(t, tt.guards, guards)
+		})
+	}
+}
+
+func TestReferencedVariables(t *testing.T) {
+	assert.Equal(t, []string{"a", "b"}, referencedVariables("variables.a.all(x, x in variables.b)"))
+	assert.Empty(t, referencedVariables("object.variables.c == 1"))
+}
+
+func TestNormalize(t *testing.T) {
+	a, ok := normalize("(object.spec.replicas<=5)&&has(object.spec)")
+	assert.True(t, ok)
+	b, _ := normalize("object.spec.replicas <= 5 &&\n  has(object.spec)")
+	assert.Equal(t, a, b)
+}
@@ -0,0 +1,190 @@
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/yashirook/vaptest/pkg/jsonfield"
+	"github.com/yashirook/vaptest/pkg/target"
+	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// presentFields are the fields the apiserver sets before validating admission, even if the request omits them.
+var presentFields = []string{"apiVersion", "kind", "metadata.name", "metadata.namespace", "metadata.uid", "metadata.creationTimestamp"}
+
+// kind is a kind matched by a policy, with its Go type and the fields of an empty object after defaulting.
+type kind struct {
+	gvk       schema.GroupVersionKind
+	goType    reflect.Type
+	defaulted map[string]any
+}
+
+// matchedKinds returns the built-in kinds of the resources matched by the resource rules of the policy for requests
+// with an object. Rules with wildcard groups or resources, subresources and custom resources are skipped.
+func (l *Linter) matchedKinds(policy *v1.ValidatingAdmissionPolicy) []kind {
+	constraints := policy.Spec.MatchConstraints
+	if constraints == nil || l.Scheme == nil {
+		return nil
+	}
+	mapper := l.mapper()
+	var kinds []kind
+	seen := map[schema.GroupVersionKind]bool{}
+	for _, rule := range constraints.ResourceRules {
+		// DELETE と CONNECT のリクエストには object がない
+		if !slices.ContainsFunc(rule.Operations, func(op v1.OperationType) bool {
+			return op == v1.Create || op == v1.Update || op == v1.OperationAll
+		}) {
+			continue
+		}
+		for _, group := range rule.APIGroups {
+			for _, version := range rule.APIVersions {
+				for _, resource := range rule.Resources {
+					if group == "*" || resource == "*" || strings.Contains(resource, "/") {
+						continue
+					}
+					gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: resource}
+					if version == "*" {
+						gvr.Version = ""
+					}
+					gvks, err := mapper.KindsFor(gvr)
+					if err != nil {
+						continue
+					}
+					for _, gvk := range gvks {
+						if seen[gvk] {
+							continue
+						}
+						seen[gvk] = true
+						if k, ok := l.kind(gvk); ok {
+							kinds = append(kinds, k)
+						}
+					}
+				}
+			}
+		}
+	}
+	return kinds
+}
+
+// mapper returns the RESTMapper of the linter, or the built-in API catalog. It is nil without either a RESTMapper
+// or a scheme.
+func (l *Linter) mapper() meta.RESTMapper {
+	switch {
+	case l.RESTMapper != nil:
+		return l.RESTMapper
+	case l.Scheme != nil:
+		return target.StaticRESTMapper(l.Scheme)
+	}
+	return nil
+}
+
+// kind returns the Go type of the GVK and an empty object of it after defaulting, or false if the scheme does
+// not know the GVK.
+func (l *Linter) kind(gvk schema.GroupVersionKind) (kind, bool) {
+	obj, err := l.Scheme.New(gvk)
+	if err != nil {
+		return kind{}, false
+	}
+	l.Scheme.Default(obj)
+	defaulted, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return kind{}, false
+	}
+	return kind{gvk: gvk, goType: reflect.TypeOf(obj), defaulted: defaulted}, true
+}
+
+// unguardedField returns the shortest prefix of the path that is an optional field of the kinds and is not tested
+// by any guard, with the guard to add, or false if there is none. A field is optional in a kind if the object may
+// omit it: its JSON field is omitempty and not set by defaulting, or it is a map key. Guards longer than the path
+// do not count, as has() itself needs the fields before the tested one. The kinds the path does not resolve in are
+// ignored.
+func unguardedField(path accessPath, guards []accessPath, kinds []kind) (accessPath, string, bool) {
+	for i := 1; i < len(path); i++ {
+		prefix := path[:i+1]
+		optional, resolved, key := false, false, false
+		for _, k := range kinds {
+			o, mapKey, ok := k.optional(path[1 : i+1])
+			if !ok {
+				continue
+			}
+			if !resolved {
+				optional = true
+			}
+			resolved = true
+			optional = optional && o
+			key = key || mapKey
+		}
+		if !resolved {
+			return nil, "", false
+		}
+		if !optional {
+			continue
+		}
+		if slices.ContainsFunc(guards, func(g accessPath) bool { return len(g) <= len(path) && prefix.covers(g) }) {
+			continue
+		}
+		// has() は識別子のフィールドしか選択できないので、ほかのキーは in で調べる
+		if key && !identifier.MatchString(prefix[i]) {
+			return prefix, fmt.Sprintf("'%s' in %s", strings.ReplaceAll(prefix[i], "'", `\'`), path[:i]), true
+		}
+		return prefix, fmt.Sprintf("has(%s)", prefix), true
+	}
+	return nil, "", false
+}
+
+// optional reports whether the last field of the path is optional in the kind and whether it is a map key, or
+// false if the path does not resolve to a field of the Go type.
+func (k kind) optional(fields []string) (bool, bool, bool) {
+	t := k.goType
+	var value any = k.defaulted
+	optional, key := false, false
+	for i, field := range fields {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Map:
+			t, value, optional, key = t.Elem(), nil, true, true
+			continue
+		case reflect.Struct:
+		default:
+			return false, false, false
+		}
+		key = false
+		f, ok := jsonfield.Field(t, field)
+		if !ok {
+			return false, false, false
+		}
+		object, _ := value.(map[string]any)
+		if object != nil {
+			value, ok = object[field]
+			optional = !ok
+		} else {
+			value = nil
+			optional = omitempty(f)
+		}
+		if slices.Contains(presentFields, strings.Join(fields[:i+1], ".")) {
+			optional = false
+		}
+		t = f.Type
+	}
+	return optional, key, true
+}
+
+func omitempty(f reflect.StructField) bool {
+	return slices.Contains(strings.Split(f.Tag.Get("json"), ",")[1:], "omitempty")
+}
+
+// kindNames returns the names of the kinds, e.g. Deployment, StatefulSet.
+func kindNames(kinds []kind) string {
+	names := make([]string, len(kinds))
+	for i, k := range kinds {
+		names[i] = k.gvk.Kind
+	}
+	return strings.Join(names, ", ")
+}
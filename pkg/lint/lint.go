@@ -0,0 +1,355 @@
+// Package lint inspects ValidatingAdmissionPolicies and their bindings without evaluating them against resources,
+// reporting mistakes such as unused variables, expressions that always evaluate to the same value and bindings of
+// missing policies.
+package lint
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Severity is how serious a finding is.
+type Severity string
+
+const (
+	// SeverityError is a finding that makes the policy or binding fail or not take effect in a cluster.
+	SeverityError Severity = "error"
+	// SeverityWarning is a finding that is likely a mistake.
+	SeverityWarning Severity = "warning"
+)
+
+// Rank orders the severities, higher is more serious.
+func (s Severity) Rank() int {
+	switch s {
+	case SeverityError:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// RuleID identifies a rule. Rule IDs are stable, so that they can be used to suppress findings.
+type RuleID string
+
+const (
+	UnusedVariable           RuleID = "unused-variable"
+	MissingMessage           RuleID = "missing-message"
+	MissingHasGuard          RuleID = "missing-has-guard"
+	ConstantExpression       RuleID = "constant-expression"
+	DuplicateValidation      RuleID = "duplicate-validation"
+	UnboundPolicy            RuleID = "unbound-policy"
+	MissingPolicy            RuleID = "missing-policy"
+	MissingValidationActions RuleID = "missing-validation-actions"
+	MissingParamRef          RuleID = "missing-param-ref"
+)
+
+// Rule is a check of the linter.
+type Rule struct {
+	ID          RuleID
+	Severity    Severity
+	Description string
+}
+
+// Rules are all rules of the linter.
+var Rules = []Rule{
+	{UnusedVariable, SeverityWarning, "a variable is not referenced by any expression of the policy"},
+	{MissingMessage, SeverityWarning, "a validation has neither a message nor a messageExpression, so the apiserver reports the expression"},
+	{MissingHasGuard, SeverityWarning, "an expression accesses an optional field of the matched kinds without a has() guard, which is an error when the field is absent"},
+	{ConstantExpression, SeverityWarning, "a validation or match condition always evaluates to the same value, whatever the request"},
+	{DuplicateValidation, SeverityWarning, "a validation has the same expression as an earlier validation of the policy"},
+	{UnboundPolicy, SeverityWarning, "no binding references the policy, so it is never evaluated"},
+	{MissingPolicy, SeverityError, "a binding references a policy that is not defined"},
+	{MissingValidationActions, SeverityError, "a binding has no validationActions, which the apiserver rejects"},
+	{MissingParamRef, SeverityError, "a binding of a policy with a paramKind has no paramRef, so the policy cannot be evaluated"},
+	{CreateWithoutUpdate, SeverityWarning, "a resource rule matches CREATE but not UPDATE, so objects created valid can be updated to violate the policy"},
+	{UnmatchedSubresource, SeverityWarning, "a resource is matched but a subresource changing the fields the policy checks is not, e.g. pods/ephemeralcontainers, so the subresource bypasses the policy"},
+	{ExactMatchPolicy, SeverityWarning, "matchPolicy is Exact, so requests through API versions or groups the rules do not list bypass the policy"},
+	{UncheckedContainers, SeverityWarning, "expressions check containers but not initContainers or ephemeralContainers, which run the same images and settings"},
+	{TenantNamespaceExclusion, SeverityWarning, "the namespaceSelector depends on what any user allowed to create namespaces controls: namespace labels, or the names of namespaces that may not exist yet"},
+}
+
+// DisableAnnotation is the annotation disabling rules, a comma separated list of rule IDs. On a policy, it disables
+// the rules for the policy and its bindings; on a binding, for the binding.
+const DisableAnnotation = "vaptest/lint-disable"
+
+const (
+	policyKind  = "ValidatingAdmissionPolicy"
+	bindingKind = "ValidatingAdmissionPolicyBinding"
+)
+
+// Finding is a problem found in a policy or binding.
+type Finding struct {
+	Rule     RuleID
+	Severity Severity
+	// Kind and Name identify the policy or binding.
+	Kind string
+	Name string
+	// Field is the path of the field with the problem, e.g. spec.validations[0].expression.
+	Field   string
+	Message string
+	// Source is the file the policy or binding was loaded from.
+	Source string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s %s %s: %s [%s]", f.Kind, f.Name, f.Field, f.Message, f.Rule)
+}
+
+// Linter lints policies and bindings.
+type Linter struct {
+	Policies []*v1.ValidatingAdmissionPolicy
+	Bindings []*v1.ValidatingAdmissionPolicyBinding
+	// Scheme provides the Go types and defaulting of the built-in kinds, used to find optional fields.
+	Scheme *runtime.Scheme
+	// RESTMapper resolves the resources matched by policies to kinds. Nil means the built-in API catalog.
+	RESTMapper meta.RESTMapper
+	// Source returns the file an object was loaded from. Optional.
+	Source func(runtime.Object) string
+}
+
+// NewLinter returns a linter of the policies and bindings.
+func NewLinter(policies []*v1.ValidatingAdmissionPolicy, bindings []*v1.ValidatingAdmissionPolicyBinding, scheme *runtime.Scheme) *Linter {
+	return &Linter{Policies: policies, Bindings: bindings, Scheme: scheme}
+}
+
+// Lint returns the findings of all rules not disabled by annotations, the findings of each policy followed by
+// those of its bindings, and then those of bindings of missing policies.
+func (l *Linter) Lint() []Finding {
+	var findings []Finding
+	policies := map[string]*v1.ValidatingAdmissionPolicy{}
+	for _, policy := range l.Policies {
+		policies[policy.Name] = policy
+		findings = append(findings, l.lintPolicy(policy)...)
+		for _, binding := range l.Bindings {
+			if binding.Spec.PolicyName == policy.Name {
+				findings = append(findings, l.lintBinding(binding, policy)...)
+			}
+		}
+	}
+	for _, binding := range l.Bindings {
+		if _, ok := policies[binding.Spec.PolicyName]; !ok {
+			findings = append(findings, l.lintBinding(binding, nil)...)
+		}
+	}
+	return findings
+}
+
+// lintPolicy returns the findings of the policy.
+func (l *Linter) lintPolicy(policy *v1.ValidatingAdmissionPolicy) []Finding {
+	r := l.reporter(policy, policyKind, policy.Name, policy.Annotations)
+	spec := policy.Spec
+	expressions := policyExpressions(policy)
+
+	// 変数はほかの変数、一致条件、検証、監査アノテーションから参照される
+	used := map[string]bool{}
+	for _, e := range expressions {
+		for _, name := range referencedVariables(e.expression) {
+			used[name] = true
+		}
+	}
+	for i, variable := range spec.Variables {
+		if !used[variable.Name] {
+			r.report(UnusedVariable, fmt.Sprintf("spec.variables[%d]", i), fmt.Sprintf("variable %s is not used by any expression", variable.Name))
+		}
+	}
+
+	normalized := map[string]int{}
+	for i, validation := range spec.Validations {
+		field := fmt.Sprintf("spec.validations[%d]", i)
+		if validation.Message == "" && validation.MessageExpression == "" {
+			r.report(MissingMessage, field, "validation has neither a message nor a messageExpression")
+		}
+		key, ok := normalize(validation.Expression)
+		if !ok {
+			continue
+		}
+		if first, ok := normalized[key]; ok {
+			r.report(DuplicateValidation, field, fmt.Sprintf("validation has the same expression as spec.validations[%d]", first))
+			continue
+		}
+		normalized[key] = i
+	}
+
+	for _, e := range expressions {
+		if !e.checkConstant {
+			continue
+		}
+		if value, ok := constantValue(e.expression); ok {
+			r.report(ConstantExpression, e.field, fmt.Sprintf("expression always evaluates to %v, so %s", value, e.consequence(value)))
+		}
+	}
+
+	kinds := l.matchedKinds(policy)
+	if len(kinds) > 0 {
+		var conditionGuards []accessPath
+		for _, condition := range spec.MatchConditions {
+			conditionGuards = append(conditionGuards, objectAccesses(condition.Expression).guards...)
+		}
+		for _, e := range expressions {
+			accesses := objectAccesses(e.expression)
+			guards := accesses.guards
+			// 検証などは一致条件がすべて真のときだけ評価される
+			if !strings.HasPrefix(e.field, "spec.matchConditions") {
+				guards = append(slices.Clone(guards), conditionGuards...)
+			}
+			reported := map[string]bool{}
+			for _, access := range accesses.paths {
+				unguarded, guard, ok := unguardedField(access, guards, kinds)
+				if !ok || reported[unguarded.String()] {
+					continue
+				}
+				reported[unguarded.String()] = true
+				message := fmt.Sprintf("%s may be absent in %s and is accessed without a %s guard", unguarded, kindNames(kinds), guard)
+				if len(unguarded) < len(access) {
+					message = fmt.Sprintf("%s may be absent in %s, so accessing %s needs a %s guard", unguarded, kindNames(kinds), access, guard)
+				}
+				r.report(MissingHasGuard, e.field, message)
+			}
+		}
+	}
+
+	l.lintPolicySecurity(r, policy)
+
+	bound := slices.ContainsFunc(l.Bindings, func(b *v1.ValidatingAdmissionPolicyBinding) bool { return b.Spec.PolicyName == policy.Name })
+	if !bound {
+		r.report(UnboundPolicy, "metadata.name", fmt.Sprintf("no binding references policy %s", policy.Name))
+	}
+	return r.sorted()
+}
+
+// lintBinding returns the findings of the binding of the policy, which is nil when the policy is missing.
+func (l *Linter) lintBinding(binding *v1.ValidatingAdmissionPolicyBinding, policy *v1.ValidatingAdmissionPolicy) []Finding {
+	annotations := []map[string]string{binding.Annotations}
+	if policy != nil {
+		annotations = append(annotations, policy.Annotations)
+	}
+	r := l.reporter(binding, bindingKind, binding.Name, annotations...)
+	if policy == nil {
+		r.report(MissingPolicy, "spec.policyName", fmt.Sprintf("policy %s is not defined", binding.Spec.PolicyName))
+	}
+	if len(binding.Spec.ValidationActions) == 0 {
+		r.report(MissingValidationActions, "spec.validationActions", "binding has no validationActions")
+	}
+	if binding.Spec.MatchResources != nil {
+		l.lintMatchResources(r, "spec.matchResources", binding.Spec.MatchResources)
+	}
+	if policy != nil && policy.Spec.ParamKind != nil && binding.Spec.ParamRef == nil {
+		r.report(MissingParamRef, "spec.paramRef", fmt.Sprintf("policy %s has paramKind %s, but the binding has no paramRef", policy.Name, policy.Spec.ParamKind.Kind))
+	}
+	return r.sorted()
+}
+
+// reporter collects the findings of an object, skipping the rules disabled by the annotations of the objects.
+type reporter struct {
+	kind, name, source string
+	disabled           map[RuleID]bool
+	findings           []Finding
+}
+
+func (l *Linter) reporter(obj runtime.Object, kind, name string, annotations ...map[string]string) *reporter {
+	r := &reporter{kind: kind, name: name, disabled: map[RuleID]bool{}}
+	if l.Source != nil {
+		r.source = l.Source(obj)
+	}
+	for _, a := range annotations {
+		for _, id := range strings.Split(a[DisableAnnotation], ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				r.disabled[RuleID(id)] = true
+			}
+		}
+	}
+	return r
+}
+
+func (r *reporter) report(id RuleID, field, message string) {
+	if r.disabled[id] {
+		return
+	}
+	r.findings = append(r.findings, Finding{Rule: id, Severity: severity(id), Kind: r.kind, Name: r.name, Field: field, Message: message, Source: r.source})
+}
+
+// fieldOrder is the order of the fields of policies and bindings in manifests.
+var fieldOrder = []string{"metadata", "spec.policyName", "spec.paramRef", "spec.matchConstraints", "spec.matchResources", "spec.validationActions", "spec.matchConditions", "spec.variables", "spec.validations", "spec.auditAnnotations"}
+
+// sorted returns the findings in the order of their fields in the manifest, e.g. spec.validations[2] before
+// spec.validations[10].
+func (r *reporter) sorted() []Finding {
+	position := func(field string) (int, int) {
+		section := slices.IndexFunc(fieldOrder, func(prefix string) bool { return strings.HasPrefix(field, prefix) })
+		index := 0
+		if start := strings.Index(field, "["); start >= 0 {
+			if end := strings.Index(field[start:], "]"); end > 0 {
+				index, _ = strconv.Atoi(field[start+1 : start+end])
+			}
+		}
+		return section, index
+	}
+	slices.SortStableFunc(r.findings, func(a, b Finding) int {
+		sectionA, indexA := position(a.Field)
+		sectionB, indexB := position(b.Field)
+		return cmp.Or(cmp.Compare(sectionA, sectionB), cmp.Compare(indexA, indexB))
+	})
+	return r.findings
+}
+
+func severity(id RuleID) Severity {
+	for _, rule := range Rules {
+		if rule.ID == id {
+			return rule.Severity
+		}
+	}
+	return SeverityWarning
+}
+
+// policyExpression is a CEL expression of a policy.
+type policyExpression struct {
+	field      string
+	expression string
+	// checkConstant is set for the expressions deciding whether the request is admitted.
+	checkConstant bool
+}
+
+// consequence describes what an expression deciding whether the request is admitted always evaluating to the
+// value means.
+func (e policyExpression) consequence(value bool) string {
+	switch {
+	case strings.HasPrefix(e.field, "spec.matchConditions") && value:
+		return "the condition matches every request"
+	case strings.HasPrefix(e.field, "spec.matchConditions"):
+		return "the policy never applies"
+	case value:
+		return "the validation never fails"
+	default:
+		return "the validation fails for every request"
+	}
+}
+
+// policyExpressions returns the expressions of the match conditions, variables, validations and audit
+// annotations of the policy.
+func policyExpressions(policy *v1.ValidatingAdmissionPolicy) []policyExpression {
+	var expressions []policyExpression
+	for i, condition := range policy.Spec.MatchConditions {
+		expressions = append(expressions, policyExpression{fmt.Sprintf("spec.matchConditions[%d].expression", i), condition.Expression, true})
+	}
+	for i, variable := range policy.Spec.Variables {
+		expressions = append(expressions, policyExpression{fmt.Sprintf("spec.variables[%d].expression", i), variable.Expression, false})
+	}
+	for i, validation := range policy.Spec.Validations {
+		expressions = append(expressions, policyExpression{fmt.Sprintf("spec.validations[%d].expression", i), validation.Expression, true})
+		if validation.MessageExpression != "" {
+			expressions = append(expressions, policyExpression{fmt.Sprintf("spec.validations[%d].messageExpression", i), validation.MessageExpression, false})
+		}
+	}
+	for i, annotation := range policy.Spec.AuditAnnotations {
+		expressions = append(expressions, policyExpression{fmt.Sprintf("spec.auditAnnotations[%d].valueExpression", i), annotation.ValueExpression, false})
+	}
+	return expressions
+}
@@ -0,0 +1,180 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yashirook/vaptest/pkg/defaults"
+	"github.com/yashirook/vaptest/pkg/loader"
+	v1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLinterLint(t *testing.T) {
+	scheme := defaults.NewScheme()
+	ldr := loader.NewLoader(scheme)
+	policies, bindings, err := ldr.LoadPolicyFromPaths([]string{"testdata/policies.yaml"})
+	require.NoError(t, err)
+	linter := NewLinter(policies, bindings, scheme)
+	linter.Source = ldr.Source
+
+	findings := linter.Lint()
+	for _, f := range findings {
+		assert.Equal(t, "testdata/policies.yaml", f.Source)
+	}
+	type finding struct {
+		rule     RuleID
+		severity Severity
+		name     string
+		field    string
+		message  string
+	}
+	var got []finding
+	for _, f := range findings {
+		got = append(got, finding{f.Rule, f.Severity, f.Name, f.Field, f.Message})
+	}
+	// clean と抑制されたルールは指摘されない
+	assert.Equal(t, []finding{
+		{UnboundPolicy, SeverityWarning, "sloppy", "metadata.name", "no binding references policy sloppy"},
+		{UnusedVariable, SeverityWarning, "sloppy", "spec.variables[1]", "variable unused is not used by any expression"},
+		{MissingMessage, SeverityWarning, "sloppy", "spec.validations[0]", "validation has neither a message nor a messageExpression"},
+		{DuplicateValidation, SeverityWarning, "sloppy", "spec.validations[1]", "validation has the same expression as spec.validations[0]"},
+		{MissingHasGuard, SeverityWarning, "sloppy", "spec.validations[2].expression", "object.metadata.labels may be absent in Deployment, so accessing object.metadata.labels.team needs a has(object.metadata.labels) guard"},
+		{ConstantExpression, SeverityWarning, "sloppy", "spec.validations[3].expression", "expression always evaluates to true, so the validation never fails"},
+		{MissingHasGuard, SeverityWarning, "with-params", "spec.validations[0].expression", "object.data may be absent in ConfigMap and is accessed without a has(object.data) guard"},
+		{MissingParamRef, SeverityError, "with-params", "spec.paramRef", "policy with-params has paramKind ConfigMap, but the binding has no paramRef"},
+		{MissingValidationActions, SeverityError, "with-params", "spec.validationActions", "binding has no validationActions"},
+		{MissingPolicy, SeverityError, "dangling", "spec.policyName", "policy missing is not defined"},
+	}, got)
+}
+
+func TestMissingHasGuard(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []string
+		expression string
+		want       []string
+	}{
+		{
+			name:       "デフォルト値のあるフィールドはガード不要",
+			expression: "object.spec.replicas <= 5 && object.spec.strategy.rollingUpdate.maxSurge == 1",
+		},
+		{
+			name:       "APIサーバーが設定するメタデータはガード不要",
+			expression: "object.kind == 'Deployment' && object.metadata.name.startsWith('web') && object.metadata.namespace != 'default'",
+		},
+		{
+			name:       "省略可能なフィールド",
+			expression: "object.spec.template.spec.securityContext.runAsNonRoot == true",
+			want:       []string{"object.spec.template.spec.securityContext.runAsNonRoot may be absent in Deployment and is accessed without a has(object.spec.template.spec.securityContext.runAsNonRoot) guard"},
+		},
+		{
+			name:       "has()でガードされている",
+			expression: "has(object.spec.template.spec.affinity) && has(object.spec.template.spec.affinity.nodeAffinity)",
+		},
+		{
+			name:       "has()自身も途中のフィールドを必要とする",
+			expression: "has(object.spec.template.spec.affinity.nodeAffinity)",
+			want:       []string{"object.spec.template.spec.affinity may be absent in Deployment and is accessed without a has(object.spec.template.spec.affinity) guard"},
+		},
+		{
+			name:       "マップのキーはinでガードする",
+			expression: "has(object.metadata.annotations) && object.metadata.annotations['example.com/owner'] != ''",
+			want:       []string{"object.metadata.annotations['example.com/owner'] may be absent in Deployment and is accessed without a 'example.com/owner' in object.metadata.annotations guard"},
+		},
+		{
+			name:       "inによるガード",
+			expression: "has(object.metadata.labels) && 'team' in object.metadata.labels && object.metadata.labels.team != ''",
+		},
+		{
+			name:       "一致条件のガードは検証にも効く",
+			conditions: []string{"has(object.metadata.labels)"},
+			expression: "object.metadata.labels.team != ''",
+			want:       []string{"object.metadata.labels.team may be absent in Deployment and is accessed without a has(object.metadata.labels.team) guard"},
+		},
+		{
+			name:       "オプショナル構文はガード不要",
+			expression: "object.spec.template.spec.?securityContext.?runAsNonRoot.orValue(false)",
+		},
+		{
+			name:       "型にないフィールドは判定しない",
+			expression: "object.spec.unknown.field == 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &v1.ValidatingAdmissionPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "policy", Annotations: map[string]string{DisableAnnotation: "unbound-policy,missing-message"}},
+				Spec: v1.ValidatingAdmissionPolicySpec{
+					MatchConstraints: &v1.MatchResources{ResourceRules: []v1.NamedRuleWithOperations{{
+						RuleWithOperations: v1.RuleWithOperations{
+							Operations: []v1.OperationType{v1.Create},
+							Rule:       v1.Rule{APIGroups: []string{"apps"}, APIVersions: []string{"v1"}, Resources: []string{"deployments"}},
+						},
+					}}},
+					Validations: []v1.Validation{{Expression: tt.expression}},
+				},
+			}
+			for i, condition := range tt.conditions {
+				policy.Spec.MatchConditions = append(policy.Spec.MatchConditions, v1.MatchCondition{Name: string(rune('a' + i)), Expression: condition})
+			}
+			var got []string
+			for _, f := range NewLinter([]*v1.ValidatingAdmissionPolicy{policy}, nil, defaults.NewScheme()).Lint() {
+				if f.Rule == MissingHasGuard {
+					got = append(got, f.Message)
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLinterLintSecurity(t *testing.T) {
+	scheme := defaults.NewScheme()
+	policies, bindings, err := loader.NewLoader(scheme).LoadPolicyFromPaths([]string{"testdata/security.yaml"})
+	require.NoError(t, err)
+
+	type finding struct {
+		rule    RuleID
+		name    string
+		field   string
+		message string
+	}
+	var got []finding
+	for _, f := range NewLinter(policies, bindings, scheme).Lint() {
+		got = append(got, finding{f.Rule, f.Name, f.Field, f.Message})
+	}
+	// secure-images は指摘されない
+	assert.Equal(t, []finding{
+		{CreateWithoutUpdate, "leaky-images", "spec.matchConstraints.resourceRules[0].operations", "rule matches CREATE but not UPDATE of pods, so an object created valid can be updated to violate the policy"},
+		{TenantNamespaceExclusion, "leaky-images", "spec.matchConstraints.namespaceSelector", "namespace sandbox is excluded by name; if it does not exist, any user allowed to create namespaces can create it and bypass the policy"},
+		{TenantNamespaceExclusion, "leaky-images", "spec.matchConstraints.namespaceSelector", "namespaces are excluded by label policy.example.com/exempt, which any user allowed to create or update a namespace can set to bypass the policy; select namespaces by kubernetes.io/metadata.name instead"},
+		{UnmatchedSubresource, "leaky-images", "spec.matchConstraints.resourceRules[0].resources", "pods is matched but pods/ephemeralcontainers is not; UPDATE of pods/ephemeralcontainers adds containers to a running pod without the policy evaluating it"},
+		{UncheckedContainers, "leaky-images", "spec.validations[0].expression", "expressions check containers but not initContainers or ephemeralContainers, so the images and settings the policy denies in containers can run there instead"},
+		{ExactMatchPolicy, "replica-limit", "spec.matchConstraints.matchPolicy", "matchPolicy is Exact, so requests through apps/v1beta1 deployments, apps/v1beta2 deployments, extensions/v1beta1 deployments, which serve the same objects, bypass the policy; use Equivalent or list them"},
+		{UnmatchedSubresource, "replica-limit", "spec.matchConstraints.resourceRules[0].resources", "deployments is matched but deployments/scale is not; UPDATE of deployments/scale changes the replicas without the policy evaluating it"},
+		{TenantNamespaceExclusion, "replica-limit", "spec.matchResources.namespaceSelector", "only namespaces with label env are matched, so any user allowed to create or update a namespace can leave it out to bypass the policy"},
+	}, got)
+}
+
+func TestCoversResource(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []string
+		resource  string
+		want      bool
+	}{
+		{name: "同じリソース", resources: []string{"pods"}, resource: "pods", want: true},
+		{name: "ワイルドカードはサブリソースを含まない", resources: []string{"*"}, resource: "pods/ephemeralcontainers", want: false},
+		{name: "すべてのサブリソース", resources: []string{"*/*"}, resource: "pods/ephemeralcontainers", want: true},
+		{name: "リソースのすべてのサブリソース", resources: []string{"pods/*"}, resource: "pods/resize", want: true},
+		{name: "すべてのリソースのサブリソース", resources: []string{"*/scale"}, resource: "deployments/scale", want: true},
+		{name: "ほかのリソースのサブリソース", resources: []string{"deployments/*"}, resource: "pods/resize", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, coversResource(tt.resources, tt.resource))
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package lint
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/yashirook/vaptest/pkg/target"
+	v1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// The security rules report match scopes and checks that let requests bypass a policy.
+const (
+	CreateWithoutUpdate      RuleID = "create-without-update"
+	UnmatchedSubresource     RuleID = "unmatched-subresource"
+	ExactMatchPolicy         RuleID = "exact-match-policy"
+	UncheckedContainers      RuleID = "unchecked-containers"
+	TenantNamespaceExclusion RuleID = "tenant-namespace-exclusion"
+)
+
+// createOnlyResources are the resources that are only created, never updated.
+var createOnlyResources = []string{"bindings", "tokenreviews", "selfsubjectreviews", "subjectaccessreviews", "selfsubjectaccessreviews", "localsubjectaccessreviews", "selfsubjectrulesreviews"}
+
+// bypassSubresource is a subresource that changes the object of its parent resource.
+type bypassSubresource struct {
+	name        string
+	explanation string
+	// field is the field of the object the subresource changes; the subresource is only reported for policies
+	// whose expressions reference it.
+	field string
+}
+
+var bypassSubresources = []bypassSubresource{
+	{"ephemeralcontainers", "adds containers to a running pod", "containers"},
+	{"resize", "changes the resources of the containers of a running pod", "resources"},
+	{"scale", "changes the replicas", "replicas"},
+}
+
+// builtinNamespaces are the namespaces every cluster has, which no tenant can create.
+var builtinNamespaces = []string{"default", "kube-system", "kube-public", "kube-node-lease"}
+
+// subresourceMapper is a RESTMapper that knows the subresources of resources, such as target.ResourceMapper.
+type subresourceMapper interface {
+	Subresources(gvr schema.GroupVersionResource) []string
+}
+
+// lintPolicySecurity reports the bypasses of the match constraints and the expressions of the policy.
+func (l *Linter) lintPolicySecurity(r *reporter, policy *v1.ValidatingAdmissionPolicy) {
+	fields := referencedFields(policy)
+	if constraints := policy.Spec.MatchConstraints; constraints != nil {
+		l.lintMatchResources(r, "spec.matchConstraints", constraints)
+		l.lintSubresources(r, constraints, fields)
+	}
+
+	if fields.Has("containers") {
+		var missing []string
+		if !fields.Has("initContainers") {
+			missing = append(missing, "initContainers")
+		}
+		if !fields.Has("ephemeralContainers") && matchesPods(policy) {
+			missing = append(missing, "ephemeralContainers")
+		}
+		if len(missing) > 0 {
+			r.report(UncheckedContainers, containersField(policy), fmt.Sprintf("expressions check containers but not %s, so the images and settings the policy denies in containers can run there instead", strings.Join(missing, " or ")))
+		}
+	}
+}
+
+// lintMatchResources reports the bypasses of the match resources of a policy or binding.
+func (l *Linter) lintMatchResources(r *reporter, field string, mr *v1.MatchResources) {
+	for i, rule := range mr.ResourceRules {
+		if !slices.Contains(rule.Operations, v1.Create) || coversOperation(rule, v1.Update) {
+			continue
+		}
+		// 同じリソースの UPDATE をほかのルールが対象にしていれば抜け穴はない
+		updated := slices.ContainsFunc(mr.ResourceRules, func(other v1.NamedRuleWithOperations) bool {
+			return coversOperation(other, v1.Update) && coversRule(other, rule)
+		})
+		if updated || !slices.ContainsFunc(rule.Resources, func(resource string) bool {
+			return !strings.Contains(resource, "/") && !slices.Contains(createOnlyResources, resource)
+		}) {
+			continue
+		}
+		r.report(CreateWithoutUpdate, fmt.Sprintf("%s.resourceRules[%d].operations", field, i), fmt.Sprintf("rule matches CREATE but not UPDATE of %s, so an object created valid can be updated to violate the policy", strings.Join(rule.Resources, ", ")))
+	}
+
+	if mr.MatchPolicy != nil && *mr.MatchPolicy == v1.Exact {
+		if bypasses := l.otherVersions(mr); len(bypasses) > 0 {
+			r.report(ExactMatchPolicy, field+".matchPolicy", fmt.Sprintf("matchPolicy is Exact, so requests through %s, which serve the same objects, bypass the policy; use Equivalent or list them", strings.Join(bypasses, ", ")))
+		}
+	}
+
+	if selector := mr.NamespaceSelector; selector != nil {
+		lintNamespaceSelector(r, field+".namespaceSelector", selector)
+	}
+}
+
+// lintSubresources reports the subresources changing the objects of matched resources that the rules do not
+// match.
+func (l *Linter) lintSubresources(r *reporter, mr *v1.MatchResources, fields sets.Set[string]) {
+	mapper, ok := l.mapper().(subresourceMapper)
+	if !ok {
+		return
+	}
+	reported := map[string]bool{}
+	for i, rule := range mr.ResourceRules {
+		if !coversOperation(rule, v1.Create) && !coversOperation(rule, v1.Update) {
+			continue
+		}
+		for _, gvr := range l.ruleResources(rule) {
+			served := mapper.Subresources(gvr)
+			for _, subresource := range bypassSubresources {
+				name := gvr.Resource + "/" + subresource.name
+				if reported[name] || !slices.Contains(served, subresource.name) || !fields.Has(subresource.field) {
+					continue
+				}
+				if slices.ContainsFunc(mr.ResourceRules, func(other v1.NamedRuleWithOperations) bool {
+					return coversOperation(other, v1.Update) && coversGroupVersion(other, gvr) && coversResource(other.Resources, name)
+				}) {
+					continue
+				}
+				reported[name] = true
+				r.report(UnmatchedSubresource, fmt.Sprintf("spec.matchConstraints.resourceRules[%d].resources", i), fmt.Sprintf("%s is matched but %s is not; UPDATE of %s %s without the policy evaluating it", gvr.Resource, name, name, subresource.explanation))
+			}
+		}
+	}
+}
+
+// otherVersions returns the group versions of the matched resources that serve the same kinds as the matched
+// ones but that the rules do not match, e.g. extensions/v1beta1 deployments for a rule of apps/v1 deployments.
+func (l *Linter) otherVersions(mr *v1.MatchResources) []string {
+	equivalents := target.NewEquivalentResourceMapper(l.mapper(), nil)
+	var bypasses []string
+	seen := map[schema.GroupVersionResource]bool{}
+	for _, rule := range mr.ResourceRules {
+		for _, matched := range l.ruleResources(rule) {
+			for _, other := range equivalents.EquivalentResourcesFor(matched, "") {
+				if seen[other] {
+					continue
+				}
+				seen[other] = true
+				if slices.ContainsFunc(mr.ResourceRules, func(r v1.NamedRuleWithOperations) bool {
+					return coversGroupVersion(r, other) && coversResource(r.Resources, other.Resource)
+				}) {
+					continue
+				}
+				bypasses = append(bypasses, fmt.Sprintf("%s %s", schema.GroupVersion{Group: other.Group, Version: other.Version}, other.Resource))
+			}
+		}
+	}
+	slices.Sort(bypasses)
+	return bypasses
+}
+
+// lintNamespaceSelector reports the requirements of the selector on namespace labels, which users creating or
+// updating namespaces set, and the exclusions of namespaces by names that may not exist yet.
+func lintNamespaceSelector(r *reporter, field string, selector *metav1.LabelSelector) {
+	requirements := slices.Clone(selector.MatchExpressions)
+	for _, key := range sets.List(sets.KeySet(selector.MatchLabels)) {
+		requirements = append(requirements, metav1.LabelSelectorRequirement{Key: key, Operator: metav1.LabelSelectorOpIn, Values: []string{selector.MatchLabels[key]}})
+	}
+	for _, requirement := range requirements {
+		excludes := requirement.Operator == metav1.LabelSelectorOpNotIn || requirement.Operator == metav1.LabelSelectorOpDoesNotExist
+		if requirement.Key == corev1.LabelMetadataName {
+			if !excludes {
+				continue
+			}
+			var creatable []string
+			for _, name := range requirement.Values {
+				if !slices.Contains(builtinNamespaces, name) {
+					creatable = append(creatable, name)
+				}
+			}
+			switch {
+			case len(creatable) == 1:
+				r.report(TenantNamespaceExclusion, field, fmt.Sprintf("namespace %s is excluded by name; if it does not exist, any user allowed to create namespaces can create it and bypass the policy", creatable[0]))
+			case len(creatable) > 1:
+				r.report(TenantNamespaceExclusion, field, fmt.Sprintf("namespaces %s are excluded by name; if one does not exist, any user allowed to create namespaces can create it and bypass the policy", strings.Join(creatable, ", ")))
+			}
+			continue
+		}
+		if excludes {
+			r.report(TenantNamespaceExclusion, field, fmt.Sprintf("namespaces are excluded by label %s, which any user allowed to create or update a namespace can set to bypass the policy; select namespaces by %s instead", requirement.Key, corev1.LabelMetadataName))
+		} else {
+			r.report(TenantNamespaceExclusion, field, fmt.Sprintf("only namespaces with label %s are matched, so any user allowed to create or update a namespace can leave it out to bypass the policy", requirement.Key))
+		}
+	}
+}
+
+// ruleResources returns the resources of each version the rule matches, without subresources. Wildcard groups and
+// resources are skipped.
+func (l *Linter) ruleResources(rule v1.NamedRuleWithOperations) []schema.GroupVersionResource {
+	mapper := l.mapper()
+	if mapper == nil {
+		return nil
+	}
+	var resources []schema.GroupVersionResource
+	for _, group := range rule.APIGroups {
+		for _, version := range rule.APIVersions {
+			for _, resource := range rule.Resources {
+				if group == "*" || resource == "*" || strings.Contains(resource, "/") {
+					continue
+				}
+				gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: resource}
+				if version == "*" {
+					gvr.Version = ""
+				}
+				all, err := mapper.ResourcesFor(gvr)
+				if err != nil {
+					continue
+				}
+				for _, r := range all {
+					if r.Group == group && !slices.Contains(resources, r) {
+						resources = append(resources, r)
+					}
+				}
+			}
+		}
+	}
+	return resources
+}
+
+// coversOperation reports whether the rule matches the operation.
+func coversOperation(rule v1.NamedRuleWithOperations, operation v1.OperationType) bool {
+	return slices.Contains(rule.Operations, operation) || slices.Contains(rule.Operations, v1.OperationAll)
+}
+
+// coversRule reports whether the rule matches all group versions and resources of the other rule.
+func coversRule(rule, other v1.NamedRuleWithOperations) bool {
+	return coversAll(rule.APIGroups, other.APIGroups) && coversAll(rule.APIVersions, other.APIVersions) &&
+		!slices.ContainsFunc(other.Resources, func(resource string) bool { return !coversResource(rule.Resources, resource) })
+}
+
+func coversAll(values, others []string) bool {
+	return slices.Contains(values, "*") || !slices.ContainsFunc(others, func(v string) bool { return !slices.Contains(values, v) })
+}
+
+// coversGroupVersion reports whether the rule matches the group and version of the resource.
+func coversGroupVersion(rule v1.NamedRuleWithOperations, gvr schema.GroupVersionResource) bool {
+	return (slices.Contains(rule.APIGroups, "*") || slices.Contains(rule.APIGroups, gvr.Group)) &&
+		(slices.Contains(rule.APIVersions, "*") || slices.Contains(rule.APIVersions, gvr.Version))
+}
+
+// coversResource reports whether the resources of a rule match the resource or subresource, with the wildcards
+// of the apiserver: *, */*, <resource>/* and */<subresource>.
+func coversResource(resources []string, resource string) bool {
+	parent, subresource, _ := strings.Cut(resource, "/")
+	for _, r := range resources {
+		p, s, hasSub := strings.Cut(r, "/")
+		switch {
+		case r == resource:
+			return true
+		case subresource == "" && !hasSub && p == "*":
+			return true
+		case subresource != "" && hasSub && (p == "*" || p == parent) && (s == "*" || s == subresource):
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPods reports whether the match constraints of the policy match pods.
+func matchesPods(policy *v1.ValidatingAdmissionPolicy) bool {
+	constraints := policy.Spec.MatchConstraints
+	if constraints == nil {
+		return false
+	}
+	return slices.ContainsFunc(constraints.ResourceRules, func(rule v1.NamedRuleWithOperations) bool {
+		return (slices.Contains(rule.APIGroups, "") || slices.Contains(rule.APIGroups, "*")) && coversResource(rule.Resources, "pods")
+	})
+}
+
+// referencedFields returns the names of the fields selected in the expressions of the policy, with has(), the
+// optional syntax or plain selection.
+func referencedFields(policy *v1.ValidatingAdmissionPolicy) sets.Set[string] {
+	fields := sets.New[string]()
+	for _, e := range policyExpressions(policy) {
+		fields = fields.Union(selectedFields(e.expression))
+	}
+	return fields
+}
+
+// selectedFields returns the names of the fields selected in the expression.
+func selectedFields(expression string) sets.Set[string] {
+	fields := sets.New[string]()
+	parsed, ok := parse(expression)
+	if !ok {
+		return fields
+	}
+	ast.PostOrderVisit(parsed.NativeRep().Expr(), ast.NewExprVisitor(func(e ast.Expr) {
+		switch e.Kind() {
+		case ast.SelectKind:
+			fields.Insert(e.AsSelect().FieldName())
+		case ast.CallKind:
+			if call := e.AsCall(); call.FunctionName() == operators.OptSelect {
+				if name, ok := stringLiteral(call.Args()[1]); ok {
+					fields.Insert(name)
+				}
+			}
+		}
+	}))
+	return fields
+}
+
+// containersField returns the field of the first expression of the policy selecting containers.
+func containersField(policy *v1.ValidatingAdmissionPolicy) string {
+	for _, e := range policyExpressions(policy) {
+		if selectedFields(e.expression).Has("containers") {
+			return e.field
+		}
+	}
+	return "spec"
+}
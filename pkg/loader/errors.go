@@ -1,6 +1,9 @@
 package loader
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // FileReadError represents an error that occurs when reading a file
 type FileReadError struct {
@@ -46,10 +49,45 @@ func (e *DecodeError) Unwrap() error {
 
 // UnknownResourceError は未知のリソースが存在する場合のエラーを表します
 type UnknownResourceError struct {
+	Path    string
 	Kind    string
 	Version string
 }
 
 func (e *UnknownResourceError) Error() string {
-	return fmt.Sprintf("unknown resource type: kind=%s, version=%s", e.Kind, e.Version)
+	return fmt.Sprintf("unknown resource type in file %s: kind=%s, version=%s", e.Path, e.Kind, e.Version)
+}
+
+// UnsupportedPolicyVersionError represents a policy or binding of another version than v1,
+// which can be neither validated nor evaluated.
+type UnsupportedPolicyVersionError struct {
+	Path    string
+	Kind    string
+	Version string
+}
+
+func (e *UnsupportedPolicyVersionError) Error() string {
+	return fmt.Sprintf("unsupported version of %s in file %s: %s, only admissionregistration.k8s.io/v1 is supported", e.Kind, e.Path, e.Version)
+}
+
+// StrictDecodeError represents an error decoding a document in strict mode, such as an unknown field, a
+// duplicate field or a value of the wrong type.
+type StrictDecodeError struct {
+	Path string
+	// Document is the index of the document in the file, starting from 0.
+	Document int
+	// Fields are the paths of the fields the error is about, e.g. spec.replicas, if it is about fields.
+	Fields []string
+	Err    error
+}
+
+func (e *StrictDecodeError) Error() string {
+	if len(e.Fields) == 0 {
+		return fmt.Sprintf("strict decoding failed in file %s (document index %d): %v", e.Path, e.Document, e.Err)
+	}
+	return fmt.Sprintf("strict decoding failed in file %s (document index %d, field %s): %v", e.Path, e.Document, strings.Join(e.Fields, ", "), e.Err)
+}
+
+func (e *StrictDecodeError) Unwrap() error {
+	return e.Err
 }
@@ -1,20 +1,37 @@
 package loader
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apimachinery/pkg/util/yaml"
+	sigsyaml "sigs.k8s.io/yaml"
+	goyaml "sigs.k8s.io/yaml/goyaml.v3"
 )
 
 // Loader is a struct for loading Kubernetes resources from YAML files
 type Loader struct {
 	Scheme *runtime.Scheme
 	Codecs serializer.CodecFactory
+	// Strict rejects unknown fields and duplicate fields, the same as the apiserver's
+	// fieldValidation=Strict, and reports them and any other decoding error, such as a value of the
+	// wrong type, as a StrictDecodeError with the document index and the field path.
+	Strict bool
+	// Warnings holds non-fatal problems found while loading, such as
+	// resources whose kind is not registered in Scheme.
+	Warnings []error
+
+	strictCodecs serializer.CodecFactory
+	sources      map[runtime.Object]string
 }
 
 // NewLoader creates a new Loader
@@ -22,9 +39,17 @@ func NewLoader(scheme *runtime.Scheme) *Loader {
 	return &Loader{
 		Scheme: scheme,
 		Codecs: serializer.NewCodecFactory(scheme),
+
+		strictCodecs: serializer.NewCodecFactory(scheme, serializer.EnableStrict),
+		sources:      make(map[runtime.Object]string),
 	}
 }
 
+// Source returns the path of the file the object was loaded from.
+func (l *Loader) Source(obj runtime.Object) string {
+	return l.sources[obj]
+}
+
 // LoadObjectFromPaths loads resources from a slice of file or directory paths
 func (l *Loader) LoadObjectFromPaths(paths []string) ([]runtime.Object, error) {
 	var objects []runtime.Object
@@ -77,33 +102,185 @@ func (l *Loader) loadFromFile(filePath string) ([]runtime.Object, error) {
 		return nil, &FileReadError{Path: filePath, Err: err}
 	}
 
-	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 1024)
+	documents, err := splitDocuments(data)
+	if err != nil {
+		return nil, &YAMLParseError{Path: filePath, Err: err}
+	}
+
 	var objects []runtime.Object
+	for i, doc := range documents {
+		obj, err := l.decodeDocument(filePath, doc)
+		var decodeErr *DecodeError
+		var parseErr *YAMLParseError
+		switch {
+		case l.Strict && errors.As(err, &decodeErr):
+			return nil, &StrictDecodeError{Path: filePath, Document: i, Fields: fieldPaths(decodeErr.Err), Err: decodeErr.Err}
+		case l.Strict && errors.As(err, &parseErr):
+			return nil, &StrictDecodeError{Path: filePath, Document: i, Err: parseErr.Err}
+		case err != nil:
+			return nil, err
+		}
+		if obj != nil {
+			l.sources[obj] = filePath
+			objects = append(objects, obj)
+		}
+	}
 
+	return objects, nil
+}
+
+// splitDocuments splits a YAML stream or a JSON stream into documents.
+// The documents keep their original encoding so that strict decoding can detect duplicate keys.
+func splitDocuments(data []byte) ([][]byte, error) {
+	var documents [][]byte
+	if yaml.IsJSONBuffer(data) {
+		decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 1024)
+		for {
+			var rawObj runtime.RawExtension
+			if err := decoder.Decode(&rawObj); err != nil {
+				if err == io.EOF {
+					return documents, nil
+				}
+				return nil, err
+			}
+			documents = append(documents, rawObj.Raw)
+		}
+	}
+
+	reader := yaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
 	for {
-		var rawObj runtime.RawExtension
-		if err := decoder.Decode(&rawObj); err != nil {
+		doc, err := reader.Read()
+		if err != nil {
 			if err == io.EOF {
-				break
+				return documents, nil
 			}
-			return nil, &YAMLParseError{Path: filePath, Err: err}
+			return nil, err
 		}
+		documents = append(documents, doc)
+	}
+}
 
-		if len(rawObj.Raw) == 0 {
-			continue
-		}
+// decodeDocument decodes a single document. It returns nil for empty documents.
+func (l *Loader) decodeDocument(filePath string, doc []byte) (runtime.Object, error) {
+	raw, err := yaml.ToJSON(doc)
+	if err != nil {
+		return nil, &YAMLParseError{Path: filePath, Err: err}
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
 
-		obj, gvk, err := l.Codecs.UniversalDeserializer().Decode(rawObj.Raw, nil, nil)
-		if err != nil {
-			return nil, &DecodeError{Path: filePath, Err: err}
+	deserializer := l.Codecs.UniversalDeserializer()
+	if l.Strict {
+		if errs := duplicateFields(doc); len(errs) > 0 {
+			return nil, &DecodeError{Path: filePath, Err: runtime.NewStrictDecodingError(errs)}
 		}
+		deserializer = l.strictCodecs.UniversalDeserializer()
+	}
+	obj, gvk, err := deserializer.Decode(doc, nil, nil)
+	if runtime.IsNotRegisteredError(err) {
+		obj, err = l.decodeUnstructured(filePath, doc)
+	}
+	if err != nil {
+		return nil, &DecodeError{Path: filePath, Err: err}
+	}
 
+	if _, isUnstructured := obj.(*unstructured.Unstructured); !isUnstructured {
 		if _, err := l.Scheme.New(*gvk); err != nil {
-			return nil, &UnknownResourceError{Kind: gvk.Kind, Version: gvk.Version}
+			return nil, &UnknownResourceError{Path: filePath, Kind: gvk.Kind, Version: gvk.GroupVersion().String()}
 		}
+	}
+	return obj, nil
+}
 
-		objects = append(objects, obj)
+// decodeUnstructured decodes a resource whose kind is not registered in the scheme.
+// The object is kept as unstructured data and a warning is recorded.
+// Without a schema, strict mode can only detect duplicate fields, which decodeDocument reports first.
+func (l *Loader) decodeUnstructured(filePath string, data []byte) (runtime.Object, error) {
+	var raw []byte
+	var err error
+	if l.Strict {
+		raw, err = sigsyaml.YAMLToJSONStrict(data)
+		if err != nil {
+			return nil, runtime.NewStrictDecodingError([]error{err})
+		}
+	} else {
+		raw, err = yaml.ToJSON(data)
+		if err != nil {
+			return nil, err
+		}
 	}
+	obj, gvk, err := unstructured.UnstructuredJSONScheme.Decode(raw, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	l.Warnings = append(l.Warnings, &UnknownResourceError{Path: filePath, Kind: gvk.Kind, Version: gvk.GroupVersion().String()})
+	return obj, nil
+}
 
-	return objects, nil
+// duplicateFields returns an error for each key repeated in a mapping of the YAML or JSON document, naming
+// the path of the field as the apiserver does for JSON, e.g. duplicate field "metadata.name". The YAML
+// decoder only reports the line of a duplicate key.
+func duplicateFields(doc []byte) []error {
+	var root goyaml.Node
+	if err := goyaml.Unmarshal(doc, &root); err != nil {
+		// 構文エラーはデコーダが報告する
+		return nil
+	}
+	var errs []error
+	var walk func(node *goyaml.Node, path string)
+	walk = func(node *goyaml.Node, path string) {
+		switch node.Kind {
+		case goyaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case goyaml.MappingNode:
+			seen := make(map[string]bool, len(node.Content)/2)
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i].Value
+				fieldPath := key
+				if path != "" {
+					fieldPath = path + "." + key
+				}
+				if seen[key] {
+					errs = append(errs, fmt.Errorf("duplicate field %q", fieldPath))
+					continue
+				}
+				seen[key] = true
+				walk(node.Content[i+1], fieldPath)
+			}
+		case goyaml.SequenceNode:
+			for i, child := range node.Content {
+				walk(child, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+	walk(&root, "")
+	return errs
+}
+
+var (
+	// strictFieldPattern matches the errors of strict decoding, e.g. unknown field "spec.contianers".
+	strictFieldPattern = regexp.MustCompile(`^(?:unknown|duplicate) field "(.+)"$`)
+	// typeFieldPattern matches the errors of values of the wrong type, e.g. json: cannot unmarshal string into
+	// Go struct field DeploymentSpec.spec.replicas of type int32, where the field path follows the struct name.
+	typeFieldPattern = regexp.MustCompile(`into Go struct field [^.\s]+\.(\S+) of type`)
+)
+
+// fieldPaths returns the paths of the fields the decoding error is about, e.g. spec.replicas.
+func fieldPaths(err error) []string {
+	var paths []string
+	if strictErr, ok := runtime.AsStrictDecodingError(err); ok {
+		for _, e := range strictErr.Errors() {
+			if m := strictFieldPattern.FindStringSubmatch(e.Error()); m != nil {
+				paths = append(paths, m[1])
+			}
+		}
+		return paths
+	}
+	if m := typeFieldPattern.FindStringSubmatch(err.Error()); m != nil {
+		paths = append(paths, m[1])
+	}
+	return paths
 }
@@ -1,14 +1,19 @@
 package loader_test
 
 import (
+	"errors"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/yashirook/vaptest/pkg/loader"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 )
 
 func TestLoader_LoadFromPaths(t *testing.T) {
@@ -17,13 +22,12 @@ func TestLoader_LoadFromPaths(t *testing.T) {
 	_ = corev1.AddToScheme(scheme)
 	_ = admissionregistrationv1.AddToScheme(scheme)
 
-	ldr := loader.NewLoader(scheme)
-
 	tests := []struct {
-		name     string
-		paths    []string
-		wantErr  bool
-		expected int
+		name             string
+		paths            []string
+		wantErr          bool
+		expected         int
+		expectedWarnings int
 	}{
 		{
 			name:     "ValidSingleManifest",
@@ -63,15 +67,18 @@ func TestLoader_LoadFromPaths(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name:    "UnknownResource",
-			paths:   []string{filepath.Join("testdata", "unknown_resource.yaml")},
-			wantErr: true,
+			name:             "UnknownResource",
+			paths:            []string{filepath.Join("testdata", "unknown_resource.yaml")},
+			wantErr:          false,
+			expected:         1,
+			expectedWarnings: 1,
 		},
 	}
 
 	for _, tt := range tests {
 		tt := tt // capture range variable
 		t.Run(tt.name, func(t *testing.T) {
+			ldr := loader.NewLoader(scheme)
 			objs, err := ldr.LoadObjectFromPaths(tt.paths)
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("LoadFromPaths() error = %v, wantErr %v", err, tt.wantErr)
@@ -79,6 +86,120 @@ func TestLoader_LoadFromPaths(t *testing.T) {
 			if len(objs) != tt.expected {
 				t.Errorf("Expected %d objects, got %d", tt.expected, len(objs))
 			}
+			if len(ldr.Warnings) != tt.expectedWarnings {
+				t.Errorf("Expected %d warnings, got %d: %v", tt.expectedWarnings, len(ldr.Warnings), ldr.Warnings)
+			}
+		})
+	}
+}
+
+func TestLoader_LoadBuiltinKinds(t *testing.T) {
+	ldr := loader.NewLoader(clientgoscheme.Scheme)
+
+	objs, err := ldr.LoadObjectFromPaths([]string{filepath.Join("testdata", "builtin_kinds.yaml")})
+	if err != nil {
+		t.Fatalf("LoadObjectFromPaths() error = %v", err)
+	}
+	if len(ldr.Warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", ldr.Warnings)
+	}
+
+	expectedKinds := []string{"HorizontalPodAutoscaler", "PodDisruptionBudget", "CronJob", "Deployment"}
+	if len(objs) != len(expectedKinds) {
+		t.Fatalf("Expected %d objects, got %d", len(expectedKinds), len(objs))
+	}
+	for i, obj := range objs {
+		if _, ok := obj.(*unstructured.Unstructured); ok {
+			t.Errorf("Expected typed object for %s, got unstructured", expectedKinds[i])
+		}
+		if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind != expectedKinds[i] {
+			t.Errorf("Expected kind %s, got %s", expectedKinds[i], kind)
+		}
+	}
+}
+
+func TestLoader_Strict(t *testing.T) {
+	tests := []struct {
+		name             string
+		path             string
+		strict           bool
+		expectedErr      string
+		expectedDocument int
+		expectedFields   []string
+	}{
+		{
+			name:   "ValidManifestsInStrictMode",
+			path:   filepath.Join("testdata", "valid_multiple_manifests.yaml"),
+			strict: true,
+		},
+		{
+			name:   "UnknownFieldWithoutStrictMode",
+			path:   filepath.Join("testdata", "strict", "unknown_field.yaml"),
+			strict: false,
+		},
+		{
+			name:             "UnknownField",
+			path:             filepath.Join("testdata", "strict", "unknown_field.yaml"),
+			strict:           true,
+			expectedErr:      `unknown field "spec.template.spec.contianers"`,
+			expectedDocument: 1,
+			expectedFields:   []string{"spec.template.spec.contianers"},
+		},
+		{
+			name:           "DuplicateField",
+			path:           filepath.Join("testdata", "strict", "duplicate_field.yaml"),
+			strict:         true,
+			expectedErr:    `duplicate field "metadata.name"`,
+			expectedFields: []string{"metadata.name"},
+		},
+		{
+			name:           "DuplicateFieldInList",
+			path:           filepath.Join("testdata", "strict", "duplicate_field_in_list.yaml"),
+			strict:         true,
+			expectedErr:    `duplicate field "spec.containers[1].image"`,
+			expectedFields: []string{"spec.containers[1].image"},
+		},
+		{
+			name:           "WrongType",
+			path:           filepath.Join("testdata", "strict", "wrong_type.yaml"),
+			strict:         true,
+			expectedErr:    "DeploymentSpec.spec.replicas of type int32",
+			expectedFields: []string{"spec.replicas"},
+		},
+		{
+			name:           "DuplicateFieldInUnknownKind",
+			path:           filepath.Join("testdata", "strict", "unknown_kind_duplicate_field.yaml"),
+			strict:         true,
+			expectedErr:    `duplicate field "metadata.name"`,
+			expectedFields: []string{"metadata.name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ldr := loader.NewLoader(clientgoscheme.Scheme)
+			ldr.Strict = tt.strict
+			_, err := ldr.LoadObjectFromPaths([]string{tt.path})
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("LoadObjectFromPaths() error = %v", err)
+				}
+				return
+			}
+
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got %q", tt.expectedErr, err.Error())
+			}
+			var strictErr *loader.StrictDecodeError
+			if !errors.As(err, &strictErr) {
+				t.Fatalf("Expected StrictDecodeError, got %v", err)
+			}
+			if strictErr.Path != tt.path || strictErr.Document != tt.expectedDocument {
+				t.Errorf("Expected %s document %d, got %s document %d", tt.path, tt.expectedDocument, strictErr.Path, strictErr.Document)
+			}
+			if !slices.Equal(strictErr.Fields, tt.expectedFields) {
+				t.Errorf("Expected fields %v, got %v", tt.expectedFields, strictErr.Fields)
+			}
 		})
 	}
 }
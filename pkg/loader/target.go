@@ -2,11 +2,14 @@ package loader
 
 import (
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 )
 
 // LoadPolicyFromPaths loads policies and bindings from the specified file paths.
 // It returns two slices: one containing ValidatingAdmissionPolicy objects and the other containing ValidatingAdmissionPolicyBinding objects.
 // If an error occurs during loading, it returns nil slices and the error.
+// Policies and bindings of other versions than v1 are rejected with an UnsupportedPolicyVersionError.
 //
 // Parameters:
 //   - paths: A slice of strings representing the file paths to load the policies and bindings from.
@@ -29,6 +32,13 @@ func (l *Loader) LoadPolicyFromPaths(paths []string) ([]*admissionregistrationv1
 			policies = append(policies, o)
 		case *admissionregistrationv1.ValidatingAdmissionPolicyBinding:
 			bindings = append(bindings, o)
+		case *admissionregistrationv1beta1.ValidatingAdmissionPolicy, *admissionregistrationv1beta1.ValidatingAdmissionPolicyBinding,
+			*admissionregistrationv1alpha1.ValidatingAdmissionPolicy, *admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding:
+			gvk, _, err := l.Scheme.ObjectKinds(obj)
+			if err != nil || len(gvk) == 0 {
+				return nil, nil, err
+			}
+			return nil, nil, &UnsupportedPolicyVersionError{Path: l.Source(obj), Kind: gvk[0].Kind, Version: gvk[0].GroupVersion().String()}
 		}
 	}
 	return policies, bindings, nil
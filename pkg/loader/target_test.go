@@ -1,12 +1,14 @@
 package loader_test
 
 import (
+	"errors"
 	"path/filepath"
 	"testing"
 
 	"github.com/yashirook/vaptest/pkg/loader"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 )
 
 func TestLoader_LoadPolicyFromPaths(t *testing.T) {
@@ -61,3 +63,17 @@ func TestLoader_LoadPolicyFromPaths(t *testing.T) {
 		})
 	}
 }
+
+func TestLoader_LoadPolicyFromPaths_UnsupportedVersion(t *testing.T) {
+	path := filepath.Join("testdata", "unsupported_policy", "v1beta1_policy.yaml")
+	ldr := loader.NewLoader(clientgoscheme.Scheme)
+	_, _, err := ldr.LoadPolicyFromPaths([]string{path})
+
+	var versionErr *loader.UnsupportedPolicyVersionError
+	if !errors.As(err, &versionErr) {
+		t.Fatalf("Expected UnsupportedPolicyVersionError, got %v", err)
+	}
+	if versionErr.Path != path || versionErr.Kind != "ValidatingAdmissionPolicy" || versionErr.Version != "admissionregistration.k8s.io/v1beta1" {
+		t.Errorf("Unexpected error %+v", versionErr)
+	}
+}
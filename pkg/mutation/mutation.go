@@ -0,0 +1,236 @@
+package mutation
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/yashirook/vaptest/pkg/celparse"
+	v1 "k8s.io/api/admissionregistration/v1"
+)
+
+// Operator is a kind of change made to a policy to create a mutant.
+type Operator string
+
+const (
+	// NegateComparison replaces a comparison operator with its negation, e.g. <= with >.
+	NegateComparison Operator = "negate-comparison"
+	// SwapLogical replaces && with || and || with &&.
+	SwapLogical Operator = "swap-logical"
+	// ChangeThreshold adds or subtracts one from a number literal.
+	ChangeThreshold Operator = "change-threshold"
+	// DropValidation removes a validation, unless it is the only thing the policy checks.
+	DropValidation Operator = "drop-validation"
+	// WidenRule replaces the operations, API groups or resources of a resource rule with *,
+	// or removes its resource names.
+	WidenRule Operator = "widen-rule"
+)
+
+var negations = map[string]string{
+	operators.Equals:        "!=",
+	operators.NotEquals:     "==",
+	operators.Less:          ">=",
+	operators.LessEquals:    ">",
+	operators.Greater:       "<=",
+	operators.GreaterEquals: "<",
+}
+
+var swaps = map[string]string{
+	operators.LogicalAnd: "||",
+	operators.LogicalOr:  "&&",
+}
+
+// Mutant is a copy of a policy with a single change.
+type Mutant struct {
+	Operator Operator
+	// Path is the field of the policy changed, e.g. spec.validations[0].expression.
+	Path string
+	// Original and Mutated are the values of the field before and after the change. Mutated is empty
+	// for a removed field.
+	Original string
+	Mutated  string
+	Policy   *v1.ValidatingAdmissionPolicy
+}
+
+// Generate returns the mutants of the policy: the mutants of its match conditions, variables and validations,
+// in source order of each expression, then those dropping a validation, then those widening a resource rule.
+// Expressions that do not parse are not mutated.
+func Generate(policy *v1.ValidatingAdmissionPolicy) []Mutant {
+	var mutants []Mutant
+	for i, condition := range policy.Spec.MatchConditions {
+		path := fmt.Sprintf("spec.matchConditions[%d].expression", i)
+		for _, m := range mutateExpression(condition.Expression) {
+			mutant := m.mutant(policy, path, condition.Expression)
+			mutant.Policy.Spec.MatchConditions[i].Expression = m.expression
+			mutants = append(mutants, mutant)
+		}
+	}
+	for i, variable := range policy.Spec.Variables {
+		path := fmt.Sprintf("spec.variables[%d].expression", i)
+		for _, m := range mutateExpression(variable.Expression) {
+			mutant := m.mutant(policy, path, variable.Expression)
+			mutant.Policy.Spec.Variables[i].Expression = m.expression
+			mutants = append(mutants, mutant)
+		}
+	}
+	for i, validation := range policy.Spec.Validations {
+		path := fmt.Sprintf("spec.validations[%d].expression", i)
+		for _, m := range mutateExpression(validation.Expression) {
+			mutant := m.mutant(policy, path, validation.Expression)
+			mutant.Policy.Spec.Validations[i].Expression = m.expression
+			mutants = append(mutants, mutant)
+		}
+	}
+
+	for i, validation := range policy.Spec.Validations {
+		if len(policy.Spec.Validations) == 1 && len(policy.Spec.AuditAnnotations) == 0 {
+			// 検証も監査アノテーションもないポリシーはapiserverが受け付けない
+			break
+		}
+		mutant := Mutant{
+			Operator: DropValidation,
+			Path:     fmt.Sprintf("spec.validations[%d]", i),
+			Original: validation.Expression,
+			Policy:   policy.DeepCopy(),
+		}
+		mutant.Policy.Spec.Validations = slices.Delete(mutant.Policy.Spec.Validations, i, i+1)
+		mutants = append(mutants, mutant)
+	}
+
+	if policy.Spec.MatchConstraints == nil {
+		return mutants
+	}
+	for i, rule := range policy.Spec.MatchConstraints.ResourceRules {
+		path := fmt.Sprintf("spec.matchConstraints.resourceRules[%d]", i)
+		widen := func(field string, values []string, set func(*v1.NamedRuleWithOperations)) {
+			mutant := Mutant{
+				Operator: WidenRule,
+				Path:     path + "." + field,
+				Original: "[" + strings.Join(values, ", ") + "]",
+				Policy:   policy.DeepCopy(),
+			}
+			set(&mutant.Policy.Spec.MatchConstraints.ResourceRules[i])
+			if field != "resourceNames" {
+				mutant.Mutated = "[*]"
+			}
+			mutants = append(mutants, mutant)
+		}
+
+		operations := make([]string, 0, len(rule.Operations))
+		for _, operation := range rule.Operations {
+			operations = append(operations, string(operation))
+		}
+		if !slices.Contains(operations, string(v1.OperationAll)) {
+			widen("operations", operations, func(r *v1.NamedRuleWithOperations) { r.Operations = []v1.OperationType{v1.OperationAll} })
+		}
+		if !slices.Contains(rule.APIGroups, "*") {
+			widen("apiGroups", rule.APIGroups, func(r *v1.NamedRuleWithOperations) { r.APIGroups = []string{"*"} })
+		}
+		if !slices.Contains(rule.Resources, "*") {
+			widen("resources", rule.Resources, func(r *v1.NamedRuleWithOperations) { r.Resources = []string{"*"} })
+		}
+		if len(rule.ResourceNames) > 0 {
+			widen("resourceNames", rule.ResourceNames, func(r *v1.NamedRuleWithOperations) { r.ResourceNames = nil })
+		}
+	}
+	return mutants
+}
+
+// expressionMutation is a mutated expression.
+type expressionMutation struct {
+	operator   Operator
+	expression string
+}
+
+func (m expressionMutation) mutant(policy *v1.ValidatingAdmissionPolicy, path, original string) Mutant {
+	return Mutant{
+		Operator: m.operator,
+		Path:     path,
+		Original: original,
+		Mutated:  m.expression,
+		Policy:   policy.DeepCopy(),
+	}
+}
+
+// mutateExpression returns the mutations of the operators and number literals of the expression,
+// in source order. Each mutation replaces a single token of the source, so the expression is only parsed, not
+// type-checked.
+func mutateExpression(expression string) []expressionMutation {
+	parsed, ok := celparse.Parse(expression)
+	if !ok {
+		return nil
+	}
+	text := []rune(expression)
+
+	type replacement struct {
+		start, end int
+		operator   Operator
+		token      string
+	}
+	var replacements []replacement
+	ast.PostOrderVisit(parsed.Expr(), ast.NewExprVisitor(func(e ast.Expr) {
+		r, ok := parsed.SourceInfo().GetOffsetRange(e.ID())
+		// 展開されたマクロのノードはソースに対応しない
+		if !ok || r.Start < 0 || int(r.Stop) > len(text) || r.Start >= r.Stop {
+			return
+		}
+		start, end := int(r.Start), int(r.Stop)
+		token := string(text[start:end])
+		switch e.Kind() {
+		case ast.CallKind:
+			function := e.AsCall().FunctionName()
+			if symbol, _ := operators.FindReverse(function); symbol != token {
+				return
+			}
+			if negated, ok := negations[function]; ok {
+				replacements = append(replacements, replacement{start, end, NegateComparison, negated})
+			}
+			if swapped, ok := swaps[function]; ok {
+				replacements = append(replacements, replacement{start, end, SwapLogical, swapped})
+			}
+		case ast.LiteralKind:
+			for _, changed := range changedNumbers(e.AsLiteral()) {
+				replacements = append(replacements, replacement{start, end, ChangeThreshold, changed})
+			}
+		}
+	}))
+	slices.SortStableFunc(replacements, func(a, b replacement) int { return a.start - b.start })
+
+	mutations := make([]expressionMutation, 0, len(replacements))
+	for _, r := range replacements {
+		mutated := string(text[:r.start]) + r.token + string(text[r.end:])
+		mutations = append(mutations, expressionMutation{operator: r.operator, expression: mutated})
+	}
+	return mutations
+}
+
+// changedNumbers returns the literals of the number plus and minus one, or nothing for other literals.
+func changedNumbers(literal ref.Val) []string {
+	switch v := literal.(type) {
+	case types.Int:
+		return []string{strconv.FormatInt(int64(v)+1, 10), strconv.FormatInt(int64(v)-1, 10)}
+	case types.Uint:
+		changed := []string{strconv.FormatUint(uint64(v)+1, 10) + "u"}
+		if v > 0 {
+			changed = append(changed, strconv.FormatUint(uint64(v)-1, 10)+"u")
+		}
+		return changed
+	case types.Double:
+		return []string{formatDouble(float64(v) + 1), formatDouble(float64(v) - 1)}
+	}
+	return nil
+}
+
+// formatDouble formats the number as a CEL double literal, which needs a decimal point or an exponent.
+func formatDouble(v float64) string {
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEnN") {
+		s += ".0"
+	}
+	return s
+}
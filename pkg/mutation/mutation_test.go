@@ -0,0 +1,130 @@
+package mutation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMutateExpression(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       []expressionMutation
+	}{
+		{
+			name:       "比較演算子の否定と数値の変更",
+			expression: "object.spec.replicas <= 5",
+			want: []expressionMutation{
+				{NegateComparison, "object.spec.replicas > 5"},
+				{ChangeThreshold, "object.spec.replicas <= 6"},
+				{ChangeThreshold, "object.spec.replicas <= 4"},
+			},
+		},
+		{
+			name:       "論理演算子の入れ替え",
+			expression: "a == 'x' || b != 1.5",
+			want: []expressionMutation{
+				{NegateComparison, "a != 'x' || b != 1.5"},
+				{SwapLogical, "a == 'x' && b != 1.5"},
+				{NegateComparison, "a == 'x' || b == 1.5"},
+				{ChangeThreshold, "a == 'x' || b != 2.5"},
+				{ChangeThreshold, "a == 'x' || b != 0.5"},
+			},
+		},
+		{
+			name:       "符号なし整数と負の数",
+			expression: "size(x) >= 0u && y < -1",
+			want: []expressionMutation{
+				{NegateComparison, "size(x) < 0u && y < -1"},
+				{ChangeThreshold, "size(x) >= 1u && y < -1"},
+				{SwapLogical, "size(x) >= 0u || y < -1"},
+				{NegateComparison, "size(x) >= 0u && y >= -1"},
+				{ChangeThreshold, "size(x) >= 0u && y < 0"},
+				{ChangeThreshold, "size(x) >= 0u && y < -2"},
+			},
+		},
+		{
+			name:       "マクロが展開したノードは変更しない",
+			expression: "x.all(i, i > 1)",
+			want: []expressionMutation{
+				{NegateComparison, "x.all(i, i <= 1)"},
+				{ChangeThreshold, "x.all(i, i > 2)"},
+				{ChangeThreshold, "x.all(i, i > 0)"},
+			},
+		},
+		{
+			name:       "構文エラーの式は変更しない",
+			expression: "object.spec.replicas <=",
+			want:       nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mutateExpression(tt.expression)
+			if len(tt.want) == 0 {
+				assert.Empty(t, got)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	policy := &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy"},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					ResourceNames: []string{"web"},
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{v1.Create},
+						Rule:       v1.Rule{APIGroups: []string{"*"}, APIVersions: []string{"v1"}, Resources: []string{"deployments"}},
+					},
+				}},
+			},
+			MatchConditions: []v1.MatchCondition{{Name: "exclude", Expression: "!has(object.metadata.labels)"}},
+			Variables:       []v1.Variable{{Name: "replicas", Expression: "object.spec.replicas"}},
+			Validations: []v1.Validation{
+				{Expression: "variables.replicas == 1"},
+				{Expression: "has(object.metadata.labels)"},
+			},
+		},
+	}
+
+	type summary struct {
+		operator          Operator
+		path              string
+		original, mutated string
+	}
+	var got []summary
+	for _, m := range Generate(policy) {
+		got = append(got, summary{m.Operator, m.Path, m.Original, m.Mutated})
+	}
+	assert.Equal(t, []summary{
+		{NegateComparison, "spec.validations[0].expression", "variables.replicas == 1", "variables.replicas != 1"},
+		{ChangeThreshold, "spec.validations[0].expression", "variables.replicas == 1", "variables.replicas == 2"},
+		{ChangeThreshold, "spec.validations[0].expression", "variables.replicas == 1", "variables.replicas == 0"},
+		{DropValidation, "spec.validations[0]", "variables.replicas == 1", ""},
+		{DropValidation, "spec.validations[1]", "has(object.metadata.labels)", ""},
+		// すでに*のapiGroupsは広げない
+		{WidenRule, "spec.matchConstraints.resourceRules[0].operations", "[CREATE]", "[*]"},
+		{WidenRule, "spec.matchConstraints.resourceRules[0].resources", "[deployments]", "[*]"},
+		{WidenRule, "spec.matchConstraints.resourceRules[0].resourceNames", "[web]", ""},
+	}, got)
+
+	mutants := Generate(policy)
+	assert.Equal(t, "variables.replicas != 1", mutants[0].Policy.Spec.Validations[0].Expression)
+	assert.Equal(t, "variables.replicas == 1", policy.Spec.Validations[0].Expression, "元のポリシーは変更しない")
+	assert.Equal(t, []v1.Validation{{Expression: "variables.replicas == 1"}}, mutants[4].Policy.Spec.Validations)
+	assert.Empty(t, mutants[7].Policy.Spec.MatchConstraints.ResourceRules[0].ResourceNames)
+
+	// 唯一のバリデーションは削除しない
+	policy.Spec.Validations = policy.Spec.Validations[1:]
+	for _, m := range Generate(policy) {
+		assert.NotEqual(t, DropValidation, m.Operator)
+	}
+}
@@ -0,0 +1,132 @@
+package mutation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/yashirook/vaptest/pkg/loader"
+	"github.com/yashirook/vaptest/pkg/testsuite"
+	"github.com/yashirook/vaptest/pkg/validation"
+	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Result is the outcome of running the test suites against a mutant.
+type Result struct {
+	Mutant Mutant
+	// Killed reports whether a test case noticed the mutant. KilledBy is the first case that failed.
+	Killed   bool
+	KilledBy *testsuite.CaseResult
+	// Stillborn reports whether the mutant is not a valid policy, e.g. an expression no longer compiles, or a
+	// suite could not be run against it. Err tells why. Stillborn mutants are neither killed nor survived and
+	// do not count in the score.
+	Stillborn bool
+	Err       error
+}
+
+// Tester runs test suites against the mutants of their policies.
+type Tester struct {
+	Runner *testsuite.Runner
+}
+
+// NewTester creates a Tester running the suites with the runner.
+func NewTester(runner *testsuite.Runner) *Tester {
+	return &Tester{Runner: runner}
+}
+
+// Run runs the suites against each mutant of their policies and returns the results in the order the policies
+// appear in the suites. Each mutant runs the suites loading its policy until a test case fails. The suites must
+// pass as they are, or a surviving mutant would say nothing about them.
+func (t *Tester) Run(ctx context.Context, suites []*testsuite.Suite) ([]Result, error) {
+	var policies []*v1.ValidatingAdmissionPolicy
+	suitesOf := map[string][]*testsuite.Suite{}
+	sources := map[string]string{}
+	for _, suite := range suites {
+		results, err := t.Runner.Run(ctx, suite)
+		if err != nil {
+			return nil, fmt.Errorf("failed to run test suite %s: %w", suite.Path, err)
+		}
+		for _, result := range results {
+			if !result.Passed() {
+				return nil, fmt.Errorf("test suite %s must pass before mutation testing: case %q failed", suite.Path, result.Case.Name)
+			}
+		}
+
+		ldr := loader.NewLoader(t.Runner.Scheme)
+		ldr.Strict = t.Runner.Strict
+		loaded, _, err := ldr.LoadPolicyFromPaths(suite.Policies)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load policy objects of test suite %s: %w", suite.Path, err)
+		}
+		for _, policy := range loaded {
+			if _, ok := suitesOf[policy.Name]; !ok {
+				policies = append(policies, policy)
+				sources[policy.Name] = ldr.Source(policy)
+			}
+			suitesOf[policy.Name] = append(suitesOf[policy.Name], suite)
+		}
+	}
+	warnings := t.Runner.Warnings
+	defer func() {
+		t.Runner.Override = nil
+		// 変異体ごとの実行で同じ警告が繰り返されるため、元のスイートの警告だけを残す
+		t.Runner.Warnings = warnings
+	}()
+
+	var results []Result
+	for _, policy := range policies {
+		for _, mutant := range Generate(policy) {
+			result := Result{Mutant: mutant}
+			source := func(runtime.Object) string { return sources[policy.Name] }
+			if errs := validation.ValidatePolicyObjects([]*v1.ValidatingAdmissionPolicy{mutant.Policy}, nil, t.Runner.Scheme, source); len(errs) > 0 {
+				result.Stillborn, result.Err = true, errors.Join(errs...)
+				results = append(results, result)
+				continue
+			}
+			t.Runner.Override = mutant.Policy
+			for _, suite := range suitesOf[policy.Name] {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				caseResults, err := t.Runner.Run(ctx, suite)
+				if err != nil && ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				if err != nil {
+					result.Stillborn, result.Err = true, fmt.Errorf("failed to run test suite %s: %w", suite.Path, err)
+					break
+				}
+				for i := range caseResults {
+					if !caseResults[i].Passed() {
+						result.Killed, result.KilledBy = true, &caseResults[i]
+						break
+					}
+				}
+				if result.Killed || result.Stillborn {
+					break
+				}
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// Score returns the number of killed mutants, the number of mutants that are not stillborn and the percentage of
+// them killed, 100 when there are none.
+func Score(results []Result) (killed, total int, percent float64) {
+	for _, result := range results {
+		if result.Stillborn {
+			continue
+		}
+		total++
+		if result.Killed {
+			killed++
+		}
+	}
+	if total == 0 {
+		return killed, total, 100
+	}
+	return killed, total, float64(killed) * 100 / float64(total)
+}
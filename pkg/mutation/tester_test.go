@@ -0,0 +1,78 @@
+package mutation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yashirook/vaptest/pkg/defaults"
+	"github.com/yashirook/vaptest/pkg/testsuite"
+)
+
+func newTestRunner(t *testing.T) *testsuite.Runner {
+	return testsuite.NewRunner(defaults.NewScheme())
+}
+
+func TestTesterRun(t *testing.T) {
+	suite, err := testsuite.Load("testdata/suite.yaml")
+	require.NoError(t, err)
+
+	runner := newTestRunner(t)
+	results, err := NewTester(runner).Run(context.Background(), []*testsuite.Suite{suite})
+	require.NoError(t, err)
+
+	type outcome struct {
+		path     string
+		killedBy string
+	}
+	var got []outcome
+	for _, result := range results {
+		killedBy := ""
+		if result.KilledBy != nil {
+			killedBy = result.KilledBy.Case.Name
+		}
+		assert.Equal(t, result.KilledBy != nil, result.Killed)
+		assert.False(t, result.Stillborn)
+		assert.NoError(t, result.Err)
+		got = append(got, outcome{result.Mutant.Path, killedBy})
+	}
+	assert.Equal(t, []outcome{
+		{"spec.validations[0].expression", "deployment at the limit is allowed"},
+		// 上限を超えるケースが閾値の引き上げを検出する
+		{"spec.validations[0].expression", "deployment over the limit is denied"},
+		{"spec.validations[0].expression", "deployment at the limit is allowed"},
+		// DELETEや他のAPIグループのケースがないため生き残る
+		{"spec.matchConstraints.resourceRules[0].operations", ""},
+		{"spec.matchConstraints.resourceRules[0].apiGroups", ""},
+		{"spec.matchConstraints.resourceRules[0].resources", "statefulset is not checked"},
+	}, got)
+	assert.Nil(t, runner.Override, "実行後は元のポリシーに戻す")
+
+	killed, total, percent := Score(results)
+	assert.Equal(t, 4, killed)
+	assert.Equal(t, 6, total)
+	assert.InDelta(t, 66.7, percent, 0.1)
+}
+
+func TestTesterRunFailingSuite(t *testing.T) {
+	suite, err := testsuite.Load("testdata/suite.yaml")
+	require.NoError(t, err)
+	suite.Tests[0].Expect = testsuite.ResultDeny
+
+	_, err = NewTester(newTestRunner(t)).Run(context.Background(), []*testsuite.Suite{suite})
+	assert.ErrorContains(t, err, `must pass before mutation testing: case "deployment at the limit is allowed" failed`)
+}
+
+func TestScore(t *testing.T) {
+	killed, total, percent := Score(nil)
+	assert.Equal(t, 0, killed)
+	assert.Equal(t, 0, total)
+	assert.Equal(t, 100.0, percent, "変異体がなければ100%とする")
+
+	killed, total, percent = Score([]Result{{Killed: true}, {}, {Stillborn: true, Err: errors.New("invalid")}})
+	assert.Equal(t, 1, killed)
+	assert.Equal(t, 2, total, "死産の変異体は数えない")
+	assert.Equal(t, 50.0, percent)
+}
@@ -0,0 +1,188 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/yashirook/vaptest/pkg/coverage"
+)
+
+// Formats of coverage reports.
+const (
+	CoverageFormatText = "text"
+	CoverageFormatJSON = "json"
+	CoverageFormatLCOV = "lcov"
+)
+
+var CoverageFormats = []string{CoverageFormatText, CoverageFormatJSON, CoverageFormatLCOV}
+
+// CoverageFormatter prints the coverage of the expressions of policies as a text table, JSON or LCOV.
+type CoverageFormatter struct {
+	Writer io.Writer
+	Format string
+}
+
+func NewCoverageFormatter(format string) *CoverageFormatter {
+	return &CoverageFormatter{Writer: os.Stdout, Format: format}
+}
+
+func (f *CoverageFormatter) Output(profile *coverage.Profile) error {
+	switch f.Format {
+	case CoverageFormatText:
+		return f.text(profile)
+	case CoverageFormatJSON:
+		return f.json(profile)
+	case CoverageFormatLCOV:
+		return f.lcov(profile)
+	default:
+		return fmt.Errorf("unknown coverage format %q: must be one of %v", f.Format, CoverageFormats)
+	}
+}
+
+func (f *CoverageFormatter) text(profile *coverage.Profile) error {
+	writer := tabwriter.NewWriter(f.Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "POLICY\tBINDING\tEXPRESSION\tTRUE\tFALSE\tERROR")
+	for _, e := range profile.Expressions {
+		binding := e.Binding
+		if binding == "" {
+			binding = "-"
+		}
+		expression := strings.TrimPrefix(e.Path(), "spec.")
+		if e.Name != "" {
+			expression += " " + e.Name
+		}
+		counts := "-\t-\t-"
+		if e.Reached() {
+			counts = fmt.Sprintf("%d\t%d\t%d", e.True, e.False, e.Error)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", e.Policy, binding, expression, counts)
+	}
+	writer.Flush()
+
+	uncovered := false
+	for _, e := range profile.Expressions {
+		if !e.Reached() || allCovered(e.Conditions) {
+			continue
+		}
+		if !uncovered {
+			fmt.Fprintln(f.Writer)
+			fmt.Fprintln(f.Writer, "UNCOVERED BRANCHES")
+			uncovered = true
+		}
+		fmt.Fprintf(f.Writer, "%s, binding %s, %s:\n", e.Policy, e.Binding, e.Path())
+		fmt.Fprintf(f.Writer, "  %s\n", strings.ReplaceAll(strings.TrimRight(e.Highlight(), "\n"), "\n", "\n  "))
+		for _, c := range e.Conditions {
+			if !c.Covered() {
+				fmt.Fprintf(f.Writer, "    %s: %s\n", strings.Join(strings.Fields(c.Text), " "), c.Missing())
+			}
+		}
+	}
+
+	reached, total := profile.Summary()
+	fmt.Fprintln(f.Writer)
+	fmt.Fprintf(f.Writer, "coverage: %d/%d expressions reached (%.1f%%)\n", reached, total, profile.Percent())
+	if taken, branches := profile.BranchSummary(); branches > 0 {
+		fmt.Fprintf(f.Writer, "branches: %d/%d taken (%.1f%%)\n", taken, branches, float64(taken)*100/float64(branches))
+	}
+	return nil
+}
+
+func allCovered(conditions []*coverage.Condition) bool {
+	for _, c := range conditions {
+		if !c.Covered() {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *CoverageFormatter) json(profile *coverage.Profile) error {
+	reached, total := profile.Summary()
+	taken, branches := profile.BranchSummary()
+	report := struct {
+		Expressions   []*coverage.Expression `json:"expressions"`
+		Reached       int                    `json:"reached"`
+		Total         int                    `json:"total"`
+		Percent       float64                `json:"percent"`
+		BranchesTaken int                    `json:"branchesTaken"`
+		Branches      int                    `json:"branches"`
+	}{profile.Expressions, reached, total, profile.Percent(), taken, branches}
+	if report.Expressions == nil {
+		report.Expressions = []*coverage.Expression{}
+	}
+	encoder := json.NewEncoder(f.Writer)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(report)
+}
+
+// lcov prints a record for each policy file. Each expression is a line hit by its evaluations, and its
+// true and false results are the branches of a block per binding, followed by the true and false results of
+// its conditions at their lines. Expressions of policies whose file is unknown are reported under the policy
+// name at line 0.
+func (f *CoverageFormatter) lcov(profile *coverage.Profile) error {
+	var sources []string
+	bySource := map[string][]*coverage.Expression{}
+	for _, e := range profile.Expressions {
+		source := e.Source
+		if source == "" {
+			source = e.Policy
+		}
+		if _, ok := bySource[source]; !ok {
+			sources = append(sources, source)
+		}
+		bySource[source] = append(bySource[source], e)
+	}
+
+	for _, source := range sources {
+		fmt.Fprintf(f.Writer, "SF:%s\n", source)
+
+		var lines []int
+		hits := map[int]int{}
+		blocks := map[string]int{}
+		branches, branchesHit := 0, 0
+		for _, e := range bySource[source] {
+			if _, ok := hits[e.Line]; !ok {
+				lines = append(lines, e.Line)
+			}
+			hits[e.Line] += e.True + e.False + e.Error
+
+			key := e.Policy + "/" + e.Binding
+			if _, ok := blocks[key]; !ok {
+				blocks[key] = len(blocks)
+			}
+			type branch struct{ line, taken int }
+			var results []branch
+			if e.Kind != coverage.KindVariable {
+				results = append(results, branch{e.Line, e.True}, branch{e.Line, e.False})
+			}
+			for _, c := range e.Conditions {
+				results = append(results, branch{c.Line, c.True}, branch{c.Line, c.False})
+			}
+			for i, b := range results {
+				count := "-"
+				if e.Reached() {
+					count = fmt.Sprint(b.taken)
+				}
+				fmt.Fprintf(f.Writer, "BRDA:%d,%d,%d,%s\n", b.line, blocks[key], i, count)
+				branches++
+				if b.taken > 0 {
+					branchesHit++
+				}
+			}
+		}
+		linesHit := 0
+		for _, line := range lines {
+			fmt.Fprintf(f.Writer, "DA:%d,%d\n", line, hits[line])
+			if hits[line] > 0 {
+				linesHit++
+			}
+		}
+		fmt.Fprintf(f.Writer, "BRF:%d\nBRH:%d\nLF:%d\nLH:%d\nend_of_record\n", branches, branchesHit, len(lines), linesHit)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/yashirook/vaptest/pkg/fuzz"
+)
+
+// FuzzReportFormatter prints the findings of a fuzzing run, with the fixture of each finding or its message,
+// and the number of objects evaluated.
+type FuzzReportFormatter struct {
+	Writer io.Writer
+}
+
+func NewFuzzReportFormatter() *FuzzReportFormatter {
+	return &FuzzReportFormatter{Writer: os.Stdout}
+}
+
+// Output prints the report of a run with the seed. fixtures maps findings to the paths of their fixture files,
+// if written.
+func (f *FuzzReportFormatter) Output(report *fuzz.Report, seed int64, fixtures map[string]string) error {
+	if len(report.Findings) > 0 {
+		writer := tabwriter.NewWriter(f.Writer, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "TYPE\tPOLICY\tVALIDATION\tKIND\tFIXTURE")
+		for _, finding := range report.Findings {
+			fixture, ok := fixtures[finding.FixtureName()]
+			if !ok {
+				fixture = oneLine(finding.Message)
+			}
+			fmt.Fprintf(writer, "%s\t%s\tspec.validations[%d]\t%s\t%s\n", finding.Type, finding.Policy, finding.Index, finding.Kind.Kind, fixture)
+		}
+		writer.Flush()
+		fmt.Fprintln(f.Writer)
+	}
+	fmt.Fprintf(f.Writer, "%d findings in %d objects of %d kinds (seed %d)\n", len(report.Findings), report.Objects, len(report.Kinds), seed)
+	return nil
+}
@@ -0,0 +1,39 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/yashirook/vaptest/pkg/lint"
+)
+
+// LintReportFormatter prints the findings of the linter with their rule and severity, followed by the number of
+// findings of each severity.
+type LintReportFormatter struct {
+	Writer io.Writer
+}
+
+func NewLintReportFormatter() *LintReportFormatter {
+	return &LintReportFormatter{Writer: os.Stdout}
+}
+
+func (f *LintReportFormatter) Output(findings []lint.Finding, policies, bindings int) error {
+	if len(findings) > 0 {
+		writer := tabwriter.NewWriter(f.Writer, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "SEVERITY\tRULE\tOBJECT\tFIELD\tMESSAGE")
+		for _, finding := range findings {
+			fmt.Fprintf(writer, "%s\t%s\t%s/%s\t%s\t%s\n", finding.Severity, finding.Rule, finding.Kind, finding.Name, finding.Field, finding.Message)
+		}
+		writer.Flush()
+		fmt.Fprintln(f.Writer)
+	}
+
+	counts := map[lint.Severity]int{}
+	for _, finding := range findings {
+		counts[finding.Severity]++
+	}
+	fmt.Fprintf(f.Writer, "%d errors, %d warnings in %d policies and %d bindings\n", counts[lint.SeverityError], counts[lint.SeverityWarning], policies, bindings)
+	return nil
+}
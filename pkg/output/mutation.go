@@ -0,0 +1,86 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/yashirook/vaptest/pkg/mutation"
+)
+
+// MutationReportFormatter prints the outcome of each mutant, the changes of the surviving mutants
+// and the mutation score.
+type MutationReportFormatter struct {
+	Writer io.Writer
+}
+
+func NewMutationReportFormatter() *MutationReportFormatter {
+	return &MutationReportFormatter{Writer: os.Stdout}
+}
+
+func (f *MutationReportFormatter) Output(results []mutation.Result) error {
+	writer := tabwriter.NewWriter(f.Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "MUTANT\tPOLICY\tFIELD\tOPERATOR\tSTATUS")
+	for i, result := range results {
+		m := result.Mutant
+		status := "survived"
+		switch {
+		case result.Stillborn:
+			status = "stillborn"
+		case result.Killed:
+			status = fmt.Sprintf("killed by %q", result.KilledBy.Case.Name)
+		}
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%s\t%s\n", i+1, m.Policy.Name, m.Path, m.Operator, status)
+	}
+	writer.Flush()
+
+	survived := false
+	for i, result := range results {
+		if result.Killed || result.Stillborn {
+			continue
+		}
+		if !survived {
+			fmt.Fprintln(f.Writer)
+			fmt.Fprintln(f.Writer, "SURVIVED MUTANTS")
+			survived = true
+		}
+		m := result.Mutant
+		fmt.Fprintf(f.Writer, "#%d %s, %s (%s):\n", i+1, m.Policy.Name, m.Path, m.Operator)
+		fmt.Fprintf(f.Writer, "  - %s\n", oneLine(m.Original))
+		if m.Mutated == "" {
+			fmt.Fprintln(f.Writer, "  + (removed)")
+		} else {
+			fmt.Fprintf(f.Writer, "  + %s\n", oneLine(m.Mutated))
+		}
+	}
+
+	stillborn := false
+	for i, result := range results {
+		if !result.Stillborn {
+			continue
+		}
+		if !stillborn {
+			fmt.Fprintln(f.Writer)
+			fmt.Fprintln(f.Writer, "STILLBORN MUTANTS")
+			stillborn = true
+		}
+		m := result.Mutant
+		fmt.Fprintf(f.Writer, "#%d %s, %s (%s): %v\n", i+1, m.Policy.Name, m.Path, m.Operator, result.Err)
+	}
+
+	killed, total, percent := mutation.Score(results)
+	fmt.Fprintln(f.Writer)
+	fmt.Fprintf(f.Writer, "mutation score: %d/%d killed (%.1f%%)", killed, total, percent)
+	if n := len(results) - total; n > 0 {
+		fmt.Fprintf(f.Writer, ", %d stillborn", n)
+	}
+	fmt.Fprintln(f.Writer)
+	return nil
+}
+
+// oneLine joins the lines of an expression written as a block scalar.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
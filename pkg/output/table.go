@@ -68,5 +68,38 @@ func (d *TableFormatter) Output(results validator.ValidationResultList) error {
 
 	writer.Flush()
 
+	printDefaultedFields(results.FailedResults())
+
 	return nil
 }
+
+// printDefaultedFields lists the fields set by API defaulting for each failed target,
+// since a failure may come from a value that is not written in the manifest.
+func printDefaultedFields(results validator.ValidationResultList) {
+	printed := make(map[string]bool)
+	var lines []string
+	for _, result := range results {
+		if len(result.DefaultedFields) == 0 {
+			continue
+		}
+		obj := result.Target
+		resource := fmt.Sprintf("%s/%s", obj.Resource, obj.ResourceName)
+		if obj.Namespace != "" {
+			resource = fmt.Sprintf("%s/%s", obj.Namespace, resource)
+		}
+		if printed[resource] {
+			continue
+		}
+		printed[resource] = true
+		lines = append(lines, fmt.Sprintf("  %s: %s", resource, strings.Join(result.DefaultedFields, ", ")))
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("DEFAULTED FIELDS")
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,59 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/yashirook/vaptest/pkg/testsuite"
+)
+
+// TestReportFormatter prints the outcome of each test case and a summary of the test suites.
+type TestReportFormatter struct {
+	Writer io.Writer
+}
+
+func NewTestReportFormatter() *TestReportFormatter {
+	return &TestReportFormatter{Writer: os.Stdout}
+}
+
+func (f *TestReportFormatter) Output(results []testsuite.CaseResult) error {
+	passed, failed := 0, 0
+	suite := ""
+	for i, result := range results {
+		if i == 0 || result.Suite != suite {
+			suite = result.Suite
+			fmt.Fprintf(f.Writer, "=== %s\n", suite)
+		}
+
+		c := result.Case
+		if result.Passed() {
+			passed++
+			fmt.Fprintf(f.Writer, "PASS  %s\n", c.Name)
+			continue
+		}
+		failed++
+		if result.Err != nil {
+			fmt.Fprintf(f.Writer, "FAIL  %s: %v\n", c.Name, result.Err)
+			continue
+		}
+		if result.Actual != c.Expect {
+			fmt.Fprintf(f.Writer, "FAIL  %s: policy %s, %s: expected %s, got %s\n", c.Name, c.Policy, c.Resource, c.Expect, result.Actual)
+			for _, message := range result.Messages {
+				fmt.Fprintf(f.Writer, "        %s\n", message)
+			}
+		} else {
+			fmt.Fprintf(f.Writer, "FAIL  %s: policy %s, %s: assertions do not match\n", c.Name, c.Policy, c.Resource)
+		}
+		for _, line := range strings.Split(strings.TrimRight(result.Diff, "\n"), "\n") {
+			if line != "" {
+				fmt.Fprintf(f.Writer, "        %s\n", line)
+			}
+		}
+	}
+
+	fmt.Fprintln(f.Writer)
+	fmt.Fprintf(f.Writer, "%d passed, %d failed\n", passed, failed)
+	return nil
+}
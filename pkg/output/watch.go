@@ -0,0 +1,103 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/yashirook/vaptest/pkg/watch"
+)
+
+const (
+	clearScreen = "\033[H\033[2J"
+	highlight   = "\033[1;33m"
+	resetStyle  = "\033[0m"
+)
+
+// WatchFormatter prints the summary of each run of the watch mode: the failed pairs and the pairs that changed
+// since the previous run, marked with *, followed by the files and policies with errors.
+type WatchFormatter struct {
+	Writer io.Writer
+	// Terminal clears the screen before each summary and highlights the changed pairs in color.
+	Terminal bool
+}
+
+func NewWatchFormatter() *WatchFormatter {
+	return &WatchFormatter{Writer: os.Stdout}
+}
+
+func (f *WatchFormatter) Output(summary *watch.Summary) error {
+	if f.Terminal {
+		fmt.Fprint(f.Writer, clearScreen)
+	} else if summary.Run > 1 {
+		fmt.Fprintln(f.Writer)
+	}
+	fmt.Fprintf(f.Writer, "run %d: %d policies, %d targets, %d pairs evaluated\n", summary.Run, summary.Policies, summary.Targets, summary.Evaluated)
+
+	var rows []watch.Row
+	for _, row := range summary.Rows {
+		if row.Status != watch.StatusPass || row.Changed {
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) > 0 {
+		var table bytes.Buffer
+		writer := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, " \tPOLICY\tEVALUATED_RESOURCE\tRESULT\tERRORS")
+		for _, row := range rows {
+			marker := " "
+			if row.Changed {
+				marker = "*"
+			}
+			errors := strings.Join(row.Messages, ", ")
+			if row.Status == watch.StatusPass || row.Removed || errors == "" {
+				errors = "-"
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s/%s\t%s\t%s\n", marker, row.Policy, row.Target.Resource, row.Target.ResourceName, watchResult(row), errors)
+		}
+		writer.Flush()
+
+		fmt.Fprintln(f.Writer)
+		lines := strings.SplitAfter(table.String(), "\n")
+		for i, line := range lines {
+			if f.Terminal && i > 0 && i <= len(rows) && rows[i-1].Changed {
+				line = highlight + strings.TrimSuffix(line, "\n") + resetStyle + "\n"
+			}
+			fmt.Fprint(f.Writer, line)
+		}
+	}
+
+	if len(summary.Errors) > 0 {
+		fmt.Fprintln(f.Writer)
+		fmt.Fprintln(f.Writer, "ERRORS")
+		for _, err := range summary.Errors {
+			fmt.Fprintf(f.Writer, "  %v\n", err)
+		}
+	}
+	for _, w := range summary.Warnings {
+		fmt.Fprintf(f.Writer, "warning: %v\n", w)
+	}
+
+	fmt.Fprintln(f.Writer)
+	fmt.Fprintf(f.Writer, "%d passed, %d failed, %d errors; waiting for changes\n", summary.Count(watch.StatusPass), summary.Count(watch.StatusFail), summary.Count(watch.StatusError))
+	return nil
+}
+
+// watchResult returns the result of the row with its previous result if it changed, e.g. FAIL (was PASS).
+func watchResult(row watch.Row) string {
+	switch {
+	case row.Removed:
+		return fmt.Sprintf("REMOVED (was %s)", row.Previous)
+	case !row.Changed:
+		return string(row.Status)
+	case row.Previous == "":
+		return fmt.Sprintf("%s (new)", row.Status)
+	case row.Previous == row.Status:
+		return fmt.Sprintf("%s (changed)", row.Status)
+	default:
+		return fmt.Sprintf("%s (was %s)", row.Status, row.Previous)
+	}
+}
@@ -0,0 +1,203 @@
+package repl
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/yashirook/vaptest/pkg/jsonfield"
+)
+
+var identifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+var marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// segment is a field, map key or list index selected in a field path.
+type segment struct {
+	key   string
+	index int
+	list  bool
+}
+
+// Complete returns the completions of the field path the line ends with, e.g. object.spec.re, and the index in
+// the line the path starts at. Each completion is a path replacing it. The fields are the keys of the value of
+// the path, and the fields of its schema, so that absent optional fields are completed too. Keys that are not
+// identifiers are completed with an index, e.g. object.metadata.labels['app.kubernetes.io/name'].
+func (s *Session) Complete(line string) (int, []string) {
+	if err := s.init(); err != nil {
+		return 0, nil
+	}
+	start := pathStart(line)
+	path := line[start:]
+	root, segments, partial, ok := splitPath(path)
+	if !ok {
+		return start, nil
+	}
+	var completions []string
+	if root == "" {
+		for _, name := range Variables {
+			if strings.HasPrefix(name, partial) {
+				completions = append(completions, name)
+			}
+		}
+		return start, completions
+	}
+	goType, known := s.goTypes[root]
+	if !known {
+		return start, nil
+	}
+	value, goType := resolve(s.activation[root], goType, segments)
+	parent := path[:len(path)-len(partial)-1]
+	for _, name := range fieldNames(value, goType) {
+		if !strings.HasPrefix(name, partial) {
+			continue
+		}
+		if identifier.MatchString(name) {
+			completions = append(completions, parent+"."+name)
+		} else {
+			completions = append(completions, parent+"['"+strings.ReplaceAll(name, "'", `\'`)+"']")
+		}
+	}
+	return start, completions
+}
+
+// pathStart returns the index the field path ending the line starts at: the path is made of identifiers, dots
+// and indexes.
+func pathStart(line string) int {
+	i := len(line)
+	for i > 0 {
+		c := line[i-1]
+		switch {
+		case c == '.' || c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+			i--
+		case c == ']':
+			j := strings.LastIndexByte(line[:i-1], '[')
+			if j < 0 {
+				return i
+			}
+			i = j
+		default:
+			return i
+		}
+	}
+	return 0
+}
+
+// splitPath splits a field path into the variable it starts with, the fields, keys and indexes selected from
+// it, and the partial field name it ends with. A path without a dot is a partial variable name, with an empty
+// root. It returns false if the path does not end with a partial field name, e.g. object.spec.containers[0].
+func splitPath(path string) (string, []segment, string, bool) {
+	name, rest := leadingIdentifier(path)
+	if rest == "" {
+		return "", nil, name, true
+	}
+	if name == "" {
+		return "", nil, "", false
+	}
+	var segments []segment
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			field, r := leadingIdentifier(rest[1:])
+			if r == "" {
+				return name, segments, field, true
+			}
+			segments = append(segments, segment{key: field})
+			rest = r
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return "", nil, "", false
+			}
+			seg, ok := parseIndex(rest[1:end])
+			if !ok {
+				return "", nil, "", false
+			}
+			segments = append(segments, seg)
+			rest = rest[end+1:]
+		default:
+			return "", nil, "", false
+		}
+	}
+	return "", nil, "", false
+}
+
+func leadingIdentifier(s string) (string, string) {
+	i := 0
+	for i < len(s) && (s[i] == '_' || '0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'z' || 'A' <= s[i] && s[i] <= 'Z') {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// parseIndex parses the index of a list, e.g. 0, or the key of a map, e.g. 'app'.
+func parseIndex(s string) (segment, bool) {
+	if index, err := strconv.Atoi(s); err == nil {
+		return segment{index: index, list: true}, true
+	}
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return segment{key: strings.ReplaceAll(s[1:len(s)-1], `\`+s[:1], s[:1])}, true
+	}
+	return segment{}, false
+}
+
+// resolve returns the value and the Go type selected by the segments from the value of a variable and its Go
+// type. Either is nil when the path does not resolve in it.
+func resolve(value any, goType reflect.Type, segments []segment) (any, reflect.Type) {
+	for _, seg := range segments {
+		for goType != nil && goType.Kind() == reflect.Pointer {
+			goType = goType.Elem()
+		}
+		if seg.list {
+			list, _ := value.([]any)
+			value = nil
+			if seg.index >= 0 && seg.index < len(list) {
+				value = list[seg.index]
+			}
+			if goType != nil && goType.Kind() == reflect.Slice {
+				goType = goType.Elem()
+			} else {
+				goType = nil
+			}
+			continue
+		}
+		object, _ := value.(map[string]any)
+		value = object[seg.key]
+		switch {
+		case goType == nil:
+		case goType.Kind() == reflect.Map:
+			goType = goType.Elem()
+		case goType.Kind() == reflect.Struct:
+			f, ok := jsonfield.Field(goType, seg.key)
+			goType = nil
+			if ok {
+				goType = f.Type
+			}
+		default:
+			goType = nil
+		}
+	}
+	return value, goType
+}
+
+// fieldNames returns the sorted keys of the value and JSON fields of the Go type. Types marshaled to JSON by
+// their own methods, such as quantities and timestamps, have no fields.
+func fieldNames(value any, goType reflect.Type) []string {
+	var names []string
+	if object, ok := value.(map[string]any); ok {
+		for key := range object {
+			names = append(names, key)
+		}
+	}
+	for goType != nil && goType.Kind() == reflect.Pointer {
+		goType = goType.Elem()
+	}
+	if goType != nil && goType.Kind() == reflect.Struct && !reflect.PointerTo(goType).Implements(marshalerType) {
+		names = append(names, jsonfield.Names(goType)...)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
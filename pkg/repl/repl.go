@@ -0,0 +1,330 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/yashirook/vaptest/pkg/target"
+	"google.golang.org/protobuf/types/known/structpb"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apiserver/pkg/admission"
+	plugincel "k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/admission/plugin/policy/validating"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizerfactory"
+	"k8s.io/apiserver/pkg/cel/environment"
+	"k8s.io/apiserver/pkg/cel/library"
+)
+
+// Variables are the variables bound in a session.
+var Variables = []string{
+	plugincel.ObjectVarName,
+	plugincel.OldObjectVarName,
+	plugincel.ParamsVarName,
+	plugincel.RequestVarName,
+	plugincel.NamespaceVarName,
+	plugincel.AuthorizerVarName,
+}
+
+// Session evaluates CEL expressions against an admission request, in the environment the apiserver compiles the
+// expressions of ValidatingAdmissionPolicies in: the variables are declared with the same types, and only the
+// libraries of the compatibility version are available.
+type Session struct {
+	// Target is the request whose object is bound to object.
+	Target target.TargetInfo
+	// OldObject is bound to oldObject, and turns a CREATE request into an UPDATE. Nil means a request without an
+	// old object.
+	OldObject map[string]any
+	// Params is bound to params, nil if the session has none.
+	Params map[string]any
+	// Namespace is bound to namespaceObject for namespaced requests. Nil means a namespace with only a name and
+	// the kubernetes.io/metadata.name label, like the upstream engine assumes for namespaces not loaded as targets.
+	Namespace *corev1.Namespace
+	Scheme    *runtime.Scheme
+	// CompatibilityVersion is the version of the CEL libraries expressions may use. Nil means the version of the
+	// validator.
+	CompatibilityVersion *version.Version
+
+	compiler   plugincel.Compiler
+	activation map[string]any
+	goTypes    map[string]reflect.Type
+}
+
+// Result is the result of an expression evaluated in a session.
+type Result struct {
+	// Type is the type the expression is checked to, dyn if it depends on the fields of an object.
+	Type  *cel.Type
+	Value ref.Val
+	// Cost is the actual cost of the evaluation, as counted against the per-call limit of the apiserver.
+	Cost uint64
+}
+
+func NewSession(t target.TargetInfo, scheme *runtime.Scheme) *Session {
+	return &Session{Target: t, Scheme: scheme}
+}
+
+// CompatibilityVersion returns the compatibility version of the CEL environment of an apiserver of the Kubernetes
+// version, e.g. 1.31 or v1.31.2. The apiserver only allows new expressions to use the libraries of the previous
+// minor version, so that it can be rolled back. An empty version means the version of the validator.
+func CompatibilityVersion(kubernetesVersion string) (*version.Version, error) {
+	if kubernetesVersion == "" {
+		return environment.DefaultCompatibilityVersion(), nil
+	}
+	v, err := version.ParseGeneric(kubernetesVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Kubernetes version %q: %w", kubernetesVersion, err)
+	}
+	if v.Major() != 1 || v.Minor() == 0 {
+		return nil, fmt.Errorf("unsupported Kubernetes version %q", kubernetesVersion)
+	}
+	return version.MajorMinor(v.Major(), v.Minor()).SubtractMinor(1), nil
+}
+
+// Eval compiles the expression and evaluates it. Compilation errors are returned with an empty result, and
+// evaluation errors with the type and the cost of the evaluation.
+func (s *Session) Eval(expression string) (Result, error) {
+	if err := s.init(); err != nil {
+		return Result{}, err
+	}
+	compiled := s.compiler.CompileCELExpression(&validating.Variable{Expression: expression}, plugincel.OptionalVariableDeclarations{HasParams: true, HasAuthorizer: true}, environment.NewExpressions)
+	if compiled.Error != nil {
+		return Result{}, compiled.Error
+	}
+	result := Result{Type: compiled.OutputType}
+	val, details, err := compiled.Program.Eval(s.activation)
+	if details != nil && details.ActualCost() != nil {
+		result.Cost = *details.ActualCost()
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Value = val
+	return result, nil
+}
+
+// init builds the compiler and the variables of the session on first use.
+func (s *Session) init() error {
+	if s.compiler != nil {
+		return nil
+	}
+	compatibilityVersion := s.CompatibilityVersion
+	if compatibilityVersion == nil {
+		compatibilityVersion = environment.DefaultCompatibilityVersion()
+	}
+	activation, err := s.newActivation()
+	if err != nil {
+		return err
+	}
+	s.compiler = plugincel.NewCompiler(environment.MustBaseEnvSet(compatibilityVersion, false))
+	s.activation = activation
+	s.goTypes = map[string]reflect.Type{
+		plugincel.ObjectVarName:    s.goType(s.Target.Object),
+		plugincel.OldObjectVarName: s.goType(s.Target.Object),
+		plugincel.ParamsVarName:    s.goType(s.Params),
+		plugincel.RequestVarName:   reflect.TypeOf(admissionv1.AdmissionRequest{}),
+		plugincel.NamespaceVarName: reflect.TypeOf(corev1.Namespace{}),
+	}
+	return nil
+}
+
+// newActivation returns the variables the admission plugin binds for the request, with an authorizer allowing
+// every request.
+func (s *Session) newActivation() (map[string]any, error) {
+	t := s.Target
+	operation := admission.Operation(t.Operation)
+	if operation == "" {
+		operation = admission.Create
+	}
+	// 古いオブジェクトがあれば作成ではなく更新のリクエストとする
+	if operation == admission.Create && s.OldObject != nil {
+		operation = admission.Update
+	}
+	namespace := t.Namespace
+	switch t.Scope {
+	case target.ScopeNamespaced:
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+	case target.ScopeCluster:
+		namespace = ""
+	}
+
+	gvr := schema.GroupVersionResource{Group: t.APIGroup, Version: t.APIVersion, Resource: t.Resource}
+	gvk := schema.GroupVersionKind{Group: t.APIGroup, Version: t.APIVersion, Kind: t.Kind}
+	object := &unstructured.Unstructured{Object: t.Object}
+	if objectKind := object.GroupVersionKind(); !objectKind.Empty() {
+		gvk = objectKind
+	}
+	var oldObject runtime.Object
+	if s.OldObject != nil {
+		oldObject = &unstructured.Unstructured{Object: s.OldObject}
+	}
+	attr := admission.NewAttributesRecord(object, oldObject, gvk, namespace, t.ResourceName, gvr, t.SubResource, operation, nil, false, &user.DefaultInfo{})
+
+	activation := map[string]any{
+		plugincel.ObjectVarName:    t.Object,
+		plugincel.OldObjectVarName: nil,
+		plugincel.ParamsVarName:    nil,
+		plugincel.NamespaceVarName: nil,
+	}
+	if s.OldObject != nil {
+		activation[plugincel.OldObjectVarName] = s.OldObject
+	}
+	if s.Params != nil {
+		activation[plugincel.ParamsVarName] = s.Params
+	}
+	request := plugincel.CreateAdmissionRequest(attr,
+		metav1.GroupVersionResource{Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource},
+		metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind})
+	val, err := runtime.DefaultUnstructuredConverter.ToUnstructured(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert admission request: %w", err)
+	}
+	activation[plugincel.RequestVarName] = val
+	if namespace != "" {
+		ns := s.Namespace
+		if ns == nil {
+			ns = &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   namespace,
+					Labels: map[string]string{corev1.LabelMetadataName: namespace},
+				},
+			}
+		}
+		val, err := runtime.DefaultUnstructuredConverter.ToUnstructured(plugincel.CreateNamespaceObject(ns))
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert namespace object: %w", err)
+		}
+		activation[plugincel.NamespaceVarName] = val
+	}
+	authz := authorizerfactory.NewAlwaysAllowAuthorizer()
+	versioned := &admission.VersionedAttributes{Attributes: attr, VersionedKind: gvk, VersionedObject: object}
+	activation[plugincel.AuthorizerVarName] = library.NewAuthorizerVal(attr.GetUserInfo(), authz)
+	activation[plugincel.RequestResourceAuthorizerVarName] = library.NewResourceAuthorizerVal(attr.GetUserInfo(), authz, versioned)
+	return activation, nil
+}
+
+// goType returns the Go type of the kind of the object in the scheme, or nil if the scheme does not know it.
+func (s *Session) goType(object map[string]any) reflect.Type {
+	if object == nil || s.Scheme == nil {
+		return nil
+	}
+	obj, err := s.Scheme.New((&unstructured.Unstructured{Object: object}).GroupVersionKind())
+	if err != nil {
+		return nil
+	}
+	return reflect.TypeOf(obj)
+}
+
+// Run evaluates the expressions read from in, one per line, and writes their results to out until in ends or
+// the :quit command.
+func (s *Session) Run(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if quit := s.handle(scanner.Text(), out); quit {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+const help = `Enter a CEL expression to evaluate it. Variables: object, oldObject, params, request, namespaceObject
+and authorizer. Tab completes the fields of the variables.
+Commands:
+  :help  show this help
+  :quit  exit (or Ctrl-D)
+`
+
+// handle evaluates a line of input and writes its result, reporting whether the line quits the session.
+func (s *Session) handle(line string, out io.Writer) bool {
+	line = strings.TrimSpace(line)
+	switch line {
+	case "":
+		return false
+	case ":quit", ":q":
+		return true
+	case ":help":
+		fmt.Fprint(out, help)
+		return false
+	}
+	result, err := s.Eval(line)
+	if err != nil {
+		fmt.Fprintf(out, "error: %v\n", err)
+	} else {
+		fmt.Fprintln(out, FormatValue(result.Value))
+	}
+	if result.Type != nil {
+		fmt.Fprintf(out, "type: %s, cost: %d\n", describeType(result), result.Cost)
+	}
+	return false
+}
+
+// describeType returns the type of the result, with the type of its value if the expression is checked to dyn.
+func describeType(result Result) string {
+	if result.Type.String() != cel.DynType.String() || result.Value == nil {
+		return result.Type.String()
+	}
+	return fmt.Sprintf("dyn (%s)", result.Value.Type().(ref.Type).TypeName())
+}
+
+// FormatValue formats a CEL value: scalars as CEL literals, lists and maps as indented JSON.
+func FormatValue(val ref.Val) string {
+	switch v := val.(type) {
+	case types.String:
+		return strconv.Quote(string(v))
+	case types.Bytes:
+		return fmt.Sprintf("b%q", []byte(v))
+	case types.Uint:
+		return fmt.Sprintf("%du", uint64(v))
+	case types.Null:
+		return "null"
+	case types.Duration:
+		return fmt.Sprintf("duration(%q)", v.Duration.String())
+	case types.Timestamp:
+		return fmt.Sprintf("timestamp(%q)", v.Time.Format(time.RFC3339Nano))
+	case ref.Type:
+		return v.TypeName()
+	case traits.Lister, traits.Mapper:
+		if s, err := formatJSON(val); err == nil {
+			return s
+		}
+	}
+	return fmt.Sprint(val.Value())
+}
+
+func formatJSON(val ref.Val) (string, error) {
+	native, err := val.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return "", err
+	}
+	value, ok := native.(*structpb.Value)
+	if !ok {
+		return "", errors.New("not a JSON value")
+	}
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value.AsInterface()); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
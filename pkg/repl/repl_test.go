@@ -0,0 +1,285 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yashirook/vaptest/pkg/defaults"
+	"github.com/yashirook/vaptest/pkg/loader"
+	"github.com/yashirook/vaptest/pkg/target"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// newTestSession returns a session of an UPDATE of testdata/deployment.yaml from testdata/old-deployment.yaml,
+// with testdata/params.yaml as params.
+func newTestSession(t *testing.T) *Session {
+	scheme := defaults.NewScheme()
+	ldr := loader.NewLoader(scheme)
+	load := func(path string) *target.TargetInfo {
+		objects, err := ldr.LoadObjectFromPaths([]string{path})
+		require.NoError(t, err)
+		require.Len(t, objects, 1)
+		info, err := target.NewTargetInfo(objects[0], scheme, target.WithDefaulting(scheme))
+		require.NoError(t, err)
+		return info
+	}
+	session := NewSession(*load("testdata/deployment.yaml"), scheme)
+	session.OldObject = load("testdata/old-deployment.yaml").Object
+	session.Params = load("testdata/params.yaml").Object
+	return session
+}
+
+func TestSessionEval(t *testing.T) {
+	testCases := []struct {
+		name          string
+		expression    string
+		expectedValue string
+		expectedType  string
+		expectedError string
+	}{
+		{
+			name:          "オブジェクトのフィールド",
+			expression:    "object.spec.replicas",
+			expectedValue: "3",
+			expectedType:  "dyn",
+		},
+		{
+			name:          "古いオブジェクトとの比較",
+			expression:    "object.spec.replicas > oldObject.spec.replicas",
+			expectedValue: "true",
+			expectedType:  "bool",
+		},
+		{
+			name:          "古いオブジェクトがあれば更新のリクエストになる",
+			expression:    "request.operation",
+			expectedValue: `"UPDATE"`,
+			expectedType:  "string",
+		},
+		{
+			name:          "パラメータ",
+			expression:    "int(params.data.maxReplicas)",
+			expectedValue: "5",
+			expectedType:  "int",
+		},
+		{
+			name:          "名前空間には名前のラベルだけがある",
+			expression:    "namespaceObject.metadata.labels",
+			expectedValue: "{\n  \"kubernetes.io/metadata.name\": \"shop\"\n}",
+			expectedType:  "map(string, string)",
+		},
+		{
+			name:          "Kubernetes のライブラリ",
+			expression:    "quantity(object.spec.template.spec.containers[0].resources.limits.memory).isLessThan(quantity('1Gi'))",
+			expectedValue: "true",
+			expectedType:  "bool",
+		},
+		{
+			name:          "リスト",
+			expression:    "object.spec.template.spec.containers.map(c, c.image)",
+			expectedValue: "[\n  \"nginx:1.27\"\n]",
+			expectedType:  "list(dyn)",
+		},
+		{
+			name:          "存在しないフィールドは評価エラーになる",
+			expression:    "object.spec.nope",
+			expectedType:  "dyn",
+			expectedError: "no such key: nope",
+		},
+		{
+			name:          "型検査のエラー",
+			expression:    "request.nope",
+			expectedError: "undefined field 'nope'",
+		},
+	}
+
+	session := newTestSession(t)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := session.Eval(tc.expression)
+			if tc.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedError)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedValue, FormatValue(result.Value))
+				assert.NotZero(t, result.Cost)
+			}
+			if tc.expectedType == "" {
+				assert.Nil(t, result.Type)
+			} else {
+				assert.Equal(t, tc.expectedType, result.Type.String())
+			}
+		})
+	}
+}
+
+func TestSessionEvalNamespace(t *testing.T) {
+	session := newTestSession(t)
+	session.Namespace = &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Labels: map[string]string{"team": "payments"}},
+	}
+
+	result, err := session.Eval("namespaceObject.metadata.labels.team")
+	require.NoError(t, err)
+	assert.Equal(t, `"payments"`, FormatValue(result.Value))
+}
+
+func TestSessionEvalCompatibilityVersion(t *testing.T) {
+	testCases := []struct {
+		name              string
+		kubernetesVersion string
+		expectedError     bool
+	}{
+		{
+			name:              "ライブラリが導入された次のバージョンから使える",
+			kubernetesVersion: "1.31",
+		},
+		{
+			name:              "ライブラリが導入されたバージョンではまだ使えない",
+			kubernetesVersion: "1.30",
+			expectedError:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			session := newTestSession(t)
+			var err error
+			session.CompatibilityVersion, err = CompatibilityVersion(tc.kubernetesVersion)
+			require.NoError(t, err)
+
+			// isIP は 1.30 で導入された
+			_, err = session.Eval("isIP('10.0.0.1')")
+			if tc.expectedError {
+				assert.ErrorContains(t, err, "undeclared reference to 'isIP'")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCompatibilityVersion(t *testing.T) {
+	testCases := []struct {
+		name              string
+		kubernetesVersion string
+		expected          *version.Version
+		expectedError     bool
+	}{
+		{
+			name:              "前のマイナーバージョン",
+			kubernetesVersion: "1.31",
+			expected:          version.MajorMinor(1, 30),
+		},
+		{
+			name:              "パッチバージョンは無視する",
+			kubernetesVersion: "v1.29.4",
+			expected:          version.MajorMinor(1, 28),
+		},
+		{
+			name:              "不正なバージョン",
+			kubernetesVersion: "latest",
+			expectedError:     true,
+		},
+		{
+			name:              "Kubernetes 1 以外のバージョン",
+			kubernetesVersion: "2.0",
+			expectedError:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := CompatibilityVersion(tc.kubernetesVersion)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tc.expected.EqualTo(got), "got %s", got)
+		})
+	}
+}
+
+func TestSessionComplete(t *testing.T) {
+	testCases := []struct {
+		name          string
+		line          string
+		expectedStart int
+		expected      []string
+	}{
+		{
+			name:     "変数名",
+			line:     "ol",
+			expected: []string{"oldObject"},
+		},
+		{
+			name:     "オブジェクトとスキーマのフィールド",
+			line:     "object.spec.re",
+			expected: []string{"object.spec.replicas", "object.spec.revisionHistoryLimit"},
+		},
+		{
+			name:     "オブジェクトにないフィールドもスキーマから補完する",
+			line:     "object.spec.template.spec.containers[0].securityC",
+			expected: []string{"object.spec.template.spec.containers[0].securityContext"},
+		},
+		{
+			name:     "識別子でないキーはインデックスで補完する",
+			line:     "object.metadata.labels.",
+			expected: []string{"object.metadata.labels['app.kubernetes.io/name']"},
+		},
+		{
+			name:     "インデックスで選択したマップ",
+			line:     "object.spec.template.metadata['labels'].a",
+			expected: []string{"object.spec.template.metadata['labels'].app"},
+		},
+		{
+			name:          "式の途中のパス",
+			line:          "has(params.data.max",
+			expectedStart: 4,
+			expected:      []string{"params.data.maxReplicas"},
+		},
+		{
+			name:     "リクエストのスキーマ",
+			line:     "request.op",
+			expected: []string{"request.operation", "request.options"},
+		},
+		{
+			name:     "数量にはフィールドがない",
+			line:     "object.spec.template.spec.containers[0].resources.limits.memory.",
+			expected: nil,
+		},
+		{
+			name:     "フィールド名で終わらないパス",
+			line:     "object.spec.template.spec.containers[0]",
+			expected: nil,
+		},
+	}
+
+	session := newTestSession(t)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start, got := session.Complete(tc.line)
+			assert.Equal(t, tc.expectedStart, start)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestSessionRun(t *testing.T) {
+	session := newTestSession(t)
+	in := strings.NewReader("object.metadata.name\n\nobject.spec.replicas +\n:quit\nobject.spec.replicas\n")
+	var out bytes.Buffer
+
+	require.NoError(t, session.Run(in, &out))
+	lines := strings.Split(out.String(), "\n")
+	// 空行は無視し、:quit のあとの式は評価しない
+	assert.Equal(t, []string{`"web"`, "type: dyn (string), cost: 3"}, lines[:2])
+	assert.True(t, strings.HasPrefix(lines[2], "error: compilation failed:"))
+	assert.NotContains(t, lines, "3")
+}
@@ -0,0 +1,98 @@
+package repl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+	plugincel "k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/cel/environment"
+)
+
+const prompt = "cel> "
+
+// RunTerminal runs the session on the terminal in, with line editing, history and completion of field paths by
+// Tab. It returns when the user quits with :quit, Ctrl-D or Ctrl-C.
+func (s *Session) RunTerminal(in *os.File, out io.Writer) error {
+	if err := s.init(); err != nil {
+		return err
+	}
+	fd := int(in.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{in, out}, prompt)
+	t.AutoCompleteCallback = s.autoComplete(t)
+	fmt.Fprint(t, s.banner())
+	for {
+		line, err := t.ReadLine()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if quit := s.handle(line, t); quit {
+			return nil
+		}
+	}
+}
+
+// banner describes the environment and the request of the session.
+func (s *Session) banner() string {
+	compatibilityVersion := s.CompatibilityVersion
+	if compatibilityVersion == nil {
+		compatibilityVersion = environment.DefaultCompatibilityVersion()
+	}
+	kubernetesVersion := compatibilityVersion.AddMinor(1)
+	request, _ := s.activation[plugincel.RequestVarName].(map[string]any)
+	return fmt.Sprintf("CEL environment of Kubernetes %d.%d, %s %s/%s\nType :help for help.\n",
+		kubernetesVersion.Major(), kubernetesVersion.Minor(), request["operation"], s.Target.Kind, s.Target.ResourceName)
+}
+
+// autoComplete returns the completion callback of the terminal. Tab completes the field path before the cursor
+// up to the common prefix of its completions, and lists them if there is nothing more to complete.
+func (s *Session) autoComplete(t *term.Terminal) func(string, int, rune) (string, int, bool) {
+	return func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		start, completions := s.Complete(line[:pos])
+		if len(completions) == 0 {
+			return line, pos, true
+		}
+		completion := commonPrefix(completions)
+		if len(completions) > 1 && completion == line[start:pos] {
+			// パス全体ではなく補完するフィールドだけを一覧にする
+			parent := strings.LastIndexByte(line[start:pos], '.')
+			names := make([]string, len(completions))
+			for i, c := range completions {
+				names[i] = c
+				if parent >= 0 {
+					names[i] = strings.TrimPrefix(c[parent:], ".")
+				}
+			}
+			fmt.Fprintln(t, strings.Join(names, "  "))
+		}
+		return line[:start] + completion + line[pos:], start + len(completion), true
+	}
+}
+
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
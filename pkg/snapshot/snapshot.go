@@ -0,0 +1,176 @@
+package snapshot
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/yashirook/vaptest/pkg/validator"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// Snapshot is the canonical serialization of the results of a validation run, stored in a golden file.
+type Snapshot struct {
+	Results []validator.ValidationResult `json:"results"`
+}
+
+// ChangeType is the kind of a difference between a snapshot and the current results.
+type ChangeType string
+
+const (
+	ChangeAdded   ChangeType = "added"
+	ChangeRemoved ChangeType = "removed"
+	ChangeChanged ChangeType = "changed"
+)
+
+// Change is a result that differs from the snapshot.
+type Change struct {
+	Type   ChangeType
+	Policy string
+	Target string
+	// Diff is a unified diff of the result in the snapshot and the current result.
+	Diff string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: policy %s, %s", c.Type, c.Policy, c.Target)
+}
+
+// New returns the snapshot of the results. Results are sorted by policy and target, and the
+// validation errors of each result by the index of the validation, so that the snapshot does not
+// depend on the order manifests were loaded or evaluated in.
+func New(results []validator.ValidationResult) *Snapshot {
+	sorted := make([]validator.ValidationResult, 0, len(results))
+	for _, result := range results {
+		result.ValidationErrors = slices.Clone(result.ValidationErrors)
+		slices.SortStableFunc(result.ValidationErrors, func(a, b validator.ValidationError) int {
+			return cmp.Compare(a.Index, b.Index)
+		})
+		sorted = append(sorted, result)
+	}
+	slices.SortStableFunc(sorted, func(a, b validator.ValidationResult) int {
+		return cmp.Compare(key(a), key(b))
+	})
+	return &Snapshot{Results: sorted}
+}
+
+// Load reads a snapshot file.
+func Load(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err := sigsyaml.UnmarshalStrict(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Write writes the snapshot to the file, replacing it.
+func (s *Snapshot) Write(path string) error {
+	data, err := sigsyaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Diff returns the results added, removed or changed in current compared to the snapshot s, sorted by policy and target.
+func (s *Snapshot) Diff(current *Snapshot) []Change {
+	expected := index(s.Results)
+	actual := index(current.Results)
+
+	var keys []string
+	for k := range expected {
+		keys = append(keys, k)
+	}
+	for k := range actual {
+		if _, ok := expected[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	var changes []Change
+	for _, k := range keys {
+		e, inExpected := expected[k]
+		a, inActual := actual[k]
+		var change Change
+		switch {
+		case !inExpected:
+			change = Change{Type: ChangeAdded, Policy: a.Policy.PolicyName, Target: describeTarget(a)}
+		case !inActual:
+			change = Change{Type: ChangeRemoved, Policy: e.Policy.PolicyName, Target: describeTarget(e)}
+		default:
+			change = Change{Type: ChangeChanged, Policy: e.Policy.PolicyName, Target: describeTarget(e)}
+		}
+		change.Diff = diff(e, a)
+		if change.Diff != "" {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// index returns the results keyed by policy and target. Results of targets sharing an identity
+// are told apart by their position.
+func index(results []validator.ValidationResult) map[string]*validator.ValidationResult {
+	indexed := make(map[string]*validator.ValidationResult, len(results))
+	for i := range results {
+		k := key(results[i])
+		for n := 2; ; n++ {
+			if _, ok := indexed[k]; !ok {
+				break
+			}
+			k = fmt.Sprintf("%s#%d", key(results[i]), n)
+		}
+		indexed[k] = &results[i]
+	}
+	return indexed
+}
+
+func key(result validator.ValidationResult) string {
+	t := result.Target
+	return strings.Join([]string{result.Policy.PolicyName, t.APIGroup, t.APIVersion, t.Resource, t.SubResource, t.Namespace, t.ResourceName, t.Operation}, "\x00")
+}
+
+// describeTarget describes the target of a result, e.g. default/deployments/example.
+func describeTarget(result *validator.ValidationResult) string {
+	t := result.Target
+	resource := t.Resource
+	if t.SubResource != "" {
+		resource += "/" + t.SubResource
+	}
+	if t.Namespace != "" {
+		resource = t.Namespace + "/" + resource
+	}
+	return resource + "/" + t.ResourceName
+}
+
+// diff returns a unified diff of the YAML of the results, either of which may be nil.
+func diff(expected, actual *validator.ValidationResult) string {
+	toLines := func(result *validator.ValidationResult) []string {
+		if result == nil {
+			return nil
+		}
+		data, _ := sigsyaml.Marshal(result)
+		lines := strings.SplitAfter(string(data), "\n")
+		return lines[:len(lines)-1]
+	}
+	a, b := toLines(expected), toLines(actual)
+	if slices.Equal(a, b) {
+		return ""
+	}
+	text, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        a,
+		B:        b,
+		FromFile: "snapshot",
+		ToFile:   "current",
+		Context:  3,
+	})
+	return text
+}
@@ -0,0 +1,103 @@
+package snapshot
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yashirook/vaptest/pkg/target"
+	"github.com/yashirook/vaptest/pkg/validator"
+)
+
+func result(policy, name string, errs ...validator.ValidationError) validator.ValidationResult {
+	return validator.ValidationResult{
+		Policy:           validator.PolicyIdentifier{PolicyName: policy},
+		Target:           target.TargetIdentifier{Resource: "deployments", Namespace: "default", ResourceName: name, Operation: "CREATE"},
+		IsValidated:      true,
+		Success:          len(errs) == 0,
+		ValidationErrors: errs,
+	}
+}
+
+func TestNew(t *testing.T) {
+	results := []validator.ValidationResult{
+		result("b", "web"),
+		result("a", "web", validator.ValidationError{Index: 1, Message: "second"}, validator.ValidationError{Index: 0, Message: "first"}),
+		result("a", "api"),
+	}
+
+	s := New(results)
+
+	var order []string
+	for _, r := range s.Results {
+		order = append(order, r.Policy.PolicyName+"/"+r.Target.ResourceName)
+	}
+	assert.Equal(t, []string{"a/api", "a/web", "b/web"}, order, "ポリシーとターゲットの順に並ぶこと")
+	assert.Equal(t, "first", s.Results[1].ValidationErrors[0].Message, "エラーがバリデーションの順に並ぶこと")
+	assert.Equal(t, "second", results[1].ValidationErrors[0].Message, "元の結果を変更しないこと")
+}
+
+func TestDiff(t *testing.T) {
+	base := []validator.ValidationResult{
+		result("a", "api"),
+		result("a", "web", validator.ValidationError{Index: 0, Message: "denied"}),
+	}
+
+	testCases := []struct {
+		name     string
+		current  []validator.ValidationResult
+		expected []string
+		diff     string
+	}{
+		{
+			name:    "同じ結果は差分なし",
+			current: []validator.ValidationResult{base[1], base[0]},
+		},
+		{
+			name: "メッセージの変更",
+			current: []validator.ValidationResult{
+				result("a", "api"),
+				result("a", "web", validator.ValidationError{Index: 0, Message: "rejected"}),
+			},
+			expected: []string{"changed: policy a, default/deployments/web"},
+			diff:     "-  message: denied\n+  message: rejected\n",
+		},
+		{
+			name:     "追加と削除",
+			current:  []validator.ValidationResult{base[1], result("b", "api")},
+			expected: []string{"removed: policy a, default/deployments/api", "added: policy b, default/deployments/api"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes := New(base).Diff(New(tc.current))
+
+			var actual []string
+			for _, c := range changes {
+				actual = append(actual, c.String())
+				assert.NotEmpty(t, c.Diff)
+			}
+			assert.Equal(t, tc.expected, actual)
+			if tc.diff != "" {
+				assert.Contains(t, changes[0].Diff, tc.diff)
+			}
+		})
+	}
+}
+
+func TestWriteLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.yaml")
+	s := New([]validator.ValidationResult{
+		result("a", "web", validator.ValidationError{Index: 0, CELExpr: "has(object.metadata.labels)", Message: "denied"}),
+	})
+
+	require.NoError(t, s.Write(path))
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Empty(t, s.Diff(loaded), "書き込んだスナップショットを読み込むと差分がないこと")
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
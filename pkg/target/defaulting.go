@@ -0,0 +1,94 @@
+package target
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Option configures how TargetInfo is built from an object.
+type Option func(*options)
+
+type options struct {
+	defaulter runtime.ObjectDefaulter
+}
+
+// WithDefaulting runs the defaulting functions registered to the defaulter (usually a *runtime.Scheme)
+// on typed objects before they are converted, as the apiserver does before admission.
+// Unstructured objects are left as they are since no defaulting functions are known for them.
+func WithDefaulting(defaulter runtime.ObjectDefaulter) Option {
+	return func(o *options) {
+		o.defaulter = defaulter
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// applyDefaults returns the unstructured content of obj after defaulting and the paths of the defaulted fields.
+// obj itself is not modified.
+func applyDefaults(obj runtime.Object, defaulter runtime.ObjectDefaulter) (map[string]interface{}, []string, error) {
+	before, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := obj.(runtime.Unstructured); ok || defaulter == nil {
+		return before, nil, nil
+	}
+
+	defaulted := obj.DeepCopyObject()
+	defaulter.Default(defaulted)
+	after, err := runtime.DefaultUnstructuredConverter.ToUnstructured(defaulted)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var fields []string
+	diffFields("", before, after, &fields)
+	sort.Strings(fields)
+	return after, fields, nil
+}
+
+// diffFields records the paths of fields in after which are missing or different in before.
+// New maps and lists are reported as a whole instead of field by field.
+func diffFields(path string, before, after interface{}, fields *[]string) {
+	switch a := after.(type) {
+	case map[string]interface{}:
+		b, ok := before.(map[string]interface{})
+		if !ok {
+			*fields = append(*fields, path)
+			return
+		}
+		for key, value := range a {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			prev, exists := b[key]
+			if !exists {
+				*fields = append(*fields, childPath)
+				continue
+			}
+			diffFields(childPath, prev, value, fields)
+		}
+	case []interface{}:
+		b, ok := before.([]interface{})
+		if !ok || len(a) != len(b) {
+			*fields = append(*fields, path)
+			return
+		}
+		for i := range a {
+			diffFields(fmt.Sprintf("%s[%d]", path, i), b[i], a[i], fields)
+		}
+	default:
+		if fmt.Sprint(before) != fmt.Sprint(after) {
+			*fields = append(*fields, path)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package target
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yashirook/vaptest/pkg/defaults"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+)
+
+// newDefaultingScheme はデフォルト値の設定関数を登録したスキームを返す
+func newDefaultingScheme(t *testing.T) *runtime.Scheme {
+	s := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(s))
+	require.NoError(t, defaults.AddToScheme(s))
+	return s
+}
+
+func TestNewTargetInfoWithDefaulting(t *testing.T) {
+	s := newDefaultingScheme(t)
+
+	testCases := []struct {
+		name            string
+		obj             runtime.Object
+		opts            []Option
+		expectedFields  []string
+		expectedValues  map[string]interface{}
+		unexpectedPaths [][]string
+	}{
+		{
+			name: "Deploymentにデフォルト値が設定される",
+			obj: &appsv1.Deployment{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+				ObjectMeta: metav1.ObjectMeta{Name: "test-deployment"},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: ptr.To[int32](3),
+					Template: corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+						},
+					},
+				},
+			},
+			opts: []Option{WithDefaulting(s)},
+			expectedFields: []string{
+				"spec.progressDeadlineSeconds",
+				"spec.revisionHistoryLimit",
+				"spec.strategy.rollingUpdate",
+				"spec.strategy.type",
+				"spec.template.spec.containers[0].imagePullPolicy",
+				"spec.template.spec.containers[0].terminationMessagePath",
+				"spec.template.spec.containers[0].terminationMessagePolicy",
+				"spec.template.spec.dnsPolicy",
+				"spec.template.spec.restartPolicy",
+				"spec.template.spec.schedulerName",
+				"spec.template.spec.securityContext",
+				"spec.template.spec.terminationGracePeriodSeconds",
+			},
+			expectedValues: map[string]interface{}{
+				"spec.replicas":                    int64(3),
+				"spec.template.spec.restartPolicy": "Always",
+			},
+		},
+		{
+			name: "デフォルト値の設定が無効な場合はマニフェストのまま",
+			obj: &appsv1.Deployment{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+				ObjectMeta: metav1.ObjectMeta{Name: "test-deployment"},
+			},
+			unexpectedPaths: [][]string{{"spec", "replicas"}},
+		},
+		{
+			name: "Unstructuredオブジェクトにはデフォルト値を設定しない",
+			obj: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "apps/v1",
+					"kind":       "Deployment",
+					"metadata":   map[string]interface{}{"name": "test-deployment"},
+				},
+			},
+			opts:            []Option{WithDefaulting(s)},
+			unexpectedPaths: [][]string{{"spec", "replicas"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := tc.obj.DeepCopyObject()
+
+			info, err := NewTargetInfo(tc.obj, s, tc.opts...)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedFields, info.DefaultedFields)
+			for path, expected := range tc.expectedValues {
+				value, found, err := unstructured.NestedFieldNoCopy(info.Object, strings.Split(path, ".")...)
+				require.NoError(t, err)
+				require.True(t, found, path)
+				assert.Equal(t, expected, value, path)
+			}
+			for _, path := range tc.unexpectedPaths {
+				_, found, _ := unstructured.NestedFieldNoCopy(info.Object, path...)
+				assert.False(t, found, path)
+			}
+			assert.Equal(t, original, tc.obj, "元のオブジェクトは変更されないこと")
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package target
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	apidiscoveryv2 "k8s.io/api/apidiscovery/v2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// discoveryCacheFile is the file name used by kubectl to cache the resources of a group version.
+const discoveryCacheFile = "serverresources.json"
+
+// LoadDiscoverySnapshot reads a discovery snapshot file and builds a ResourceMapper from it.
+// See ParseDiscoverySnapshot for the supported formats.
+func LoadDiscoverySnapshot(path string) (*ResourceMapper, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read discovery snapshot %s: %w", path, err)
+	}
+	resourceLists, err := ParseDiscoverySnapshot(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse discovery snapshot %s: %w", path, err)
+	}
+	return NewDiscoveryRESTMapper(resourceLists), nil
+}
+
+// ParseDiscoverySnapshot parses API discovery data into APIResourceLists.
+// The following formats are supported:
+//   - the output of `kubectl api-resources` (with or without `-o wide`)
+//   - APIResourceList JSON documents, or JSON arrays of them (the `vaptest discovery export` format)
+//   - aggregated discovery JSON documents (APIGroupDiscoveryList) served from /api and /apis
+//
+// Several JSON documents may be concatenated in one file.
+func ParseDiscoverySnapshot(data []byte) ([]*metav1.APIResourceList, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("discovery snapshot is empty")
+	}
+	if trimmed[0] != '{' && trimmed[0] != '[' {
+		return parseAPIResourcesTable(trimmed)
+	}
+
+	var resourceLists []*metav1.APIResourceList
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		lists, err := parseDiscoveryDocument(raw)
+		if err != nil {
+			return nil, err
+		}
+		resourceLists = append(resourceLists, lists...)
+	}
+	return resourceLists, nil
+}
+
+func parseDiscoveryDocument(raw json.RawMessage) ([]*metav1.APIResourceList, error) {
+	if bytes.HasPrefix(raw, []byte("[")) {
+		var lists []*metav1.APIResourceList
+		if err := json.Unmarshal(raw, &lists); err != nil {
+			return nil, err
+		}
+		return lists, nil
+	}
+
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, err
+	}
+	switch typeMeta.Kind {
+	case "APIResourceList":
+		var list metav1.APIResourceList
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+		return []*metav1.APIResourceList{&list}, nil
+	case "APIGroupDiscoveryList":
+		var list apidiscoveryv2.APIGroupDiscoveryList
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+		return convertAggregatedDiscovery(&list), nil
+	default:
+		return nil, fmt.Errorf("unsupported discovery document kind %q", typeMeta.Kind)
+	}
+}
+
+// convertAggregatedDiscovery converts aggregated discovery into the legacy APIResourceList form,
+// where subresources are listed as "<resource>/<subresource>".
+func convertAggregatedDiscovery(list *apidiscoveryv2.APIGroupDiscoveryList) []*metav1.APIResourceList {
+	var resourceLists []*metav1.APIResourceList
+	for _, group := range list.Items {
+		for _, version := range group.Versions {
+			gv := schema.GroupVersion{Group: group.Name, Version: version.Version}
+			resourceList := &metav1.APIResourceList{GroupVersion: gv.String()}
+			for _, r := range version.Resources {
+				resource := metav1.APIResource{
+					Name:         r.Resource,
+					SingularName: r.SingularResource,
+					Namespaced:   r.Scope == apidiscoveryv2.ScopeNamespace,
+					Verbs:        r.Verbs,
+					ShortNames:   r.ShortNames,
+					Categories:   r.Categories,
+				}
+				if r.ResponseKind != nil {
+					resource.Group = r.ResponseKind.Group
+					resource.Version = r.ResponseKind.Version
+					resource.Kind = r.ResponseKind.Kind
+				}
+				resourceList.APIResources = append(resourceList.APIResources, resource)
+
+				for _, sub := range r.Subresources {
+					subresource := metav1.APIResource{
+						Name:       r.Resource + "/" + sub.Subresource,
+						Namespaced: resource.Namespaced,
+						Verbs:      sub.Verbs,
+					}
+					if sub.ResponseKind != nil {
+						subresource.Group = sub.ResponseKind.Group
+						subresource.Version = sub.ResponseKind.Version
+						subresource.Kind = sub.ResponseKind.Kind
+					}
+					resourceList.APIResources = append(resourceList.APIResources, subresource)
+				}
+			}
+			resourceLists = append(resourceLists, resourceList)
+		}
+	}
+	return resourceLists
+}
+
+var tableColumnPattern = regexp.MustCompile(`\S+`)
+
+// parseAPIResourcesTable parses the table printed by `kubectl api-resources`.
+// Columns are located by the offsets of the header, since SHORTNAMES may be empty.
+func parseAPIResourcesTable(data []byte) ([]*metav1.APIResourceList, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("api-resources table has no header")
+	}
+	header := scanner.Text()
+	columns := map[string]int{}
+	locations := tableColumnPattern.FindAllStringIndex(header, -1)
+	for i, loc := range locations {
+		columns[header[loc[0]:loc[1]]] = i
+	}
+	for _, required := range []string{"NAME", "APIVERSION", "NAMESPACED", "KIND"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("api-resources table has no %s column", required)
+		}
+	}
+
+	field := func(line string, name string) string {
+		i, ok := columns[name]
+		if !ok {
+			return ""
+		}
+		start := locations[i][0]
+		if start >= len(line) {
+			return ""
+		}
+		end := len(line)
+		if i+1 < len(locations) && locations[i+1][0] < end {
+			end = locations[i+1][0]
+		}
+		return strings.TrimSpace(line[start:end])
+	}
+
+	listsByGroupVersion := map[string]*metav1.APIResourceList{}
+	var groupVersions []string
+	lineNumber := 1
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		groupVersion := field(line, "APIVERSION")
+		if _, err := schema.ParseGroupVersion(groupVersion); err != nil || groupVersion == "" {
+			return nil, fmt.Errorf("line %d: invalid APIVERSION %q", lineNumber, groupVersion)
+		}
+		resource := metav1.APIResource{
+			Name:       field(line, "NAME"),
+			Namespaced: field(line, "NAMESPACED") == "true",
+			Kind:       field(line, "KIND"),
+		}
+		if shortNames := field(line, "SHORTNAMES"); shortNames != "" {
+			resource.ShortNames = strings.Split(shortNames, ",")
+		}
+		if verbs := strings.Trim(field(line, "VERBS"), "[]"); verbs != "" {
+			resource.Verbs = strings.Fields(verbs)
+		}
+		if categories := field(line, "CATEGORIES"); categories != "" {
+			resource.Categories = strings.Split(categories, ",")
+		}
+
+		list, ok := listsByGroupVersion[groupVersion]
+		if !ok {
+			list = &metav1.APIResourceList{GroupVersion: groupVersion}
+			listsByGroupVersion[groupVersion] = list
+			groupVersions = append(groupVersions, groupVersion)
+		}
+		list.APIResources = append(list.APIResources, resource)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	resourceLists := make([]*metav1.APIResourceList, 0, len(groupVersions))
+	for _, gv := range groupVersions {
+		resourceLists = append(resourceLists, listsByGroupVersion[gv])
+	}
+	return resourceLists, nil
+}
+
+// NewDiscoveryRESTMapper builds a ResourceMapper from discovered resources.
+// Entries named "<resource>/<subresource>" are recorded as subresources of their parent resource.
+func NewDiscoveryRESTMapper(resourceLists []*metav1.APIResourceList) *ResourceMapper {
+	var groupVersions []schema.GroupVersion
+	for _, list := range resourceLists {
+		if gv, err := schema.ParseGroupVersion(list.GroupVersion); err == nil {
+			groupVersions = append(groupVersions, gv)
+		}
+	}
+
+	mapper := newResourceMapper(groupVersions)
+	for _, list := range resourceLists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			scope := meta.RESTScopeRoot
+			if r.Namespaced {
+				scope = meta.RESTScopeNamespace
+			}
+
+			if resource, subresource, found := strings.Cut(r.Name, "/"); found {
+				mapper.addSubresource(gv.WithResource(resource), subresource)
+				continue
+			}
+			if r.Kind == "" {
+				continue
+			}
+
+			// Resources served in a group version may report a kind from another group version.
+			gvk := gv.WithKind(r.Kind)
+			if r.Group != "" || r.Version != "" {
+				gvk = schema.GroupVersionKind{Group: r.Group, Version: r.Version, Kind: r.Kind}
+			}
+			plural := gv.WithResource(r.Name)
+			singular := plural
+			if r.SingularName != "" {
+				singular = gv.WithResource(r.SingularName)
+			}
+			mapper.AddSpecific(gvk, plural, singular, scope)
+		}
+	}
+	return mapper
+}
+
+// LoadDiscoveryCacheDir reads the discovery cache that kubectl writes for a cluster,
+// usually ~/.kube/cache/discovery/<host>, and returns the cached APIResourceLists sorted by group version.
+func LoadDiscoveryCacheDir(dir string) ([]*metav1.APIResourceList, error) {
+	var resourceLists []*metav1.APIResourceList
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != discoveryCacheFile {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var list metav1.APIResourceList
+		if err := json.Unmarshal(data, &list); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		resourceLists = append(resourceLists, &list)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resourceLists) == 0 {
+		return nil, fmt.Errorf("no %s found in discovery cache directory %s", discoveryCacheFile, dir)
+	}
+
+	sort.Slice(resourceLists, func(i, j int) bool {
+		return resourceLists[i].GroupVersion < resourceLists[j].GroupVersion
+	})
+	return resourceLists, nil
+}
+
+// WriteDiscoverySnapshot writes APIResourceLists as a discovery snapshot that LoadDiscoverySnapshot can read.
+func WriteDiscoverySnapshot(w io.Writer, resourceLists []*metav1.APIResourceList) error {
+	for _, list := range resourceLists {
+		list.Kind = "APIResourceList"
+		list.APIVersion = "v1"
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(resourceLists)
+}
@@ -0,0 +1,117 @@
+package target
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestLoadDiscoverySnapshot(t *testing.T) {
+	testCases := []struct {
+		name             string
+		path             string
+		gvk              schema.GroupVersionKind
+		expectedResource schema.GroupVersionResource
+		expectedScope    meta.RESTScopeName
+		subresources     []string
+	}{
+		{
+			name:             "api-resources table (core)",
+			path:             filepath.Join("testdata", "discovery", "api-resources.txt"),
+			gvk:              schema.GroupVersionKind{Version: "v1", Kind: "Namespace"},
+			expectedResource: schema.GroupVersionResource{Version: "v1", Resource: "namespaces"},
+			expectedScope:    meta.RESTScopeNameRoot,
+		},
+		{
+			name:             "api-resources table (aggregated API)",
+			path:             filepath.Join("testdata", "discovery", "api-resources.txt"),
+			gvk:              schema.GroupVersionKind{Group: "metrics.k8s.io", Version: "v1beta1", Kind: "PodMetrics"},
+			expectedResource: schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "pods"},
+			expectedScope:    meta.RESTScopeNameNamespace,
+		},
+		{
+			name:             "api-resources table (custom resource without short names)",
+			path:             filepath.Join("testdata", "discovery", "api-resources.txt"),
+			gvk:              schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"},
+			expectedResource: schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"},
+			expectedScope:    meta.RESTScopeNameNamespace,
+		},
+		{
+			name:             "aggregated discovery with subresources",
+			path:             filepath.Join("testdata", "discovery", "aggregated.json"),
+			gvk:              schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			expectedResource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+			expectedScope:    meta.RESTScopeNameNamespace,
+			subresources:     []string{"scale", "status"},
+		},
+		{
+			name:             "aggregated discovery (cluster scoped)",
+			path:             filepath.Join("testdata", "discovery", "aggregated.json"),
+			gvk:              schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Gadget"},
+			expectedResource: schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "gadgets"},
+			expectedScope:    meta.RESTScopeNameRoot,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mapper, err := LoadDiscoverySnapshot(tc.path)
+			require.NoError(t, err)
+
+			mapping, err := mapper.RESTMapping(tc.gvk.GroupKind(), tc.gvk.Version)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedResource, mapping.Resource)
+			assert.Equal(t, tc.expectedScope, mapping.Scope.Name())
+			assert.Equal(t, tc.subresources, mapper.Subresources(tc.expectedResource))
+		})
+	}
+}
+
+func TestDiscoveryCacheDirRoundTrip(t *testing.T) {
+	resourceLists, err := LoadDiscoveryCacheDir(filepath.Join("testdata", "discovery", "cache"))
+	require.NoError(t, err)
+	require.Len(t, resourceLists, 2)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDiscoverySnapshot(&buf, resourceLists))
+
+	parsed, err := ParseDiscoverySnapshot(buf.Bytes())
+	require.NoError(t, err)
+	mapper := NewDiscoveryRESTMapper(parsed)
+
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	assert.Equal(t, []string{"ephemeralcontainers", "exec"}, mapper.Subresources(pods))
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	assert.Equal(t, []string{"scale"}, mapper.Subresources(deployments))
+
+	// Scale is only served as a subresource, so it must not be mapped as a resource of its own.
+	_, err = mapper.RESTMapping(schema.GroupKind{Group: "autoscaling", Kind: "Scale"}, "v1")
+	assert.True(t, meta.IsNoMatchError(err))
+}
+
+func TestNewTargetInfoWithDiscoveryMapper(t *testing.T) {
+	mapper, err := LoadDiscoverySnapshot(filepath.Join("testdata", "discovery", "api-resources.txt"))
+	require.NoError(t, err)
+
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "Widget",
+			"metadata": map[string]interface{}{
+				"name":      "test-widget",
+				"namespace": "default",
+			},
+		},
+	}
+	info, err := NewTargetInfoWithMapper(obj, mapper)
+	require.NoError(t, err)
+	assert.Equal(t, "widgets", info.Resource)
+	assert.Equal(t, "example.com", info.APIGroup)
+	assert.Equal(t, "default", info.Namespace)
+}
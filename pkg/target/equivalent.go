@@ -0,0 +1,117 @@
+package target
+
+import (
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// EquivalentResourceMapper finds the resources serving the same objects in other versions or groups, such as
+// extensions/v1beta1 and apps/v1 deployments, which policies with matchPolicy: Equivalent match.
+// The apiserver knows which resources share a storage; outside of it, resources are equivalent when they have
+// the same name and their kinds have the same name.
+type EquivalentResourceMapper struct {
+	mapper meta.RESTMapper
+	scheme *runtime.Scheme
+}
+
+var _ runtime.EquivalentResourceMapper = &EquivalentResourceMapper{}
+
+// NewEquivalentResourceMapper creates an EquivalentResourceMapper resolving resources with the RESTMapper.
+// The scheme, if not nil, provides the Go types objects are converted through.
+func NewEquivalentResourceMapper(mapper meta.RESTMapper, scheme *runtime.Scheme) *EquivalentResourceMapper {
+	return &EquivalentResourceMapper{mapper: mapper, scheme: scheme}
+}
+
+// EquivalentResourcesFor returns the resources equivalent to the resource, including itself.
+// With a subresource, only the resources serving the subresource are returned, when the RESTMapper knows them.
+func (m *EquivalentResourceMapper) EquivalentResourcesFor(resource schema.GroupVersionResource, subresource string) []schema.GroupVersionResource {
+	kind, ok := m.kindFor(resource)
+	if !ok {
+		return nil
+	}
+	all, err := m.mapper.ResourcesFor(schema.GroupVersionResource{Resource: resource.Resource})
+	if err != nil {
+		return nil
+	}
+	lister, _ := m.mapper.(subresourceLister)
+	var equivalents []schema.GroupVersionResource
+	for _, other := range all {
+		if slices.Contains(equivalents, other) {
+			// the RESTMapper may return a resource more than once
+			continue
+		}
+		otherKind, ok := m.kindFor(other)
+		if !ok || otherKind.Kind != kind.Kind {
+			continue
+		}
+		if other != resource && subresource != "" && lister != nil && !containsString(lister.Subresources(other), subresource) {
+			continue
+		}
+		equivalents = append(equivalents, other)
+	}
+	return equivalents
+}
+
+// KindFor returns the kind of the object admitted for a request to the subresource of the resource, e.g.
+// autoscaling/v1 Scale for deployments/scale, or the kind of the resource when the parent object is admitted.
+func (m *EquivalentResourceMapper) KindFor(resource schema.GroupVersionResource, subresource string) schema.GroupVersionKind {
+	if req, ok := subresourceRequests[subresource]; ok && req.newObject != nil {
+		obj, err := req.newObject(resource.Resource, map[string]interface{}{}, nil)
+		if err != nil {
+			return schema.GroupVersionKind{}
+		}
+		return (&unstructured.Unstructured{Object: obj}).GroupVersionKind()
+	}
+	kind, _ := m.kindFor(resource)
+	return kind
+}
+
+// kindFor returns the kind of the resource. Unlike RESTMapper.KindFor, it does not take the empty group of core
+// resources for any group, which makes resources such as events ambiguous.
+func (m *EquivalentResourceMapper) kindFor(resource schema.GroupVersionResource) (schema.GroupVersionKind, bool) {
+	kinds, err := m.mapper.KindsFor(resource)
+	if err != nil {
+		return schema.GroupVersionKind{}, false
+	}
+	for _, kind := range kinds {
+		if kind.Group == resource.Group && kind.Version == resource.Version {
+			return kind, true
+		}
+	}
+	return schema.GroupVersionKind{}, false
+}
+
+// ConvertObject converts the object to the kind of an equivalent resource, as the apiserver does before
+// evaluating a policy matching the request through it. The conversion functions of the apiserver are not
+// available, so the fields are kept as they are, except those the Go type of the kind, if registered in the
+// scheme, does not have.
+func (m *EquivalentResourceMapper) ConvertObject(obj map[string]interface{}, gvk schema.GroupVersionKind) (map[string]interface{}, error) {
+	converted := runtime.DeepCopyJSON(obj)
+	u := &unstructured.Unstructured{Object: converted}
+	if u.GroupVersionKind() == gvk {
+		return converted, nil
+	}
+	u.SetGroupVersionKind(gvk)
+	if m.scheme == nil || !m.scheme.Recognizes(gvk) {
+		return converted, nil
+	}
+
+	typed, err := m.scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(converted, typed); err != nil {
+		return nil, err
+	}
+	converted, err = runtime.DefaultUnstructuredConverter.ToUnstructured(typed)
+	if err != nil {
+		return nil, err
+	}
+	u = &unstructured.Unstructured{Object: converted}
+	u.SetGroupVersionKind(gvk)
+	return converted, nil
+}
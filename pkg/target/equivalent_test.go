@@ -0,0 +1,96 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func TestEquivalentResourceMapper(t *testing.T) {
+	m := NewEquivalentResourceMapper(StaticRESTMapper(scheme.Scheme), scheme.Scheme)
+	appsDeployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+	testCases := []struct {
+		name        string
+		resource    schema.GroupVersionResource
+		subresource string
+		expected    []schema.GroupVersionResource
+		kind        schema.GroupVersionKind
+	}{
+		{
+			name:     "other versions and groups",
+			resource: appsDeployments,
+			expected: []schema.GroupVersionResource{
+				appsDeployments,
+				{Group: "apps", Version: "v1beta2", Resource: "deployments"},
+				{Group: "apps", Version: "v1beta1", Resource: "deployments"},
+				{Group: "extensions", Version: "v1beta1", Resource: "deployments"},
+			},
+			kind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		},
+		{
+			name:        "only resources serving the subresource",
+			resource:    appsDeployments,
+			subresource: "scale",
+			expected: []schema.GroupVersionResource{
+				appsDeployments,
+				{Group: "apps", Version: "v1beta2", Resource: "deployments"},
+				{Group: "apps", Version: "v1beta1", Resource: "deployments"},
+			},
+			kind: schema.GroupVersionKind{Group: "autoscaling", Version: "v1", Kind: "Scale"},
+		},
+		{
+			name:     "kinds of the same name",
+			resource: schema.GroupVersionResource{Version: "v1", Resource: "events"},
+			expected: []schema.GroupVersionResource{
+				{Version: "v1", Resource: "events"},
+				{Group: "events.k8s.io", Version: "v1", Resource: "events"},
+				{Group: "events.k8s.io", Version: "v1beta1", Resource: "events"},
+			},
+			kind: schema.GroupVersionKind{Version: "v1", Kind: "Event"},
+		},
+		{
+			name:     "unknown resource",
+			resource: schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ElementsMatch(t, tc.expected, m.EquivalentResourcesFor(tc.resource, tc.subresource))
+			assert.Equal(t, tc.kind, m.KindFor(tc.resource, tc.subresource))
+		})
+	}
+}
+
+func TestEquivalentResourceMapperConvertObject(t *testing.T) {
+	m := NewEquivalentResourceMapper(StaticRESTMapper(scheme.Scheme), scheme.Scheme)
+	deployment := map[string]interface{}{
+		"apiVersion": "extensions/v1beta1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "legacy"},
+		"spec": map[string]interface{}{
+			"replicas":   int64(3),
+			"rollbackTo": map[string]interface{}{"revision": int64(1)},
+		},
+	}
+
+	converted, err := m.ConvertObject(deployment, schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"})
+	require.NoError(t, err)
+	assert.Equal(t, "apps/v1", converted["apiVersion"])
+	replicas, _, _ := unstructured.NestedInt64(converted, "spec", "replicas")
+	assert.Equal(t, int64(3), replicas)
+	_, found, _ := unstructured.NestedFieldNoCopy(converted, "spec", "rollbackTo")
+	assert.False(t, found, "fields the kind does not have are dropped")
+	assert.Equal(t, "extensions/v1beta1", deployment["apiVersion"], "the object is not modified")
+
+	// Kinds unknown to the scheme keep all their fields.
+	widget := map[string]interface{}{"apiVersion": "example.com/v1beta1", "kind": "Widget", "spec": map[string]interface{}{"size": "L"}}
+	converted, err = m.ConvertObject(widget, schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"apiVersion": "example.com/v1", "kind": "Widget", "spec": map[string]interface{}{"size": "L"}}, converted)
+}
@@ -1,60 +1,226 @@
 package target
 
 import (
+	"sync"
+
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
-func createStaticRESTMapper(scheme *runtime.Scheme) meta.RESTMapper {
-	groupVersions := scheme.PrioritizedVersionsAllGroups()
-
-	mapper := meta.NewDefaultRESTMapper(groupVersions)
-
-	addResourceMappings(mapper)
-
-	return mapper
+// builtinResource describes a resource served by kube-apiserver.
+type builtinResource struct {
+	group    string
+	versions []string
+	kind     string
+	resource string
+	scope    meta.RESTScope
 }
 
-func addResourceMappings(mapper *meta.DefaultRESTMapper) {
+// builtinResources is the catalog of resources served by kube-apiserver (as of v1.31),
+// including the deprecated and alpha versions known to the client-go scheme.
+var builtinResources = []builtinResource{
 	// Coreリソース
-	addSpecificResource(mapper, "", "v1", "Pod", "pods", meta.RESTScopeNamespace)
-	addSpecificResource(mapper, "", "v1", "Service", "services", meta.RESTScopeNamespace)
-	addSpecificResource(mapper, "", "v1", "ConfigMap", "configmaps", meta.RESTScopeNamespace)
-	addSpecificResource(mapper, "", "v1", "Secret", "secrets", meta.RESTScopeNamespace)
-	addSpecificResource(mapper, "", "v1", "Node", "nodes", meta.RESTScopeRoot)
-	addSpecificResource(mapper, "", "v1", "Namespace", "namespaces", meta.RESTScopeRoot)
-	addSpecificResource(mapper, "", "v1", "PersistentVolume", "persistentvolumes", meta.RESTScopeRoot)
-	addSpecificResource(mapper, "", "v1", "PersistentVolumeClaim", "persistentvolumeclaims", meta.RESTScopeNamespace)
-	addSpecificResource(mapper, "", "v1", "ServiceAccount", "serviceaccounts", meta.RESTScopeNamespace)
-	addSpecificResource(mapper, "", "v1", "ReplicationController", "replicationcontrollers", meta.RESTScopeNamespace)
+	{"", []string{"v1"}, "Binding", "bindings", meta.RESTScopeNamespace},
+	{"", []string{"v1"}, "ComponentStatus", "componentstatuses", meta.RESTScopeRoot},
+	{"", []string{"v1"}, "ConfigMap", "configmaps", meta.RESTScopeNamespace},
+	{"", []string{"v1"}, "Endpoints", "endpoints", meta.RESTScopeNamespace},
+	{"", []string{"v1"}, "Event", "events", meta.RESTScopeNamespace},
+	{"", []string{"v1"}, "LimitRange", "limitranges", meta.RESTScopeNamespace},
+	{"", []string{"v1"}, "Namespace", "namespaces", meta.RESTScopeRoot},
+	{"", []string{"v1"}, "Node", "nodes", meta.RESTScopeRoot},
+	{"", []string{"v1"}, "PersistentVolume", "persistentvolumes", meta.RESTScopeRoot},
+	{"", []string{"v1"}, "PersistentVolumeClaim", "persistentvolumeclaims", meta.RESTScopeNamespace},
+	{"", []string{"v1"}, "Pod", "pods", meta.RESTScopeNamespace},
+	{"", []string{"v1"}, "PodTemplate", "podtemplates", meta.RESTScopeNamespace},
+	{"", []string{"v1"}, "ReplicationController", "replicationcontrollers", meta.RESTScopeNamespace},
+	{"", []string{"v1"}, "ResourceQuota", "resourcequotas", meta.RESTScopeNamespace},
+	{"", []string{"v1"}, "Secret", "secrets", meta.RESTScopeNamespace},
+	{"", []string{"v1"}, "Service", "services", meta.RESTScopeNamespace},
+	{"", []string{"v1"}, "ServiceAccount", "serviceaccounts", meta.RESTScopeNamespace},
+
+	// Admissionregistrationリソース
+	{"admissionregistration.k8s.io", []string{"v1", "v1beta1"}, "MutatingWebhookConfiguration", "mutatingwebhookconfigurations", meta.RESTScopeRoot},
+	{"admissionregistration.k8s.io", []string{"v1", "v1beta1"}, "ValidatingWebhookConfiguration", "validatingwebhookconfigurations", meta.RESTScopeRoot},
+	{"admissionregistration.k8s.io", []string{"v1", "v1beta1", "v1alpha1"}, "ValidatingAdmissionPolicy", "validatingadmissionpolicies", meta.RESTScopeRoot},
+	{"admissionregistration.k8s.io", []string{"v1", "v1beta1", "v1alpha1"}, "ValidatingAdmissionPolicyBinding", "validatingadmissionpolicybindings", meta.RESTScopeRoot},
+
+	// Apiextensions / Apiregistrationリソース
+	{"apiextensions.k8s.io", []string{"v1", "v1beta1"}, "CustomResourceDefinition", "customresourcedefinitions", meta.RESTScopeRoot},
+	{"apiregistration.k8s.io", []string{"v1", "v1beta1"}, "APIService", "apiservices", meta.RESTScopeRoot},
 
 	// Appsリソース
-	addSpecificResource(mapper, "apps", "v1", "Deployment", "deployments", meta.RESTScopeNamespace)
-	addSpecificResource(mapper, "apps", "v1", "StatefulSet", "statefulsets", meta.RESTScopeNamespace)
-	addSpecificResource(mapper, "apps", "v1", "DaemonSet", "daemonsets", meta.RESTScopeNamespace)
-	addSpecificResource(mapper, "apps", "v1", "ReplicaSet", "replicasets", meta.RESTScopeNamespace)
+	{"apps", []string{"v1", "v1beta2", "v1beta1"}, "ControllerRevision", "controllerrevisions", meta.RESTScopeNamespace},
+	{"apps", []string{"v1", "v1beta2"}, "DaemonSet", "daemonsets", meta.RESTScopeNamespace},
+	{"apps", []string{"v1", "v1beta2", "v1beta1"}, "Deployment", "deployments", meta.RESTScopeNamespace},
+	{"apps", []string{"v1", "v1beta2"}, "ReplicaSet", "replicasets", meta.RESTScopeNamespace},
+	{"apps", []string{"v1", "v1beta2", "v1beta1"}, "StatefulSet", "statefulsets", meta.RESTScopeNamespace},
+
+	// Authentication / Authorizationリソース
+	{"authentication.k8s.io", []string{"v1", "v1beta1", "v1alpha1"}, "SelfSubjectReview", "selfsubjectreviews", meta.RESTScopeRoot},
+	{"authentication.k8s.io", []string{"v1", "v1beta1"}, "TokenReview", "tokenreviews", meta.RESTScopeRoot},
+	{"authorization.k8s.io", []string{"v1", "v1beta1"}, "LocalSubjectAccessReview", "localsubjectaccessreviews", meta.RESTScopeNamespace},
+	{"authorization.k8s.io", []string{"v1", "v1beta1"}, "SelfSubjectAccessReview", "selfsubjectaccessreviews", meta.RESTScopeRoot},
+	{"authorization.k8s.io", []string{"v1", "v1beta1"}, "SelfSubjectRulesReview", "selfsubjectrulesreviews", meta.RESTScopeRoot},
+	{"authorization.k8s.io", []string{"v1", "v1beta1"}, "SubjectAccessReview", "subjectaccessreviews", meta.RESTScopeRoot},
+
+	// Autoscalingリソース
+	{"autoscaling", []string{"v2", "v1", "v2beta2", "v2beta1"}, "HorizontalPodAutoscaler", "horizontalpodautoscalers", meta.RESTScopeNamespace},
 
 	// Batchリソース
-	addSpecificResource(mapper, "batch", "v1", "Job", "jobs", meta.RESTScopeNamespace)
-	addSpecificResource(mapper, "batch", "v1", "CronJob", "cronjobs", meta.RESTScopeNamespace)
+	{"batch", []string{"v1", "v1beta1"}, "CronJob", "cronjobs", meta.RESTScopeNamespace},
+	{"batch", []string{"v1"}, "Job", "jobs", meta.RESTScopeNamespace},
+
+	// Certificates / Coordinationリソース
+	{"certificates.k8s.io", []string{"v1", "v1beta1"}, "CertificateSigningRequest", "certificatesigningrequests", meta.RESTScopeRoot},
+	{"certificates.k8s.io", []string{"v1alpha1"}, "ClusterTrustBundle", "clustertrustbundles", meta.RESTScopeRoot},
+	{"coordination.k8s.io", []string{"v1", "v1beta1"}, "Lease", "leases", meta.RESTScopeNamespace},
+	{"coordination.k8s.io", []string{"v1alpha1"}, "LeaseCandidate", "leasecandidates", meta.RESTScopeNamespace},
+
+	// Discovery / Eventsリソース
+	{"discovery.k8s.io", []string{"v1", "v1beta1"}, "EndpointSlice", "endpointslices", meta.RESTScopeNamespace},
+	{"events.k8s.io", []string{"v1", "v1beta1"}, "Event", "events", meta.RESTScopeNamespace},
+
+	// Extensionsリソース
+	{"extensions", []string{"v1beta1"}, "DaemonSet", "daemonsets", meta.RESTScopeNamespace},
+	{"extensions", []string{"v1beta1"}, "Deployment", "deployments", meta.RESTScopeNamespace},
+	{"extensions", []string{"v1beta1"}, "Ingress", "ingresses", meta.RESTScopeNamespace},
+	{"extensions", []string{"v1beta1"}, "NetworkPolicy", "networkpolicies", meta.RESTScopeNamespace},
+	{"extensions", []string{"v1beta1"}, "ReplicaSet", "replicasets", meta.RESTScopeNamespace},
+
+	// Flowcontrolリソース
+	{"flowcontrol.apiserver.k8s.io", []string{"v1", "v1beta3", "v1beta2", "v1beta1"}, "FlowSchema", "flowschemas", meta.RESTScopeRoot},
+	{"flowcontrol.apiserver.k8s.io", []string{"v1", "v1beta3", "v1beta2", "v1beta1"}, "PriorityLevelConfiguration", "prioritylevelconfigurations", meta.RESTScopeRoot},
+	{"internal.apiserver.k8s.io", []string{"v1alpha1"}, "StorageVersion", "storageversions", meta.RESTScopeRoot},
 
 	// Networkingリソース
-	addSpecificResource(mapper, "networking.k8s.io", "v1", "Ingress", "ingresses", meta.RESTScopeNamespace)
-	addSpecificResource(mapper, "networking.k8s.io", "v1", "NetworkPolicy", "networkpolicies", meta.RESTScopeNamespace)
+	{"networking.k8s.io", []string{"v1beta1", "v1alpha1"}, "IPAddress", "ipaddresses", meta.RESTScopeRoot},
+	{"networking.k8s.io", []string{"v1", "v1beta1"}, "Ingress", "ingresses", meta.RESTScopeNamespace},
+	{"networking.k8s.io", []string{"v1", "v1beta1"}, "IngressClass", "ingressclasses", meta.RESTScopeRoot},
+	{"networking.k8s.io", []string{"v1"}, "NetworkPolicy", "networkpolicies", meta.RESTScopeNamespace},
+	{"networking.k8s.io", []string{"v1beta1", "v1alpha1"}, "ServiceCIDR", "servicecidrs", meta.RESTScopeRoot},
+	{"node.k8s.io", []string{"v1", "v1beta1", "v1alpha1"}, "RuntimeClass", "runtimeclasses", meta.RESTScopeRoot},
+
+	// Policyリソース
+	{"policy", []string{"v1", "v1beta1"}, "PodDisruptionBudget", "poddisruptionbudgets", meta.RESTScopeNamespace},
 
 	// RBACリソース
-	addSpecificResource(mapper, "rbac.authorization.k8s.io", "v1", "Role", "roles", meta.RESTScopeNamespace)
-	addSpecificResource(mapper, "rbac.authorization.k8s.io", "v1", "ClusterRole", "clusterroles", meta.RESTScopeRoot)
-	addSpecificResource(mapper, "rbac.authorization.k8s.io", "v1", "RoleBinding", "rolebindings", meta.RESTScopeNamespace)
-	addSpecificResource(mapper, "rbac.authorization.k8s.io", "v1", "ClusterRoleBinding", "clusterrolebindings", meta.RESTScopeRoot)
-
-	// その他必要なリソースを追加
-	// 例:
-	// addSpecificResource(mapper, "autoscaling", "v1", "HorizontalPodAutoscaler", "horizontalpodautoscalers", meta.RESTScopeNamespace)
+	{"rbac.authorization.k8s.io", []string{"v1", "v1beta1", "v1alpha1"}, "ClusterRole", "clusterroles", meta.RESTScopeRoot},
+	{"rbac.authorization.k8s.io", []string{"v1", "v1beta1", "v1alpha1"}, "ClusterRoleBinding", "clusterrolebindings", meta.RESTScopeRoot},
+	{"rbac.authorization.k8s.io", []string{"v1", "v1beta1", "v1alpha1"}, "Role", "roles", meta.RESTScopeNamespace},
+	{"rbac.authorization.k8s.io", []string{"v1", "v1beta1", "v1alpha1"}, "RoleBinding", "rolebindings", meta.RESTScopeNamespace},
+
+	// Resourceリソース
+	{"resource.k8s.io", []string{"v1alpha3"}, "DeviceClass", "deviceclasses", meta.RESTScopeRoot},
+	{"resource.k8s.io", []string{"v1alpha3"}, "PodSchedulingContext", "podschedulingcontexts", meta.RESTScopeNamespace},
+	{"resource.k8s.io", []string{"v1alpha3"}, "ResourceClaim", "resourceclaims", meta.RESTScopeNamespace},
+	{"resource.k8s.io", []string{"v1alpha3"}, "ResourceClaimTemplate", "resourceclaimtemplates", meta.RESTScopeNamespace},
+	{"resource.k8s.io", []string{"v1alpha3"}, "ResourceSlice", "resourceslices", meta.RESTScopeRoot},
+
+	// Schedulingリソース
+	{"scheduling.k8s.io", []string{"v1", "v1beta1", "v1alpha1"}, "PriorityClass", "priorityclasses", meta.RESTScopeRoot},
+
+	// Storageリソース
+	{"storage.k8s.io", []string{"v1", "v1beta1"}, "CSIDriver", "csidrivers", meta.RESTScopeRoot},
+	{"storage.k8s.io", []string{"v1", "v1beta1"}, "CSINode", "csinodes", meta.RESTScopeRoot},
+	{"storage.k8s.io", []string{"v1", "v1beta1", "v1alpha1"}, "CSIStorageCapacity", "csistoragecapacities", meta.RESTScopeNamespace},
+	{"storage.k8s.io", []string{"v1", "v1beta1"}, "StorageClass", "storageclasses", meta.RESTScopeRoot},
+	{"storage.k8s.io", []string{"v1", "v1beta1", "v1alpha1"}, "VolumeAttachment", "volumeattachments", meta.RESTScopeRoot},
+	{"storage.k8s.io", []string{"v1beta1", "v1alpha1"}, "VolumeAttributesClass", "volumeattributesclasses", meta.RESTScopeRoot},
+	{"storagemigration.k8s.io", []string{"v1alpha1"}, "StorageVersionMigration", "storageversionmigrations", meta.RESTScopeRoot},
+}
+
+// ResourceMapper is a RESTMapper that also knows which subresources are served for each resource.
+type ResourceMapper struct {
+	*meta.DefaultRESTMapper
+	subresources map[schema.GroupVersionResource][]string
+}
+
+func newResourceMapper(groupVersions []schema.GroupVersion) *ResourceMapper {
+	return &ResourceMapper{
+		DefaultRESTMapper: meta.NewDefaultRESTMapper(groupVersions),
+		subresources:      make(map[schema.GroupVersionResource][]string),
+	}
+}
+
+// Subresources returns the subresources served for the given resource.
+func (m *ResourceMapper) Subresources(gvr schema.GroupVersionResource) []string {
+	return m.subresources[gvr]
+}
+
+func (m *ResourceMapper) addSubresource(gvr schema.GroupVersionResource, subresource string) {
+	for _, s := range m.subresources[gvr] {
+		if s == subresource {
+			return
+		}
+	}
+	m.subresources[gvr] = append(m.subresources[gvr], subresource)
+}
+
+// builtinSubresources lists the subresources served for built-in resources, keyed by "<group>/<resource>".
+var builtinSubresources = map[string][]string{
+	"/pods":                                {"attach", "binding", "ephemeralcontainers", "eviction", "exec", "log", "portforward", "proxy", "resize", "status"},
+	"/services":                            {"proxy", "status"},
+	"/nodes":                               {"proxy", "status"},
+	"/namespaces":                          {"finalize", "status"},
+	"/serviceaccounts":                     {"token"},
+	"/persistentvolumes":                   {"status"},
+	"/persistentvolumeclaims":              {"status"},
+	"/replicationcontrollers":              {"scale", "status"},
+	"/resourcequotas":                      {"status"},
+	"apps/deployments":                     {"scale", "status"},
+	"apps/replicasets":                     {"scale", "status"},
+	"apps/statefulsets":                    {"scale", "status"},
+	"apps/daemonsets":                      {"status"},
+	"autoscaling/horizontalpodautoscalers": {"status"},
+	"batch/cronjobs":                       {"status"},
+	"batch/jobs":                           {"status"},
+	"certificates.k8s.io/certificatesigningrequests": {"approval", "status"},
+	"networking.k8s.io/ingresses":                    {"status"},
+	"policy/poddisruptionbudgets":                    {"status"},
+	"resource.k8s.io/resourceclaims":                 {"status"},
+	"storage.k8s.io/volumeattachments":               {"status"},
+}
+
+// staticMappers caches the static RESTMapper built for each scheme.
+var staticMappers sync.Map
+
+// staticRESTMapper returns the static RESTMapper for the scheme, building it on first use.
+func staticRESTMapper(scheme *runtime.Scheme) *ResourceMapper {
+	if mapper, ok := staticMappers.Load(scheme); ok {
+		return mapper.(*ResourceMapper)
+	}
+	mapper, _ := staticMappers.LoadOrStore(scheme, createStaticRESTMapper(scheme))
+	return mapper.(*ResourceMapper)
+}
+
+// StaticRESTMapper returns the RESTMapper of the built-in Kubernetes API catalog, used by NewTargetInfoList.
+func StaticRESTMapper(scheme *runtime.Scheme) meta.RESTMapper {
+	return staticRESTMapper(scheme)
+}
+
+func createStaticRESTMapper(scheme *runtime.Scheme) *ResourceMapper {
+	groupVersions := scheme.PrioritizedVersionsAllGroups()
+
+	mapper := newResourceMapper(groupVersions)
+
+	addResourceMappings(mapper)
+
+	return mapper
+}
+
+func addResourceMappings(mapper *ResourceMapper) {
+	for _, r := range builtinResources {
+		for _, version := range r.versions {
+			addSpecificResource(mapper, r.group, version, r.kind, r.resource, r.scope)
+			for _, subresource := range builtinSubresources[r.group+"/"+r.resource] {
+				mapper.addSubresource(schema.GroupVersionResource{Group: r.group, Version: version, Resource: r.resource}, subresource)
+			}
+		}
+	}
 }
 
-func addSpecificResource(mapper *meta.DefaultRESTMapper, group, version, kind, resource string, scope meta.RESTScope) {
+func addSpecificResource(mapper *ResourceMapper, group, version, kind, resource string, scope meta.RESTScope) {
 	gvk := schema.GroupVersionKind{Group: group, Version: version, Kind: kind}
 	gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: resource}
 	scopeValue := meta.RESTScopeRoot
@@ -0,0 +1,247 @@
+package target
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// SubresourceAnnotation declares that a manifest represents a request to a subresource of the object,
+	// e.g. "scale", "status", "ephemeralcontainers" or "exec".
+	SubresourceAnnotation = "vaptest/subresource"
+	// OperationAnnotation overrides the admission operation (CREATE, UPDATE, DELETE or CONNECT) of the request.
+	OperationAnnotation = "vaptest/operation"
+	// OptionsAnnotation holds the JSON body of a subresource request whose object is not the manifest itself,
+	// e.g. the PodExecOptions of a pods/exec request.
+	OptionsAnnotation = "vaptest/options"
+)
+
+// Admission operations
+const (
+	OperationCreate  = "CREATE"
+	OperationUpdate  = "UPDATE"
+	OperationDelete  = "DELETE"
+	OperationConnect = "CONNECT"
+)
+
+// subresourceRequest describes how the apiserver admits a request to a subresource.
+type subresourceRequest struct {
+	operation string
+	// newObject builds the object seen by admission. A nil newObject means the parent object itself is admitted.
+	newObject func(resource string, parent map[string]interface{}, options []byte) (map[string]interface{}, error)
+}
+
+var subresourceRequests = map[string]subresourceRequest{
+	"status":              {operation: OperationUpdate},
+	"ephemeralcontainers": {operation: OperationUpdate},
+	"resize":              {operation: OperationUpdate},
+	"approval":            {operation: OperationUpdate},
+	"finalize":            {operation: OperationUpdate},
+	"scale":               {operation: OperationUpdate, newObject: newScaleObject},
+	"exec":                {operation: OperationConnect, newObject: newOptionsObject(func() runtime.Object { return &corev1.PodExecOptions{} })},
+	"attach":              {operation: OperationConnect, newObject: newOptionsObject(func() runtime.Object { return &corev1.PodAttachOptions{} })},
+	"portforward":         {operation: OperationConnect, newObject: newOptionsObject(func() runtime.Object { return &corev1.PodPortForwardOptions{} })},
+	"proxy":               {operation: OperationConnect, newObject: newProxyOptionsObject},
+	"eviction":            {operation: OperationCreate, newObject: newChildObject(func() runtime.Object { return &policyv1.Eviction{} })},
+	"binding":             {operation: OperationCreate, newObject: newChildObject(func() runtime.Object { return &corev1.Binding{} })},
+	"token":               {operation: OperationCreate, newObject: newChildObject(func() runtime.Object { return &authenticationv1.TokenRequest{} })},
+}
+
+// applySubresourceRequest turns a manifest annotated with SubresourceAnnotation into the subresource request it declares.
+// It sets SubResource and Operation and replaces Object with the object admitted for the request.
+func applySubresourceRequest(info *TargetInfo, served []string) error {
+	annotations, _, _ := unstructured.NestedStringMap(info.Object, "metadata", "annotations")
+	stripRequestAnnotations(info.Object)
+
+	subresource := annotations[SubresourceAnnotation]
+	info.Operation = OperationCreate
+	if subresource != "" {
+		if len(served) > 0 && !containsString(served, subresource) {
+			return fmt.Errorf("subresource %q is not served for resource %s (served: %s)", subresource, info.Resource, strings.Join(served, ", "))
+		}
+
+		info.SubResource = subresource
+		info.Operation = OperationUpdate
+		if req, ok := subresourceRequests[subresource]; ok {
+			info.Operation = req.operation
+			if req.newObject != nil {
+				obj, err := req.newObject(info.Resource, info.Object, []byte(annotations[OptionsAnnotation]))
+				if err != nil {
+					return fmt.Errorf("failed to build %s/%s request object for %s: %w", info.Resource, subresource, info.ResourceName, err)
+				}
+				info.Object = obj
+				// デフォルト値が設定されたフィールドは親オブジェクトのものなので破棄する
+				info.DefaultedFields = nil
+			}
+		}
+	}
+
+	if operation, ok := annotations[OperationAnnotation]; ok {
+		switch operation {
+		case OperationCreate, OperationUpdate, OperationDelete, OperationConnect:
+			info.Operation = operation
+		default:
+			return fmt.Errorf("invalid %s annotation %q on %s", OperationAnnotation, operation, info.ResourceName)
+		}
+	}
+	return nil
+}
+
+// stripRequestAnnotations removes the vaptest request annotations so that policies see the object as admitted.
+func stripRequestAnnotations(obj map[string]interface{}) {
+	metadata, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	annotations, ok := metadata["annotations"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, key := range []string{SubresourceAnnotation, OperationAnnotation, OptionsAnnotation} {
+		delete(annotations, key)
+	}
+	if len(annotations) == 0 {
+		delete(metadata, "annotations")
+	}
+}
+
+// newScaleObject builds the autoscaling/v1 Scale admitted for a <resource>/scale request.
+func newScaleObject(_ string, parent map[string]interface{}, _ []byte) (map[string]interface{}, error) {
+	scale := &autoscalingv1.Scale{
+		TypeMeta: metav1.TypeMeta{APIVersion: autoscalingv1.SchemeGroupVersion.String(), Kind: "Scale"},
+	}
+	copyNameAndNamespace(parent, scale)
+
+	// The apiserver defaults replicas to 1 for all scalable built-in workloads.
+	scale.Spec.Replicas = 1
+	if replicas, ok, _ := unstructured.NestedInt64(parent, "spec", "replicas"); ok {
+		scale.Spec.Replicas = int32(replicas)
+	}
+	if replicas, ok, _ := unstructured.NestedInt64(parent, "status", "replicas"); ok {
+		scale.Status.Replicas = int32(replicas)
+	}
+
+	selector, err := scaleSelector(parent)
+	if err != nil {
+		return nil, err
+	}
+	scale.Status.Selector = selector
+
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(scale)
+}
+
+// scaleSelector renders spec.selector as the label selector string reported in Scale status.
+// ReplicationControllers use a plain label map, other workloads a LabelSelector.
+func scaleSelector(parent map[string]interface{}) (string, error) {
+	spec, _ := parent["spec"].(map[string]interface{})
+	rawSelector, ok := spec["selector"].(map[string]interface{})
+	if !ok {
+		return "", nil
+	}
+
+	_, hasMatchLabels := rawSelector["matchLabels"]
+	_, hasMatchExpressions := rawSelector["matchExpressions"]
+	if !hasMatchLabels && !hasMatchExpressions {
+		set := labels.Set{}
+		for k, v := range rawSelector {
+			set[k] = fmt.Sprint(v)
+		}
+		return labels.SelectorFromSet(set).String(), nil
+	}
+
+	var labelSelector metav1.LabelSelector
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawSelector, &labelSelector); err != nil {
+		return "", err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&labelSelector)
+	if err != nil {
+		return "", err
+	}
+	return selector.String(), nil
+}
+
+// newOptionsObject builds a connect options object such as PodExecOptions from the OptionsAnnotation.
+func newOptionsObject(newFn func() runtime.Object) func(string, map[string]interface{}, []byte) (map[string]interface{}, error) {
+	return func(_ string, _ map[string]interface{}, options []byte) (map[string]interface{}, error) {
+		return decodeRequestObject(newFn(), options)
+	}
+}
+
+// newProxyOptionsObject builds the options object of a proxy request, whose type depends on the proxied resource.
+func newProxyOptionsObject(resource string, parent map[string]interface{}, options []byte) (map[string]interface{}, error) {
+	switch resource {
+	case "services":
+		return decodeRequestObject(&corev1.ServiceProxyOptions{}, options)
+	case "nodes":
+		return decodeRequestObject(&corev1.NodeProxyOptions{}, options)
+	default:
+		return decodeRequestObject(&corev1.PodProxyOptions{}, options)
+	}
+}
+
+// newChildObject builds an object created through a subresource, such as an Eviction, named after its parent.
+func newChildObject(newFn func() runtime.Object) func(string, map[string]interface{}, []byte) (map[string]interface{}, error) {
+	return func(_ string, parent map[string]interface{}, options []byte) (map[string]interface{}, error) {
+		obj := newFn()
+		if len(options) > 0 {
+			if err := json.Unmarshal(options, obj); err != nil {
+				return nil, fmt.Errorf("invalid %s annotation: %w", OptionsAnnotation, err)
+			}
+		}
+		copyNameAndNamespace(parent, obj)
+		return toRequestObject(obj)
+	}
+}
+
+func decodeRequestObject(obj runtime.Object, options []byte) (map[string]interface{}, error) {
+	if len(options) > 0 {
+		if err := json.Unmarshal(options, obj); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", OptionsAnnotation, err)
+		}
+	}
+	return toRequestObject(obj)
+}
+
+// toRequestObject sets the kind of a request object from the client-go scheme and converts it to unstructured data.
+func toRequestObject(obj runtime.Object) (map[string]interface{}, error) {
+	gvk, err := getGroupVersionKind(obj)
+	if err != nil {
+		return nil, err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
+
+func copyNameAndNamespace(parent map[string]interface{}, obj runtime.Object) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	metadata, _ := parent["metadata"].(map[string]interface{})
+	if name, ok := metadata["name"].(string); ok {
+		accessor.SetName(name)
+	}
+	if namespace, ok := metadata["namespace"].(string); ok {
+		accessor.SetNamespace(namespace)
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
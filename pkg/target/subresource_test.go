@@ -0,0 +1,173 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func TestNewTargetInfoSubresource(t *testing.T) {
+	deployment := func(annotations map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata": map[string]interface{}{
+					"name":        "test-deployment",
+					"namespace":   "default",
+					"annotations": annotations,
+				},
+				"spec": map[string]interface{}{
+					"replicas": int64(10),
+					"selector": map[string]interface{}{
+						"matchLabels": map[string]interface{}{"app": "test"},
+					},
+				},
+			},
+		}
+	}
+	pod := func(annotations map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Pod",
+				"metadata": map[string]interface{}{
+					"name":        "test-pod",
+					"namespace":   "default",
+					"annotations": annotations,
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name                string
+		obj                 *unstructured.Unstructured
+		expectedSubResource string
+		expectedOperation   string
+		expectedKind        string
+		check               func(t *testing.T, obj map[string]interface{})
+		wantErr             bool
+	}{
+		{
+			name:              "no annotation is a create request of the object",
+			obj:               deployment(map[string]interface{}{"keep": "me"}),
+			expectedOperation: OperationCreate,
+			expectedKind:      "Deployment",
+			check: func(t *testing.T, obj map[string]interface{}) {
+				annotations, _, _ := unstructured.NestedStringMap(obj, "metadata", "annotations")
+				assert.Equal(t, map[string]string{"keep": "me"}, annotations)
+			},
+		},
+		{
+			name:                "scale binds an autoscaling/v1 Scale",
+			obj:                 deployment(map[string]interface{}{SubresourceAnnotation: "scale"}),
+			expectedSubResource: "scale",
+			expectedOperation:   OperationUpdate,
+			expectedKind:        "Scale",
+			check: func(t *testing.T, obj map[string]interface{}) {
+				assert.Equal(t, "autoscaling/v1", obj["apiVersion"])
+				replicas, _, _ := unstructured.NestedInt64(obj, "spec", "replicas")
+				assert.Equal(t, int64(10), replicas)
+				selector, _, _ := unstructured.NestedString(obj, "status", "selector")
+				assert.Equal(t, "app=test", selector)
+				name, _, _ := unstructured.NestedString(obj, "metadata", "name")
+				assert.Equal(t, "test-deployment", name)
+			},
+		},
+		{
+			name:                "status binds the object itself without request annotations",
+			obj:                 deployment(map[string]interface{}{SubresourceAnnotation: "status"}),
+			expectedSubResource: "status",
+			expectedOperation:   OperationUpdate,
+			expectedKind:        "Deployment",
+			check: func(t *testing.T, obj map[string]interface{}) {
+				_, found, _ := unstructured.NestedMap(obj, "metadata", "annotations")
+				assert.False(t, found)
+			},
+		},
+		{
+			name:                "ephemeralcontainers binds the pod",
+			obj:                 pod(map[string]interface{}{SubresourceAnnotation: "ephemeralcontainers"}),
+			expectedSubResource: "ephemeralcontainers",
+			expectedOperation:   OperationUpdate,
+			expectedKind:        "Pod",
+		},
+		{
+			name: "exec binds PodExecOptions from the options annotation",
+			obj: pod(map[string]interface{}{
+				SubresourceAnnotation: "exec",
+				OptionsAnnotation:     `{"command":["sh"],"container":"app","stdin":true,"tty":true}`,
+			}),
+			expectedSubResource: "exec",
+			expectedOperation:   OperationConnect,
+			expectedKind:        "PodExecOptions",
+			check: func(t *testing.T, obj map[string]interface{}) {
+				command, _, _ := unstructured.NestedStringSlice(obj, "command")
+				assert.Equal(t, []string{"sh"}, command)
+				tty, _, _ := unstructured.NestedBool(obj, "tty")
+				assert.True(t, tty)
+			},
+		},
+		{
+			name:                "eviction binds a policy/v1 Eviction named after the pod",
+			obj:                 pod(map[string]interface{}{SubresourceAnnotation: "eviction"}),
+			expectedSubResource: "eviction",
+			expectedOperation:   OperationCreate,
+			expectedKind:        "Eviction",
+			check: func(t *testing.T, obj map[string]interface{}) {
+				assert.Equal(t, "policy/v1", obj["apiVersion"])
+				name, _, _ := unstructured.NestedString(obj, "metadata", "name")
+				assert.Equal(t, "test-pod", name)
+			},
+		},
+		{
+			name:                "operation annotation overrides the operation",
+			obj:                 deployment(map[string]interface{}{OperationAnnotation: OperationDelete}),
+			expectedSubResource: "",
+			expectedOperation:   OperationDelete,
+			expectedKind:        "Deployment",
+		},
+		{
+			name:    "subresource not served for the resource",
+			obj:     deployment(map[string]interface{}{SubresourceAnnotation: "exec"}),
+			wantErr: true,
+		},
+		{
+			name:    "invalid options annotation",
+			obj:     pod(map[string]interface{}{SubresourceAnnotation: "exec", OptionsAnnotation: "{"}),
+			wantErr: true,
+		},
+		{
+			name:    "invalid operation annotation",
+			obj:     pod(map[string]interface{}{OperationAnnotation: "PATCH"}),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := NewTargetInfo(tc.obj, scheme.Scheme)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedSubResource, info.SubResource)
+			assert.Equal(t, tc.expectedOperation, info.Operation)
+			assert.Equal(t, tc.expectedKind, info.Object["kind"])
+
+			request := info.RequestAttributes()
+			assert.Equal(t, tc.expectedSubResource, request["subResource"])
+			assert.Equal(t, tc.expectedOperation, request["operation"])
+			assert.Equal(t, tc.expectedKind, request["kind"].(map[string]interface{})["kind"])
+			if tc.check != nil {
+				tc.check(t, info.Object)
+			}
+		})
+	}
+}
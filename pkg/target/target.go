@@ -13,6 +13,8 @@ import (
 type TargetInfo struct {
 	TargetIdentifier
 	Object map[string]interface{}
+	// DefaultedFields are the paths of the fields set by API defaulting, e.g. spec.replicas.
+	DefaultedFields []string
 }
 
 type TargetIdentifier struct {
@@ -23,14 +25,56 @@ type TargetIdentifier struct {
 	SubResource  string `json:"subResource"`
 	ResourceName string `json:"resourceName"`
 	Namespace    string `json:"namespace"`
+	Operation    string `json:"operation"`
+	// Scope is "Namespaced" or "Cluster", as in the scope of admission rules.
+	Scope string `json:"scope"`
 }
 
+const (
+	ScopeNamespaced = "Namespaced"
+	ScopeCluster    = "Cluster"
+)
+
 type TargetInfoList []TargetInfo
 
-func NewTargetInfoList(objects []runtime.Object, scheme *runtime.Scheme) (TargetInfoList, error) {
+// subresourceLister is implemented by RESTMappers that know the subresources served for each resource.
+type subresourceLister interface {
+	Subresources(gvr schema.GroupVersionResource) []string
+}
+
+// RequestAttributes returns the admission request attributes bound to the CEL variable "request".
+// The request kind is the kind of the admitted object, e.g. autoscaling/v1 Scale for a deployments/scale request.
+func (t *TargetInfo) RequestAttributes() map[string]interface{} {
+	requestGroupVersion, _ := schema.ParseGroupVersion(fmt.Sprint(t.Object["apiVersion"]))
+	requestKind, _ := t.Object["kind"].(string)
+	return map[string]interface{}{
+		"kind": map[string]interface{}{
+			"group":   requestGroupVersion.Group,
+			"version": requestGroupVersion.Version,
+			"kind":    requestKind,
+		},
+		"resource": map[string]interface{}{
+			"group":    t.APIGroup,
+			"version":  t.APIVersion,
+			"resource": t.Resource,
+		},
+		"subResource": t.SubResource,
+		"name":        t.ResourceName,
+		"namespace":   t.Namespace,
+		"operation":   t.Operation,
+	}
+}
+
+func NewTargetInfoList(objects []runtime.Object, scheme *runtime.Scheme, opts ...Option) (TargetInfoList, error) {
+	return NewTargetInfoListWithMapper(objects, staticRESTMapper(scheme), opts...)
+}
+
+// NewTargetInfoListWithMapper creates a TargetInfoList resolving resources with the given RESTMapper,
+// such as one built from a discovery snapshot.
+func NewTargetInfoListWithMapper(objects []runtime.Object, mapper meta.RESTMapper, opts ...Option) (TargetInfoList, error) {
 	results := make([]TargetInfo, 0)
 	for _, obj := range objects {
-		info, err := NewTargetInfo(obj, scheme)
+		info, err := NewTargetInfoWithMapper(obj, mapper, opts...)
 		if err != nil {
 			return nil, err
 		}
@@ -39,7 +83,15 @@ func NewTargetInfoList(objects []runtime.Object, scheme *runtime.Scheme) (Target
 	return results, nil
 }
 
-func NewTargetInfo(obj runtime.Object, scheme *runtime.Scheme) (*TargetInfo, error) {
+func NewTargetInfo(obj runtime.Object, scheme *runtime.Scheme, opts ...Option) (*TargetInfo, error) {
+	// スキームごとにキャッシュされた静的なRESTMapperを使用
+	return NewTargetInfoWithMapper(obj, staticRESTMapper(scheme), opts...)
+}
+
+// NewTargetInfoWithMapper creates a TargetInfo resolving the resource with the given RESTMapper.
+func NewTargetInfoWithMapper(obj runtime.Object, mapper meta.RESTMapper, opts ...Option) (*TargetInfo, error) {
+	o := newOptions(opts)
+
 	metaObj, err := getObjectMeta(obj)
 	if err != nil {
 		return &TargetInfo{}, err
@@ -50,17 +102,32 @@ func NewTargetInfo(obj runtime.Object, scheme *runtime.Scheme) (*TargetInfo, err
 		return &TargetInfo{}, err
 	}
 
-	// 静的なRESTMapperを作成
-	mapper := createStaticRESTMapper(scheme)
-
-	gvr, err := getGroupVersionResource(gvk, mapper)
-	if err != nil {
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	var gvr schema.GroupVersionResource
+	var scope string
+	switch {
+	case err == nil:
+		gvr = mapping.Resource
+		scope = scopeOf(mapping.Scope)
+	case meta.IsNoMatchError(err):
+		// 未知のリソースの場合、Kindからリソース名を推測し、namespaceの有無からスコープを判断する
+		gvr, _ = meta.UnsafeGuessKindToResource(gvk)
+		scope = ScopeCluster
+		if metaObj.GetNamespace() != "" {
+			scope = ScopeNamespaced
+		}
+	default:
 		return &TargetInfo{}, err
 	}
+	var servedSubresources []string
+	if lister, ok := mapper.(subresourceLister); ok && mapping != nil {
+		servedSubresources = lister.Subresources(gvr)
+	}
 
 	resourceName := metaObj.GetName()
 
-	objMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	// デフォルト値の適用が有効な場合、apiserverと同様にデフォルト値を設定したオブジェクトを評価対象とする
+	objMap, defaultedFields, err := applyDefaults(obj, o.defaulter)
 	if err != nil {
 		fmt.Println(err)
 		return &TargetInfo{}, err
@@ -72,11 +139,17 @@ func NewTargetInfo(obj runtime.Object, scheme *runtime.Scheme) (*TargetInfo, err
 			APIVersion:   gvk.Version,
 			Resource:     gvr.Resource,
 			Kind:         gvk.Kind,
-			SubResource:  "", // サブリソースがある場合は設定
 			Namespace:    metaObj.GetNamespace(),
 			ResourceName: resourceName,
+			Scope:        scope,
 		},
-		Object: objMap,
+		Object:          objMap,
+		DefaultedFields: defaultedFields,
+	}
+
+	// サブリソースへのリクエストの場合、SubResource・Operation・Objectを設定
+	if err := applySubresourceRequest(&targetInfo, servedSubresources); err != nil {
+		return &TargetInfo{}, err
 	}
 
 	return &targetInfo, nil
@@ -106,10 +179,10 @@ func getGroupVersionKind(obj runtime.Object) (schema.GroupVersionKind, error) {
 	return gvk, nil
 }
 
-func getGroupVersionResource(gvk schema.GroupVersionKind, mapper meta.RESTMapper) (schema.GroupVersionResource, error) {
-	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
-	if err != nil {
-		return schema.GroupVersionResource{}, err
+// scopeOf returns the admission rule scope of a RESTScope.
+func scopeOf(scope meta.RESTScope) string {
+	if scope != nil && scope.Name() == meta.RESTScopeNameRoot {
+		return ScopeCluster
 	}
-	return mapping.Resource, nil
+	return ScopeNamespaced
 }
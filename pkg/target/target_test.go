@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -35,6 +36,7 @@ func TestNewTargetInfo(t *testing.T) {
 					APIVersion:   "v1",
 					Resource:     "deployments",
 					ResourceName: "test-deployment",
+					Scope:        ScopeNamespaced,
 				},
 			},
 			wantErr: false,
@@ -56,6 +58,7 @@ func TestNewTargetInfo(t *testing.T) {
 					APIVersion:   "v1",
 					Resource:     "pods",
 					ResourceName: "test-pod",
+					Scope:        ScopeNamespaced,
 				},
 			},
 			wantErr: false,
@@ -77,6 +80,7 @@ func TestNewTargetInfo(t *testing.T) {
 					APIVersion:   "v1",
 					Resource:     "deployments",
 					ResourceName: "test-structured-deployment",
+					Scope:        ScopeNamespaced,
 				},
 			},
 			wantErr: false,
@@ -92,8 +96,100 @@ func TestNewTargetInfo(t *testing.T) {
 					},
 				},
 			},
-			expected: nil,
-			wantErr:  true,
+			expected: &TargetInfo{
+				TargetIdentifier: TargetIdentifier{
+					APIGroup:     "unknown",
+					APIVersion:   "v1",
+					Resource:     "unknownresources",
+					ResourceName: "test-unknown",
+					Scope:        ScopeCluster,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "有効な構造化オブジェクト（CronJob）",
+			obj: &batchv1.CronJob{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "batch/v1",
+					Kind:       "CronJob",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-cronjob",
+				},
+			},
+			expected: &TargetInfo{
+				TargetIdentifier: TargetIdentifier{
+					APIGroup:     "batch",
+					APIVersion:   "v1",
+					Resource:     "cronjobs",
+					ResourceName: "test-cronjob",
+					Scope:        ScopeNamespaced,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "有効なUnstructuredオブジェクト（HorizontalPodAutoscaler）",
+			obj: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "autoscaling/v2",
+					"kind":       "HorizontalPodAutoscaler",
+					"metadata": map[string]interface{}{
+						"name": "test-hpa",
+					},
+				},
+			},
+			expected: &TargetInfo{
+				TargetIdentifier: TargetIdentifier{
+					APIGroup:     "autoscaling",
+					APIVersion:   "v2",
+					Resource:     "horizontalpodautoscalers",
+					ResourceName: "test-hpa",
+					Scope:        ScopeNamespaced,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "クラスタスコープのリソース（Namespace）",
+			obj: &corev1.Namespace{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+				ObjectMeta: metav1.ObjectMeta{Name: "test-namespace"},
+			},
+			expected: &TargetInfo{
+				TargetIdentifier: TargetIdentifier{
+					APIGroup:     "",
+					APIVersion:   "v1",
+					Resource:     "namespaces",
+					ResourceName: "test-namespace",
+					Scope:        ScopeCluster,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "namespaceを持つ未知のリソース種類",
+			obj: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "unknown/v1",
+					"kind":       "UnknownResource",
+					"metadata": map[string]interface{}{
+						"name":      "test-unknown",
+						"namespace": "default",
+					},
+				},
+			},
+			expected: &TargetInfo{
+				TargetIdentifier: TargetIdentifier{
+					APIGroup:     "unknown",
+					APIVersion:   "v1",
+					Resource:     "unknownresources",
+					ResourceName: "test-unknown",
+					Scope:        ScopeNamespaced,
+				},
+			},
+			wantErr: false,
 		},
 	}
 
@@ -119,6 +215,9 @@ func TestNewTargetInfo(t *testing.T) {
 				if result.ResourceName != tc.expected.ResourceName {
 					t.Errorf("ResourceName mismatch: got %v, want %v", result.ResourceName, tc.expected.ResourceName)
 				}
+				if result.Scope != tc.expected.Scope {
+					t.Errorf("Scope mismatch: got %v, want %v", result.Scope, tc.expected.Scope)
+				}
 				if result.Object == nil {
 					t.Error("Object is nil, but should not be")
 				}
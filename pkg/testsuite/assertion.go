@@ -0,0 +1,115 @@
+package testsuite
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/yashirook/vaptest/pkg/validator"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// assertionView is the part of a result a case asserts on, rendered as YAML to diff the expected and actual values.
+type assertionView struct {
+	Validations      []validationView  `json:"validations,omitempty"`
+	Warnings         []string          `json:"warnings,omitempty"`
+	AuditAnnotations map[string]string `json:"auditAnnotations,omitempty"`
+}
+
+type validationView struct {
+	Index      *int                `json:"index,omitempty"`
+	Expression string              `json:"expression,omitempty"`
+	Failed     bool                `json:"failed"`
+	Message    string              `json:"message,omitempty"`
+	Reason     metav1.StatusReason `json:"reason,omitempty"`
+	Code       int32               `json:"code,omitempty"`
+}
+
+// diffAssertions returns a unified diff of the values the case asserts on and the values of the result,
+// or an empty string when they agree. Only the asserted fields are compared.
+func diffAssertions(c Case, result *validator.ValidationResult) string {
+	if len(c.Validations) == 0 && len(c.Warnings) == 0 && len(c.AuditAnnotations) == 0 {
+		return ""
+	}
+	if result == nil {
+		result = &validator.ValidationResult{Success: true}
+	}
+
+	var expected, actual assertionView
+	for _, a := range c.Validations {
+		e, a := a.views(result.ValidationErrors)
+		expected.Validations = append(expected.Validations, e)
+		actual.Validations = append(actual.Validations, a)
+	}
+	if len(c.Warnings) > 0 {
+		expected.Warnings = c.Warnings
+		actual.Warnings = result.Warnings
+	}
+	if len(c.AuditAnnotations) > 0 {
+		expected.AuditAnnotations = c.AuditAnnotations
+		actual.AuditAnnotations = map[string]string{}
+		for key := range c.AuditAnnotations {
+			if value, ok := result.AuditAnnotations[key]; ok {
+				actual.AuditAnnotations[key] = value
+			}
+		}
+	}
+
+	expectedYAML, _ := sigsyaml.Marshal(expected)
+	actualYAML, _ := sigsyaml.Marshal(actual)
+	if string(expectedYAML) == string(actualYAML) {
+		return ""
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(string(expectedYAML)),
+		B:        splitLines(string(actualYAML)),
+		FromFile: "expected",
+		ToFile:   "actual",
+		Context:  3,
+	})
+	return diff
+}
+
+// splitLines splits the text into lines, each ending with a newline.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// views returns the expected and actual views of the validation the assertion selects.
+// A message matching messageRegex is shown as the regular expression, so that it does not show up in the diff.
+func (a ValidationAssertion) views(errs []validator.ValidationError) (validationView, validationView) {
+	expected := validationView{Index: a.Index, Expression: a.Expression, Failed: true, Message: a.Message, Reason: a.Reason, Code: a.Code}
+	if a.MessageRegex != "" {
+		expected.Message = "=~ " + a.MessageRegex
+	}
+	actual := validationView{Index: a.Index, Expression: a.Expression}
+
+	for _, e := range errs {
+		if (a.Index != nil && e.Index != *a.Index) || (a.Expression != "" && e.CELExpr != a.Expression) {
+			continue
+		}
+		actual.Failed = true
+		switch {
+		case a.Message != "":
+			actual.Message = e.Message
+		case a.MessageRegex != "":
+			actual.Message = e.Message
+			if regexp.MustCompile(a.MessageRegex).MatchString(e.Message) {
+				actual.Message = expected.Message
+			}
+		}
+		if a.Reason != "" {
+			actual.Reason = e.Reason
+		}
+		if a.Code != 0 {
+			actual.Code = e.Code
+		}
+		break
+	}
+	return expected, actual
+}
@@ -0,0 +1,78 @@
+package testsuite
+
+import (
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// applyPatches returns the variant of the object produced by applying the patches in order.
+// The object itself is not modified.
+func applyPatches(obj runtime.Object, patches []Patch, scheme *runtime.Scheme) (runtime.Object, error) {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		kinds, _, err := scheme.ObjectKinds(obj)
+		if err != nil {
+			return nil, err
+		}
+		gvk = kinds[0]
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(gvk)
+	doc, err := json.Marshal(u.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	_, isUnstructured := obj.(runtime.Unstructured)
+	for i, p := range patches {
+		switch p.Type {
+		case PatchTypeJSON:
+			var patch jsonpatch.Patch
+			patch, err = jsonpatch.DecodePatch(p.Patch)
+			if err == nil {
+				doc, err = patch.Apply(doc)
+			}
+		case PatchTypeMerge:
+			doc, err = jsonpatch.MergePatch(doc, p.Patch)
+		case PatchTypeStrategic:
+			if isUnstructured {
+				// スキーマのないリソースにはパッチのマージ方法が定義されていない
+				return nil, fmt.Errorf("patches[%d] (%s): strategic merge patch is not supported for %s, use a json or merge patch", i, p.Type, gvk.Kind)
+			}
+			var dataStruct runtime.Object
+			dataStruct, err = scheme.New(gvk)
+			if err == nil {
+				doc, err = strategicpatch.StrategicMergePatch(doc, p.Patch, dataStruct)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("patches[%d] (%s): %w", i, p.Type, err)
+		}
+	}
+
+	if isUnstructured {
+		variant := &unstructured.Unstructured{}
+		if err := variant.UnmarshalJSON(doc); err != nil {
+			return nil, err
+		}
+		return variant, nil
+	}
+	variant, err := scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(doc, variant); err != nil {
+		return nil, fmt.Errorf("patched %s is invalid: %w", gvk.Kind, err)
+	}
+	variant.GetObjectKind().SetGroupVersionKind(gvk)
+	return variant, nil
+}
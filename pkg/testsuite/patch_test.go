@@ -0,0 +1,115 @@
+package testsuite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yashirook/vaptest/pkg/defaults"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+)
+
+func TestApplyPatches(t *testing.T) {
+	scheme := defaults.NewScheme()
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", Labels: map[string]string{"app": "a"}},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To[int32](1),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "nginx:1.27"}}},
+			},
+		},
+	}
+	widget := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]interface{}{"name": "widget"},
+		"spec":       map[string]interface{}{"size": int64(1)},
+	}}
+
+	testCases := []struct {
+		name          string
+		obj           runtime.Object
+		patches       []Patch
+		expected      runtime.Object
+		expectedError string
+	}{
+		{
+			name: "JSON Patchを適用する",
+			obj:  deployment,
+			patches: []Patch{
+				{Type: PatchTypeJSON, Patch: []byte(`[{"op": "replace", "path": "/spec/replicas", "value": 3}, {"op": "remove", "path": "/metadata/labels"}]`)},
+			},
+			expected: func() runtime.Object {
+				d := deployment.DeepCopy()
+				d.TypeMeta = metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"}
+				d.Spec.Replicas = ptr.To[int32](3)
+				d.Labels = nil
+				return d
+			}(),
+		},
+		{
+			name: "strategic merge patchはコンテナを名前でマージする",
+			obj:  deployment,
+			patches: []Patch{
+				{Type: PatchTypeStrategic, Patch: []byte(`{"spec": {"template": {"spec": {"containers": [{"name": "sidecar", "image": "envoy:latest"}]}}}}`)},
+			},
+			expected: func() runtime.Object {
+				d := deployment.DeepCopy()
+				d.TypeMeta = metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"}
+				d.Spec.Template.Spec.Containers = []corev1.Container{{Name: "sidecar", Image: "envoy:latest"}, {Name: "app", Image: "nginx:1.27"}}
+				return d
+			}(),
+		},
+		{
+			name: "スキーマのないリソースにはmerge patchを適用する",
+			obj:  widget,
+			patches: []Patch{
+				{Type: PatchTypeMerge, Patch: []byte(`{"spec": {"size": 2}}`)},
+			},
+			expected: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "example.com/v1",
+				"kind":       "Widget",
+				"metadata":   map[string]interface{}{"name": "widget"},
+				"spec":       map[string]interface{}{"size": int64(2)},
+			}},
+		},
+		{
+			name: "適用できなかったパッチを報告する",
+			obj:  deployment,
+			patches: []Patch{
+				{Type: PatchTypeMerge, Patch: []byte(`{"spec": {"replicas": 2}}`)},
+				{Type: PatchTypeJSON, Patch: []byte(`[{"op": "remove", "path": "/spec/paused"}]`)},
+			},
+			expectedError: "patches[1] (json): ",
+		},
+		{
+			name: "スキーマのないリソースにはstrategic merge patchを適用できない",
+			obj:  widget,
+			patches: []Patch{
+				{Type: PatchTypeStrategic, Patch: []byte(`{"spec": {"size": 2}}`)},
+			},
+			expectedError: "patches[0] (strategic): strategic merge patch is not supported for Widget, use a json or merge patch",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			variant, err := applyPatches(tc.obj, tc.patches, scheme)
+			if tc.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, variant)
+		})
+	}
+	// 元のオブジェクトは変更しない
+	assert.Equal(t, ptr.To[int32](1), deployment.Spec.Replicas)
+}
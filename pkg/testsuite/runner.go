@@ -0,0 +1,218 @@
+package testsuite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/yashirook/vaptest/pkg/coverage"
+	"github.com/yashirook/vaptest/pkg/loader"
+	"github.com/yashirook/vaptest/pkg/target"
+	"github.com/yashirook/vaptest/pkg/validation"
+	"github.com/yashirook/vaptest/pkg/validator"
+	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Runner evaluates test suites with the upstream engine, which applies the bindings of the policies
+// and reports the validation actions of failures, as the apiserver does.
+type Runner struct {
+	Scheme *runtime.Scheme
+	// RESTMapper resolves resources. Nil means the built-in API catalog.
+	RESTMapper meta.RESTMapper
+	// Strict rejects unknown fields and duplicate fields in manifests, and reports them and values of the wrong
+	// type with the document and the field path, as loader.Loader.Strict does.
+	Strict bool
+	// Defaulting applies Kubernetes API defaulting to resources before evaluation.
+	Defaulting bool
+	// Warnings holds non-fatal problems found while loading manifests.
+	Warnings []error
+	// Coverage, if set, accumulates the coverage of the expressions of the policies over the suites run.
+	Coverage *coverage.Profile
+	// Override, if set, replaces the policy of the same name loaded from a suite, e.g. with a mutant of it.
+	// It is not validated, as a mutant may be invalid on purpose.
+	Override *v1.ValidatingAdmissionPolicy
+}
+
+// NewRunner creates a Runner with API defaulting enabled.
+func NewRunner(scheme *runtime.Scheme) *Runner {
+	return &Runner{Scheme: scheme, Defaulting: true}
+}
+
+// CaseResult is the outcome of a test case.
+type CaseResult struct {
+	Suite string
+	Case  Case
+	// Actual is the result of the evaluation. It is empty when the case could not be run.
+	Actual Result
+	// Messages are the messages of the failed validations.
+	Messages []string
+	// Diff is a unified diff of the asserted and actual validations, warnings and audit annotations,
+	// empty when they agree.
+	Diff string
+	// Err reports why the case could not be run, e.g. the resource is not in the suite.
+	Err error
+}
+
+// Passed reports whether the actual result is the expected one and all assertions of the case hold.
+func (r CaseResult) Passed() bool {
+	return r.Err == nil && r.Actual == r.Case.Expect && r.Diff == ""
+}
+
+// Run evaluates the suite and returns the results of its cases in order.
+// Errors loading the manifests of the suite or evaluating its policies are returned as errors.
+func (r *Runner) Run(ctx context.Context, suite *Suite) ([]CaseResult, error) {
+	ldr := loader.NewLoader(r.Scheme)
+	ldr.Strict = r.Strict
+	policies, bindings, err := ldr.LoadPolicyFromPaths(append(slices.Clone(suite.Policies), suite.Bindings...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policy objects: %w", err)
+	}
+	if errs := validation.ValidatePolicyObjects(policies, bindings, r.Scheme, ldr.Source); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid policy object: %w", errors.Join(errs...))
+	}
+	if r.Override != nil {
+		for i, policy := range policies {
+			if policy.Name == r.Override.Name {
+				policies[i] = r.Override
+			}
+		}
+	}
+	params, err := ldr.LoadObjectFromPaths(suite.Params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load params: %w", err)
+	}
+	resources, err := ldr.LoadObjectFromPaths(suite.Resources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load resources: %w", err)
+	}
+	r.Warnings = append(r.Warnings, ldr.Warnings...)
+
+	mapper := r.RESTMapper
+	if mapper == nil {
+		mapper = target.StaticRESTMapper(r.Scheme)
+	}
+	var opts []target.Option
+	if r.Defaulting {
+		opts = append(opts, target.WithDefaulting(r.Scheme))
+	}
+	targets, err := target.NewTargetInfoListWithMapper(resources, mapper, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create target info list: %w", err)
+	}
+
+	// パッチを適用したバリアントは元のリソースと同じ識別子を持つため、targetsの位置で結果を対応付ける
+	caseTargets := make([]int, len(suite.Tests))
+	caseErrs := make([]error, len(suite.Tests))
+	for i, c := range suite.Tests {
+		caseTargets[i] = findTarget(targets[:len(resources)], c.Resource)
+		if caseTargets[i] < 0 || len(c.Patches) == 0 {
+			continue
+		}
+		variant, err := applyPatches(resources[caseTargets[i]], c.Patches, r.Scheme)
+		if err != nil {
+			caseErrs[i] = fmt.Errorf("failed to patch %s: %w", c.Resource, err)
+			continue
+		}
+		info, err := target.NewTargetInfoWithMapper(variant, mapper, opts...)
+		if err != nil {
+			caseErrs[i] = fmt.Errorf("failed to patch %s: %w", c.Resource, err)
+			continue
+		}
+		caseTargets[i] = len(targets)
+		targets = append(targets, *info)
+	}
+
+	v, err := validator.NewUpstreamValidator(targets, policies, bindings, r.Scheme)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create validator: %w", err)
+	}
+	v.Params = params
+	v.RESTMapper = mapper
+	v.Coverage = r.Coverage
+	validationResults, err := v.ValidatePerTargetContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	if r.Coverage != nil {
+		for _, policy := range policies {
+			if err := r.Coverage.SetSource(policy.Name, ldr.Source(policy)); err != nil {
+				r.Warnings = append(r.Warnings, err)
+			}
+		}
+	}
+
+	caseResults := make([]CaseResult, 0, len(suite.Tests))
+	for i, c := range suite.Tests {
+		caseResult := CaseResult{Suite: suite.Name, Case: c}
+		switch {
+		case !slices.ContainsFunc(policies, func(p *v1.ValidatingAdmissionPolicy) bool { return p.Name == c.Policy }):
+			caseResult.Err = fmt.Errorf("policy %s not found in the suite", c.Policy)
+		case caseTargets[i] < 0:
+			caseResult.Err = fmt.Errorf("resource %s not found in the suite", c.Resource)
+		case caseErrs[i] != nil:
+			caseResult.Err = caseErrs[i]
+		default:
+			result := validator.FindResult(validationResults[caseTargets[i]], c.Policy)
+			caseResult.Actual, caseResult.Messages = outcome(result)
+			caseResult.Diff = diffAssertions(c, result)
+		}
+		caseResults = append(caseResults, caseResult)
+	}
+	return caseResults, nil
+}
+
+// findTarget returns the index of the target the reference refers to, or -1.
+func findTarget(targets target.TargetInfoList, ref ResourceRef) int {
+	for i, t := range targets {
+		if t.Kind != ref.Kind || t.ResourceName != ref.Name {
+			continue
+		}
+		namespace, refNamespace := t.Namespace, ref.Namespace
+		if t.Scope == target.ScopeNamespaced {
+			// apiserverと同様に、namespaceのないリソースはdefault namespaceとして扱う
+			if namespace == "" {
+				namespace = "default"
+			}
+			if refNamespace == "" {
+				refNamespace = "default"
+			}
+		}
+		if namespace == refNamespace {
+			return i
+		}
+	}
+	return -1
+}
+
+// outcome classifies the result of the upstream engine. The most severe outcome wins:
+// evaluation errors, then Deny, Warn and Audit.
+func outcome(result *validator.ValidationResult) (Result, []string) {
+	if result == nil {
+		return ResultSkip, nil
+	}
+	if result.Success {
+		return ResultPass, nil
+	}
+
+	var messages []string
+	var evaluationError bool
+	var actions []v1.ValidationAction
+	for _, e := range result.ValidationErrors {
+		messages = append(messages, e.Message)
+		evaluationError = evaluationError || e.EvaluationError
+		actions = append(actions, e.Actions...)
+	}
+	switch {
+	case evaluationError:
+		return ResultError, messages
+	case slices.Contains(actions, v1.Deny):
+		return ResultDeny, messages
+	case slices.Contains(actions, v1.Warn):
+		return ResultWarn, messages
+	default:
+		return ResultAudit, messages
+	}
+}
@@ -0,0 +1,231 @@
+package testsuite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yashirook/vaptest/pkg/coverage"
+	"github.com/yashirook/vaptest/pkg/defaults"
+	"github.com/yashirook/vaptest/pkg/loader"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestRunnerRun(t *testing.T) {
+	suite, err := Load("testdata/policies/suite.yaml")
+	require.NoError(t, err)
+
+	// 期待と異なる結果や存在しないリソースはケースの失敗として報告する
+	suite.Tests = append(suite.Tests,
+		Case{Name: "wrong expectation", Policy: "replica-limit", Resource: ResourceRef{Kind: "Deployment", Name: "large"}, Expect: ResultPass},
+		Case{Name: "missing resource", Policy: "replica-limit", Resource: ResourceRef{Kind: "Deployment", Name: "missing"}, Expect: ResultPass},
+		Case{Name: "missing policy", Policy: "missing", Resource: ResourceRef{Kind: "Deployment", Name: "small"}, Expect: ResultPass},
+	)
+
+	results, err := NewRunner(defaults.NewScheme()).Run(context.Background(), suite)
+	require.NoError(t, err)
+	require.Len(t, results, len(suite.Tests))
+
+	for _, result := range results[:6] {
+		assert.True(t, result.Passed(), "%s: expected %s, got %s (%v)", result.Case.Name, result.Case.Expect, result.Actual, result.Err)
+		assert.Equal(t, "deployment policies", result.Suite)
+	}
+	assert.Equal(t, []string{"replicas must be at most 5"}, results[1].Messages)
+	assert.Equal(t, []string{"labels are required"}, results[3].Messages)
+
+	assert.False(t, results[6].Passed())
+	assert.Equal(t, ResultDeny, results[6].Actual)
+	assert.NoError(t, results[6].Err)
+
+	assert.False(t, results[7].Passed())
+	assert.EqualError(t, results[7].Err, "resource Deployment missing not found in the suite")
+
+	assert.False(t, results[8].Passed())
+	assert.EqualError(t, results[8].Err, "policy missing not found in the suite")
+}
+
+func TestRunnerRunAssertions(t *testing.T) {
+	suite, err := Load("testdata/policies/suite.yaml")
+	require.NoError(t, err)
+	large := ResourceRef{Kind: "Deployment", Name: "large"}
+	unlabeled := ResourceRef{Kind: "Deployment", Name: "unlabeled"}
+
+	testCases := []struct {
+		name         string
+		testCase     Case
+		expectedDiff string
+	}{
+		{
+			name: "メッセージ・reason・ステータスコードの不一致を差分で報告する",
+			testCase: Case{
+				Policy: "replica-limit", Resource: large, Expect: ResultDeny,
+				Validations: []ValidationAssertion{{Index: ptr.To(0), Message: "replicas must be at most 3", Reason: metav1.StatusReasonInvalid, Code: 403}},
+			},
+			expectedDiff: `--- expected
++++ actual
+@@ -2,5 +2,5 @@
+ - code: 403
+   failed: true
+   index: 0
+-  message: replicas must be at most 3
+-  reason: Invalid
++  message: replicas must be at most 5
++  reason: Forbidden
+`,
+		},
+		{
+			name: "失敗していないバリデーションを報告する",
+			testCase: Case{
+				Policy: "require-labels", Resource: large, Expect: ResultPass,
+				Validations: []ValidationAssertion{{Expression: "has(object.metadata.labels)"}},
+			},
+			expectedDiff: `--- expected
++++ actual
+@@ -1,3 +1,3 @@
+ validations:
+ - expression: has(object.metadata.labels)
+-  failed: true
++  failed: false
+`,
+		},
+		{
+			name: "正規表現に一致しないメッセージを報告する",
+			testCase: Case{
+				Policy: "require-labels", Resource: unlabeled, Expect: ResultWarn,
+				Validations: []ValidationAssertion{{Index: ptr.To(0), MessageRegex: "^Labels"}},
+				Warnings:    []string{"labels are required"},
+			},
+			expectedDiff: `--- expected
++++ actual
+@@ -1,6 +1,7 @@
+ validations:
+ - failed: true
+   index: 0
+-  message: =~ ^Labels
++  message: labels are required
+ warnings:
+-- labels are required
++- 'Validation failed for ValidatingAdmissionPolicy ''require-labels'' with binding
++  ''require-labels-binding'': labels are required'
+`,
+		},
+		{
+			name: "監査アノテーションの不足を報告する",
+			testCase: Case{
+				Policy: "replica-limit", Resource: large, Expect: ResultDeny,
+				AuditAnnotations: map[string]string{"replicas": "10", "owner": "team-a"},
+			},
+			expectedDiff: `--- expected
++++ actual
+@@ -1,3 +1,2 @@
+ auditAnnotations:
+-  owner: team-a
+   replicas: "10"
+`,
+		},
+		{
+			name: "一致するアサーションは差分を報告しない",
+			testCase: Case{
+				Policy: "replica-limit", Resource: large, Expect: ResultDeny,
+				Validations: []ValidationAssertion{{Index: ptr.To(0), MessageRegex: "at most [0-9]+$", Reason: metav1.StatusReasonForbidden}},
+			},
+		},
+	}
+
+	suite.Tests = nil
+	for _, tc := range testCases {
+		tc.testCase.Name = tc.name
+		suite.Tests = append(suite.Tests, tc.testCase)
+	}
+	results, err := NewRunner(defaults.NewScheme()).Run(context.Background(), suite)
+	require.NoError(t, err)
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, results[i].Err)
+			assert.Equal(t, tc.testCase.Expect, results[i].Actual)
+			assert.Equal(t, tc.expectedDiff, results[i].Diff)
+			assert.Equal(t, tc.expectedDiff == "", results[i].Passed())
+		})
+	}
+}
+
+func TestRunnerRunVariants(t *testing.T) {
+	suite, err := Load("testdata/variants/suite.yaml")
+	require.NoError(t, err)
+	suite.Tests = append(suite.Tests, Case{
+		Name:     "broken patch",
+		Policy:   "replica-limit",
+		Resource: ResourceRef{Kind: "Deployment", Name: "small"},
+		Patches:  []Patch{{Type: PatchTypeJSON, Patch: []byte(`[{"op": "remove", "path": "/spec/paused"}]`)}},
+		Expect:   ResultPass,
+	})
+
+	results, err := NewRunner(defaults.NewScheme()).Run(context.Background(), suite)
+	require.NoError(t, err)
+	require.Len(t, results, len(suite.Tests))
+
+	// バリアントは元のリソースとは別に評価される
+	for _, result := range results[:len(results)-1] {
+		assert.True(t, result.Passed(), "%s: expected %s, got %s (%v, %s)", result.Case.Name, result.Case.Expect, result.Actual, result.Err, result.Diff)
+	}
+	broken := results[len(results)-1]
+	require.Error(t, broken.Err)
+	assert.Contains(t, broken.Err.Error(), "failed to patch Deployment small: patches[0] (json): ")
+}
+
+func TestRunnerRunCoverage(t *testing.T) {
+	suite, err := Load("testdata/policies/suite.yaml")
+	require.NoError(t, err)
+
+	runner := NewRunner(defaults.NewScheme())
+	runner.Coverage = coverage.New()
+	// 複数回の実行の結果は同じプロファイルに合算される
+	for range 2 {
+		_, err = runner.Run(context.Background(), suite)
+		require.NoError(t, err)
+	}
+
+	type counts struct {
+		policy  string
+		line    int
+		t, f, e int
+	}
+	var got []counts
+	for _, e := range runner.Coverage.Expressions {
+		assert.Equal(t, "testdata/policies/policy.yaml", e.Source)
+		got = append(got, counts{e.Policy, e.Line, e.True, e.False, e.Error})
+	}
+	assert.Equal(t, []counts{
+		{"replica-limit", 17, 4, 2, 0},
+		{"require-labels", 37, 6, 2, 0},
+		{"latest-image", 53, 6, 2, 0},
+		{"owner-annotation", 69, 6, 0, 2},
+	}, got)
+}
+
+func TestRunnerRunOverride(t *testing.T) {
+	suite, err := Load("testdata/policies/suite.yaml")
+	require.NoError(t, err)
+	suite.Tests = suite.Tests[3:4]
+
+	runner := NewRunner(defaults.NewScheme())
+	results, err := runner.Run(context.Background(), suite)
+	require.NoError(t, err)
+	require.True(t, results[0].Passed())
+
+	// 同じ名前のポリシーを置き換えて評価する
+	ldr := loader.NewLoader(runner.Scheme)
+	policies, _, err := ldr.LoadPolicyFromPaths([]string{"testdata/policies/policy.yaml"})
+	require.NoError(t, err)
+	override := policies[1].DeepCopy()
+	require.Equal(t, "require-labels", override.Name)
+	override.Spec.Validations[0].Expression = "true"
+	runner.Override = override
+
+	results, err = runner.Run(context.Background(), suite)
+	require.NoError(t, err)
+	assert.Equal(t, ResultPass, results[0].Actual)
+}
@@ -0,0 +1,324 @@
+package testsuite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	sigsyaml "sigs.k8s.io/yaml"
+	yaml "sigs.k8s.io/yaml/goyaml.v3"
+)
+
+const (
+	// APIVersion and Kind identify a test suite file.
+	APIVersion = "vaptest/v1alpha1"
+	Kind       = "TestSuite"
+)
+
+// Result is the outcome of admitting a resource to a policy.
+type Result string
+
+const (
+	// ResultPass means the policy was evaluated and every validation passed.
+	ResultPass Result = "pass"
+	// ResultDeny means a validation failed with the Deny validation action.
+	ResultDeny Result = "deny"
+	// ResultWarn means a validation failed with the Warn validation action only.
+	ResultWarn Result = "warn"
+	// ResultAudit means a validation failed with the Audit validation action only.
+	ResultAudit Result = "audit"
+	// ResultSkip means the policy was not evaluated, e.g. no binding matches the resource.
+	ResultSkip Result = "skip"
+	// ResultError means a validation could not be evaluated, e.g. a CEL runtime error or missing params.
+	ResultError Result = "error"
+)
+
+var results = []Result{ResultPass, ResultDeny, ResultWarn, ResultAudit, ResultSkip, ResultError}
+
+// Suite is a set of test cases sharing the policies, bindings, params and resources they are evaluated with.
+// Paths are relative to the directory of the suite file.
+type Suite struct {
+	metav1.TypeMeta `json:",inline"`
+	Name            string   `json:"name"`
+	Policies        []string `json:"policies"`
+	Bindings        []string `json:"bindings,omitempty"`
+	Params          []string `json:"params,omitempty"`
+	Resources       []string `json:"resources"`
+	Tests           []Case   `json:"tests"`
+
+	// Path is the path of the suite file.
+	Path string `json:"-"`
+}
+
+// Case is the expected result of admitting a resource to a policy.
+type Case struct {
+	Name     string      `json:"name"`
+	Policy   string      `json:"policy"`
+	Resource ResourceRef `json:"resource"`
+	Expect   Result      `json:"expect"`
+	// Patches turn the resource into a variant evaluated in its place, applied in order.
+	Patches []Patch `json:"patches,omitempty"`
+	// Validations are assertions on the failed validations.
+	Validations []ValidationAssertion `json:"validations,omitempty"`
+	// Warnings are the warnings expected for the Warn validation action, in order.
+	Warnings []string `json:"warnings,omitempty"`
+	// AuditAnnotations are the expected values of audit annotations of the policy, keyed without the policy name.
+	AuditAnnotations map[string]string `json:"auditAnnotations,omitempty"`
+}
+
+// PatchType is the type of a patch, as the --type flag of kubectl patch.
+type PatchType string
+
+const (
+	// PatchTypeJSON is a JSON Patch (RFC 6902).
+	PatchTypeJSON PatchType = "json"
+	// PatchTypeMerge is a JSON Merge Patch (RFC 7386).
+	PatchTypeMerge PatchType = "merge"
+	// PatchTypeStrategic is a strategic merge patch, only supported for built-in types.
+	PatchTypeStrategic PatchType = "strategic"
+)
+
+var patchTypes = []PatchType{PatchTypeJSON, PatchTypeMerge, PatchTypeStrategic}
+
+// Patch is a patch of a resource. The type defaults to strategic, as in kubectl patch.
+type Patch struct {
+	Type  PatchType       `json:"type,omitempty"`
+	Patch json.RawMessage `json:"patch"`
+}
+
+// ValidationAssertion asserts that the validation selected by its index or expression failed,
+// and optionally its message, reason and status code.
+type ValidationAssertion struct {
+	Index      *int   `json:"index,omitempty"`
+	Expression string `json:"expression,omitempty"`
+	// Message is the expected message, rendered from messageExpression if set.
+	Message string `json:"message,omitempty"`
+	// MessageRegex is a regular expression the message must match.
+	MessageRegex string `json:"messageRegex,omitempty"`
+	// Reason and Code are the expected status of the denied request.
+	Reason metav1.StatusReason `json:"reason,omitempty"`
+	Code   int32               `json:"code,omitempty"`
+}
+
+// ResourceRef refers to a resource of the suite.
+// An empty namespace of a namespaced resource means the default namespace.
+type ResourceRef struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+func (r ResourceRef) String() string {
+	if r.Namespace != "" {
+		return fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name)
+	}
+	return fmt.Sprintf("%s %s", r.Kind, r.Name)
+}
+
+// Load reads a test suite file and resolves its paths against the directory of the file.
+func Load(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read test suite %s: %w", path, err)
+	}
+	if !isSuite(data) {
+		return nil, fmt.Errorf("invalid test suite %s: apiVersion and kind must be %s and %s", path, APIVersion, Kind)
+	}
+	var suite Suite
+	if err := sigsyaml.UnmarshalStrict(data, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse test suite %s: %w", path, err)
+	}
+	if err := suite.validate(); err != nil {
+		return nil, fmt.Errorf("invalid test suite %s: %w", path, err)
+	}
+
+	suite.Path = path
+	if suite.Name == "" {
+		suite.Name = path
+	}
+	dir := filepath.Dir(path)
+	for _, paths := range [][]string{suite.Policies, suite.Bindings, suite.Params, suite.Resources} {
+		for i, p := range paths {
+			if !filepath.IsAbs(p) {
+				paths[i] = filepath.Join(dir, p)
+			}
+		}
+	}
+	return &suite, nil
+}
+
+// Write writes the suite as YAML, with its paths relative to the directory of Path, or to the working directory
+// if Path is empty, as Load resolves them. The fields are written in the order of the suite file format.
+func (s *Suite) Write(w io.Writer) error {
+	dir, err := filepath.Abs(filepath.Dir(s.Path))
+	if err != nil {
+		return err
+	}
+	relative := *s
+	for _, paths := range []*[]string{&relative.Policies, &relative.Bindings, &relative.Params, &relative.Resources} {
+		resolved := make([]string, len(*paths))
+		for i, p := range *paths {
+			resolved[i] = p
+			if filepath.IsAbs(p) {
+				continue
+			}
+			if abs, err := filepath.Abs(p); err == nil {
+				if rel, err := filepath.Rel(dir, abs); err == nil {
+					resolved[i] = filepath.ToSlash(rel)
+				}
+			}
+		}
+		*paths = resolved
+	}
+	relative.APIVersion, relative.Kind = APIVersion, Kind
+
+	// JSONとして書き出したフィールドの順序をYAMLのノードで保つ
+	data, err := json.Marshal(relative)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	clearStyle(&doc)
+	// TypeMetaはkindを先に書き出すため、マニフェストと同じくapiVersionを先頭にする
+	if root := doc.Content[0]; len(root.Content) >= 4 && root.Content[0].Value == "kind" {
+		content := root.Content
+		content[0], content[1], content[2], content[3] = content[2], content[3], content[0], content[1]
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// clearStyle drops the flow style and quotes of the nodes decoded from JSON. The encoder still quotes strings
+// that would read as another type.
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+func (s *Suite) validate() error {
+	if len(s.Policies) == 0 {
+		return fmt.Errorf("policies: required")
+	}
+	if len(s.Resources) == 0 {
+		return fmt.Errorf("resources: required")
+	}
+	if len(s.Tests) == 0 {
+		return fmt.Errorf("tests: required")
+	}
+	for i, c := range s.Tests {
+		switch {
+		case c.Name == "":
+			return fmt.Errorf("tests[%d].name: required", i)
+		case c.Policy == "":
+			return fmt.Errorf("tests[%d].policy: required", i)
+		case c.Resource.Kind == "" || c.Resource.Name == "":
+			return fmt.Errorf("tests[%d].resource: kind and name are required", i)
+		case !slices.Contains(results, c.Expect):
+			return fmt.Errorf("tests[%d].expect: unsupported value %q: must be one of %v", i, c.Expect, results)
+		}
+		for j := range c.Patches {
+			p := &s.Tests[i].Patches[j]
+			if p.Type == "" {
+				p.Type = PatchTypeStrategic
+			}
+			switch {
+			case !slices.Contains(patchTypes, p.Type):
+				return fmt.Errorf("tests[%d].patches[%d].type: unsupported value %q: must be one of %v", i, j, p.Type, patchTypes)
+			case len(p.Patch) == 0:
+				return fmt.Errorf("tests[%d].patches[%d].patch: required", i, j)
+			}
+		}
+		for j, a := range c.Validations {
+			if err := a.validate(); err != nil {
+				return fmt.Errorf("tests[%d].validations[%d]: %w", i, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *ValidationAssertion) validate() error {
+	if (a.Index == nil) == (a.Expression == "") {
+		return fmt.Errorf("exactly one of index or expression is required")
+	}
+	if a.Message != "" && a.MessageRegex != "" {
+		return fmt.Errorf("message and messageRegex are mutually exclusive")
+	}
+	if a.MessageRegex != "" {
+		if _, err := regexp.Compile(a.MessageRegex); err != nil {
+			return fmt.Errorf("messageRegex: %w", err)
+		}
+	}
+	return nil
+}
+
+// LoadFromPaths loads the test suites of the paths. Directories are searched recursively
+// for YAML files of kind TestSuite, and other files in them are ignored.
+func LoadFromPaths(paths []string) ([]*Suite, error) {
+	var suites []*Suite
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read test suite %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			suite, err := Load(path)
+			if err != nil {
+				return nil, err
+			}
+			suites = append(suites, suite)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !isYAML(p) {
+				return nil
+			}
+			data, err := os.ReadFile(p)
+			if err != nil || !isSuite(data) {
+				return err
+			}
+			suite, err := Load(p)
+			if err != nil {
+				return err
+			}
+			suites = append(suites, suite)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return suites, nil
+}
+
+func isYAML(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// isSuite reports whether the document is of kind TestSuite, so that manifests next to suites are skipped.
+func isSuite(data []byte) bool {
+	var typeMeta metav1.TypeMeta
+	if err := sigsyaml.Unmarshal(data, &typeMeta); err != nil {
+		return false
+	}
+	return typeMeta.Kind == Kind && typeMeta.APIVersion == APIVersion
+}
@@ -0,0 +1,147 @@
+package testsuite
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestLoad(t *testing.T) {
+	suite, err := Load("testdata/policies/suite.yaml")
+	require.NoError(t, err)
+
+	assert.Equal(t, "deployment policies", suite.Name)
+	assert.Equal(t, "testdata/policies/suite.yaml", suite.Path)
+	// パスはスイートファイルのディレクトリからの相対パスとして解決する
+	assert.Equal(t, []string{filepath.Join("testdata", "policies", "policy.yaml")}, suite.Policies)
+	assert.Equal(t, []string{filepath.Join("testdata", "policies", "resources.yaml")}, suite.Resources)
+	require.Len(t, suite.Tests, 6)
+	assert.Equal(t, Case{
+		Name:     "replicas over the limit are denied",
+		Policy:   "replica-limit",
+		Resource: ResourceRef{Kind: "Deployment", Namespace: "default", Name: "large"},
+		Expect:   ResultDeny,
+		Validations: []ValidationAssertion{
+			{Index: ptr.To(0), Message: "replicas must be at most 5", Reason: metav1.StatusReasonForbidden, Code: 403},
+		},
+		AuditAnnotations: map[string]string{"replicas": "10"},
+	}, suite.Tests[1])
+}
+
+func TestLoadInvalid(t *testing.T) {
+	testCases := []struct {
+		name          string
+		path          string
+		expectedError string
+	}{
+		{
+			name:          "未知の結果はエラーにする",
+			path:          "testdata/invalid/unknown_result.yaml",
+			expectedError: `tests[0].expect: unsupported value "allow"`,
+		},
+		{
+			name:          "未知のフィールドはエラーにする",
+			path:          "testdata/invalid/unknown_field.yaml",
+			expectedError: `unknown field "expected"`,
+		},
+		{
+			name:          "indexとexpressionの両方を指定したアサーションはエラーにする",
+			path:          "testdata/invalid/ambiguous_assertion.yaml",
+			expectedError: "tests[0].validations[0]: exactly one of index or expression is required",
+		},
+		{
+			name:          "未知のパッチの種類はエラーにする",
+			path:          "testdata/invalid/unknown_patch_type.yaml",
+			expectedError: `tests[0].patches[0].type: unsupported value "json-merge"`,
+		},
+		{
+			name:          "テストスイートでないファイルはエラーにする",
+			path:          "testdata/policies/policy.yaml",
+			expectedError: "apiVersion and kind must be vaptest/v1alpha1 and TestSuite",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load(tc.path)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedError)
+			assert.Contains(t, err.Error(), tc.path)
+		})
+	}
+}
+
+func TestLoadPatches(t *testing.T) {
+	suite, err := Load("testdata/variants/suite.yaml")
+	require.NoError(t, err)
+
+	assert.Equal(t, []Patch{{Type: PatchTypeJSON, Patch: []byte(`[{"op":"replace","path":"/spec/replicas","value":6}]`)}}, suite.Tests[1].Patches)
+	// 種類を省略したパッチはkubectl patchと同様にstrategic merge patchとして扱う
+	assert.Equal(t, PatchTypeStrategic, suite.Tests[3].Patches[0].Type)
+}
+
+func TestLoadFromPaths(t *testing.T) {
+	// ディレクトリ内のテストスイート以外のマニフェストは読み込まない
+	suites, err := LoadFromPaths([]string{"testdata/policies"})
+	require.NoError(t, err)
+	require.Len(t, suites, 1)
+	assert.Equal(t, "testdata/policies/suite.yaml", suites[0].Path)
+}
+
+func TestSuiteWrite(t *testing.T) {
+	suite, err := Load("testdata/policies/suite.yaml")
+	require.NoError(t, err)
+	suite.Tests = suite.Tests[:1]
+	suite.Tests[0].Patches = []Patch{{Type: PatchTypeMerge, Patch: []byte(`{"metadata":{"labels":{"version":"1.0"}}}`)}}
+
+	var b strings.Builder
+	require.NoError(t, suite.Write(&b))
+	// パスはスイートファイルのディレクトリからの相対パスに戻す
+	assert.Equal(t, `apiVersion: vaptest/v1alpha1
+kind: TestSuite
+name: deployment policies
+policies:
+  - policy.yaml
+bindings:
+  - binding.yaml
+params:
+  - params.yaml
+resources:
+  - resources.yaml
+tests:
+  - name: replicas within the limit are allowed
+    policy: replica-limit
+    resource:
+      kind: Deployment
+      name: small
+      namespace: default
+    expect: pass
+    patches:
+      - type: merge
+        patch:
+          metadata:
+            labels:
+              version: "1.0"
+`, b.String())
+
+	path := filepath.Join(t.TempDir(), "generated", "suite.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	suite.Path = path
+	b.Reset()
+	require.NoError(t, suite.Write(&b))
+	require.NoError(t, os.WriteFile(path, []byte(b.String()), 0o644))
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, suite.Tests, loaded.Tests)
+	policies, err := filepath.Abs(loaded.Policies[0])
+	require.NoError(t, err)
+	want, err := filepath.Abs(filepath.Join("testdata", "policies", "policy.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, want, policies, "別のディレクトリに書き出しても同じファイルを指す")
+}
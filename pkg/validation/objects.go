@@ -0,0 +1,43 @@
+package validation
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// InvalidObjectError reports all validation errors of a policy or binding together with its source file.
+type InvalidObjectError struct {
+	Path   string
+	Kind   string
+	Name   string
+	Errors field.ErrorList
+}
+
+func (e *InvalidObjectError) Error() string {
+	return fmt.Sprintf("%s %q in file %s is invalid: %v", e.Kind, e.Name, e.Path, e.Errors.ToAggregate())
+}
+
+// ValidatePolicyObjects validates all policies and bindings the same way as the apiserver and returns
+// an InvalidObjectError for each invalid object. Each object is defaulted with the defaulter before
+// validation, on a copy. source returns the file an object was loaded from.
+func ValidatePolicyObjects(policies []*v1.ValidatingAdmissionPolicy, bindings []*v1.ValidatingAdmissionPolicyBinding, defaulter runtime.ObjectDefaulter, source func(runtime.Object) string) []error {
+	var errs []error
+	for _, policy := range policies {
+		defaulted := policy.DeepCopy()
+		defaulter.Default(defaulted)
+		if fieldErrs := ValidateValidatingAdmissionPolicy(defaulted); len(fieldErrs) > 0 {
+			errs = append(errs, &InvalidObjectError{Path: source(policy), Kind: "ValidatingAdmissionPolicy", Name: policy.Name, Errors: fieldErrs})
+		}
+	}
+	for _, binding := range bindings {
+		defaulted := binding.DeepCopy()
+		defaulter.Default(defaulted)
+		if fieldErrs := ValidateValidatingAdmissionPolicyBinding(defaulted); len(fieldErrs) > 0 {
+			errs = append(errs, &InvalidObjectError{Path: source(binding), Kind: "ValidatingAdmissionPolicyBinding", Name: binding.Name, Errors: fieldErrs})
+		}
+	}
+	return errs
+}
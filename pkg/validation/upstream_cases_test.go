@@ -0,0 +1,1441 @@
+package validation
+
+// The test cases in this file are copied from TestValidateValidatingAdmissionPolicy and
+// TestValidateValidatingAdmissionPolicyBinding of k8s.io/kubernetes/pkg/apis/admissionregistration/validation
+// (v1.31.1), with the internal API types replaced by their v1 equivalents, so that the ported rules
+// fail the same objects with the same errors as upstream.
+
+import (
+	v1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// upstreamCase is a test case of upstream: validating config fails with an error containing expectedError,
+// or passes when expectedError is empty.
+type upstreamCase[T any] struct {
+	name          string
+	config        T
+	expectedError string
+}
+
+var upstreamPolicyCases = []upstreamCase[*v1.ValidatingAdmissionPolicy]{{
+	name: "metadata.name validation",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "!!!!",
+		},
+	},
+	expectedError: `metadata.name: Invalid value: "!!!!":`,
+}, {
+	name: "failure policy validation",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			FailurePolicy: func() *v1.FailurePolicyType {
+				r := v1.FailurePolicyType("other")
+				return &r
+			}(),
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+		},
+	},
+	expectedError: `spec.failurePolicy: Unsupported value: "other": supported values: "Fail", "Ignore"`,
+}, {
+	name: "failure policy validation",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			FailurePolicy: func() *v1.FailurePolicyType {
+				r := v1.FailurePolicyType("other")
+				return &r
+			}(),
+		},
+	},
+	expectedError: `spec.failurePolicy: Unsupported value: "other": supported values: "Fail", "Ignore"`,
+}, {
+	name: "API version is required in ParamKind",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			ParamKind: &v1.ParamKind{
+				Kind:       "Example",
+				APIVersion: "test.example.com",
+			},
+		},
+	},
+	expectedError: `spec.paramKind.apiVersion: Invalid value: "test.example.com"`,
+}, {
+	name: "API kind is required in ParamKind",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			ParamKind: &v1.ParamKind{
+				APIVersion: "test.example.com/v1",
+			},
+		},
+	},
+	expectedError: `spec.paramKind.kind: Required value`,
+}, {
+	name: "API version format in ParamKind",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			ParamKind: &v1.ParamKind{
+				Kind:       "Example",
+				APIVersion: "test.example.com/!!!",
+			},
+		},
+	},
+	expectedError: `pec.paramKind.apiVersion: Invalid value: "!!!":`,
+}, {
+	name: "API group format in ParamKind",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			ParamKind: &v1.ParamKind{
+				APIVersion: "!!!/v1",
+				Kind:       "ReplicaLimit",
+			},
+		},
+	},
+	expectedError: `pec.paramKind.apiVersion: Invalid value: "!!!":`,
+}, {
+	name: "Validations is required",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{},
+	},
+
+	expectedError: `spec.validations: Required value: validations or auditAnnotations must contain at least one item`,
+}, {
+	name: "Invalid Validations Reason",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+				Reason: func() *metav1.StatusReason {
+					r := metav1.StatusReason("other")
+					return &r
+				}(),
+			}},
+		},
+	},
+
+	expectedError: `spec.validations[0].reason: Unsupported value: "other"`,
+}, {
+	name: "MatchConstraints is required",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+		},
+	},
+
+	expectedError: `spec.matchConstraints: Required value`,
+}, {
+	name: "matchConstraints.resourceRules is required",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			MatchConstraints: &v1.MatchResources{},
+		},
+	},
+	expectedError: `spec.matchConstraints.resourceRules: Required value`,
+}, {
+	name: "matchConstraints.resourceRules has at least one explicit rule",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Rule: v1.Rule{},
+					},
+					ResourceNames: []string{"/./."},
+				}},
+			},
+		},
+	},
+	expectedError: `spec.matchConstraints.resourceRules[0].apiVersions: Required value`,
+}, {
+	name: "expression is required",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{}},
+		},
+	},
+
+	expectedError: `spec.validations[0].expression: Required value: expression is not specified`,
+}, {
+	name: "matchResources resourceNames check",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					ResourceNames: []string{"/./."},
+				}},
+			},
+		},
+	},
+	expectedError: `spec.matchConstraints.resourceRules[0].resourceNames[0]: Invalid value: "/./."`,
+}, {
+	name: "matchResources resourceNames cannot duplicate",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					ResourceNames: []string{"test", "test"},
+				}},
+			},
+		},
+	},
+	expectedError: `spec.matchConstraints.resourceRules[0].resourceNames[1]: Duplicate value: "test"`,
+}, {
+	name: "matchResources validation: matchPolicy",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				MatchPolicy: func() *v1.MatchPolicyType {
+					r := v1.MatchPolicyType("other")
+					return &r
+				}(),
+			},
+		},
+	},
+	expectedError: `spec.matchConstraints.matchPolicy: Unsupported value: "other": supported values: "Equivalent", "Exact"`,
+}, {
+	name: "Operations must not be empty or nil",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			FailurePolicy: func() *v1.FailurePolicyType {
+				r := v1.FailurePolicyType("Fail")
+				return &r
+			}(),
+			MatchConstraints: &v1.MatchResources{
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"a": "b"},
+				},
+				ObjectSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"a": "b"},
+				},
+				MatchPolicy: func() *v1.MatchPolicyType {
+					r := v1.MatchPolicyType("Exact")
+					return &r
+				}(),
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}, {
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: nil,
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}},
+				ExcludeResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}, {
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: nil,
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `spec.matchConstraints.resourceRules[0].operations: Required value, spec.matchConstraints.resourceRules[1].operations: Required value, spec.matchConstraints.excludeResourceRules[0].operations: Required value, spec.matchConstraints.excludeResourceRules[1].operations: Required value`,
+}, {
+	name: "\"\" is NOT a valid operation",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE", ""},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `Unsupported value: ""`,
+}, {
+	name: "operation must be either create/update/delete/connect",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"PATCH"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `Unsupported value: "PATCH"`,
+}, {
+	name: "wildcard operation cannot be mixed with other strings",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE", "*"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `if '*' is present, must not specify other operations`,
+}, {
+	name: `resource "*" can co-exist with resources that have subresources`,
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			FailurePolicy: func() *v1.FailurePolicyType {
+				r := v1.FailurePolicyType("Fail")
+				return &r
+			}(),
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				MatchPolicy: func() *v1.MatchPolicyType {
+					r := v1.MatchPolicyType("Exact")
+					return &r
+				}(),
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"*", "a/b", "a/*", "*/b"},
+						},
+					},
+				}},
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"a": "b"},
+				},
+				ObjectSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"a": "b"},
+				},
+			},
+		},
+	},
+}, {
+	name: `resource "*" cannot mix with resources that don't have subresources`,
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"*", "a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `if '*' is present, must not specify other resources without subresources`,
+}, {
+	name: "resource a/* cannot mix with a/x",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a/*", "a/x"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `spec.matchConstraints.resourceRules[0].resources[1]: Invalid value: "a/x": if 'a/*' is present, must not specify a/x`,
+}, {
+	name: "resource a/* can mix with a",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			FailurePolicy: func() *v1.FailurePolicyType {
+				r := v1.FailurePolicyType("Fail")
+				return &r
+			}(),
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				MatchPolicy: func() *v1.MatchPolicyType {
+					r := v1.MatchPolicyType("Exact")
+					return &r
+				}(),
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"a": "b"},
+				},
+				ObjectSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"a": "b"},
+				},
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a/*", "a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+}, {
+	name: "resource */a cannot mix with x/a",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"*/a", "x/a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `spec.matchConstraints.resourceRules[0].resources[1]: Invalid value: "x/a": if '*/a' is present, must not specify x/a`,
+}, {
+	name: "resource */* cannot mix with other resources",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"*/*", "a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `spec.matchConstraints.resourceRules[0].resources: Invalid value: []string{"*/*", "a"}: if '*/*' is present, must not specify other resources`,
+}, {
+	name: "invalid expression",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression: "object.x in [1, 2, ",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"*/*"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `spec.validations[0].expression: Invalid value: "object.x in [1, 2, ": compilation failed: ERROR: <input>:1:20: Syntax error: missing ']' at '<EOF>`,
+}, {
+	name: "invalid messageExpression",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression:        "true",
+				MessageExpression: "object.x in [1, 2, ",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"*/*"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `spec.validations[0].messageExpression: Invalid value: "object.x in [1, 2, ": compilation failed: ERROR: <input>:1:20: Syntax error: missing ']' at '<EOF>`,
+}, {
+	name: "messageExpression of wrong type",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{
+				Expression:        "true",
+				MessageExpression: "0 == 0",
+			}},
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"*/*"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `spec.validations[0].messageExpression: Invalid value: "0 == 0": must evaluate to string`,
+}, {
+	name: "invalid auditAnnotations key due to key name",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			AuditAnnotations: []v1.AuditAnnotation{{
+				Key:             "@",
+				ValueExpression: "value",
+			}},
+		},
+	},
+	expectedError: `spec.auditAnnotations[0].key: Invalid value: "config/@": name part must consist of alphanumeric characters`,
+}, {
+	name: "auditAnnotations keys must be unique",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			AuditAnnotations: []v1.AuditAnnotation{{
+				Key:             "a",
+				ValueExpression: "'1'",
+			}, {
+				Key:             "a",
+				ValueExpression: "'2'",
+			}},
+		},
+	},
+	expectedError: `spec.auditAnnotations[1].key: Duplicate value: "a"`,
+}, {
+	name: "invalid auditAnnotations key due to metadata.name",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "nope!",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			AuditAnnotations: []v1.AuditAnnotation{{
+				Key:             "key",
+				ValueExpression: "'value'",
+			}},
+		},
+	},
+	expectedError: `spec.auditAnnotations[0].key: Invalid value: "nope!/key": prefix part a lowercase RFC 1123 subdomain`,
+}, {
+	name: "invalid auditAnnotations key due to length",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "this-is-a-long-name-for-a-admission-policy-xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			AuditAnnotations: []v1.AuditAnnotation{{
+				Key:             "this-is-a-long-name-for-an-audit-annotation-key-xxxxxxxxxxxxxxxxxxxxxxxxxx",
+				ValueExpression: "'value'",
+			}},
+		},
+	},
+	expectedError: `spec.auditAnnotations[0].key: Invalid value`,
+}, {
+	name: "invalid auditAnnotations valueExpression type",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			AuditAnnotations: []v1.AuditAnnotation{{
+				Key:             "something",
+				ValueExpression: "true",
+			}},
+		},
+	},
+	expectedError: `spec.auditAnnotations[0].valueExpression: Invalid value: "true": must evaluate to one of [string null_type]`,
+}, {
+	name: "invalid auditAnnotations valueExpression",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			AuditAnnotations: []v1.AuditAnnotation{{
+				Key:             "something",
+				ValueExpression: "object.x in [1, 2, ",
+			}},
+		},
+	},
+	expectedError: `spec.auditAnnotations[0].valueExpression: Invalid value: "object.x in [1, 2, ": compilation failed: ERROR: <input>:1:19: Syntax error: missing ']' at '<EOF>`,
+}, {
+	name: "single match condition must have a name",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			MatchConditions: []v1.MatchCondition{{
+				Expression: "true",
+			}},
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+		},
+	},
+	expectedError: `spec.matchConditions[0].name: Required value`,
+}, {
+	name: "match condition with parameters allowed",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			ParamKind: &v1.ParamKind{
+				Kind:       "Foo",
+				APIVersion: "foobar/v1alpha1",
+			},
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"*"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}},
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"a": "b"},
+				},
+				ObjectSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"a": "b"},
+				},
+				MatchPolicy: func() *v1.MatchPolicyType {
+					r := v1.MatchPolicyType("Exact")
+					return &r
+				}(),
+			},
+			FailurePolicy: func() *v1.FailurePolicyType {
+				r := v1.FailurePolicyType("Fail")
+				return &r
+			}(),
+			MatchConditions: []v1.MatchCondition{{
+				Name:       "hasParams",
+				Expression: `params.foo == "okay"`,
+			}},
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+		},
+	},
+	expectedError: "",
+}, {
+	name: "match condition with parameters not allowed if no param kind",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"*"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}},
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"a": "b"},
+				},
+				ObjectSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"a": "b"},
+				},
+				MatchPolicy: func() *v1.MatchPolicyType {
+					r := v1.MatchPolicyType("Exact")
+					return &r
+				}(),
+			},
+			FailurePolicy: func() *v1.FailurePolicyType {
+				r := v1.FailurePolicyType("Fail")
+				return &r
+			}(),
+			MatchConditions: []v1.MatchCondition{{
+				Name:       "hasParams",
+				Expression: `params.foo == "okay"`,
+			}},
+			Validations: []v1.Validation{{
+				Expression: "object.x < 100",
+			}},
+		},
+	},
+	expectedError: `undeclared reference to 'params'`,
+}, {
+	name: "variable composition empty name",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Variables: []v1.Variable{
+				{
+					Name:       "    ",
+					Expression: "true",
+				},
+			},
+			Validations: []v1.Validation{
+				{
+					Expression: "true",
+				},
+			},
+		},
+	},
+	expectedError: `spec.variables[0].name: Required value: name is not specified`,
+}, {
+	name: "variable composition name is not a valid identifier",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Variables: []v1.Variable{
+				{
+					Name:       "4ever",
+					Expression: "true",
+				},
+			},
+			Validations: []v1.Validation{
+				{
+					Expression: "true",
+				},
+			},
+		},
+	},
+	expectedError: `spec.variables[0].name: Invalid value: "4ever": name is not a valid CEL identifier`,
+}, {
+	name: "variable composition cannot compile",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Variables: []v1.Variable{
+				{
+					Name:       "foo",
+					Expression: "114 + '514'", // compile error: type confusion
+				},
+			},
+			Validations: []v1.Validation{
+				{
+					Expression: "true",
+				},
+			},
+		},
+	},
+	expectedError: `spec.variables[0].expression: Invalid value: "114 + '514'": compilation failed: ERROR: <input>:1:5: found no matching overload for '_+_' applied to '(int, string)`,
+}, {
+	name: "validation referred to non-existing variable",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Variables: []v1.Variable{
+				{
+					Name:       "foo",
+					Expression: "1 + 1",
+				},
+				{
+					Name:       "bar",
+					Expression: "variables.foo + 1",
+				},
+			},
+			Validations: []v1.Validation{
+				{
+					Expression: "variables.foo > 1", // correct
+				},
+				{
+					Expression: "variables.replicas == 2", // replicas undefined
+				},
+			},
+		},
+	},
+	expectedError: `spec.validations[1].expression: Invalid value: "variables.replicas == 2": compilation failed: ERROR: <input>:1:10: undefined field 'replicas'`,
+}, {
+	name: "variables wrong order",
+	config: &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Variables: []v1.Variable{
+				{
+					Name:       "correct",
+					Expression: "object",
+				},
+				{
+					Name:       "bar", // should go below foo
+					Expression: "variables.foo + 1",
+				},
+				{
+					Name:       "foo",
+					Expression: "1 + 1",
+				},
+			},
+			Validations: []v1.Validation{
+				{
+					Expression: "variables.foo > 1", // correct
+				},
+			},
+		},
+	},
+	expectedError: `spec.variables[1].expression: Invalid value: "variables.foo + 1": compilation failed: ERROR: <input>:1:10: undefined field 'foo'`,
+},
+}
+
+var upstreamBindingCases = []upstreamCase[*v1.ValidatingAdmissionPolicyBinding]{{
+	name: "metadata.name validation",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "!!!!",
+		},
+	},
+	expectedError: `metadata.name: Invalid value: "!!!!":`,
+}, {
+	name: "PolicyName is required",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{},
+	},
+	expectedError: `spec.policyName: Required value`,
+}, {
+	name: "matchResources validation: matchPolicy",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: "xyzlimit-scale.example.com",
+			ParamRef: &v1.ParamRef{
+				Name:                    "xyzlimit-scale-setting.example.com",
+				ParameterNotFoundAction: ptr.To(v1.DenyAction),
+			},
+			MatchResources: &v1.MatchResources{
+				MatchPolicy: func() *v1.MatchPolicyType {
+					r := v1.MatchPolicyType("other")
+					return &r
+				}(),
+			},
+		},
+	},
+	expectedError: `spec.matchResouces.matchPolicy: Unsupported value: "other": supported values: "Equivalent", "Exact"`,
+}, {
+	name: "Operations must not be empty or nil",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: "xyzlimit-scale.example.com",
+			ParamRef: &v1.ParamRef{
+				Name:                    "xyzlimit-scale-setting.example.com",
+				ParameterNotFoundAction: ptr.To(v1.DenyAction),
+			},
+			MatchResources: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}, {
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: nil,
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}},
+				ExcludeResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}, {
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: nil,
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `spec.matchResouces.resourceRules[0].operations: Required value, spec.matchResouces.resourceRules[1].operations: Required value, spec.matchResouces.excludeResourceRules[0].operations: Required value, spec.matchResouces.excludeResourceRules[1].operations: Required value`,
+}, {
+	name: "\"\" is NOT a valid operation",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: "xyzlimit-scale.example.com",
+			ParamRef: &v1.ParamRef{
+				Name:                    "xyzlimit-scale-setting.example.com",
+				ParameterNotFoundAction: ptr.To(v1.DenyAction),
+			}, MatchResources: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE", ""},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `Unsupported value: ""`,
+}, {
+	name: "operation must be either create/update/delete/connect",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: "xyzlimit-scale.example.com",
+			ParamRef: &v1.ParamRef{
+				Name:                    "xyzlimit-scale-setting.example.com",
+				ParameterNotFoundAction: ptr.To(v1.DenyAction),
+			}, MatchResources: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"PATCH"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `Unsupported value: "PATCH"`,
+}, {
+	name: "wildcard operation cannot be mixed with other strings",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: "xyzlimit-scale.example.com",
+			ParamRef: &v1.ParamRef{
+				Name:                    "xyzlimit-scale-setting.example.com",
+				ParameterNotFoundAction: ptr.To(v1.DenyAction),
+			},
+			ValidationActions: []v1.ValidationAction{v1.Deny},
+			MatchResources: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE", "*"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `if '*' is present, must not specify other operations`,
+}, {
+	name: `resource "*" can co-exist with resources that have subresources`,
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: "xyzlimit-scale.example.com",
+			ParamRef: &v1.ParamRef{
+				Name:                    "xyzlimit-scale-setting.example.com",
+				ParameterNotFoundAction: ptr.To(v1.DenyAction),
+			},
+			ValidationActions: []v1.ValidationAction{v1.Deny},
+			MatchResources: &v1.MatchResources{
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"a": "b"},
+				},
+				ObjectSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"a": "b"},
+				},
+				MatchPolicy: func() *v1.MatchPolicyType {
+					r := v1.MatchPolicyType("Exact")
+					return &r
+				}(),
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"*", "a/b", "a/*", "*/b"},
+						},
+					},
+				}},
+			},
+		},
+	},
+}, {
+	name: `resource "*" cannot mix with resources that don't have subresources`,
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: "xyzlimit-scale.example.com",
+			ParamRef: &v1.ParamRef{
+				Name:                    "xyzlimit-scale-setting.example.com",
+				ParameterNotFoundAction: ptr.To(v1.DenyAction),
+			},
+			ValidationActions: []v1.ValidationAction{v1.Deny},
+			MatchResources: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"*", "a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `if '*' is present, must not specify other resources without subresources`,
+}, {
+	name: "resource a/* cannot mix with a/x",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: "xyzlimit-scale.example.com",
+			ParamRef: &v1.ParamRef{
+				Name:                    "xyzlimit-scale-setting.example.com",
+				ParameterNotFoundAction: ptr.To(v1.DenyAction),
+			},
+			ValidationActions: []v1.ValidationAction{v1.Deny},
+			MatchResources: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a/*", "a/x"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `spec.matchResouces.resourceRules[0].resources[1]: Invalid value: "a/x": if 'a/*' is present, must not specify a/x`,
+}, {
+	name: "resource a/* can mix with a",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: "xyzlimit-scale.example.com",
+			ParamRef: &v1.ParamRef{
+				Name:                    "xyzlimit-scale-setting.example.com",
+				ParameterNotFoundAction: ptr.To(v1.DenyAction),
+			},
+			ValidationActions: []v1.ValidationAction{v1.Deny},
+			MatchResources: &v1.MatchResources{
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"a": "b"},
+				},
+				ObjectSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"a": "b"},
+				},
+				MatchPolicy: func() *v1.MatchPolicyType {
+					r := v1.MatchPolicyType("Exact")
+					return &r
+				}(),
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"a/*", "a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+}, {
+	name: "resource */a cannot mix with x/a",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: "xyzlimit-scale.example.com",
+			ParamRef: &v1.ParamRef{
+				Name:                    "xyzlimit-scale-setting.example.com",
+				ParameterNotFoundAction: ptr.To(v1.DenyAction),
+			},
+			ValidationActions: []v1.ValidationAction{v1.Deny},
+			MatchResources: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"*/a", "x/a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `spec.matchResouces.resourceRules[0].resources[1]: Invalid value: "x/a": if '*/a' is present, must not specify x/a`,
+}, {
+	name: "resource */* cannot mix with other resources",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: "xyzlimit-scale.example.com",
+			ParamRef: &v1.ParamRef{
+				Name:                    "xyzlimit-scale-setting.example.com",
+				ParameterNotFoundAction: ptr.To(v1.DenyAction),
+			},
+			ValidationActions: []v1.ValidationAction{v1.Deny},
+			MatchResources: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{"CREATE"},
+						Rule: v1.Rule{
+							APIGroups:   []string{"a"},
+							APIVersions: []string{"a"},
+							Resources:   []string{"*/*", "a"},
+						},
+					},
+				}},
+			},
+		},
+	},
+	expectedError: `spec.matchResouces.resourceRules[0].resources: Invalid value: []string{"*/*", "a"}: if '*/*' is present, must not specify other resources`,
+}, {
+	name: "validationActions must be unique",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: "xyzlimit-scale.example.com",
+			ParamRef: &v1.ParamRef{
+				Name:                    "xyzlimit-scale-setting.example.com",
+				ParameterNotFoundAction: ptr.To(v1.DenyAction),
+			},
+			ValidationActions: []v1.ValidationAction{v1.Deny, v1.Deny},
+		},
+	},
+	expectedError: `spec.validationActions[1]: Duplicate value: "Deny"`,
+}, {
+	name: "validationActions must contain supported values",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: "xyzlimit-scale.example.com",
+			ParamRef: &v1.ParamRef{
+				Name:                    "xyzlimit-scale-setting.example.com",
+				ParameterNotFoundAction: ptr.To(v1.DenyAction),
+			},
+			ValidationActions: []v1.ValidationAction{v1.ValidationAction("illegal")},
+		},
+	},
+	expectedError: `Unsupported value: "illegal": supported values: "Audit", "Deny", "Warn"`,
+}, {
+	name: "paramRef selector must not be set when name is set",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName:        "xyzlimit-scale.example.com",
+			ValidationActions: []v1.ValidationAction{v1.Deny},
+			ParamRef: &v1.ParamRef{
+				Name: "xyzlimit-scale-setting.example.com",
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"label": "value",
+					},
+				},
+				ParameterNotFoundAction: ptr.To(v1.DenyAction),
+			},
+		},
+	},
+	expectedError: `spec.paramRef.name: Forbidden: name and selector are mutually exclusive, spec.paramRef.selector: Forbidden: name and selector are mutually exclusive`,
+}, {
+	name: "paramRef parameterNotFoundAction must be set",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName:        "xyzlimit-scale.example.com",
+			ValidationActions: []v1.ValidationAction{v1.Deny},
+			ParamRef: &v1.ParamRef{
+				Name: "xyzlimit-scale-setting.example.com",
+			},
+		},
+	},
+	expectedError: "spec.paramRef.parameterNotFoundAction: Required value",
+}, {
+	name: "paramRef parameterNotFoundAction must be an valid value",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName:        "xyzlimit-scale.example.com",
+			ValidationActions: []v1.ValidationAction{v1.Deny},
+			ParamRef: &v1.ParamRef{
+				Name:                    "xyzlimit-scale-setting.example.com",
+				ParameterNotFoundAction: ptr.To(v1.ParameterNotFoundActionType("invalid")),
+			},
+		},
+	},
+	expectedError: `spec.paramRef.parameterNotFoundAction: Unsupported value: "invalid": supported values: "Deny", "Allow"`,
+}, {
+	name: "paramRef one of name or selector",
+	config: &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+		},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName:        "xyzlimit-scale.example.com",
+			ValidationActions: []v1.ValidationAction{v1.Deny},
+			ParamRef: &v1.ParamRef{
+				ParameterNotFoundAction: ptr.To(v1.DenyAction),
+			},
+		},
+	},
+	expectedError: `one of name or selector must be specified`,
+}}
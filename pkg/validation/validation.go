@@ -0,0 +1,568 @@
+// Package validation validates ValidatingAdmissionPolicy and ValidatingAdmissionPolicyBinding objects
+// with the rules the apiserver applies when they are created.
+//
+// The rules are ported from k8s.io/kubernetes/pkg/apis/admissionregistration/validation (as of v1.31),
+// which is not importable as a library, and pinned to it by its test cases. Objects are expected to be
+// defaulted before validation, since the apiserver validates them after defaulting. CEL expressions are
+// type-checked with the compilers of the admission plugin, with the StrictCostEnforcementForVAP feature
+// gate disabled, its default. Only v1 objects can be validated; see ValidatePolicyObjects.
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/admissionregistration/v1"
+	apimachineryvalidation "k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/api/validation/path"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/sets"
+	utilvalidation "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	plugincel "k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/admission/plugin/policy/validating"
+	"k8s.io/apiserver/pkg/admission/plugin/webhook/matchconditions"
+	apiservercel "k8s.io/apiserver/pkg/cel"
+	"k8s.io/apiserver/pkg/cel/environment"
+)
+
+const (
+	maxMatchConditions  = 64
+	maxAuditAnnotations = 20
+	// maxAuditAnnotationValueExpressionLength is less than the limit of audit annotation values (10kb),
+	// since an expression concatenating strings often produces a longer value than the expression.
+	maxAuditAnnotationValueExpressionLength = 5 * 1024
+)
+
+var (
+	supportedFailurePolicies   = sets.New(string(v1.Ignore), string(v1.Fail))
+	supportedMatchPolicies     = sets.New(string(v1.Exact), string(v1.Equivalent))
+	supportedOperations        = sets.New(string(v1.OperationAll), string(v1.Create), string(v1.Update), string(v1.Delete), string(v1.Connect))
+	supportedScopes            = sets.New(string(v1.AllScopes), string(v1.ClusterScope), string(v1.NamespacedScope))
+	supportedValidationReasons = sets.New("Forbidden", "Invalid", "RequestEntityTooLarge")
+	supportedValidationActions = sets.New(string(v1.Deny), string(v1.Warn), string(v1.Audit))
+
+	celIdentifier = regexp.MustCompile(`^[_a-zA-Z][_a-zA-Z0-9]*$`)
+	// celReservedSymbols are the CEL reserved words, which cannot be used as variable names.
+	celReservedSymbols = sets.New(
+		"true", "false", "null", "in",
+		"as", "break", "const", "continue", "else",
+		"for", "function", "if", "import", "let",
+		"loop", "package", "namespace", "return",
+		"var", "void", "while",
+	)
+)
+
+// statelessCompiler compiles the expressions of policies without variables, and match conditions.
+var statelessCompiler = sync.OnceValue(func() plugincel.Compiler {
+	return plugincel.NewCompiler(environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion(), false))
+})
+
+// newCompiler returns a compiler for the expressions of a policy. Variables are compiled and stored by a
+// composited compiler, so that later expressions can refer to them.
+func newCompiler(allowComposition bool) plugincel.Compiler {
+	if !allowComposition {
+		return statelessCompiler()
+	}
+	compiler, err := plugincel.NewCompositedCompiler(environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion(), false))
+	if err != nil {
+		return statelessCompiler()
+	}
+	return compiler
+}
+
+// ValidateValidatingAdmissionPolicy validates a ValidatingAdmissionPolicy.
+func ValidateValidatingAdmissionPolicy(policy *v1.ValidatingAdmissionPolicy) field.ErrorList {
+	allErrors := apimachineryvalidation.ValidateObjectMeta(&policy.ObjectMeta, false, apimachineryvalidation.NameIsDNSSubdomain, field.NewPath("metadata"))
+	allErrors = append(allErrors, validateValidatingAdmissionPolicySpec(policy.Name, &policy.Spec, field.NewPath("spec"))...)
+	return allErrors
+}
+
+func validateValidatingAdmissionPolicySpec(policyName string, spec *v1.ValidatingAdmissionPolicySpec, fldPath *field.Path) field.ErrorList {
+	var allErrors field.ErrorList
+	var compiler plugincel.Compiler // the composited compiler is stateful, so one is created per policy
+	getCompiler := func() plugincel.Compiler {
+		if compiler == nil {
+			compiler = newCompiler(len(spec.Variables) > 0)
+		}
+		return compiler
+	}
+
+	if spec.FailurePolicy == nil {
+		allErrors = append(allErrors, field.Required(fldPath.Child("failurePolicy"), ""))
+	} else if !supportedFailurePolicies.Has(string(*spec.FailurePolicy)) {
+		allErrors = append(allErrors, field.NotSupported(fldPath.Child("failurePolicy"), *spec.FailurePolicy, sets.List(supportedFailurePolicies)))
+	}
+
+	if spec.ParamKind != nil {
+		allErrors = append(allErrors, validateParamKind(spec.ParamKind, fldPath.Child("paramKind"))...)
+	}
+
+	if spec.MatchConstraints == nil {
+		allErrors = append(allErrors, field.Required(fldPath.Child("matchConstraints"), ""))
+	} else {
+		allErrors = append(allErrors, validateMatchResources(spec.MatchConstraints, fldPath.Child("matchConstraints"))...)
+		// at least one resourceRule must be defined to provide type information
+		if len(spec.MatchConstraints.ResourceRules) == 0 {
+			allErrors = append(allErrors, field.Required(fldPath.Child("matchConstraints", "resourceRules"), ""))
+		}
+	}
+
+	hasParams := spec.ParamKind != nil
+	allErrors = append(allErrors, validateMatchConditions(spec.MatchConditions, hasParams, fldPath.Child("matchConditions"))...)
+
+	// Upstream leaves duplicate variable names to the list-map semantics of the API, reported here instead.
+	variableNames := sets.New[string]()
+	for i, variable := range spec.Variables {
+		varPath := fldPath.Child("variables").Index(i)
+		allErrors = append(allErrors, validateVariable(getCompiler(), &variable, hasParams, varPath)...)
+		if len(variable.Name) > 0 {
+			if variableNames.Has(variable.Name) {
+				allErrors = append(allErrors, field.Duplicate(varPath.Child("name"), variable.Name))
+			}
+			variableNames.Insert(variable.Name)
+		}
+	}
+
+	if len(spec.Validations) == 0 && len(spec.AuditAnnotations) == 0 {
+		allErrors = append(allErrors, field.Required(fldPath.Child("validations"), "validations or auditAnnotations must contain at least one item"))
+		allErrors = append(allErrors, field.Required(fldPath.Child("auditAnnotations"), "validations or auditAnnotations must contain at least one item"))
+		return allErrors
+	}
+	for i, validation := range spec.Validations {
+		allErrors = append(allErrors, validateValidation(getCompiler(), &validation, hasParams, fldPath.Child("validations").Index(i))...)
+	}
+
+	if len(spec.AuditAnnotations) > maxAuditAnnotations {
+		allErrors = append(allErrors, field.Invalid(fldPath.Child("auditAnnotations"), spec.AuditAnnotations, fmt.Sprintf("must not have more than %d auditAnnotations", maxAuditAnnotations)))
+	}
+	auditAnnotationKeys := sets.New[string]()
+	for i, auditAnnotation := range spec.AuditAnnotations {
+		annotationPath := fldPath.Child("auditAnnotations").Index(i)
+		allErrors = append(allErrors, validateAuditAnnotation(getCompiler(), policyName, &auditAnnotation, hasParams, annotationPath)...)
+		if auditAnnotationKeys.Has(auditAnnotation.Key) {
+			allErrors = append(allErrors, field.Duplicate(annotationPath.Child("key"), auditAnnotation.Key))
+		}
+		auditAnnotationKeys.Insert(auditAnnotation.Key)
+	}
+
+	return allErrors
+}
+
+func validateParamKind(paramKind *v1.ParamKind, fldPath *field.Path) field.ErrorList {
+	var allErrors field.ErrorList
+	if len(paramKind.APIVersion) == 0 {
+		allErrors = append(allErrors, field.Required(fldPath.Child("apiVersion"), ""))
+	} else if group, version, err := parseGroupVersion(paramKind.APIVersion); err != nil {
+		allErrors = append(allErrors, field.Invalid(fldPath.Child("apiVersion"), paramKind.APIVersion, err.Error()))
+	} else {
+		// this matches the APIService group and version field validation
+		if len(group) > 0 {
+			if errs := utilvalidation.IsDNS1123Subdomain(group); len(errs) > 0 {
+				allErrors = append(allErrors, field.Invalid(fldPath.Child("apiVersion"), group, strings.Join(errs, ",")))
+			}
+		}
+		if len(version) == 0 {
+			allErrors = append(allErrors, field.Invalid(fldPath.Child("apiVersion"), paramKind.APIVersion, "version must be specified"))
+		} else if errs := utilvalidation.IsDNS1035Label(version); len(errs) > 0 {
+			allErrors = append(allErrors, field.Invalid(fldPath.Child("apiVersion"), version, strings.Join(errs, ",")))
+		}
+	}
+	if len(paramKind.Kind) == 0 {
+		allErrors = append(allErrors, field.Required(fldPath.Child("kind"), ""))
+	} else if errs := utilvalidation.IsDNS1035Label(strings.ToLower(paramKind.Kind)); len(errs) > 0 {
+		allErrors = append(allErrors, field.Invalid(fldPath.Child("kind"), paramKind.Kind, "may have mixed case, but should otherwise match: "+strings.Join(errs, ",")))
+	}
+	return allErrors
+}
+
+// parseGroupVersion splits "group/version", or "version" for the core group. Unlike
+// schema.ParseGroupVersion, a single segment is always a version.
+func parseGroupVersion(gv string) (string, string, error) {
+	if len(gv) == 0 || gv == "/" {
+		return "", "", nil
+	}
+	switch strings.Count(gv, "/") {
+	case 0:
+		return "", gv, nil
+	case 1:
+		group, version, _ := strings.Cut(gv, "/")
+		return group, version, nil
+	default:
+		return "", "", fmt.Errorf("unexpected GroupVersion string: %v", gv)
+	}
+}
+
+func validateMatchResources(mr *v1.MatchResources, fldPath *field.Path) field.ErrorList {
+	var allErrors field.ErrorList
+
+	if mr.MatchPolicy == nil {
+		allErrors = append(allErrors, field.Required(fldPath.Child("matchPolicy"), ""))
+	} else if !supportedMatchPolicies.Has(string(*mr.MatchPolicy)) {
+		allErrors = append(allErrors, field.NotSupported(fldPath.Child("matchPolicy"), *mr.MatchPolicy, sets.List(supportedMatchPolicies)))
+	}
+
+	selectorOpts := metav1validation.LabelSelectorValidationOptions{}
+	if mr.NamespaceSelector == nil {
+		allErrors = append(allErrors, field.Required(fldPath.Child("namespaceSelector"), ""))
+	} else {
+		allErrors = append(allErrors, metav1validation.ValidateLabelSelector(mr.NamespaceSelector, selectorOpts, fldPath.Child("namespaceSelector"))...)
+	}
+	if mr.ObjectSelector == nil {
+		allErrors = append(allErrors, field.Required(fldPath.Child("objectSelector"), ""))
+	} else {
+		allErrors = append(allErrors, metav1validation.ValidateLabelSelector(mr.ObjectSelector, selectorOpts, fldPath.Child("objectSelector"))...)
+	}
+
+	for i, rule := range mr.ResourceRules {
+		allErrors = append(allErrors, validateNamedRuleWithOperations(&rule, fldPath.Child("resourceRules").Index(i))...)
+	}
+	for i, rule := range mr.ExcludeResourceRules {
+		allErrors = append(allErrors, validateNamedRuleWithOperations(&rule, fldPath.Child("excludeResourceRules").Index(i))...)
+	}
+	return allErrors
+}
+
+func validateNamedRuleWithOperations(n *v1.NamedRuleWithOperations, fldPath *field.Path) field.ErrorList {
+	var allErrors field.ErrorList
+	resourceNames := sets.New[string]()
+	for i, rName := range n.ResourceNames {
+		for _, msg := range path.ValidatePathSegmentName(rName, false) {
+			allErrors = append(allErrors, field.Invalid(fldPath.Child("resourceNames").Index(i), rName, msg))
+		}
+		if resourceNames.Has(rName) {
+			allErrors = append(allErrors, field.Duplicate(fldPath.Child("resourceNames").Index(i), rName))
+		} else {
+			resourceNames.Insert(rName)
+		}
+	}
+	allErrors = append(allErrors, validateRuleWithOperations(&n.RuleWithOperations, fldPath)...)
+	return allErrors
+}
+
+func validateRuleWithOperations(rule *v1.RuleWithOperations, fldPath *field.Path) field.ErrorList {
+	var allErrors field.ErrorList
+	if len(rule.Operations) == 0 {
+		allErrors = append(allErrors, field.Required(fldPath.Child("operations"), ""))
+	}
+	if len(rule.Operations) > 1 && hasWildcardOperation(rule.Operations) {
+		allErrors = append(allErrors, field.Invalid(fldPath.Child("operations"), rule.Operations, "if '*' is present, must not specify other operations"))
+	}
+	for i, operation := range rule.Operations {
+		if !supportedOperations.Has(string(operation)) {
+			allErrors = append(allErrors, field.NotSupported(fldPath.Child("operations").Index(i), operation, sets.List(supportedOperations)))
+		}
+	}
+	allErrors = append(allErrors, validateRule(&rule.Rule, fldPath)...)
+	return allErrors
+}
+
+func validateRule(rule *v1.Rule, fldPath *field.Path) field.ErrorList {
+	var allErrors field.ErrorList
+	if len(rule.APIGroups) == 0 {
+		allErrors = append(allErrors, field.Required(fldPath.Child("apiGroups"), ""))
+	}
+	if len(rule.APIGroups) > 1 && hasWildcard(rule.APIGroups) {
+		allErrors = append(allErrors, field.Invalid(fldPath.Child("apiGroups"), rule.APIGroups, "if '*' is present, must not specify other API groups"))
+	}
+	// Note: group could be empty, e.g., the legacy "v1" API
+	if len(rule.APIVersions) == 0 {
+		allErrors = append(allErrors, field.Required(fldPath.Child("apiVersions"), ""))
+	}
+	if len(rule.APIVersions) > 1 && hasWildcard(rule.APIVersions) {
+		allErrors = append(allErrors, field.Invalid(fldPath.Child("apiVersions"), rule.APIVersions, "if '*' is present, must not specify other API versions"))
+	}
+	for i, version := range rule.APIVersions {
+		if version == "" {
+			allErrors = append(allErrors, field.Required(fldPath.Child("apiVersions").Index(i), ""))
+		}
+	}
+	allErrors = append(allErrors, validateResources(rule.Resources, fldPath.Child("resources"))...)
+	if rule.Scope != nil && !supportedScopes.Has(string(*rule.Scope)) {
+		allErrors = append(allErrors, field.NotSupported(fldPath.Child("scope"), *rule.Scope, sets.List(supportedScopes)))
+	}
+	return allErrors
+}
+
+func validateResources(resources []string, fldPath *field.Path) field.ErrorList {
+	var allErrors field.ErrorList
+	if len(resources) == 0 {
+		allErrors = append(allErrors, field.Required(fldPath, ""))
+	}
+
+	// x/*
+	resourcesWithWildcardSubresources := sets.New[string]()
+	// */x
+	subResourcesWithWildcardResource := sets.New[string]()
+	// */*
+	hasDoubleWildcard := false
+	// *
+	hasSingleWildcard := false
+	// x
+	hasResourceWithoutSubresource := false
+
+	for i, resSub := range resources {
+		if resSub == "" {
+			allErrors = append(allErrors, field.Required(fldPath.Index(i), ""))
+			continue
+		}
+		if resSub == "*/*" {
+			hasDoubleWildcard = true
+		}
+		if resSub == "*" {
+			hasSingleWildcard = true
+		}
+		parts := strings.SplitN(resSub, "/", 2)
+		if len(parts) == 1 {
+			// As upstream, only the last resource without a subresource counts.
+			hasResourceWithoutSubresource = resSub != "*"
+			continue
+		}
+		res, sub := parts[0], parts[1]
+		if resourcesWithWildcardSubresources.Has(res) {
+			allErrors = append(allErrors, field.Invalid(fldPath.Index(i), resSub, fmt.Sprintf("if '%s/*' is present, must not specify %s", res, resSub)))
+		}
+		if subResourcesWithWildcardResource.Has(sub) {
+			allErrors = append(allErrors, field.Invalid(fldPath.Index(i), resSub, fmt.Sprintf("if '*/%s' is present, must not specify %s", sub, resSub)))
+		}
+		if sub == "*" {
+			resourcesWithWildcardSubresources.Insert(res)
+		}
+		if res == "*" {
+			subResourcesWithWildcardResource.Insert(sub)
+		}
+	}
+	if len(resources) > 1 && hasDoubleWildcard {
+		allErrors = append(allErrors, field.Invalid(fldPath, resources, "if '*/*' is present, must not specify other resources"))
+	}
+	if hasSingleWildcard && hasResourceWithoutSubresource {
+		allErrors = append(allErrors, field.Invalid(fldPath, resources, "if '*' is present, must not specify other resources without subresources"))
+	}
+	return allErrors
+}
+
+func validateMatchConditions(conditions []v1.MatchCondition, hasParams bool, fldPath *field.Path) field.ErrorList {
+	var allErrors field.ErrorList
+	if len(conditions) > maxMatchConditions {
+		allErrors = append(allErrors, field.TooMany(fldPath, len(conditions), maxMatchConditions))
+	}
+	conditionNames := sets.New[string]()
+	for i, condition := range conditions {
+		if len(strings.TrimSpace(condition.Expression)) == 0 {
+			allErrors = append(allErrors, field.Required(fldPath.Index(i).Child("expression"), ""))
+		} else {
+			matchCondition := matchconditions.MatchCondition{Expression: strings.TrimSpace(condition.Expression)}
+			allErrors = append(allErrors, compileExpression(statelessCompiler(), &matchCondition, plugincel.OptionalVariableDeclarations{HasParams: hasParams, HasAuthorizer: true}, fldPath.Index(i).Child("expression"))...)
+		}
+		namePath := fldPath.Index(i).Child("name")
+		if len(condition.Name) == 0 {
+			allErrors = append(allErrors, field.Required(namePath, ""))
+		} else {
+			for _, msg := range utilvalidation.IsQualifiedName(condition.Name) {
+				allErrors = append(allErrors, field.Invalid(namePath, condition.Name, msg))
+			}
+			if conditionNames.Has(condition.Name) {
+				allErrors = append(allErrors, field.Duplicate(namePath, condition.Name))
+			}
+			conditionNames.Insert(condition.Name)
+		}
+	}
+	return allErrors
+}
+
+func validateVariable(compiler plugincel.Compiler, variable *v1.Variable, hasParams bool, fldPath *field.Path) field.ErrorList {
+	var allErrors field.ErrorList
+	if len(strings.TrimSpace(variable.Name)) == 0 {
+		allErrors = append(allErrors, field.Required(fldPath.Child("name"), "name is not specified"))
+	} else if !celIdentifier.MatchString(variable.Name) || celReservedSymbols.Has(variable.Name) {
+		allErrors = append(allErrors, field.Invalid(fldPath.Child("name"), variable.Name, "name is not a valid CEL identifier"))
+	}
+	if len(strings.TrimSpace(variable.Expression)) == 0 {
+		allErrors = append(allErrors, field.Required(fldPath.Child("expression"), "expression is not specified"))
+		return allErrors
+	}
+	composited, ok := compiler.(*plugincel.CompositedCompiler)
+	if !ok {
+		return append(allErrors, field.InternalError(fldPath, fmt.Errorf("variable composition is not allowed")))
+	}
+	accessor := &validating.Variable{Name: variable.Name, Expression: variable.Expression}
+	result := composited.CompileAndStoreVariable(accessor, plugincel.OptionalVariableDeclarations{HasParams: hasParams, HasAuthorizer: true}, environment.NewExpressions)
+	if result.Error != nil {
+		allErrors = append(allErrors, celFieldError(fldPath.Child("expression"), variable.Expression, result.Error))
+	}
+	return allErrors
+}
+
+func validateValidation(compiler plugincel.Compiler, v *v1.Validation, hasParams bool, fldPath *field.Path) field.ErrorList {
+	var allErrors field.ErrorList
+	trimmedMsg := strings.TrimSpace(v.Message)
+	trimmedMessageExpression := strings.TrimSpace(v.MessageExpression)
+
+	if len(strings.TrimSpace(v.Expression)) == 0 {
+		allErrors = append(allErrors, field.Required(fldPath.Child("expression"), "expression is not specified"))
+	} else {
+		allErrors = append(allErrors, compileExpression(compiler, &validating.ValidationCondition{Expression: v.Expression}, plugincel.OptionalVariableDeclarations{HasParams: hasParams, HasAuthorizer: true}, fldPath.Child("expression"))...)
+	}
+	if len(v.MessageExpression) > 0 && len(trimmedMessageExpression) == 0 {
+		allErrors = append(allErrors, field.Invalid(fldPath.Child("messageExpression"), v.MessageExpression, "must be non-empty if specified"))
+	} else if len(trimmedMessageExpression) > 0 {
+		// The untrimmed expression is compiled so that errors show the correct column.
+		allErrors = append(allErrors, compileExpression(compiler, &validating.MessageExpressionCondition{MessageExpression: v.MessageExpression}, plugincel.OptionalVariableDeclarations{HasParams: hasParams}, fldPath.Child("messageExpression"))...)
+	}
+	if len(v.Message) > 0 && len(trimmedMsg) == 0 {
+		allErrors = append(allErrors, field.Invalid(fldPath.Child("message"), v.Message, "message must be non-empty if specified"))
+	} else if strings.ContainsAny(trimmedMsg, "\n\r") {
+		allErrors = append(allErrors, field.Invalid(fldPath.Child("message"), v.Message, "message must not contain line breaks"))
+	}
+	if v.Reason != nil && !supportedValidationReasons.Has(string(*v.Reason)) {
+		allErrors = append(allErrors, field.NotSupported(fldPath.Child("reason"), *v.Reason, sets.List(supportedValidationReasons)))
+	}
+	return allErrors
+}
+
+func validateAuditAnnotation(compiler plugincel.Compiler, policyName string, annotation *v1.AuditAnnotation, hasParams bool, fldPath *field.Path) field.ErrorList {
+	var allErrors field.ErrorList
+	if len(policyName) == 0 {
+		allErrors = append(allErrors, field.Invalid(fldPath.Child("key"), annotation.Key, "requires metadata.name be non-empty"))
+	} else {
+		// The key is published as "<policy name>/<key>", which has to be a qualified name.
+		key := policyName + "/" + annotation.Key
+		for _, msg := range utilvalidation.IsQualifiedName(key) {
+			allErrors = append(allErrors, field.Invalid(fldPath.Child("key"), key, msg))
+		}
+	}
+
+	trimmedValueExpression := strings.TrimSpace(annotation.ValueExpression)
+	switch {
+	case len(trimmedValueExpression) == 0:
+		allErrors = append(allErrors, field.Required(fldPath.Child("valueExpression"), "valueExpression is not specified"))
+	case len(trimmedValueExpression) > maxAuditAnnotationValueExpressionLength:
+		allErrors = append(allErrors, field.Required(fldPath.Child("valueExpression"), fmt.Sprintf("must not exceed %d bytes in length", maxAuditAnnotationValueExpressionLength)))
+	default:
+		// The trimmed expression is compiled, but errors show the expression as written.
+		condition := &validating.AuditAnnotationCondition{ValueExpression: trimmedValueExpression}
+		result := compiler.CompileCELExpression(condition, plugincel.OptionalVariableDeclarations{HasParams: hasParams, HasAuthorizer: true}, environment.NewExpressions)
+		if result.Error != nil {
+			allErrors = append(allErrors, celFieldError(fldPath.Child("valueExpression"), annotation.ValueExpression, result.Error))
+		}
+	}
+	return allErrors
+}
+
+// compileExpression type-checks the expression with the variables the admission plugin declares for it.
+func compileExpression(compiler plugincel.Compiler, accessor plugincel.ExpressionAccessor, declarations plugincel.OptionalVariableDeclarations, fldPath *field.Path) field.ErrorList {
+	result := compiler.CompileCELExpression(accessor, declarations, environment.NewExpressions)
+	if result.Error == nil {
+		return nil
+	}
+	return field.ErrorList{celFieldError(fldPath, accessor.GetExpression(), result.Error)}
+}
+
+// celFieldError converts a compilation error to a field error.
+func celFieldError(fldPath *field.Path, expression string, err *apiservercel.Error) *field.Error {
+	switch err.Type {
+	case apiservercel.ErrorTypeRequired:
+		return field.Required(fldPath, err.Detail)
+	case apiservercel.ErrorTypeInvalid:
+		return field.Invalid(fldPath, expression, err.Detail)
+	default:
+		return field.InternalError(fldPath, err)
+	}
+}
+
+// ValidateValidatingAdmissionPolicyBinding validates a ValidatingAdmissionPolicyBinding.
+func ValidateValidatingAdmissionPolicyBinding(binding *v1.ValidatingAdmissionPolicyBinding) field.ErrorList {
+	allErrors := apimachineryvalidation.ValidateObjectMeta(&binding.ObjectMeta, false, apimachineryvalidation.NameIsDNSSubdomain, field.NewPath("metadata"))
+	allErrors = append(allErrors, validateValidatingAdmissionPolicyBindingSpec(&binding.Spec, field.NewPath("spec"))...)
+	return allErrors
+}
+
+func validateValidatingAdmissionPolicyBindingSpec(spec *v1.ValidatingAdmissionPolicyBindingSpec, fldPath *field.Path) field.ErrorList {
+	var allErrors field.ErrorList
+
+	if len(spec.PolicyName) == 0 {
+		allErrors = append(allErrors, field.Required(fldPath.Child("policyName"), ""))
+	} else {
+		for _, msg := range apimachineryvalidation.NameIsDNSSubdomain(spec.PolicyName, false) {
+			allErrors = append(allErrors, field.Invalid(fldPath.Child("policyName"), spec.PolicyName, msg))
+		}
+	}
+	if spec.ParamRef != nil {
+		allErrors = append(allErrors, validateParamRef(spec.ParamRef, fldPath.Child("paramRef"))...)
+	}
+	if spec.MatchResources != nil {
+		// "matchResouces" is misspelled in the field path by upstream as well.
+		allErrors = append(allErrors, validateMatchResources(spec.MatchResources, fldPath.Child("matchResouces"))...)
+	}
+	allErrors = append(allErrors, validateValidationActions(spec.ValidationActions, fldPath.Child("validationActions"))...)
+	return allErrors
+}
+
+func validateParamRef(pr *v1.ParamRef, fldPath *field.Path) field.ErrorList {
+	var allErrors field.ErrorList
+
+	if len(pr.Name) > 0 {
+		for _, msg := range path.ValidatePathSegmentName(pr.Name, false) {
+			allErrors = append(allErrors, field.Invalid(fldPath.Child("name"), pr.Name, msg))
+		}
+		if pr.Selector != nil {
+			allErrors = append(allErrors, field.Forbidden(fldPath.Child("name"), "name and selector are mutually exclusive"))
+		}
+	}
+	if pr.Selector != nil {
+		allErrors = append(allErrors, metav1validation.ValidateLabelSelector(pr.Selector, metav1validation.LabelSelectorValidationOptions{}, fldPath.Child("selector"))...)
+		if len(pr.Name) > 0 {
+			allErrors = append(allErrors, field.Forbidden(fldPath.Child("selector"), "name and selector are mutually exclusive"))
+		}
+	}
+	if len(pr.Name) == 0 && pr.Selector == nil {
+		allErrors = append(allErrors, field.Required(fldPath, "one of name or selector must be specified"))
+	}
+	if pr.ParameterNotFoundAction == nil || len(*pr.ParameterNotFoundAction) == 0 {
+		allErrors = append(allErrors, field.Required(fldPath.Child("parameterNotFoundAction"), ""))
+	} else if *pr.ParameterNotFoundAction != v1.DenyAction && *pr.ParameterNotFoundAction != v1.AllowAction {
+		allErrors = append(allErrors, field.NotSupported(fldPath.Child("parameterNotFoundAction"), pr.ParameterNotFoundAction, []string{string(v1.DenyAction), string(v1.AllowAction)}))
+	}
+	return allErrors
+}
+
+func validateValidationActions(actions []v1.ValidationAction, fldPath *field.Path) field.ErrorList {
+	var allErrors field.ErrorList
+	if len(actions) == 0 {
+		allErrors = append(allErrors, field.Required(fldPath, "at least one validation action is required"))
+	}
+	seen := sets.New[v1.ValidationAction]()
+	for i, action := range actions {
+		if !supportedValidationActions.Has(string(action)) {
+			allErrors = append(allErrors, field.NotSupported(fldPath.Index(i), action, sets.List(supportedValidationActions)))
+		}
+		if seen.Has(action) {
+			allErrors = append(allErrors, field.Duplicate(fldPath.Index(i), action))
+		}
+		seen.Insert(action)
+	}
+	if seen.Has(v1.Deny) && seen.Has(v1.Warn) {
+		allErrors = append(allErrors, field.Invalid(fldPath, actions, "must not contain both Deny and Warn (repeating the same validation failure information in the API response and headers serves no purpose)"))
+	}
+	return allErrors
+}
+
+func hasWildcard(slice []string) bool {
+	for _, s := range slice {
+		if s == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func hasWildcardOperation(operations []v1.OperationType) bool {
+	for _, o := range operations {
+		if o == v1.OperationAll {
+			return true
+		}
+	}
+	return false
+}
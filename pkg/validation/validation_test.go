@@ -0,0 +1,271 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func newPolicy(mutate func(*v1.ValidatingAdmissionPolicy)) *v1.ValidatingAdmissionPolicy {
+	policy := &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-policy"},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			FailurePolicy: ptr.To(v1.Fail),
+			MatchConstraints: &v1.MatchResources{
+				MatchPolicy:       ptr.To(v1.Equivalent),
+				NamespaceSelector: &metav1.LabelSelector{},
+				ObjectSelector:    &metav1.LabelSelector{},
+				ResourceRules: []v1.NamedRuleWithOperations{{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{v1.Create, v1.Update},
+						Rule: v1.Rule{
+							APIGroups:   []string{"apps"},
+							APIVersions: []string{"v1"},
+							Resources:   []string{"deployments"},
+							Scope:       ptr.To(v1.AllScopes),
+						},
+					},
+				}},
+			},
+			Validations: []v1.Validation{{Expression: "object.spec.replicas <= 5"}},
+		},
+	}
+	if mutate != nil {
+		mutate(policy)
+	}
+	return policy
+}
+
+func TestValidateValidatingAdmissionPolicy(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   *v1.ValidatingAdmissionPolicy
+		expected []string
+	}{
+		{
+			name:   "valid",
+			policy: newPolicy(nil),
+		},
+		{
+			name: "missing matchConstraints",
+			policy: newPolicy(func(p *v1.ValidatingAdmissionPolicy) {
+				p.Spec.MatchConstraints = nil
+			}),
+			expected: []string{"spec.matchConstraints: Required value"},
+		},
+		{
+			name: "no validations",
+			policy: newPolicy(func(p *v1.ValidatingAdmissionPolicy) {
+				p.Spec.Validations = nil
+			}),
+			expected: []string{
+				"spec.validations: Required value: validations or auditAnnotations must contain at least one item",
+				"spec.auditAnnotations: Required value: validations or auditAnnotations must contain at least one item",
+			},
+		},
+		{
+			name: "resource wildcards",
+			policy: newPolicy(func(p *v1.ValidatingAdmissionPolicy) {
+				p.Spec.MatchConstraints.ResourceRules[0].Resources = []string{"*", "pods", "*/status", "deployments/status"}
+			}),
+			expected: []string{
+				`spec.matchConstraints.resourceRules[0].resources[3]: Invalid value: "deployments/status": if '*/status' is present, must not specify deployments/status`,
+				`spec.matchConstraints.resourceRules[0].resources: Invalid value: []string{"*", "pods", "*/status", "deployments/status"}: if '*' is present, must not specify other resources without subresources`,
+			},
+		},
+		{
+			name: "double wildcard with other resources",
+			policy: newPolicy(func(p *v1.ValidatingAdmissionPolicy) {
+				p.Spec.MatchConstraints.ResourceRules[0].Resources = []string{"*/*", "pods/status"}
+			}),
+			expected: []string{
+				`spec.matchConstraints.resourceRules[0].resources: Invalid value: []string{"*/*", "pods/status"}: if '*/*' is present, must not specify other resources`,
+			},
+		},
+		{
+			name: "invalid operations and scope",
+			policy: newPolicy(func(p *v1.ValidatingAdmissionPolicy) {
+				rule := &p.Spec.MatchConstraints.ResourceRules[0]
+				rule.Operations = []v1.OperationType{"*", "PATCH"}
+				rule.Scope = ptr.To(v1.ScopeType("Global"))
+			}),
+			expected: []string{
+				`spec.matchConstraints.resourceRules[0].operations: Invalid value: []v1.OperationType{"*", "PATCH"}: if '*' is present, must not specify other operations`,
+				`spec.matchConstraints.resourceRules[0].operations[1]: Unsupported value: "PATCH": supported values: "*", "CONNECT", "CREATE", "DELETE", "UPDATE"`,
+				`spec.matchConstraints.resourceRules[0].scope: Unsupported value: "Global": supported values: "*", "Cluster", "Namespaced"`,
+			},
+		},
+		{
+			name: "duplicate variables",
+			policy: newPolicy(func(p *v1.ValidatingAdmissionPolicy) {
+				p.Spec.Variables = []v1.Variable{
+					{Name: "replicas", Expression: "object.spec.replicas"},
+					{Name: "replicas", Expression: "object.spec.replicas"},
+					{Name: "in", Expression: "1"},
+				}
+			}),
+			expected: []string{
+				`spec.variables[1].name: Duplicate value: "replicas"`,
+				`spec.variables[2].name: Invalid value: "in": name is not a valid CEL identifier`,
+			},
+		},
+		{
+			name: "invalid validation",
+			policy: newPolicy(func(p *v1.ValidatingAdmissionPolicy) {
+				p.Spec.Validations = []v1.Validation{
+					{Expression: "object.spec.replicas <=", Message: "line\nbreak", Reason: ptr.To(metav1.StatusReason("Denied"))},
+				}
+			}),
+			expected: []string{
+				"spec.validations[0].expression: Invalid value: \"object.spec.replicas <=\": compilation failed: ERROR: <input>:1:24: Syntax error: mismatched input '<EOF>' expecting {'[', '{', '(', '.', '-', '!', 'true', 'false', 'null', NUM_FLOAT, NUM_INT, NUM_UINT, STRING, BYTES, IDENTIFIER}\n | object.spec.replicas <=\n | .......................^",
+				`spec.validations[0].message: Invalid value: "line\nbreak": message must not contain line breaks`,
+				`spec.validations[0].reason: Unsupported value: "Denied": supported values: "Forbidden", "Invalid", "RequestEntityTooLarge"`,
+			},
+		},
+		{
+			name: "match conditions",
+			policy: newPolicy(func(p *v1.ValidatingAdmissionPolicy) {
+				p.Spec.MatchConditions = []v1.MatchCondition{
+					{Expression: "true"},
+					{Name: "exclude-system", Expression: "true"},
+					{Name: "exclude-system", Expression: ""},
+				}
+			}),
+			expected: []string{
+				"spec.matchConditions[0].name: Required value",
+				"spec.matchConditions[2].expression: Required value",
+				`spec.matchConditions[2].name: Duplicate value: "exclude-system"`,
+			},
+		},
+		{
+			name: "audit annotations",
+			policy: newPolicy(func(p *v1.ValidatingAdmissionPolicy) {
+				p.Spec.AuditAnnotations = []v1.AuditAnnotation{
+					{Key: "replicas", ValueExpression: "string(object.spec.replicas)"},
+					{Key: "replicas", ValueExpression: "string(object.spec.replicas)"},
+				}
+			}),
+			expected: []string{`spec.auditAnnotations[1].key: Duplicate value: "replicas"`},
+		},
+		{
+			name: "invalid paramKind",
+			policy: newPolicy(func(p *v1.ValidatingAdmissionPolicy) {
+				p.Spec.ParamKind = &v1.ParamKind{APIVersion: "example.com/v1/extra"}
+			}),
+			expected: []string{
+				`spec.paramKind.apiVersion: Invalid value: "example.com/v1/extra": unexpected GroupVersion string: example.com/v1/extra`,
+				"spec.paramKind.kind: Required value",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := ValidateValidatingAdmissionPolicy(tc.policy)
+			var got []string
+			for _, err := range errs {
+				got = append(got, err.Error())
+			}
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestValidateValidatingAdmissionPolicyBinding(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     v1.ValidatingAdmissionPolicyBindingSpec
+		expected []string
+	}{
+		{
+			name: "valid",
+			spec: v1.ValidatingAdmissionPolicyBindingSpec{
+				PolicyName:        "test-policy",
+				ParamRef:          &v1.ParamRef{Name: "params", ParameterNotFoundAction: ptr.To(v1.DenyAction)},
+				ValidationActions: []v1.ValidationAction{v1.Deny, v1.Audit},
+			},
+		},
+		{
+			name: "missing policyName and validationActions",
+			spec: v1.ValidatingAdmissionPolicyBindingSpec{},
+			expected: []string{
+				"spec.policyName: Required value",
+				"spec.validationActions: Required value: at least one validation action is required",
+			},
+		},
+		{
+			name: "paramRef with name and selector",
+			spec: v1.ValidatingAdmissionPolicyBindingSpec{
+				PolicyName: "test-policy",
+				ParamRef: &v1.ParamRef{
+					Name:                    "params",
+					Selector:                &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+					ParameterNotFoundAction: ptr.To(v1.ParameterNotFoundActionType("Ignore")),
+				},
+				ValidationActions: []v1.ValidationAction{v1.Deny},
+			},
+			expected: []string{
+				"spec.paramRef.name: Forbidden: name and selector are mutually exclusive",
+				"spec.paramRef.selector: Forbidden: name and selector are mutually exclusive",
+				`spec.paramRef.parameterNotFoundAction: Unsupported value: "Ignore": supported values: "Deny", "Allow"`,
+			},
+		},
+		{
+			name: "invalid validationActions",
+			spec: v1.ValidatingAdmissionPolicyBindingSpec{
+				PolicyName:        "test-policy",
+				ValidationActions: []v1.ValidationAction{v1.Deny, v1.Warn, v1.Deny, "Log"},
+			},
+			expected: []string{
+				`spec.validationActions[2]: Duplicate value: "Deny"`,
+				`spec.validationActions[3]: Unsupported value: "Log": supported values: "Audit", "Deny", "Warn"`,
+				`spec.validationActions: Invalid value: []v1.ValidationAction{"Deny", "Warn", "Deny", "Log"}: must not contain both Deny and Warn (repeating the same validation failure information in the API response and headers serves no purpose)`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			binding := &v1.ValidatingAdmissionPolicyBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-binding"},
+				Spec:       tc.spec,
+			}
+			var got []string
+			for _, err := range ValidateValidatingAdmissionPolicyBinding(binding) {
+				got = append(got, err.Error())
+			}
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestValidateValidatingAdmissionPolicyUpstream(t *testing.T) {
+	for _, tc := range upstreamPolicyCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assertUpstreamCase(t, ValidateValidatingAdmissionPolicy(tc.config).ToAggregate(), tc.expectedError)
+		})
+	}
+}
+
+func TestValidateValidatingAdmissionPolicyBindingUpstream(t *testing.T) {
+	for _, tc := range upstreamBindingCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assertUpstreamCase(t, ValidateValidatingAdmissionPolicyBinding(tc.config).ToAggregate(), tc.expectedError)
+		})
+	}
+}
+
+// assertUpstreamCase asserts the result of an upstream test case, the same way as upstream.
+func assertUpstreamCase(t *testing.T, err error, expectedError string) {
+	t.Helper()
+	if expectedError == "" {
+		assert.NoError(t, err)
+		return
+	}
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), expectedError)
+	}
+}
@@ -0,0 +1,294 @@
+package validator
+
+import (
+	"slices"
+	"unicode"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter"
+	"github.com/yashirook/vaptest/pkg/coverage"
+)
+
+// conditionNode is a condition of an expression and the ID of its node in the AST of the expression.
+type conditionNode struct {
+	id        int64
+	condition coverage.Condition
+}
+
+// parseConditions parses the expression and returns its conditions in source order: the operands of
+// logical operators, the conditions of ternary operators and the predicates of comprehensions.
+func parseConditions(expression string) (*cel.Ast, []conditionNode, error) {
+	parsed, issues := expressionsEnv().Parse(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, nil, issues.Err()
+	}
+	native := parsed.NativeRep()
+	text := []rune(expression)
+
+	var nodes []conditionNode
+	add := func(e ast.Expr, operator string) {
+		start, end := exprSpan(e, native.SourceInfo(), text)
+		nodes = append(nodes, conditionNode{id: e.ID(), condition: coverage.Condition{
+			Operator: operator,
+			Text:     string(text[start:end]),
+			Start:    start,
+			End:      end,
+		}})
+	}
+	var walk func(e ast.Expr)
+	walk = func(e ast.Expr) {
+		switch e.Kind() {
+		case ast.CallKind:
+			call := e.AsCall()
+			switch call.FunctionName() {
+			case operators.LogicalAnd:
+				for _, arg := range call.Args() {
+					add(arg, "&&")
+				}
+			case operators.LogicalOr:
+				for _, arg := range call.Args() {
+					add(arg, "||")
+				}
+			case operators.Conditional:
+				add(call.Args()[0], "?:")
+			}
+		case ast.ComprehensionKind:
+			comprehension := e.AsComprehension()
+			walk(comprehension.IterRange())
+			macro, predicate, rest := comprehensionParts(comprehension)
+			if predicate != nil {
+				add(predicate, macro)
+				walk(predicate)
+			}
+			// 展開されたマクロのアキュムレータは式に書かれていないため、述語と変換式だけをたどる
+			for _, r := range rest {
+				walk(r)
+			}
+			return
+		}
+		for _, child := range exprChildren(e) {
+			walk(child)
+		}
+	}
+	walk(native.Expr())
+
+	slices.SortStableFunc(nodes, func(a, b conditionNode) int {
+		if a.condition.Start != b.condition.Start {
+			return a.condition.Start - b.condition.Start
+		}
+		return b.condition.End - a.condition.End
+	})
+	return parsed, nodes, nil
+}
+
+// comprehensionParts returns the macro a comprehension was expanded from, its predicate, if any, and the other
+// expressions written in the macro call, such as the transform of map. Comprehensions of unknown shape are
+// returned with the loop step as is.
+func comprehensionParts(c ast.ComprehensionExpr) (string, ast.Expr, []ast.Expr) {
+	step := c.LoopStep()
+	if step.Kind() != ast.CallKind {
+		return "", nil, []ast.Expr{step}
+	}
+	call := step.AsCall()
+	args := call.Args()
+	switch call.FunctionName() {
+	case operators.LogicalAnd:
+		if isAccumulator(args[0], c) {
+			return "all", args[1], nil
+		}
+	case operators.LogicalOr:
+		if isAccumulator(args[0], c) {
+			return "exists", args[1], nil
+		}
+	case operators.Conditional:
+		if c.Result().Kind() == ast.CallKind && c.Result().AsCall().FunctionName() == operators.Equals {
+			return "exists_one", args[0], nil
+		}
+		if transform, ok := appendedElement(args[1], c); ok {
+			if transform.Kind() == ast.IdentKind && transform.AsIdent() == c.IterVar() {
+				return "filter", args[0], nil
+			}
+			return "map", args[0], []ast.Expr{transform}
+		}
+	case operators.Add:
+		if transform, ok := appendedElement(step, c); ok {
+			return "map", nil, []ast.Expr{transform}
+		}
+	}
+	return "", nil, []ast.Expr{step}
+}
+
+// appendedElement returns the element of the step accu + [element] of a comprehension.
+func appendedElement(step ast.Expr, c ast.ComprehensionExpr) (ast.Expr, bool) {
+	if step.Kind() != ast.CallKind || step.AsCall().FunctionName() != operators.Add {
+		return nil, false
+	}
+	args := step.AsCall().Args()
+	if !isAccumulator(args[0], c) || args[1].Kind() != ast.ListKind || len(args[1].AsList().Elements()) != 1 {
+		return nil, false
+	}
+	return args[1].AsList().Elements()[0], true
+}
+
+func isAccumulator(e ast.Expr, c ast.ComprehensionExpr) bool {
+	return e.Kind() == ast.IdentKind && e.AsIdent() == c.AccuVar()
+}
+
+// exprChildren returns the sub-expressions of the expression.
+func exprChildren(e ast.Expr) []ast.Expr {
+	var children []ast.Expr
+	switch e.Kind() {
+	case ast.CallKind:
+		call := e.AsCall()
+		if call.IsMemberFunction() {
+			children = append(children, call.Target())
+		}
+		children = append(children, call.Args()...)
+	case ast.ComprehensionKind:
+		c := e.AsComprehension()
+		children = append(children, c.IterRange(), c.AccuInit(), c.LoopCondition(), c.LoopStep(), c.Result())
+	case ast.ListKind:
+		children = append(children, e.AsList().Elements()...)
+	case ast.MapKind:
+		for _, entry := range e.AsMap().Entries() {
+			children = append(children, entry.AsMapEntry().Key(), entry.AsMapEntry().Value())
+		}
+	case ast.StructKind:
+		for _, field := range e.AsStruct().Fields() {
+			children = append(children, field.AsStructField().Value())
+		}
+	case ast.SelectKind:
+		children = append(children, e.AsSelect().Operand())
+	}
+	return children
+}
+
+// exprSpan returns the offsets of the source of the expression. The parser records the offset of a token
+// for each node, e.g. the operator of a call, so the span covers the tokens of all nodes of the expression,
+// extended to the closing brackets of its calls and indexes.
+func exprSpan(e ast.Expr, info *ast.SourceInfo, text []rune) (int, int) {
+	start, end := len(text), 0
+	var visit func(e ast.Expr)
+	visit = func(e ast.Expr) {
+		if r, ok := info.GetOffsetRange(e.ID()); ok {
+			start = min(start, int(r.Start))
+			end = max(end, int(r.Stop))
+		}
+		for _, child := range exprChildren(e) {
+			visit(child)
+		}
+	}
+	visit(e)
+	start, end = max(start, 0), min(end, len(text))
+	if start >= end {
+		return start, start
+	}
+	// 選択の位置はドットなので、最後のフィールド名と has() などのマクロ名を含める
+	for end < len(text) && (text[end-1] == '.' || text[end-1] == '?' || isIdentRune(text[end-1])) && isIdentRune(text[end]) {
+		end++
+	}
+	// 括弧で囲まれた部分式では、括弧の位置が記録されないため、開き括弧までさかのぼる
+	for unclosed := unopenedBrackets(text[start:end]); unclosed > 0 && start > 0; start-- {
+		if r := text[start-1]; r == '(' || r == '[' {
+			unclosed--
+		} else if !unicode.IsSpace(r) {
+			break
+		}
+	}
+	if text[start] == '(' {
+		for start > 0 && isIdentRune(text[start-1]) {
+			start--
+		}
+	}
+
+	// 開いた括弧はすべて式の一部なので、対応する閉じ括弧まで含める
+	depth := 0
+	var quote rune
+	for i := start; i < len(text) && (i < end || depth > 0); i++ {
+		r := text[i]
+		switch {
+		case quote != 0:
+			if r == '\\' {
+				i++
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '(' || r == '[':
+			depth++
+		case r == ')' || r == ']':
+			depth--
+			if i >= end {
+				end = i + 1
+			}
+		}
+	}
+	return start, end
+}
+
+// unopenedBrackets returns the number of closing brackets in the text without an opening bracket.
+func unopenedBrackets(text []rune) int {
+	depth, unopened := 0, 0
+	var quote rune
+	for i := 0; i < len(text); i++ {
+		r := text[i]
+		switch {
+		case quote != 0:
+			if r == '\\' {
+				i++
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '(' || r == '[':
+			depth++
+		case r == ')' || r == ']':
+			if depth == 0 {
+				unopened++
+			} else {
+				depth--
+			}
+		}
+	}
+	return unopened
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// conditionProgram compiles the parsed expression to a program counting the results of its conditions.
+// Like cel.OptTrackState, the program decorates the nodes of the conditions to observe their values, but it
+// counts every evaluation, whereas the evaluation state keeps only the last value of each node and would show
+// only the last element of a comprehension.
+func conditionProgram(parsed *cel.Ast, conditions map[int64]*coverage.Condition) (cel.Program, error) {
+	return expressionsEnv().Program(parsed, cel.CustomDecorator(func(i interpreter.Interpretable) (interpreter.Interpretable, error) {
+		if c, ok := conditions[i.ID()]; ok {
+			return &observedCondition{Interpretable: i, condition: c}, nil
+		}
+		return i, nil
+	}))
+}
+
+// observedCondition counts the boolean results of the node of a condition.
+type observedCondition struct {
+	interpreter.Interpretable
+	condition *coverage.Condition
+}
+
+func (o *observedCondition) Eval(activation interpreter.Activation) ref.Val {
+	val := o.Interpretable.Eval(activation)
+	switch val {
+	case types.True:
+		o.condition.True++
+	case types.False:
+		o.condition.False++
+	}
+	return val
+}
@@ -0,0 +1,74 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConditions(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       []string
+	}{
+		{
+			name:       "論理演算子のオペランド",
+			expression: "a || (b && c.all(x, x.name.startsWith('y')))",
+			want: []string{
+				"|| a",
+				"|| b && c.all(x, x.name.startsWith('y'))",
+				"&& b",
+				"&& c.all(x, x.name.startsWith('y'))",
+				"all x.name.startsWith('y')",
+			},
+		},
+		{
+			name:       "has()と添字を含むオペランド",
+			expression: "has(object.metadata.labels) && object.metadata.labels['team'] != ''",
+			want: []string{
+				"&& has(object.metadata.labels)",
+				"&& object.metadata.labels['team'] != ''",
+			},
+		},
+		{
+			name:       "三項演算子の条件",
+			expression: "object.spec.replicas > 3 ? params.max > 1 : true",
+			want:       []string{"?: object.spec.replicas > 3"},
+		},
+		{
+			name:       "マクロの述語",
+			expression: "object.items.exists_one(i, i > 2) || object.items.filter(i, i < 0).size() == 0",
+			want: []string{
+				"|| object.items.exists_one(i, i > 2)",
+				"exists_one i > 2",
+				"|| object.items.filter(i, i < 0).size() == 0",
+				"filter i < 0",
+			},
+		},
+		{
+			name:       "述語のないマクロ",
+			expression: "object.items.map(i, i * 2).size() > 0",
+			want:       nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, nodes, err := parseConditions(tt.expression)
+			require.NoError(t, err)
+			var got []string
+			for _, node := range nodes {
+				c := node.condition
+				assert.Equal(t, c.Text, string([]rune(tt.expression)[c.Start:c.End]))
+				got = append(got, c.Operator+" "+c.Text)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseConditionsError(t *testing.T) {
+	_, _, err := parseConditions("object.spec.replicas >")
+	assert.Error(t, err)
+}
@@ -0,0 +1,143 @@
+package validator
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/yashirook/vaptest/pkg/target"
+	v1 "k8s.io/api/admissionregistration/v1"
+)
+
+// Disagreement is a policy and target pair for which two engines gave different answers.
+// A nil result means the engine did not evaluate the pair.
+type Disagreement struct {
+	Policy   PolicyIdentifier
+	Target   target.TargetIdentifier
+	Native   *ValidationResult
+	Upstream *ValidationResult
+}
+
+func (d Disagreement) String() string {
+	resource := d.Target.Resource
+	if d.Target.SubResource != "" {
+		resource += "/" + d.Target.SubResource
+	}
+	if d.Target.Namespace != "" {
+		resource = d.Target.Namespace + "/" + resource
+	}
+	return fmt.Sprintf("policy %s, %s/%s: native=%s, upstream=%s",
+		d.Policy.PolicyName, resource, d.Target.ResourceName, describeResult(d.Native), describeResult(d.Upstream))
+}
+
+// describeResult describes the answer of an engine, e.g. fail [has(object.metadata.labels)].
+func describeResult(result *ValidationResult) string {
+	if result == nil {
+		return "not evaluated"
+	}
+	if result.Success {
+		return "pass"
+	}
+	return fmt.Sprintf("fail [%s]", strings.Join(failedExpressions(result), ", "))
+}
+
+// failedExpressions returns the sorted CEL expressions of the failed validations.
+func failedExpressions(result *ValidationResult) []string {
+	expressions := make([]string, 0, len(result.ValidationErrors))
+	for _, e := range result.ValidationErrors {
+		expressions = append(expressions, e.CELExpr)
+	}
+	slices.Sort(expressions)
+	return expressions
+}
+
+type resultKey struct {
+	policy string
+	target target.TargetIdentifier
+}
+
+// IgnoredFeatures returns the features of the policy and its bindings that Validator ignores, such as
+// matchConditions, and that make its results differ from those of UpstreamValidator by design. An unbound
+// policy has none: UpstreamValidator never evaluates it, so every result of Validator for it is a disagreement,
+// as it would be in a cluster.
+func IgnoredFeatures(policy *v1.ValidatingAdmissionPolicy, bindings []*v1.ValidatingAdmissionPolicyBinding) []string {
+	var features []string
+	bound := false
+	for _, binding := range bindings {
+		if binding.Spec.PolicyName != policy.Name {
+			continue
+		}
+		bound = true
+		if binding.Spec.ParamRef != nil && !slices.Contains(features, "paramRef") {
+			features = append(features, "paramRef")
+		}
+	}
+	if !bound {
+		return nil
+	}
+	if policy.Spec.ParamKind != nil {
+		features = append(features, "paramKind")
+	}
+	if len(policy.Spec.MatchConditions) > 0 {
+		features = append(features, "matchConditions")
+	}
+	if len(policy.Spec.Variables) > 0 {
+		features = append(features, "variables")
+	}
+	return features
+}
+
+// CompareResults returns the policy and target pairs evaluated differently by the native and upstream engines.
+// Two results agree when both pass, or both fail the same validations. Messages are not compared, since the
+// upstream plugin reports a default message, such as "failed expression: ...", for validations without one.
+// Policies with IgnoredFeatures are not compared, and neither are validationActions, which only decide how
+// the upstream plugin reports a failure. Validator also ignores the matchResources of bindings, so a pair of a
+// policy bound with matchResources that only Validator evaluated is not a disagreement.
+func CompareResults(native, upstream []ValidationResult, policies []*v1.ValidatingAdmissionPolicy, bindings []*v1.ValidatingAdmissionPolicyBinding) []Disagreement {
+	comparable := make(map[string]bool, len(policies))
+	narrowed := make(map[string]bool, len(policies))
+	for _, policy := range policies {
+		comparable[policy.Name] = len(IgnoredFeatures(policy, bindings)) == 0
+	}
+	for _, binding := range bindings {
+		if binding.Spec.MatchResources != nil {
+			narrowed[binding.Spec.PolicyName] = true
+		}
+	}
+
+	upstreamResults := make(map[resultKey]*ValidationResult, len(upstream))
+	for i := range upstream {
+		upstreamResults[resultKey{upstream[i].Policy.PolicyName, upstream[i].Target}] = &upstream[i]
+	}
+
+	var disagreements []Disagreement
+	seen := make(map[resultKey]bool, len(native))
+	for i := range native {
+		key := resultKey{native[i].Policy.PolicyName, native[i].Target}
+		seen[key] = true
+		if !comparable[key.policy] {
+			continue
+		}
+		u := upstreamResults[key]
+		if u == nil && narrowed[key.policy] {
+			continue
+		}
+		if !agrees(&native[i], u) {
+			disagreements = append(disagreements, Disagreement{Policy: native[i].Policy, Target: native[i].Target, Native: &native[i], Upstream: u})
+		}
+	}
+	for i := range upstream {
+		key := resultKey{upstream[i].Policy.PolicyName, upstream[i].Target}
+		if !seen[key] && comparable[key.policy] {
+			disagreements = append(disagreements, Disagreement{Policy: upstream[i].Policy, Target: upstream[i].Target, Upstream: &upstream[i]})
+		}
+	}
+	return disagreements
+}
+
+func agrees(a, b *ValidationResult) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Success == b.Success && slices.Equal(failedExpressions(a), failedExpressions(b))
+}
@@ -0,0 +1,71 @@
+package validator
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apiserver/pkg/cel/environment"
+)
+
+// expressionsEnv returns the CEL environment shared by all compiled expressions.
+// Building the base environment is expensive, so it is built once per process.
+var expressionsEnv = sync.OnceValue(func() *cel.Env {
+	celEnv := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion(), false)
+	return celEnv.NewExpressionsEnv()
+})
+
+// CompiledPolicy is a ValidatingAdmissionPolicy whose validations are compiled to CEL programs.
+// A CompiledPolicy is read-only once created and can be evaluated against any number of targets.
+type CompiledPolicy struct {
+	Policy      *v1.ValidatingAdmissionPolicy
+	Validations []CompiledValidation
+}
+
+// CompiledValidation is a validation with its compiled CEL program.
+type CompiledValidation struct {
+	Validation v1.Validation
+	Program    cel.Program
+}
+
+// CompilePolicy compiles all validations of the policy.
+func CompilePolicy(policy *v1.ValidatingAdmissionPolicy) (*CompiledPolicy, error) {
+	compiled := &CompiledPolicy{
+		Policy:      policy,
+		Validations: make([]CompiledValidation, 0, len(policy.Spec.Validations)),
+	}
+	for _, validation := range policy.Spec.Validations {
+		prog, err := makeCELProgram(&validation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile policy %s: %w", policy.Name, err)
+		}
+		compiled.Validations = append(compiled.Validations, CompiledValidation{
+			Validation: validation,
+			Program:    prog,
+		})
+	}
+	return compiled, nil
+}
+
+func makeCELProgram(validation *v1.Validation) (cel.Program, error) {
+	env := expressionsEnv()
+
+	ast, issues := env.Parse(validation.Expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("CEL expression parse error: %w", issues.Err())
+	}
+
+	// todo: check implementation
+	// _, issues = env.Check(ast)
+	// if issues != nil && issues.Err() != nil {
+	// 	return nil, fmt.Errorf("CEL expression check error: %w", issues.Err())
+	// }
+
+	prog, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("build CEL Program error: %w", err)
+	}
+
+	return prog, nil
+}
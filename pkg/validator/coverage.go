@@ -0,0 +1,319 @@
+package validator
+
+import (
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/yashirook/vaptest/pkg/coverage"
+	v1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	plugincel "k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/admission/plugin/policy/generic"
+	"k8s.io/apiserver/pkg/admission/plugin/policy/matching"
+	"k8s.io/apiserver/pkg/admission/plugin/policy/validating"
+	"k8s.io/apiserver/pkg/authorization/authorizerfactory"
+	"k8s.io/apiserver/pkg/cel/lazy"
+	"k8s.io/apiserver/pkg/cel/library"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// coverageTracker records the coverage of the match conditions, variables and validations of a policy through
+// each of its bindings. The plugin does not tell how each expression evaluated, so the tracker evaluates them
+// again in process, once per binding and param matching a request, as the plugin does: the match conditions
+// first, then the variables and validations if all of them are true. The policy and its bindings are matched
+// against the request by the matcher of the plugin.
+type coverageTracker struct {
+	policy   *v1.ValidatingAdmissionPolicy
+	bindings []*trackedBinding
+}
+
+// trackedBinding holds the programs of the expressions of the policy registered for a binding.
+type trackedBinding struct {
+	binding         *v1.ValidatingAdmissionPolicyBinding
+	matchConditions []*trackedExpression
+	variables       []*trackedExpression
+	validations     []*trackedExpression
+}
+
+// trackedExpression is a registered expression with a program counting the results of its conditions, or nil
+// if the expression does not compile.
+type trackedExpression struct {
+	expression *coverage.Expression
+	program    cel.Program
+}
+
+// newCoverageTracker registers the expressions of the policy for each of its bindings and returns their tracker.
+// The expressions of a policy without bindings are registered without tracking, as they are never evaluated.
+func newCoverageTracker(profile *coverage.Profile, policy *v1.ValidatingAdmissionPolicy, bindings []*v1.ValidatingAdmissionPolicyBinding) *coverageTracker {
+	tracker := &coverageTracker{policy: policy}
+	register := func(bindingName string) *trackedBinding {
+		b := &trackedBinding{}
+		add := func(e coverage.Expression) *trackedExpression {
+			e.Policy = policy.Name
+			e.Binding = bindingName
+			registered := profile.Register(e)
+			return &trackedExpression{expression: registered, program: expressionProgram(registered)}
+		}
+		for i, condition := range policy.Spec.MatchConditions {
+			b.matchConditions = append(b.matchConditions, add(coverage.Expression{Kind: coverage.KindMatchCondition, Index: i, Name: condition.Name, Expression: condition.Expression}))
+		}
+		for i, variable := range policy.Spec.Variables {
+			b.variables = append(b.variables, add(coverage.Expression{Kind: coverage.KindVariable, Index: i, Name: variable.Name, Expression: variable.Expression}))
+		}
+		for i, validation := range policy.Spec.Validations {
+			b.validations = append(b.validations, add(coverage.Expression{Kind: coverage.KindValidation, Index: i, Expression: validation.Expression}))
+		}
+		return b
+	}
+
+	if len(bindings) == 0 {
+		register("")
+		return tracker
+	}
+	for _, binding := range bindings {
+		b := register(binding.Name)
+		b.binding = binding
+		tracker.bindings = append(tracker.bindings, b)
+	}
+	return tracker
+}
+
+// expressionProgram sets the conditions of the registered expression, unless set by an earlier registration,
+// and returns a program evaluating it and counting their results, or nil for an expression not parsed.
+func expressionProgram(e *coverage.Expression) cel.Program {
+	if program := conditionTracker(e); program != nil {
+		return program
+	}
+	parsed, issues := expressionsEnv().Parse(e.Expression)
+	if issues != nil && issues.Err() != nil {
+		return nil
+	}
+	program, err := expressionsEnv().Program(parsed)
+	if err != nil {
+		return nil
+	}
+	return program
+}
+
+// conditionTracker sets the conditions of the registered expression, unless set by an earlier registration,
+// and returns a program counting their results, or nil for an expression without conditions or not parsed.
+func conditionTracker(e *coverage.Expression) cel.Program {
+	parsed, nodes, err := parseConditions(e.Expression)
+	if err != nil || len(nodes) == 0 {
+		return nil
+	}
+	if e.Conditions == nil {
+		for _, node := range nodes {
+			condition := node.condition
+			e.Conditions = append(e.Conditions, &condition)
+		}
+	}
+	if len(e.Conditions) != len(nodes) {
+		return nil
+	}
+	observed := make(map[int64]*coverage.Condition, len(nodes))
+	for i, node := range nodes {
+		observed[node.id] = e.Conditions[i]
+	}
+	program, err := conditionProgram(parsed, observed)
+	if err != nil {
+		return nil
+	}
+	return program
+}
+
+// track records the evaluation of the policy for the request through each binding matching it, with each param
+// the binding selects. A request the plugin fails to match, or denies for the configuration of the policy or
+// binding, such as missing params, does not reach the expressions.
+func (c *coverageTracker) track(attr admission.Attributes, o admission.ObjectInterfaces, matcher generic.PolicyMatcher, params []runtime.Object) {
+	if len(c.bindings) == 0 {
+		return
+	}
+	matches, gvr, gvk, err := matcher.DefinitionMatches(attr, o, validating.NewValidatingAdmissionPolicyAccessor(c.policy))
+	if err != nil || !matches {
+		return
+	}
+	versioned, err := admission.NewVersionedAttributes(attr, gvk, o)
+	if err != nil {
+		return
+	}
+	var namespace *corev1.Namespace
+	if attr.GetNamespace() != "" {
+		if namespace, err = matcher.GetNamespace(attr.GetNamespace()); err != nil {
+			return
+		}
+	}
+	activation := requestActivation(versioned, gvr, namespace)
+
+	for _, b := range c.bindings {
+		matches, err := matcher.BindingMatches(attr, o, validating.NewValidatingAdmissionPolicyBindingAccessor(b.binding))
+		if err != nil || !matches {
+			continue
+		}
+		for _, param := range bindingParams(c.policy, b.binding, params, attr.GetNamespace()) {
+			b.evaluate(activation, param)
+		}
+	}
+}
+
+// evaluate evaluates the expressions with the activation of a request and the param, and records their results.
+// Variables are lazy as in the plugin: they are evaluated and recorded at most once, when a match condition or
+// a validation refers to them, so a variable nothing refers to is never reached.
+func (b *trackedBinding) evaluate(activation map[string]any, param any) {
+	vars := make(map[string]any, len(activation)+2)
+	for name, val := range activation {
+		vars[name] = val
+	}
+	vars[plugincel.ParamsVarName] = param
+	variables := lazy.NewMapValue(types.NewObjectType("vaptest.Variables"))
+	for _, variable := range b.variables {
+		variables.Append(variable.expression.Name, func(*lazy.MapValue) ref.Val {
+			val := variable.eval(vars)
+			variable.record(val)
+			return val
+		})
+	}
+	vars[plugincel.VariableVarName] = variables
+
+	matched := true
+	for _, condition := range b.matchConditions {
+		val := condition.eval(vars)
+		condition.record(val)
+		matched = matched && val == types.True
+	}
+	if !matched {
+		return
+	}
+	for _, validation := range b.validations {
+		validation.record(validation.eval(vars))
+	}
+}
+
+func (e *trackedExpression) eval(vars map[string]any) ref.Val {
+	if e.program == nil {
+		return types.NewErr("failed to compile expression %q", e.expression.Expression)
+	}
+	val, _, err := e.program.Eval(vars)
+	if err != nil {
+		return types.WrapErr(err)
+	}
+	return val
+}
+
+// record counts the result of the expression. Match conditions and validations not evaluating to a bool fail
+// to evaluate, while variables may have any value, which counts as true.
+func (e *trackedExpression) record(val ref.Val) {
+	switch {
+	case val == types.True:
+		e.expression.True++
+	case val == types.False:
+		e.expression.False++
+	case types.IsUnknownOrError(val) || e.expression.Kind != coverage.KindVariable:
+		e.expression.Error++
+	default:
+		e.expression.True++
+	}
+}
+
+// requestActivation returns the variables the plugin binds for the versioned request, except params and
+// variables, which depend on the binding and the policy. The authorizer allows every request, like the
+// authorizer of the plugin.
+func requestActivation(versioned *admission.VersionedAttributes, gvr schema.GroupVersionResource, namespace *corev1.Namespace) map[string]any {
+	activation := map[string]any{
+		plugincel.ObjectVarName:    objectValue(versioned.VersionedObject),
+		plugincel.OldObjectVarName: objectValue(versioned.VersionedOldObject),
+		plugincel.NamespaceVarName: nil,
+	}
+	gvk := versioned.VersionedKind
+	request := plugincel.CreateAdmissionRequest(versioned.Attributes,
+		metav1.GroupVersionResource{Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource},
+		metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind})
+	if val, err := runtime.DefaultUnstructuredConverter.ToUnstructured(request); err == nil {
+		activation[plugincel.RequestVarName] = val
+	}
+	if namespace != nil {
+		if val, err := runtime.DefaultUnstructuredConverter.ToUnstructured(plugincel.CreateNamespaceObject(namespace)); err == nil {
+			activation[plugincel.NamespaceVarName] = val
+		}
+	}
+	authz := authorizerfactory.NewAlwaysAllowAuthorizer()
+	activation[plugincel.AuthorizerVarName] = library.NewAuthorizerVal(versioned.GetUserInfo(), authz)
+	activation[plugincel.RequestResourceAuthorizerVarName] = library.NewResourceAuthorizerVal(versioned.GetUserInfo(), authz, versioned)
+	return activation
+}
+
+// objectValue returns the object as the value CEL expressions see, or nil.
+func objectValue(obj runtime.Object) any {
+	if obj == nil {
+		return nil
+	}
+	if u, ok := obj.(runtime.Unstructured); ok {
+		return u.UnstructuredContent()
+	}
+	val, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil
+	}
+	return val
+}
+
+// newCoverageMatcher returns the matcher of the plugin for the namespaces, with the store of the namespaces.
+func newCoverageMatcher(namespaces []*corev1.Namespace) (generic.PolicyMatcher, cache.Indexer) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, ns := range namespaces {
+		_ = indexer.Add(ns)
+	}
+	// The namespaces of targets are all in the indexer, so the client is never asked for one.
+	return generic.NewPolicyMatcher(matching.NewMatcher(corelisters.NewNamespaceLister(indexer), fake.NewSimpleClientset())), indexer
+}
+
+// bindingParams returns the params the binding selects for a request in the namespace, or a single nil when
+// the policy takes no params. Params in a namespace are selected from the namespace of the paramRef, or of the
+// request if the paramRef has none.
+func bindingParams(policy *v1.ValidatingAdmissionPolicy, binding *v1.ValidatingAdmissionPolicyBinding, params []runtime.Object, namespace string) []any {
+	paramKind, ref := policy.Spec.ParamKind, binding.Spec.ParamRef
+	if paramKind == nil || ref == nil {
+		return []any{nil}
+	}
+	var selected []any
+	for _, param := range params {
+		gvk := param.GetObjectKind().GroupVersionKind()
+		if gvk.GroupVersion().String() != paramKind.APIVersion || gvk.Kind != paramKind.Kind {
+			continue
+		}
+		accessor, err := meta.Accessor(param)
+		if err != nil {
+			continue
+		}
+		if accessor.GetNamespace() != "" {
+			ns := ref.Namespace
+			if ns == "" {
+				ns = namespace
+			}
+			if accessor.GetNamespace() != ns {
+				continue
+			}
+		}
+		if ref.Name != "" && accessor.GetName() != ref.Name {
+			continue
+		}
+		if ref.Selector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(ref.Selector)
+			if err != nil || !selector.Matches(labels.Set(accessor.GetLabels())) {
+				continue
+			}
+		}
+		if val, err := runtime.DefaultUnstructuredConverter.ToUnstructured(param); err == nil {
+			selected = append(selected, val)
+		}
+	}
+	return selected
+}
@@ -5,23 +5,29 @@ import (
 	v1 "k8s.io/api/admissionregistration/v1"
 )
 
-func filterTarget(policy *v1.ValidatingAdmissionPolicy, targetInfoList target.TargetInfoList) (target.TargetInfoList, error) {
-	filteredTargets := make(target.TargetInfoList, 0)
-	if policy.Spec.MatchConstraints == nil {
-		return targetInfoList, nil
-	}
+// matchedTarget is a target matched by a policy and the target as the policy sees it, which differs when the
+// policy matches it through an equivalent resource.
+type matchedTarget struct {
+	target    *target.TargetInfo
+	versioned *target.TargetInfo
+}
 
-	for _, t := range targetInfoList {
-		// ExcludeResourceRulesが空でない場合のみチェックを行う
-		if len(policy.Spec.MatchConstraints.ExcludeResourceRules) > 0 && matchesExcludeRule(policy.Spec.MatchConstraints.ExcludeResourceRules, &t) {
-			continue
+func filterTarget(policy *v1.ValidatingAdmissionPolicy, targetInfoList target.TargetInfoList, equivalents *target.EquivalentResourceMapper) ([]matchedTarget, error) {
+	filteredTargets := make([]matchedTarget, 0)
+	for i := range targetInfoList {
+		t := &targetInfoList[i]
+		matched, resource, kind, err := matchesConstraints(policy.Spec.MatchConstraints, newAdmissionAttributes(t), equivalents)
+		if err != nil {
+			return nil, err
 		}
-
-		// ResourceRulesが空の場合、デフォルトで全てのリソースにマッチする
-		if len(policy.Spec.MatchConstraints.ResourceRules) > 0 && !matchesRule(policy.Spec.MatchConstraints.ResourceRules, &t) {
+		if !matched {
 			continue
 		}
-		filteredTargets = append(filteredTargets, t)
+		versioned, err := versionedTarget(t, resource, kind, equivalents)
+		if err != nil {
+			return nil, err
+		}
+		filteredTargets = append(filteredTargets, matchedTarget{target: t, versioned: versioned})
 	}
 
 	return filteredTargets, nil
@@ -0,0 +1,98 @@
+package validator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/yashirook/vaptest/pkg/target"
+	v1 "k8s.io/api/admissionregistration/v1"
+)
+
+// policyIndex maps API groups and resources to the policies whose resource rules could match them.
+// It is a coarse prefilter: candidates still have to pass filterTarget.
+type policyIndex struct {
+	// byGroupResource holds the policies with rules naming a concrete group and resource, keyed by "<group>/<resource>".
+	byGroupResource map[string][]int
+	// byGroup holds the policies with rules naming a concrete group but a wildcard resource.
+	byGroup map[string][]int
+	// byResource holds the policies with matchPolicy: Equivalent, the default, and rules naming a concrete
+	// resource, which also match the resources of the same name in other groups.
+	byResource map[string][]int
+	// any holds the policies that can match every resource.
+	any []int
+}
+
+func newPolicyIndex(policies []*CompiledPolicy) *policyIndex {
+	index := &policyIndex{
+		byGroupResource: make(map[string][]int),
+		byGroup:         make(map[string][]int),
+		byResource:      make(map[string][]int),
+	}
+	for i, p := range policies {
+		index.add(i, p.Policy)
+	}
+	return index
+}
+
+func (idx *policyIndex) add(i int, policy *v1.ValidatingAdmissionPolicy) {
+	constraints := policy.Spec.MatchConstraints
+	if constraints == nil || len(constraints.ResourceRules) == 0 {
+		idx.any = append(idx.any, i)
+		return
+	}
+
+	equivalent := constraints.MatchPolicy == nil || *constraints.MatchPolicy == v1.Equivalent
+	for _, rule := range constraints.ResourceRules {
+		groups := rule.APIGroups
+		if len(groups) == 0 {
+			groups = []string{"*"}
+		}
+		resources := rule.Resources
+		if len(resources) == 0 {
+			resources = []string{"*"}
+		}
+		for _, group := range groups {
+			for _, resource := range resources {
+				base, _, _ := strings.Cut(resource, "/")
+				switch {
+				case group == "*":
+					idx.any = appendUnique(idx.any, i)
+				case equivalent && base == "*":
+					idx.any = appendUnique(idx.any, i)
+				case equivalent:
+					idx.byResource[base] = appendUnique(idx.byResource[base], i)
+				case base == "*":
+					idx.byGroup[group] = appendUnique(idx.byGroup[group], i)
+				default:
+					key := group + "/" + base
+					idx.byGroupResource[key] = appendUnique(idx.byGroupResource[key], i)
+				}
+			}
+		}
+	}
+}
+
+// lookup returns the indices of the policies that could match the target, in policy order.
+func (idx *policyIndex) lookup(t *target.TargetInfo) []int {
+	var candidates []int
+	candidates = append(candidates, idx.any...)
+	candidates = append(candidates, idx.byGroup[t.APIGroup]...)
+	candidates = append(candidates, idx.byGroupResource[t.APIGroup+"/"+t.Resource]...)
+	candidates = append(candidates, idx.byResource[t.Resource]...)
+
+	sort.Ints(candidates)
+	unique := candidates[:0]
+	for i, c := range candidates {
+		if i == 0 || c != candidates[i-1] {
+			unique = append(unique, c)
+		}
+	}
+	return unique
+}
+
+func appendUnique(indices []int, i int) []int {
+	if len(indices) > 0 && indices[len(indices)-1] == i {
+		return indices
+	}
+	return append(indices, i)
+}
@@ -0,0 +1,165 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yashirook/vaptest/pkg/target"
+	v1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func newRulePolicy(name string, groups []string, resources []string) *v1.ValidatingAdmissionPolicy {
+	policy := &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{
+				{Expression: "has(object.metadata.labels)", Message: "labels are required"},
+			},
+		},
+	}
+	if groups != nil || resources != nil {
+		policy.Spec.MatchConstraints = &v1.MatchResources{
+			MatchPolicy: ptr.To(v1.Exact),
+			ResourceRules: []v1.NamedRuleWithOperations{
+				{
+					RuleWithOperations: v1.RuleWithOperations{
+						Operations: []v1.OperationType{v1.OperationAll},
+						Rule: v1.Rule{
+							APIGroups:   groups,
+							APIVersions: []string{"*"},
+							Resources:   resources,
+						},
+					},
+				},
+			},
+		}
+	}
+	return policy
+}
+
+func withMatchPolicy(policy *v1.ValidatingAdmissionPolicy, matchPolicy v1.MatchPolicyType) *v1.ValidatingAdmissionPolicy {
+	policy.Spec.MatchConstraints.MatchPolicy = &matchPolicy
+	return policy
+}
+
+func TestPolicyIndexLookup(t *testing.T) {
+	policies := []*v1.ValidatingAdmissionPolicy{
+		newRulePolicy("all", nil, nil),
+		newRulePolicy("deployments", []string{"apps"}, []string{"deployments"}),
+		newRulePolicy("deployment-scale", []string{"apps"}, []string{"deployments/scale"}),
+		newRulePolicy("apps-wildcard", []string{"apps"}, []string{"*"}),
+		newRulePolicy("any-group-pods", []string{"*"}, []string{"pods"}),
+		newRulePolicy("pods", []string{""}, []string{"pods", "pods/*"}),
+		withMatchPolicy(newRulePolicy("equivalent-deployments", []string{"apps"}, []string{"deployments"}), v1.Equivalent),
+		withMatchPolicy(newRulePolicy("equivalent-apps-wildcard", []string{"apps"}, []string{"*"}), v1.Equivalent),
+	}
+	compiled := make([]*CompiledPolicy, 0, len(policies))
+	for _, p := range policies {
+		cp, err := CompilePolicy(p)
+		require.NoError(t, err)
+		compiled = append(compiled, cp)
+	}
+	index := newPolicyIndex(compiled)
+
+	testCases := []struct {
+		name     string
+		target   target.TargetIdentifier
+		expected []int
+	}{
+		{
+			name:     "deployment",
+			target:   target.TargetIdentifier{APIGroup: "apps", APIVersion: "v1", Resource: "deployments"},
+			expected: []int{0, 1, 2, 3, 4, 6, 7},
+		},
+		{
+			name:     "extensions deployment",
+			target:   target.TargetIdentifier{APIGroup: "extensions", APIVersion: "v1beta1", Resource: "deployments"},
+			expected: []int{0, 4, 6, 7},
+		},
+		{
+			name:     "statefulset",
+			target:   target.TargetIdentifier{APIGroup: "apps", APIVersion: "v1", Resource: "statefulsets"},
+			expected: []int{0, 3, 4, 7},
+		},
+		{
+			name:     "pod",
+			target:   target.TargetIdentifier{APIGroup: "", APIVersion: "v1", Resource: "pods"},
+			expected: []int{0, 4, 5, 7},
+		},
+		{
+			name:     "configmap",
+			target:   target.TargetIdentifier{APIGroup: "", APIVersion: "v1", Resource: "configmaps"},
+			expected: []int{0, 4, 7},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, index.lookup(&target.TargetInfo{TargetIdentifier: tc.target}))
+		})
+	}
+}
+
+// policyTarget is the policy, target and outcome of a result.
+type policyTarget struct {
+	policy  string
+	target  string
+	success bool
+}
+
+func TestValidatorValidate(t *testing.T) {
+	targets := target.TargetInfoList{
+		{
+			Object:           map[string]interface{}{"metadata": map[string]interface{}{"name": "deploy"}},
+			TargetIdentifier: target.TargetIdentifier{APIGroup: "apps", APIVersion: "v1", Resource: "deployments", ResourceName: "deploy"},
+		},
+		{
+			Object:           map[string]interface{}{"metadata": map[string]interface{}{"name": "pod", "labels": map[string]interface{}{"app": "test"}}},
+			TargetIdentifier: target.TargetIdentifier{APIGroup: "", APIVersion: "v1", Resource: "pods", ResourceName: "pod"},
+		},
+		{
+			Object:           map[string]interface{}{"metadata": map[string]interface{}{"name": "config"}},
+			TargetIdentifier: target.TargetIdentifier{APIGroup: "", APIVersion: "v1", Resource: "configmaps", ResourceName: "config"},
+		},
+	}
+	policies := []*v1.ValidatingAdmissionPolicy{
+		newRulePolicy("pods", []string{""}, []string{"pods"}),
+		newRulePolicy("all", nil, nil),
+		newRulePolicy("deployments", []string{"apps"}, []string{"deployments"}),
+	}
+
+	v, err := NewValidator(targets, policies, nil, nil)
+	require.NoError(t, err)
+	results, err := v.Validate()
+	require.NoError(t, err)
+
+	var got []policyTarget
+	for _, r := range results {
+		got = append(got, policyTarget{r.Policy.PolicyName, r.Target.ResourceName, r.Success})
+	}
+	assert.Equal(t, []policyTarget{
+		{"pods", "pod", true},
+		{"all", "deploy", false},
+		{"all", "pod", true},
+		{"all", "config", false},
+		{"deployments", "deploy", false},
+	}, got)
+}
+
+func TestNewValidatorCompileError(t *testing.T) {
+	targets := target.TargetInfoList{
+		{TargetIdentifier: target.TargetIdentifier{APIGroup: "apps", APIVersion: "v1", Resource: "deployments"}},
+	}
+	policy := &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "broken-policy"},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{{Expression: "object.metadata.name ==", Message: "broken"}},
+		},
+	}
+
+	_, err := NewValidator(targets, []*v1.ValidatingAdmissionPolicy{policy}, nil, nil)
+	assert.ErrorContains(t, err, "failed to compile policy broken-policy")
+}
@@ -2,79 +2,162 @@ package validator
 
 import (
 	"fmt"
-	"os"
-	"strings"
+	"slices"
 
 	"github.com/yashirook/vaptest/pkg/target"
 	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/webhook/predicates/rules"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/utils/ptr"
 )
 
-func matchesExcludeRule(rules []v1.NamedRuleWithOperations, targetInfo *target.TargetInfo) bool {
-	if len(rules) == 0 || rules == nil {
-		return false
-	}
+// defaultNamespace is used as the namespace of a namespaced target without metadata.namespace,
+// as the apiserver fills in the namespace of the request.
+const defaultNamespace = "default"
 
-	return matchesRule(rules, targetInfo)
+// matchesConstraints reports whether the request is matched by the match constraints of a policy, and returns
+// the resource and kind it is matched through, following the matching of the apiserver
+// (k8s.io/apiserver/pkg/admission/plugin/policy/matching). A request matched by any exclude rule is never
+// matched. Otherwise, it is matched when resourceRules is empty or any of the rules matches.
+func matchesConstraints(constraints *v1.MatchResources, attr admission.Attributes, equivalents *target.EquivalentResourceMapper) (bool, schema.GroupVersionResource, schema.GroupVersionKind, error) {
+	if constraints == nil {
+		return true, attr.GetResource(), attr.GetKind(), nil
+	}
+	matchPolicy := constraints.MatchPolicy
+	if matchPolicy == nil {
+		// the apiserver defaults matchPolicy to Equivalent when the policy is created
+		matchPolicy = ptr.To(v1.Equivalent)
+	}
+	if excluded, _, _, err := matchesResourceRules(constraints.ExcludeResourceRules, matchPolicy, attr, equivalents); excluded || err != nil {
+		return false, schema.GroupVersionResource{}, schema.GroupVersionKind{}, err
+	}
+	if len(constraints.ResourceRules) == 0 {
+		return true, attr.GetResource(), attr.GetKind(), nil
+	}
+	return matchesResourceRules(constraints.ResourceRules, matchPolicy, attr, equivalents)
 }
 
-func matchesRule(rules []v1.NamedRuleWithOperations, targetInfo *target.TargetInfo) bool {
-	if len(rules) == 0 || rules == nil {
-		return true
+// matchesResourceRules reports whether any of the rules matches the request. With matchPolicy: Equivalent,
+// a request not matched by the rules is matched when they match an equivalent resource, and the resource and
+// kind of the request are replaced by the equivalent ones.
+func matchesResourceRules(namedRules []v1.NamedRuleWithOperations, matchPolicy *v1.MatchPolicyType, attr admission.Attributes, equivalents *target.EquivalentResourceMapper) (bool, schema.GroupVersionResource, schema.GroupVersionKind, error) {
+	if matchesNamedRules(namedRules, attr) {
+		return true, attr.GetResource(), attr.GetKind(), nil
+	}
+	if *matchPolicy == v1.Exact || equivalents == nil {
+		return false, schema.GroupVersionResource{}, schema.GroupVersionKind{}, nil
 	}
 
-	for _, rule := range rules {
-		if !matchesString(rule.APIGroups, targetInfo.APIGroup) {
-			return false
+	// like the apiserver, rules are tried in order, each against all the equivalent resources
+	resources := equivalents.EquivalentResourcesFor(attr.GetResource(), attr.GetSubresource())
+	for _, namedRule := range namedRules {
+		for _, equivalent := range resources {
+			if equivalent == attr.GetResource() {
+				continue
+			}
+			if !matchesNamedRules([]v1.NamedRuleWithOperations{namedRule}, &attributesWithResource{Attributes: attr, resource: equivalent}) {
+				continue
+			}
+			kind := equivalents.KindFor(equivalent, attr.GetSubresource())
+			if kind.Empty() {
+				return false, schema.GroupVersionResource{}, schema.GroupVersionKind{}, fmt.Errorf("unable to convert to %v: unknown kind", equivalent)
+			}
+			return true, equivalent, kind, nil
 		}
-		if !matchesString(rule.APIVersions, targetInfo.APIVersion) {
-			return false
+	}
+	return false, schema.GroupVersionResource{}, schema.GroupVersionKind{}, nil
+}
+
+// matchesNamedRules reports whether any of the rules matches the request as it is.
+func matchesNamedRules(namedRules []v1.NamedRuleWithOperations, attr admission.Attributes) bool {
+	for _, namedRule := range namedRules {
+		matcher := rules.Matcher{
+			Rule: namedRule.RuleWithOperations,
+			Attr: attr,
 		}
-		if !matchesResource(rule.Resources, targetInfo.Resource, targetInfo.SubResource) {
-			fmt.Fprintf(os.Stderr, "%v resource is unmatch\n", targetInfo.ResourceName)
-			return false
+		if !matcher.Matches() {
+			continue
 		}
-		if len(rule.ResourceNames) > 0 && !matchesString(rule.ResourceNames, targetInfo.ResourceName) {
-			return false
+		// an empty name list always matches
+		if len(namedRule.ResourceNames) == 0 || slices.Contains(namedRule.ResourceNames, attr.GetName()) {
+			return true
 		}
-		// OperationPolicy is not supported.
 	}
+	return false
+}
 
-	return true
+// attributesWithResource overrides the resource of a request, to match rules against an equivalent resource.
+type attributesWithResource struct {
+	admission.Attributes
+	resource schema.GroupVersionResource
 }
 
-func matchesString(patterns []string, value string) bool {
-	if len(patterns) == 0 {
-		// Match if no pattern is specified
-		return true
+func (a *attributesWithResource) GetResource() schema.GroupVersionResource { return a.resource }
+
+// versionedTarget returns the target as seen by a policy matching it through the resource and kind: the
+// object is converted to the kind and the request is for the resource, as the apiserver converts requests
+// before evaluating policies.
+func versionedTarget(t *target.TargetInfo, resource schema.GroupVersionResource, kind schema.GroupVersionKind, equivalents *target.EquivalentResourceMapper) (*target.TargetInfo, error) {
+	if resource == newAdmissionAttributes(t).GetResource() {
+		return t, nil
 	}
-	for _, pattern := range patterns {
-		if pattern == "*" || pattern == value {
-			return true
+	versioned := *t
+	versioned.APIGroup = resource.Group
+	versioned.APIVersion = resource.Version
+	versioned.Resource = resource.Resource
+	if t.Object != nil {
+		obj, err := equivalents.ConvertObject(t.Object, kind)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s to %s: %w", t.ResourceName, kind, err)
 		}
+		versioned.Object = obj
 	}
-	return false
+	return &versioned, nil
 }
 
-func matchesResource(patterns []string, resource string, subResource string) bool {
-	fullResource := resource
-	if subResource != "" {
-		fullResource = resource + "/" + subResource
-	}
-	if len(patterns) == 0 {
-		// Match if no pattern is specified
-		return true
+// newAdmissionAttributes builds the admission request the apiserver would receive for the target.
+func newAdmissionAttributes(targetInfo *target.TargetInfo) admission.Attributes {
+	operation := admission.Operation(targetInfo.Operation)
+	if operation == "" {
+		operation = admission.Create
 	}
-	for _, pattern := range patterns {
-		if pattern == "*" || pattern == resource || pattern == fullResource {
-			return true
+
+	namespace := targetInfo.Namespace
+	switch targetInfo.Scope {
+	case target.ScopeNamespaced:
+		if namespace == "" {
+			namespace = defaultNamespace
 		}
-		// Process patterns with wildcards (e.g., "pods/*")
-		if strings.HasSuffix(pattern, "/*") {
-			baseResource := strings.TrimSuffix(pattern, "/*")
-			if baseResource == resource {
-				return true
-			}
+	case target.ScopeCluster:
+		namespace = ""
+	}
+
+	// The kind of the request is the kind of the admitted object, e.g. autoscaling/v1 Scale for deployments/scale.
+	kind := schema.GroupVersionKind{Group: targetInfo.APIGroup, Version: targetInfo.APIVersion, Kind: targetInfo.Kind}
+	var object runtime.Object
+	if targetInfo.Object != nil {
+		u := &unstructured.Unstructured{Object: targetInfo.Object}
+		if objectKind := u.GroupVersionKind(); !objectKind.Empty() {
+			kind = objectKind
 		}
+		object = u
 	}
-	return false
+
+	return admission.NewAttributesRecord(
+		object,
+		nil,
+		kind,
+		namespace,
+		targetInfo.ResourceName,
+		schema.GroupVersionResource{Group: targetInfo.APIGroup, Version: targetInfo.APIVersion, Resource: targetInfo.Resource},
+		targetInfo.SubResource,
+		operation,
+		nil,
+		false,
+		&user.DefaultInfo{},
+	)
 }
@@ -5,101 +5,557 @@ import (
 
 	"github.com/yashirook/vaptest/pkg/target"
 	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
 )
 
-func TestMatchesRule(t *testing.T) {
+// The cases below mirror the upstream tests of the matching library
+// (k8s.io/apiserver/pkg/admission/plugin/webhook/predicates/rules/rules_test.go and
+// k8s.io/apiserver/pkg/admission/plugin/policy/matching/matching_test.go).
+
+func namedRule(operations []v1.OperationType, groups, versions, resources []string, scope *v1.ScopeType, names ...string) v1.NamedRuleWithOperations {
+	return v1.NamedRuleWithOperations{
+		ResourceNames: names,
+		RuleWithOperations: v1.RuleWithOperations{
+			Operations: operations,
+			Rule: v1.Rule{
+				APIGroups:   groups,
+				APIVersions: versions,
+				Resources:   resources,
+				Scope:       scope,
+			},
+		},
+	}
+}
+
+func allOperations() []v1.OperationType { return []v1.OperationType{v1.OperationAll} }
+
+func a(values ...string) []string { return values }
+
+func namespacedTarget(group, version, resource, subResource, name, operation string) *target.TargetInfo {
+	return &target.TargetInfo{
+		TargetIdentifier: target.TargetIdentifier{
+			APIGroup:     group,
+			APIVersion:   version,
+			Resource:     resource,
+			SubResource:  subResource,
+			ResourceName: name,
+			Namespace:    "ns",
+			Operation:    operation,
+			Scope:        target.ScopeNamespaced,
+		},
+	}
+}
+
+func clusterTarget(group, version, resource, subResource, name, operation string) *target.TargetInfo {
+	return &target.TargetInfo{
+		TargetIdentifier: target.TargetIdentifier{
+			APIGroup:     group,
+			APIVersion:   version,
+			Resource:     resource,
+			SubResource:  subResource,
+			ResourceName: name,
+			Operation:    operation,
+			Scope:        target.ScopeCluster,
+		},
+	}
+}
+
+func TestMatchesResourceRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  v1.NamedRuleWithOperations
+		match []*target.TargetInfo
+		miss  []*target.TargetInfo
+	}{
+		// rules_test.go TestGroup
+		{
+			name: "group: wildcard",
+			rule: namedRule(allOperations(), a("*"), a("*"), a("*"), nil),
+			match: []*target.TargetInfo{
+				namespacedTarget("g1", "v", "r", "", "name", "CREATE"),
+			},
+		},
+		{
+			name: "group: exact",
+			rule: namedRule(allOperations(), a("g1", "g2"), a("*"), a("*"), nil),
+			match: []*target.TargetInfo{
+				namespacedTarget("g1", "v", "r", "", "name", "CREATE"),
+				namespacedTarget("g2", "v2", "r3", "", "name", "CREATE"),
+			},
+			miss: []*target.TargetInfo{
+				namespacedTarget("g3", "v", "r", "", "name", "CREATE"),
+				namespacedTarget("g4", "v", "r", "", "name", "CREATE"),
+			},
+		},
+		// rules_test.go TestVersion
+		{
+			name: "version: wildcard",
+			rule: namedRule(allOperations(), a("*"), a("*"), a("*"), nil),
+			match: []*target.TargetInfo{
+				namespacedTarget("g1", "v", "r", "", "name", "CREATE"),
+			},
+		},
+		{
+			name: "version: exact",
+			rule: namedRule(allOperations(), a("*"), a("v1", "v2"), a("*"), nil),
+			match: []*target.TargetInfo{
+				namespacedTarget("g1", "v1", "r", "", "name", "CREATE"),
+				namespacedTarget("g2", "v2", "r", "", "name", "CREATE"),
+			},
+			miss: []*target.TargetInfo{
+				namespacedTarget("g1", "v3", "r", "", "name", "CREATE"),
+				namespacedTarget("g2", "v4", "r", "", "name", "CREATE"),
+			},
+		},
+		// rules_test.go TestOperation
+		{
+			name: "operation: wildcard",
+			rule: namedRule(allOperations(), a("*"), a("*"), a("*"), nil),
+			match: []*target.TargetInfo{
+				namespacedTarget("g", "v", "r", "", "name", "CREATE"),
+				namespacedTarget("g", "v", "r", "", "name", "UPDATE"),
+				namespacedTarget("g", "v", "r", "", "name", "DELETE"),
+				namespacedTarget("g", "v", "r", "", "name", "CONNECT"),
+			},
+		},
+		{
+			name: "operation: create",
+			rule: namedRule([]v1.OperationType{v1.Create}, a("*"), a("*"), a("*"), nil),
+			match: []*target.TargetInfo{
+				namespacedTarget("g", "v", "r", "", "name", "CREATE"),
+			},
+			miss: []*target.TargetInfo{
+				namespacedTarget("g", "v", "r", "", "name", "UPDATE"),
+				namespacedTarget("g", "v", "r", "", "name", "DELETE"),
+				namespacedTarget("g", "v", "r", "", "name", "CONNECT"),
+			},
+		},
+		{
+			name: "operation: update and delete",
+			rule: namedRule([]v1.OperationType{v1.Update, v1.Delete}, a("*"), a("*"), a("*"), nil),
+			match: []*target.TargetInfo{
+				namespacedTarget("g", "v", "r", "", "name", "UPDATE"),
+				namespacedTarget("g", "v", "r", "", "name", "DELETE"),
+			},
+			miss: []*target.TargetInfo{
+				namespacedTarget("g", "v", "r", "", "name", "CREATE"),
+				namespacedTarget("g", "v", "r", "", "name", "CONNECT"),
+			},
+		},
+		{
+			name: "operation: no operations",
+			rule: namedRule(nil, a("*"), a("*"), a("*"), nil),
+			miss: []*target.TargetInfo{
+				namespacedTarget("g", "v", "r", "", "name", "CREATE"),
+			},
+		},
+		{
+			name: "operation: defaults to create",
+			rule: namedRule([]v1.OperationType{v1.Create}, a("*"), a("*"), a("*"), nil),
+			match: []*target.TargetInfo{
+				namespacedTarget("g", "v", "r", "", "name", ""),
+			},
+		},
+		// rules_test.go TestResource
+		{
+			name: "resource: no subresources",
+			rule: namedRule(allOperations(), a("*"), a("*"), a("*"), nil),
+			match: []*target.TargetInfo{
+				namespacedTarget("2", "v", "r", "", "name", "CREATE"),
+				namespacedTarget("2", "v", "r2", "", "name", "CREATE"),
+			},
+			miss: []*target.TargetInfo{
+				namespacedTarget("g", "v", "r", "exec", "name", "CREATE"),
+				namespacedTarget("2", "v", "r2", "proxy", "name", "CREATE"),
+			},
+		},
+		{
+			name: "resource: r & subresources",
+			rule: namedRule(allOperations(), a("*"), a("*"), a("r/*"), nil),
+			match: []*target.TargetInfo{
+				namespacedTarget("g", "v", "r", "", "name", "CREATE"),
+				namespacedTarget("2", "v", "r", "exec", "name", "CREATE"),
+			},
+			miss: []*target.TargetInfo{
+				namespacedTarget("2", "v", "r2", "", "name", "CREATE"),
+				namespacedTarget("2", "v", "r2", "proxy", "name", "CREATE"),
+			},
+		},
+		{
+			name: "resource: r & subresources or r2",
+			rule: namedRule(allOperations(), a("*"), a("*"), a("r/*", "r2"), nil),
+			match: []*target.TargetInfo{
+				namespacedTarget("g", "v", "r", "", "name", "CREATE"),
+				namespacedTarget("2", "v", "r", "exec", "name", "CREATE"),
+				namespacedTarget("2", "v", "r2", "", "name", "CREATE"),
+			},
+			miss: []*target.TargetInfo{
+				namespacedTarget("2", "v", "r2", "proxy", "name", "CREATE"),
+			},
+		},
+		{
+			name: "resource: proxy or exec",
+			rule: namedRule(allOperations(), a("*"), a("*"), a("*/proxy", "*/exec"), nil),
+			match: []*target.TargetInfo{
+				namespacedTarget("g", "v", "r", "exec", "name", "CREATE"),
+				namespacedTarget("2", "v", "r2", "proxy", "name", "CREATE"),
+				namespacedTarget("2", "v", "r3", "proxy", "name", "CREATE"),
+			},
+			miss: []*target.TargetInfo{
+				namespacedTarget("2", "v", "r", "", "name", "CREATE"),
+				namespacedTarget("2", "v", "r2", "", "name", "CREATE"),
+				namespacedTarget("2", "v", "r4", "scale", "name", "CREATE"),
+			},
+		},
+		{
+			name: "resource: all subresources",
+			rule: namedRule(allOperations(), a("*"), a("*"), a("*/*"), nil),
+			match: []*target.TargetInfo{
+				namespacedTarget("g", "v", "r", "", "name", "CREATE"),
+				namespacedTarget("2", "v", "r", "exec", "name", "CREATE"),
+				namespacedTarget("2", "v", "r2", "status", "name", "CREATE"),
+			},
+		},
+		{
+			name: "resource: status of all resources",
+			rule: namedRule(allOperations(), a("*"), a("*"), a("*/status"), nil),
+			match: []*target.TargetInfo{
+				namespacedTarget("apps", "v1", "deployments", "status", "name", "UPDATE"),
+				namespacedTarget("", "v1", "pods", "status", "name", "UPDATE"),
+			},
+			miss: []*target.TargetInfo{
+				namespacedTarget("apps", "v1", "deployments", "", "name", "UPDATE"),
+				namespacedTarget("apps", "v1", "deployments", "scale", "name", "UPDATE"),
+			},
+		},
+		// rules_test.go TestScope
+		{
+			name: "scope: cluster scope",
+			rule: namedRule(allOperations(), a("*"), a("*"), a("*/*"), ptr.To(v1.ClusterScope)),
+			match: []*target.TargetInfo{
+				clusterTarget("g", "v", "r", "", "name", "CREATE"),
+				clusterTarget("g", "v", "r", "exec", "name", "CREATE"),
+				clusterTarget("", "v1", "namespaces", "", "ns", "CREATE"),
+				clusterTarget("", "v1", "namespaces", "finalize", "ns", "CREATE"),
+				namespacedTarget("", "v1", "namespaces", "", "ns", "CREATE"),
+				namespacedTarget("", "v1", "namespaces", "finalize", "ns", "CREATE"),
+			},
+			miss: []*target.TargetInfo{
+				namespacedTarget("g", "v", "r", "", "name", "CREATE"),
+				namespacedTarget("g", "v", "r", "exec", "name", "CREATE"),
+			},
+		},
+		{
+			name: "scope: namespace scope",
+			rule: namedRule(allOperations(), a("*"), a("*"), a("*/*"), ptr.To(v1.NamespacedScope)),
+			match: []*target.TargetInfo{
+				namespacedTarget("g", "v", "r", "", "name", "CREATE"),
+				namespacedTarget("g", "v", "r", "exec", "name", "CREATE"),
+			},
+			miss: []*target.TargetInfo{
+				clusterTarget("", "v1", "namespaces", "", "ns", "CREATE"),
+				clusterTarget("", "v1", "namespaces", "finalize", "ns", "CREATE"),
+				namespacedTarget("", "v1", "namespaces", "", "ns", "CREATE"),
+				namespacedTarget("", "v1", "namespaces", "finalize", "ns", "CREATE"),
+				clusterTarget("g", "v", "r", "", "name", "CREATE"),
+				clusterTarget("g", "v", "r", "exec", "name", "CREATE"),
+			},
+		},
+		{
+			name: "scope: all scopes",
+			rule: namedRule(allOperations(), a("*"), a("*"), a("*/*"), ptr.To(v1.AllScopes)),
+			match: []*target.TargetInfo{
+				namespacedTarget("g", "v", "r", "", "name", "CREATE"),
+				namespacedTarget("g", "v", "r", "exec", "name", "CREATE"),
+				clusterTarget("g", "v", "r", "", "name", "CREATE"),
+				clusterTarget("g", "v", "r", "exec", "name", "CREATE"),
+				clusterTarget("", "v1", "namespaces", "", "ns", "CREATE"),
+				clusterTarget("", "v1", "namespaces", "finalize", "ns", "CREATE"),
+				namespacedTarget("", "v1", "namespaces", "", "ns", "CREATE"),
+				namespacedTarget("", "v1", "namespaces", "finalize", "ns", "CREATE"),
+			},
+		},
+		{
+			name: "scope: namespaced target without namespace",
+			rule: namedRule(allOperations(), a("*"), a("*"), a("*/*"), ptr.To(v1.NamespacedScope)),
+			match: []*target.TargetInfo{
+				{TargetIdentifier: target.TargetIdentifier{APIGroup: "apps", APIVersion: "v1", Resource: "deployments", Scope: target.ScopeNamespaced}},
+				{TargetIdentifier: target.TargetIdentifier{APIGroup: "example.com", APIVersion: "v1", Resource: "widgets", Namespace: "default"}},
+			},
+			miss: []*target.TargetInfo{
+				{TargetIdentifier: target.TargetIdentifier{APIGroup: "example.com", APIVersion: "v1", Resource: "widgets"}},
+			},
+		},
+		// matching_test.go TestMatcher
+		{
+			name: "resourceNames: name match",
+			rule: namedRule(allOperations(), a("apps"), a("v1"), a("deployments"), nil, "name1"),
+			match: []*target.TargetInfo{
+				namespacedTarget("apps", "v1", "deployments", "", "name1", "CREATE"),
+			},
+			miss: []*target.TargetInfo{
+				namespacedTarget("apps", "v1", "deployments", "", "wrong-name", "CREATE"),
+			},
+		},
+		{
+			name: "resourceNames: subresource name match",
+			rule: namedRule(allOperations(), a("apps"), a("v1"), a("deployments/scale"), nil, "name1"),
+			match: []*target.TargetInfo{
+				namespacedTarget("apps", "v1", "deployments", "scale", "name1", "UPDATE"),
+			},
+			miss: []*target.TargetInfo{
+				namespacedTarget("apps", "v1", "deployments", "", "name1", "UPDATE"),
+			},
+		},
+		{
+			name: "empty rule",
+			rule: v1.NamedRuleWithOperations{},
+			miss: []*target.TargetInfo{
+				namespacedTarget("apps", "v1", "deployments", "", "name", "CREATE"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, ti := range tt.match {
+				if !matchesNamedRules([]v1.NamedRuleWithOperations{tt.rule}, newAdmissionAttributes(ti)) {
+					t.Errorf("expected %+v to match", ti.TargetIdentifier)
+				}
+			}
+			for _, ti := range tt.miss {
+				if matchesNamedRules([]v1.NamedRuleWithOperations{tt.rule}, newAdmissionAttributes(ti)) {
+					t.Errorf("expected %+v not to match", ti.TargetIdentifier)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchesConstraints(t *testing.T) {
+	deployments := namedRule(allOperations(), a("apps"), a("v1"), a("deployments"), nil)
+	pods := namedRule(allOperations(), a(""), a("v1"), a("pods"), nil)
+	wildcard := namedRule(allOperations(), a("*"), a("*"), a("*"), nil)
+
 	tests := []struct {
-		name       string
-		rules      []v1.NamedRuleWithOperations
-		targetInfo *target.TargetInfo
-		want       bool
+		name        string
+		constraints *v1.MatchResources
+		targetInfo  *target.TargetInfo
+		want        bool
 	}{
 		{
-			name:  "Empty rules",
-			rules: []v1.NamedRuleWithOperations{},
-			targetInfo: &target.TargetInfo{
-				TargetIdentifier: target.TargetIdentifier{
-					APIGroup:   "apps",
-					APIVersion: "v1",
-					Resource:   "deployments",
-				},
-			},
-			want: true,
-		},
-		{
-			name: "Exact match",
-			rules: []v1.NamedRuleWithOperations{
-				{
-					RuleWithOperations: v1.RuleWithOperations{
-						Rule: v1.Rule{
-							APIGroups:   []string{"apps"},
-							APIVersions: []string{"v1"},
-							Resources:   []string{"deployments"},
-						},
-					},
-				},
-			},
-			targetInfo: &target.TargetInfo{
-				TargetIdentifier: target.TargetIdentifier{
-					APIGroup:   "apps",
-					APIVersion: "v1",
-					Resource:   "deployments",
-				},
-			},
-			want: true,
-		},
-		{
-			name: "Wildcard match",
-			rules: []v1.NamedRuleWithOperations{
-				{
-					RuleWithOperations: v1.RuleWithOperations{
-						Rule: v1.Rule{
-							APIGroups:   []string{"*"},
-							APIVersions: []string{"*"},
-							Resources:   []string{"*"},
-						},
-					},
-				},
-			},
-			targetInfo: &target.TargetInfo{
-				TargetIdentifier: target.TargetIdentifier{
-					APIGroup:   "apps",
-					APIVersion: "v1",
-					Resource:   "deployments",
-				},
-			},
-			want: true,
-		},
-		{
-			name: "No match",
-			rules: []v1.NamedRuleWithOperations{
-				{
-					RuleWithOperations: v1.RuleWithOperations{
-						Rule: v1.Rule{
-							APIGroups:   []string{"core"},
-							APIVersions: []string{"v1"},
-							Resources:   []string{"pods"},
-						},
-					},
-				},
-			},
-			targetInfo: &target.TargetInfo{
-				TargetIdentifier: target.TargetIdentifier{
-					APIGroup:   "apps",
-					APIVersion: "v1",
-					Resource:   "deployments",
-				},
-			},
-			want: false,
-		},
-		// Additional test cases can be described here
+			name:        "nil constraints",
+			constraints: nil,
+			targetInfo:  namespacedTarget("apps", "v1", "deployments", "", "name", "CREATE"),
+			want:        true,
+		},
+		{
+			name:        "empty resourceRules",
+			constraints: &v1.MatchResources{},
+			targetInfo:  namespacedTarget("apps", "v1", "deployments", "", "name", "CREATE"),
+			want:        true,
+		},
+		{
+			name:        "wildcard match",
+			constraints: &v1.MatchResources{ResourceRules: []v1.NamedRuleWithOperations{wildcard}},
+			targetInfo:  namespacedTarget("apps", "v1", "deployments", "", "name", "CREATE"),
+			want:        true,
+		},
+		{
+			name:        "match miss",
+			constraints: &v1.MatchResources{ResourceRules: []v1.NamedRuleWithOperations{pods}},
+			targetInfo:  namespacedTarget("apps", "v1", "deployments", "", "name", "CREATE"),
+			want:        false,
+		},
+		{
+			name:        "rules are OR'ed: second rule matches",
+			constraints: &v1.MatchResources{ResourceRules: []v1.NamedRuleWithOperations{pods, deployments}},
+			targetInfo:  namespacedTarget("apps", "v1", "deployments", "", "name", "CREATE"),
+			want:        true,
+		},
+		{
+			name:        "rules are OR'ed: first rule matches",
+			constraints: &v1.MatchResources{ResourceRules: []v1.NamedRuleWithOperations{pods, deployments}},
+			targetInfo:  namespacedTarget("", "v1", "pods", "", "name", "CREATE"),
+			want:        true,
+		},
+		{
+			name: "exclude match",
+			constraints: &v1.MatchResources{
+				ResourceRules:        []v1.NamedRuleWithOperations{wildcard},
+				ExcludeResourceRules: []v1.NamedRuleWithOperations{deployments},
+			},
+			targetInfo: namespacedTarget("apps", "v1", "deployments", "", "name", "CREATE"),
+			want:       false,
+		},
+		{
+			name: "exclude miss",
+			constraints: &v1.MatchResources{
+				ResourceRules:        []v1.NamedRuleWithOperations{wildcard},
+				ExcludeResourceRules: []v1.NamedRuleWithOperations{pods},
+			},
+			targetInfo: namespacedTarget("apps", "v1", "deployments", "", "name", "CREATE"),
+			want:       true,
+		},
+		{
+			name: "exclude any of the rules",
+			constraints: &v1.MatchResources{
+				ResourceRules:        []v1.NamedRuleWithOperations{wildcard},
+				ExcludeResourceRules: []v1.NamedRuleWithOperations{pods, deployments},
+			},
+			targetInfo: namespacedTarget("apps", "v1", "deployments", "", "name", "CREATE"),
+			want:       false,
+		},
+		{
+			name: "exclude with empty resourceRules",
+			constraints: &v1.MatchResources{
+				ExcludeResourceRules: []v1.NamedRuleWithOperations{deployments},
+			},
+			targetInfo: namespacedTarget("apps", "v1", "deployments", "", "name", "CREATE"),
+			want:       false,
+		},
+		{
+			name: "exclude by name",
+			constraints: &v1.MatchResources{
+				ResourceRules:        []v1.NamedRuleWithOperations{wildcard},
+				ExcludeResourceRules: []v1.NamedRuleWithOperations{namedRule(allOperations(), a("apps"), a("v1"), a("deployments"), nil, "name1")},
+			},
+			targetInfo: namespacedTarget("apps", "v1", "deployments", "", "name2", "CREATE"),
+			want:       true,
+		},
+		{
+			name: "exclude subresource",
+			constraints: &v1.MatchResources{
+				ResourceRules:        []v1.NamedRuleWithOperations{namedRule(allOperations(), a("*"), a("*"), a("*/*"), nil)},
+				ExcludeResourceRules: []v1.NamedRuleWithOperations{namedRule(allOperations(), a("*"), a("*"), a("*/status"), nil)},
+			},
+			targetInfo: namespacedTarget("apps", "v1", "deployments", "status", "name", "UPDATE"),
+			want:       false,
+		},
+		{
+			name: "operation miss",
+			constraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{namedRule([]v1.OperationType{v1.Create}, a("*"), a("*"), a("*"), nil)},
+			},
+			targetInfo: namespacedTarget("apps", "v1", "deployments", "", "name", "DELETE"),
+			want:       false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := matchesRule(tt.rules, tt.targetInfo); got != tt.want {
-				t.Errorf("matchesRule() = %v, want %v", got, tt.want)
+			got, _, _, err := matchesConstraints(tt.constraints, newAdmissionAttributes(tt.targetInfo), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("matchesConstraints() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestMatchesConstraintsEquivalent(t *testing.T) {
+	equivalents := equivalentResourceMapper(nil, nil)
+	deployments := namedRule(allOperations(), a("apps"), a("v1"), a("deployments"), nil)
+	scale := namedRule(allOperations(), a("apps"), a("v1"), a("deployments/scale"), nil)
+
+	tests := []struct {
+		name         string
+		matchPolicy  v1.MatchPolicyType
+		rule         v1.NamedRuleWithOperations
+		exclude      bool
+		targetInfo   *target.TargetInfo
+		want         bool
+		wantResource schema.GroupVersionResource
+		wantKind     schema.GroupVersionKind
+	}{
+		{
+			name:         "exact match",
+			matchPolicy:  v1.Equivalent,
+			rule:         deployments,
+			targetInfo:   withKind(namespacedTarget("apps", "v1", "deployments", "", "name", "CREATE"), "Deployment"),
+			want:         true,
+			wantResource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+			wantKind:     schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		},
+		{
+			name:         "other version",
+			matchPolicy:  v1.Equivalent,
+			rule:         deployments,
+			targetInfo:   namespacedTarget("apps", "v1beta2", "deployments", "", "name", "CREATE"),
+			want:         true,
+			wantResource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+			wantKind:     schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		},
+		{
+			name:         "other group",
+			matchPolicy:  v1.Equivalent,
+			rule:         deployments,
+			targetInfo:   namespacedTarget("extensions", "v1beta1", "deployments", "", "name", "CREATE"),
+			want:         true,
+			wantResource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+			wantKind:     schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		},
+		{
+			name:         "subresource of other version",
+			matchPolicy:  v1.Equivalent,
+			rule:         scale,
+			targetInfo:   namespacedTarget("apps", "v1beta2", "deployments", "scale", "name", "UPDATE"),
+			want:         true,
+			wantResource: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+			wantKind:     schema.GroupVersionKind{Group: "autoscaling", Version: "v1", Kind: "Scale"},
+		},
+		{
+			name:        "other version with Exact",
+			matchPolicy: v1.Exact,
+			rule:        deployments,
+			targetInfo:  namespacedTarget("extensions", "v1beta1", "deployments", "", "name", "CREATE"),
+			want:        false,
+		},
+		{
+			name:        "other resource",
+			matchPolicy: v1.Equivalent,
+			rule:        deployments,
+			targetInfo:  namespacedTarget("apps", "v1beta2", "replicasets", "", "name", "CREATE"),
+			want:        false,
+		},
+		{
+			name:        "excluded through other version",
+			matchPolicy: v1.Equivalent,
+			rule:        deployments,
+			exclude:     true,
+			targetInfo:  namespacedTarget("extensions", "v1beta1", "deployments", "", "name", "CREATE"),
+			want:        false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			constraints := &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{tt.rule},
+				MatchPolicy:   &tt.matchPolicy,
+			}
+			if tt.exclude {
+				constraints.ResourceRules = []v1.NamedRuleWithOperations{namedRule(allOperations(), a("*"), a("*"), a("*"), nil)}
+				constraints.ExcludeResourceRules = []v1.NamedRuleWithOperations{tt.rule}
+			}
+			got, resource, kind, err := matchesConstraints(constraints, newAdmissionAttributes(tt.targetInfo), equivalents)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("matchesConstraints() = %v, want %v", got, tt.want)
+			}
+			if tt.want && (resource != tt.wantResource || kind != tt.wantKind) {
+				t.Errorf("matchesConstraints() matched %v %v, want %v %v", resource, kind, tt.wantResource, tt.wantKind)
+			}
+		})
+	}
+}
+
+func withKind(ti *target.TargetInfo, kind string) *target.TargetInfo {
+	ti.Kind = kind
+	return ti
+}
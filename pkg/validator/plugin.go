@@ -0,0 +1,176 @@
+package validator
+
+import (
+	"context"
+	"sync"
+
+	v1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	plugincel "k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/admission/plugin/policy/generic"
+	"k8s.io/apiserver/pkg/admission/plugin/policy/matching"
+	"k8s.io/apiserver/pkg/admission/plugin/policy/validating"
+	"k8s.io/apiserver/pkg/admission/plugin/webhook/matchconditions"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/cel/environment"
+	"k8s.io/apiserver/pkg/features"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+)
+
+// newPlugin returns the ValidatingAdmissionPolicy admission plugin as built by validating.NewPlugin, except that
+// the validators of policies report their results to the evaluationRecorder of the request context. The results
+// tell whether a policy was evaluated and how each validation was decided, which the errors, warnings and audit
+// annotations returned by the plugin only tell in their messages.
+func newPlugin() *validating.Plugin {
+	handler := admission.NewHandler(admission.Connect, admission.Create, admission.Delete, admission.Update)
+	return &validating.Plugin{
+		Plugin: generic.NewPlugin(
+			handler,
+			func(f informers.SharedInformerFactory, client kubernetes.Interface, dynamicClient dynamic.Interface, restMapper meta.RESTMapper) generic.Source[validating.PolicyHook] {
+				return generic.NewPolicySource(
+					f.Admissionregistration().V1().ValidatingAdmissionPolicies().Informer(),
+					f.Admissionregistration().V1().ValidatingAdmissionPolicyBindings().Informer(),
+					validating.NewValidatingAdmissionPolicyAccessor,
+					validating.NewValidatingAdmissionPolicyBindingAccessor,
+					compilePluginPolicy,
+					f,
+					dynamicClient,
+					restMapper,
+				)
+			},
+			func(a authorizer.Authorizer, m *matching.Matcher) generic.Dispatcher[validating.PolicyHook] {
+				return validating.NewDispatcher(a, generic.NewPolicyMatcher(m))
+			},
+		),
+	}
+}
+
+// compositionEnvTemplate returns the CEL environment of policies with variables, with or without strict cost
+// enforcement.
+var compositionEnvTemplate = func() func(strictCost bool) *plugincel.CompositionEnv {
+	newTemplate := func(strictCost bool) func() *plugincel.CompositionEnv {
+		return sync.OnceValue(func() *plugincel.CompositionEnv {
+			env, err := plugincel.NewCompositionEnv(plugincel.VariablesTypeName, environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion(), strictCost))
+			if err != nil {
+				panic(err)
+			}
+			return env
+		})
+	}
+	withStrictCost, withoutStrictCost := newTemplate(true), newTemplate(false)
+	return func(strictCost bool) *plugincel.CompositionEnv {
+		if strictCost {
+			return withStrictCost()
+		}
+		return withoutStrictCost()
+	}
+}()
+
+// compilePluginPolicy compiles the policy as the plugin does (compilePolicy in
+// k8s.io/apiserver/pkg/admission/plugin/policy/validating) into a validator recording its results.
+func compilePluginPolicy(policy *v1.ValidatingAdmissionPolicy) validating.Validator {
+	hasParam := policy.Spec.ParamKind != nil
+	strictCost := utilfeature.DefaultFeatureGate.Enabled(features.StrictCostEnforcementForVAP)
+	optionalVars := plugincel.OptionalVariableDeclarations{HasParams: hasParam, HasAuthorizer: true, StrictCost: strictCost}
+	expressionOptionalVars := plugincel.OptionalVariableDeclarations{HasParams: hasParam, HasAuthorizer: false, StrictCost: strictCost}
+	failurePolicy := policy.Spec.FailurePolicy
+
+	filterCompiler := plugincel.NewCompositedCompilerFromTemplate(compositionEnvTemplate(strictCost))
+	variables := make([]plugincel.NamedExpressionAccessor, len(policy.Spec.Variables))
+	for i, variable := range policy.Spec.Variables {
+		variables[i] = &validating.Variable{Name: variable.Name, Expression: variable.Expression}
+	}
+	filterCompiler.CompileAndStoreVariables(variables, optionalVars, environment.StoredExpressions)
+
+	var matcher matchconditions.Matcher
+	if matchConditions := policy.Spec.MatchConditions; len(matchConditions) > 0 {
+		accessors := make([]plugincel.ExpressionAccessor, len(matchConditions))
+		for i := range matchConditions {
+			accessors[i] = (*matchconditions.MatchCondition)(&matchConditions[i])
+		}
+		matcher = matchconditions.NewMatcher(filterCompiler.Compile(accessors, optionalVars, environment.StoredExpressions), failurePolicy, "policy", "validate", policy.Name)
+	}
+
+	validations := make([]plugincel.ExpressionAccessor, len(policy.Spec.Validations))
+	messageExpressions := make([]plugincel.ExpressionAccessor, len(policy.Spec.Validations))
+	for i, validation := range policy.Spec.Validations {
+		validations[i] = &validating.ValidationCondition{Expression: validation.Expression, Message: validation.Message, Reason: validation.Reason}
+		if validation.MessageExpression != "" {
+			messageExpressions[i] = &validating.MessageExpressionCondition{MessageExpression: validation.MessageExpression}
+		}
+	}
+	auditAnnotations := make([]plugincel.ExpressionAccessor, len(policy.Spec.AuditAnnotations))
+	for i, annotation := range policy.Spec.AuditAnnotations {
+		auditAnnotations[i] = &validating.AuditAnnotationCondition{Key: annotation.Key, ValueExpression: annotation.ValueExpression}
+	}
+
+	return &recordingValidator{Validator: validating.NewValidator(
+		filterCompiler.Compile(validations, optionalVars, environment.StoredExpressions),
+		matcher,
+		filterCompiler.Compile(auditAnnotations, optionalVars, environment.StoredExpressions),
+		filterCompiler.Compile(messageExpressions, expressionOptionalVars, environment.StoredExpressions),
+		failurePolicy,
+	)}
+}
+
+// recordingValidator passes the results of a validator to the evaluationRecorder of the request context.
+type recordingValidator struct {
+	validating.Validator
+}
+
+func (v *recordingValidator) Validate(ctx context.Context, matchedResource schema.GroupVersionResource, versionedAttr *admission.VersionedAttributes, versionedParams runtime.Object, namespace *corev1.Namespace, runtimeCELCostBudget int64, authz authorizer.Authorizer) validating.ValidateResult {
+	result := v.Validator.Validate(ctx, matchedResource, versionedAttr, versionedParams, namespace, runtimeCELCostBudget, authz)
+	if recorder, ok := ctx.Value(evaluationRecorderKey{}).(*evaluationRecorder); ok {
+		recorder.results = append(recorder.results, result)
+	}
+	return result
+}
+
+// evaluationRecorder records the results of the validators run for a request, one for each binding and param.
+type evaluationRecorder struct {
+	results []validating.ValidateResult
+}
+
+type evaluationRecorderKey struct{}
+
+func withEvaluationRecorder(ctx context.Context, recorder *evaluationRecorder) context.Context {
+	return context.WithValue(ctx, evaluationRecorderKey{}, recorder)
+}
+
+// evaluated reports whether a validator evaluated the validations or audit annotations of the policy, that is,
+// the policy and a binding matched the request and so did the match conditions, or they failed to evaluate.
+func (r *evaluationRecorder) evaluated() bool {
+	for _, result := range r.results {
+		if len(result.Decisions) > 0 || len(result.AuditAnnotations) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// denied returns the first decision to deny the request, with the key of the audit annotation it is about if
+// the valueExpression of an audit annotation failed to evaluate.
+func (r *evaluationRecorder) denied() (validating.PolicyDecision, string, bool) {
+	for _, result := range r.results {
+		for _, decision := range result.Decisions {
+			if decision.Action == validating.ActionDeny {
+				return decision, "", true
+			}
+		}
+	}
+	for _, result := range r.results {
+		for _, annotation := range result.AuditAnnotations {
+			if annotation.Action == validating.AuditAnnotationActionError {
+				return validating.PolicyDecision{Action: validating.ActionDeny, Evaluation: validating.EvalError, Message: annotation.Error}, annotation.Key, true
+			}
+		}
+	}
+	return validating.PolicyDecision{}, "", false
+}
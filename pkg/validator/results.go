@@ -1,6 +1,10 @@
 package validator
 
-import "github.com/yashirook/vaptest/pkg/target"
+import (
+	"github.com/yashirook/vaptest/pkg/target"
+	v1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
 
 type PolicyIdentifier struct {
 	PolicyName string `json:"name"`
@@ -12,15 +16,45 @@ type ValidationResult struct {
 	Success          bool                    `json:"success"`
 	IsValidated      bool                    `json:"isValidated"`
 	ValidationErrors []ValidationError       `json:"validationErrors,omitempty"`
+	// DefaultedFields are the fields of the target set by API defaulting before evaluation.
+	DefaultedFields []string `json:"defaultedFields,omitempty"`
+	// Warnings are the warnings returned to the client for the Warn validation action.
+	Warnings []string `json:"warnings,omitempty"`
+	// AuditAnnotations are the audit annotations published by the policy, keyed without the policy name prefix.
+	AuditAnnotations map[string]string `json:"auditAnnotations,omitempty"`
 }
 
 type ValidationError struct {
+	// Index is the index of the validation in spec.validations,
+	// or -1 for an audit annotation that could not be evaluated.
+	Index   int    `json:"index"`
 	Message string `json:"message"`
 	CELExpr string `json:"celExpression"`
+	// Actions are the validationActions of the bindings the failure was reported with.
+	// The native engine ignores bindings and leaves them empty.
+	Actions []v1.ValidationAction `json:"actions,omitempty"`
+	// EvaluationError reports that the validation could not be evaluated, e.g. a CEL runtime error,
+	// and failed by the failurePolicy of the policy instead of evaluating to false.
+	EvaluationError bool `json:"evaluationError,omitempty"`
+	// Reason and Code are the status of the request denied by the validation.
+	// They are empty unless the validation failed with the Deny validation action.
+	Reason metav1.StatusReason `json:"reason,omitempty"`
+	Code   int32               `json:"code,omitempty"`
 }
 
 type ValidationResultList []ValidationResult
 
+// FindResult returns the result of the policy among the results of a target, or nil if the policy was not
+// evaluated.
+func FindResult(results []ValidationResult, policyName string) *ValidationResult {
+	for i := range results {
+		if results[i].Policy.PolicyName == policyName {
+			return &results[i]
+		}
+	}
+	return nil
+}
+
 func (v ValidationResultList) SuccessResults() ValidationResultList {
 	successResults := make(ValidationResultList, 0)
 	for _, result := range v {
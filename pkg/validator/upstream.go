@@ -0,0 +1,654 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yashirook/vaptest/pkg/coverage"
+	"github.com/yashirook/vaptest/pkg/target"
+	v1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/policy/generic"
+	"k8s.io/apiserver/pkg/admission/plugin/policy/validating"
+	auditinternal "k8s.io/apiserver/pkg/apis/audit"
+	"k8s.io/apiserver/pkg/authorization/authorizerfactory"
+	"k8s.io/apiserver/pkg/warning"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// validationFailureAnnotation is the audit annotation the plugin publishes for the Audit validation action.
+	validationFailureAnnotation = "validation.policy.admission.k8s.io/validation_failure"
+)
+
+// Engine evaluates policies against targets.
+type Engine interface {
+	ValidateContext(ctx context.Context) ([]ValidationResult, error)
+}
+
+var (
+	_ Engine = &Validator{}
+	_ Engine = &UpstreamValidator{}
+)
+
+// UpstreamValidator evaluates policies with the ValidatingAdmissionPolicy admission plugin of the apiserver
+// (k8s.io/apiserver/pkg/admission/plugin/policy/validating). The plugin is backed by in-memory informers fed
+// from the policies, the bindings and the namespaces of the targets.
+//
+// Unlike Validator, a policy is evaluated only through its bindings, as in a cluster: a policy without a
+// matching binding is not evaluated, and the matchResources, paramRef and validationActions of bindings apply.
+// A validation failing with any validation action (Deny, Warn or Audit) is reported as a ValidationError.
+type UpstreamValidator struct {
+	TargetInfoList []target.TargetInfo
+	Policies       []*v1.ValidatingAdmissionPolicy
+	PolicyBindings []*v1.ValidatingAdmissionPolicyBinding
+	Scheme         *runtime.Scheme
+	// Params are the resources referenced by the paramRef of bindings, such as ConfigMaps or custom resources.
+	Params []runtime.Object
+	// RESTMapper resolves the paramKind of policies. Nil means the built-in API catalog.
+	RESTMapper meta.RESTMapper
+	// Coverage, if set, records how often each match condition, variable and validation of the policies
+	// evaluated to true, false or an error through each binding. The expressions are evaluated again in
+	// process for the requests the plugin matches to a policy and binding.
+	Coverage *coverage.Profile
+}
+
+func NewUpstreamValidator(targets target.TargetInfoList, policies []*v1.ValidatingAdmissionPolicy, policyBindings []*v1.ValidatingAdmissionPolicyBinding, scheme *runtime.Scheme) (UpstreamValidator, error) {
+	if len(targets) == 0 {
+		return UpstreamValidator{}, errors.New("target objects is empty")
+	}
+
+	if len(policies) == 0 {
+		return UpstreamValidator{}, errors.New("policies is empty")
+	}
+
+	return UpstreamValidator{
+		TargetInfoList: targets,
+		Policies:       policies,
+		PolicyBindings: policyBindings,
+		Scheme:         scheme,
+	}, nil
+}
+
+func (v *UpstreamValidator) Validate() ([]ValidationResult, error) {
+	return v.ValidateContext(context.Background())
+}
+
+// ValidateContext admits every target to the plugin and converts its decisions to results, in policy order,
+// then target order.
+func (v *UpstreamValidator) ValidateContext(ctx context.Context) ([]ValidationResult, error) {
+	perTarget, err := v.ValidatePerTargetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ValidationResult, 0)
+	for _, policy := range v.Policies {
+		for _, targetResults := range perTarget {
+			for _, result := range targetResults {
+				if result.Policy.PolicyName == policy.Name {
+					results = append(results, result)
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+// ValidatePerTargetContext returns the results of each target of TargetInfoList, in policy order, so that
+// targets with the same identity, such as variants of a manifest, can be told apart. The plugin only reports
+// the first denial of a request, so each validation of a policy is run by its own plugin instance to find all
+// failing validations.
+func (v *UpstreamValidator) ValidatePerTargetContext(ctx context.Context) ([][]ValidationResult, error) {
+	session, err := v.Start()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Stop()
+	return session.Evaluate(ctx, v.TargetInfoList)
+}
+
+// UpstreamSession holds the started plugins of an UpstreamValidator, which evaluate any number of targets
+// until the session is stopped. Starting the plugins takes most of the time of an evaluation, so a session
+// saves it when targets are evaluated one at a time, e.g. from go test. A session is safe for concurrent use.
+type UpstreamSession struct {
+	params   []runtime.Object
+	stopCh   chan struct{}
+	policies []upstreamPolicy
+	trackers []*coverageTracker
+	// pluginNamespaces are the namespaces of the plugins and matcherNamespaces those of the coverage matcher,
+	// which the namespaces of targets are added to as they are evaluated.
+	pluginNamespaces  []*pluginNamespaces
+	matcherNamespaces cache.Indexer
+	matcher           generic.PolicyMatcher
+	objectInterfaces  admission.ObjectInterfaces
+
+	mu         sync.Mutex
+	namespaces map[string]bool
+}
+
+// upstreamPolicy is a policy with the plugins running its splits.
+type upstreamPolicy struct {
+	policy  *v1.ValidatingAdmissionPolicy
+	splits  []*v1.ValidatingAdmissionPolicy
+	plugins []*validating.Plugin
+}
+
+// Start starts the plugins of the policies and their bindings and returns the session evaluating targets with
+// them. The policies, bindings, params and coverage profile of the validator are those when it is started.
+func (v *UpstreamValidator) Start() (*UpstreamSession, error) {
+	restMapper := v.RESTMapper
+	if restMapper == nil {
+		restMapper = target.StaticRESTMapper(v.Scheme)
+	}
+	restMapper = paramRESTMapper(restMapper, v.Params)
+	namespaces := targetNamespaces(v.TargetInfoList)
+	s := &UpstreamSession{
+		params:           v.Params,
+		stopCh:           make(chan struct{}),
+		objectInterfaces: newObjectInterfaces(v.Scheme, equivalentResourceMapper(restMapper, v.Scheme)),
+		namespaces:       map[string]bool{},
+	}
+	for _, ns := range namespaces {
+		s.namespaces[ns.Name] = true
+	}
+
+	var starts []func() (*pluginNamespaces, error)
+	for _, policy := range v.Policies {
+		bindings := v.bindingsOf(policy.Name)
+		if len(bindings) == 0 {
+			// バインディングのないポリシーはクラスタでは評価されない
+			continue
+		}
+		p := upstreamPolicy{
+			policy: policy,
+			splits: splitValidations(v.defaulted(policy).(*v1.ValidatingAdmissionPolicy)),
+		}
+		p.plugins = make([]*validating.Plugin, len(p.splits))
+		for i, split := range p.splits {
+			starts = append(starts, func() (*pluginNamespaces, error) {
+				plugin, pluginNamespaces, err := startPlugin(split, bindings, namespaces, v.Params, v.Scheme, restMapper, s.stopCh)
+				p.plugins[i] = plugin
+				return pluginNamespaces, err
+			})
+		}
+		s.policies = append(s.policies, p)
+	}
+	if v.Coverage != nil {
+		for _, policy := range v.Policies {
+			s.trackers = append(s.trackers, newCoverageTracker(v.Coverage, v.defaulted(policy).(*v1.ValidatingAdmissionPolicy), v.bindingsOf(policy.Name)))
+		}
+	}
+	s.matcher, s.matcherNamespaces = newCoverageMatcher(namespaces)
+
+	// Starting a plugin mostly waits for its informers to sync, so all of them are started at once.
+	s.pluginNamespaces = make([]*pluginNamespaces, len(starts))
+	errs := make([]error, len(starts))
+	var wg sync.WaitGroup
+	for i, start := range starts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.pluginNamespaces[i], errs[i] = start()
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		close(s.stopCh)
+		return nil, err
+	}
+	return s, nil
+}
+
+// Stop stops the plugins of the session.
+func (s *UpstreamSession) Stop() {
+	close(s.stopCh)
+}
+
+// Evaluate returns the results of each target, in policy order.
+func (s *UpstreamSession) Evaluate(ctx context.Context, targets []target.TargetInfo) ([][]ValidationResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.addNamespaces(ctx, targets); err != nil {
+		return nil, err
+	}
+
+	results := make([][]ValidationResult, len(targets))
+	for i := range targets {
+		t := &targets[i]
+		results[i] = make([]ValidationResult, 0)
+		for _, p := range s.policies {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			validated := false
+			success := true
+			validationErrors := make([]ValidationError, 0)
+			var warnings []string
+			var annotations map[string]string
+			for j, plugin := range p.plugins {
+				decision, err := admit(ctx, plugin, p.splits[j], t, s.objectInterfaces)
+				if err != nil {
+					return nil, fmt.Errorf("failed to evaluate policy %s: %w", p.policy.Name, err)
+				}
+				if !decision.evaluated {
+					continue
+				}
+				validated = true
+				warnings = append(warnings, decision.warnings...)
+				if j == len(p.policy.Spec.Validations) {
+					// 監査アノテーションの評価。設定エラーは各バリデーションで報告済み
+					annotations = decision.annotations
+					if decision.failed && (!decision.configurationError || len(p.policy.Spec.Validations) == 0) {
+						success = false
+						validationErrors = append(validationErrors, decision.validationError(-1, auditAnnotationExpression(p.policy, decision.auditAnnotation)))
+					}
+					continue
+				}
+				if decision.failed {
+					success = false
+					validationErrors = append(validationErrors, decision.validationError(j, p.policy.Spec.Validations[j].Expression))
+				}
+			}
+			if validated {
+				result := newResult(success, validated, p.policy, *t, validationErrors)
+				result.Warnings = warnings
+				result.AuditAnnotations = annotations
+				results[i] = append(results[i], result)
+			}
+		}
+		for _, tracker := range s.trackers {
+			tracker.track(newAdmissionAttributes(t), s.objectInterfaces, s.matcher, s.params)
+		}
+	}
+	return results, nil
+}
+
+// addNamespaces adds the namespaces of the targets unknown to the plugins. A namespace keeps the labels it had
+// when first added.
+func (s *UpstreamSession) addNamespaces(ctx context.Context, targets []target.TargetInfo) error {
+	for _, ns := range targetNamespaces(targets) {
+		if s.namespaces[ns.Name] {
+			continue
+		}
+		for _, n := range s.pluginNamespaces {
+			if err := n.add(ctx, ns); err != nil {
+				return fmt.Errorf("failed to add namespace %s: %w", ns.Name, err)
+			}
+		}
+		if err := s.matcherNamespaces.Add(ns); err != nil {
+			return fmt.Errorf("failed to add namespace %s: %w", ns.Name, err)
+		}
+		s.namespaces[ns.Name] = true
+	}
+	return nil
+}
+
+// pluginNamespaces are the namespaces served to a plugin by its client and its informer.
+type pluginNamespaces struct {
+	client *fake.Clientset
+	lister corelisters.NamespaceLister
+}
+
+// add creates the namespace and waits until the informer of the plugin has it, as the plugin looks up the
+// namespaces of requests in its informer.
+func (n *pluginNamespaces) add(ctx context.Context, ns *corev1.Namespace) error {
+	if _, err := n.client.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	return wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 10*time.Second, true, func(context.Context) (bool, error) {
+		_, err := n.lister.Get(ns.Name)
+		return err == nil, nil
+	})
+}
+
+// bindingsOf returns defaulted copies of the bindings of the policy.
+func (v *UpstreamValidator) bindingsOf(policyName string) []*v1.ValidatingAdmissionPolicyBinding {
+	var bindings []*v1.ValidatingAdmissionPolicyBinding
+	for _, binding := range v.PolicyBindings {
+		if binding.Spec.PolicyName == policyName {
+			bindings = append(bindings, v.defaulted(binding).(*v1.ValidatingAdmissionPolicyBinding))
+		}
+	}
+	return bindings
+}
+
+// defaulted returns a copy of the object with API defaulting applied, as stored by the apiserver.
+// The plugin relies on defaults such as the empty label selectors of matchResources.
+func (v *UpstreamValidator) defaulted(obj runtime.Object) runtime.Object {
+	obj = obj.DeepCopyObject()
+	if v.Scheme != nil {
+		v.Scheme.Default(obj)
+	}
+	return obj
+}
+
+// splitValidations returns a copy of the policy for each of its validations, without audit annotations.
+// The audit annotations are evaluated by one more copy without validations, added last.
+func splitValidations(policy *v1.ValidatingAdmissionPolicy) []*v1.ValidatingAdmissionPolicy {
+	splits := make([]*v1.ValidatingAdmissionPolicy, 0, len(policy.Spec.Validations)+1)
+	for _, validation := range policy.Spec.Validations {
+		split := policy.DeepCopy()
+		split.Spec.Validations = []v1.Validation{validation}
+		split.Spec.AuditAnnotations = nil
+		splits = append(splits, split)
+	}
+	if len(policy.Spec.AuditAnnotations) > 0 {
+		split := policy.DeepCopy()
+		split.Spec.Validations = nil
+		splits = append(splits, split)
+	}
+	return splits
+}
+
+// auditAnnotationExpression returns the valueExpression of the audit annotation of the policy with the key,
+// or an empty string.
+func auditAnnotationExpression(policy *v1.ValidatingAdmissionPolicy, key string) string {
+	for _, annotation := range policy.Spec.AuditAnnotations {
+		if annotation.Key == key {
+			return annotation.ValueExpression
+		}
+	}
+	return ""
+}
+
+// targetNamespaces returns the namespaces of the targets. Namespaces loaded as targets keep their labels,
+// so that namespaceSelectors see them as in a cluster.
+func targetNamespaces(targets target.TargetInfoList) []*corev1.Namespace {
+	namespaces := map[string]*corev1.Namespace{}
+	for i := range targets {
+		t := &targets[i]
+		if t.APIGroup == "" && t.Resource == "namespaces" && t.SubResource == "" {
+			ns := &corev1.Namespace{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(t.Object, ns); err == nil {
+				namespaces[ns.Name] = ns
+			}
+		}
+	}
+	for i := range targets {
+		name := newAdmissionAttributes(&targets[i]).GetNamespace()
+		if _, ok := namespaces[name]; name == "" || ok {
+			continue
+		}
+		namespaces[name] = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{corev1.LabelMetadataName: name},
+			},
+		}
+	}
+
+	result := make([]*corev1.Namespace, 0, len(namespaces))
+	for _, ns := range namespaces {
+		result = append(result, ns)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// paramRESTMapper adds mappings for the kinds of params unknown to the mapper, such as custom resources.
+// The resource name is guessed from the kind and the scope from the namespace of the param.
+func paramRESTMapper(mapper meta.RESTMapper, params []runtime.Object) meta.RESTMapper {
+	guessed := meta.NewDefaultRESTMapper(nil)
+	added := false
+	for _, param := range params {
+		gvk := param.GetObjectKind().GroupVersionKind()
+		if _, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+			continue
+		}
+		scope := meta.RESTScopeRoot
+		if accessor, err := meta.Accessor(param); err == nil && accessor.GetNamespace() != "" {
+			scope = meta.RESTScopeNamespace
+		}
+		guessed.Add(gvk, scope)
+		added = true
+	}
+	if !added {
+		return mapper
+	}
+	return meta.MultiRESTMapper{mapper, guessed}
+}
+
+// newObjectInterfaces returns the object interfaces of admission requests. Unlike those of
+// admission.NewObjectInterfacesFromScheme, they know the equivalent resources and convert the unstructured
+// objects of targets, so that policies with matchPolicy: Equivalent match requests through other versions.
+func newObjectInterfaces(scheme *runtime.Scheme, equivalents *target.EquivalentResourceMapper) admission.ObjectInterfaces {
+	if scheme == nil {
+		scheme = clientgoscheme.Scheme
+	}
+	return &admission.RuntimeObjectInterfaces{
+		ObjectCreater:            unstructuredCreater{},
+		ObjectTyper:              scheme,
+		ObjectDefaulter:          scheme,
+		ObjectConvertor:          &unstructuredConvertor{ObjectConvertor: scheme, equivalents: equivalents},
+		EquivalentResourceMapper: equivalents,
+	}
+}
+
+// unstructuredCreater creates the unstructured objects targets are converted to.
+type unstructuredCreater struct{}
+
+func (unstructuredCreater) New(gvk schema.GroupVersionKind) (runtime.Object, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	return obj, nil
+}
+
+// unstructuredConvertor converts unstructured objects to the kind of the output object.
+type unstructuredConvertor struct {
+	runtime.ObjectConvertor
+	equivalents *target.EquivalentResourceMapper
+}
+
+func (c *unstructuredConvertor) Convert(in, out, context interface{}) error {
+	from, fromUnstructured := in.(*unstructured.Unstructured)
+	to, toUnstructured := out.(*unstructured.Unstructured)
+	if !fromUnstructured || !toUnstructured {
+		return c.ObjectConvertor.Convert(in, out, context)
+	}
+	obj, err := c.equivalents.ConvertObject(from.Object, to.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+	to.Object = obj
+	return nil
+}
+
+// startPlugin starts a plugin serving the policy, its bindings and the params, and waits until it is ready.
+// Params of built-in types are served by the informers of the clientset, and the others by the dynamic client.
+// The namespaces of the plugin are returned to add more.
+func startPlugin(policy *v1.ValidatingAdmissionPolicy, bindings []*v1.ValidatingAdmissionPolicyBinding, namespaces []*corev1.Namespace, params []runtime.Object, scheme *runtime.Scheme, restMapper meta.RESTMapper, stopCh <-chan struct{}) (*validating.Plugin, *pluginNamespaces, error) {
+	objects := []runtime.Object{policy}
+	for _, binding := range bindings {
+		objects = append(objects, binding)
+	}
+	for _, ns := range namespaces {
+		objects = append(objects, ns)
+	}
+	var dynamicObjects []runtime.Object
+	for _, param := range params {
+		if _, ok := param.(runtime.Unstructured); ok {
+			dynamicObjects = append(dynamicObjects, param.DeepCopyObject())
+		} else {
+			objects = append(objects, param.DeepCopyObject())
+		}
+	}
+	client := fake.NewSimpleClientset(objects...)
+	factory := informers.NewSharedInformerFactory(client, 0)
+
+	// The fake dynamic client panics when listing an unregistered resource, so the param resource is registered.
+	listKinds := map[schema.GroupVersionResource]string{}
+	if paramKind := policy.Spec.ParamKind; paramKind != nil {
+		gv, err := schema.ParseGroupVersion(paramKind.APIVersion)
+		if err == nil {
+			if mapping, err := restMapper.RESTMapping(gv.WithKind(paramKind.Kind).GroupKind(), gv.Version); err == nil {
+				listKinds[mapping.Resource] = paramKind.Kind + "List"
+			}
+		}
+	}
+
+	plugin := newPlugin()
+	plugin.SetEnabled(true)
+	plugin.SetExternalKubeInformerFactory(factory)
+	plugin.SetExternalKubeClientSet(client)
+	plugin.SetDynamicClient(dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme, listKinds, dynamicObjects...))
+	plugin.SetRESTMapper(restMapper)
+	plugin.SetAuthorizer(authorizerfactory.NewAlwaysAllowAuthorizer())
+	plugin.SetDrainedNotification(stopCh)
+	if err := plugin.ValidateInitialization(); err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize admission plugin for policy %s: %w", policy.Name, err)
+	}
+
+	factory.Start(stopCh)
+	for informerType, synced := range factory.WaitForCacheSync(stopCh) {
+		if !synced {
+			return nil, nil, fmt.Errorf("failed to sync %v informer for policy %s", informerType, policy.Name)
+		}
+	}
+	if !plugin.WaitForReady() {
+		return nil, nil, fmt.Errorf("admission plugin for policy %s is not ready", policy.Name)
+	}
+	return plugin, &pluginNamespaces{client: client, lister: factory.Core().V1().Namespaces().Lister()}, nil
+}
+
+// upstreamDecision is the outcome of admitting a target to a plugin running a single validation.
+type upstreamDecision struct {
+	evaluated       bool
+	failed          bool
+	message         string
+	actions         []v1.ValidationAction
+	evaluationError bool
+	// configurationError is set when the request was denied for the configuration of the policy or binding,
+	// such as missing params, without evaluating the validation.
+	configurationError bool
+	// auditAnnotation is the key of the audit annotation whose valueExpression failed to evaluate.
+	auditAnnotation string
+	// reason and code are the status of the denied request.
+	reason metav1.StatusReason
+	code   int32
+	// warnings are the warnings returned to the client.
+	warnings []string
+	// annotations are the audit annotations published by the policy, without the policy name prefix.
+	annotations map[string]string
+}
+
+// validationError converts the failure to the ValidationError of the validation at the index.
+func (d *upstreamDecision) validationError(index int, expression string) ValidationError {
+	return ValidationError{
+		Index:           index,
+		Message:         d.message,
+		CELExpr:         expression,
+		Actions:         d.actions,
+		EvaluationError: d.evaluationError,
+		Reason:          d.reason,
+		Code:            d.code,
+	}
+}
+
+// admit sends the admission request of the target to the plugin and collects its decision. The validators of
+// the plugin tell whether the policy was evaluated and how, and the validation actions are those the request was
+// reported with: the returned error (Deny), the recorded warnings (Warn) and the audit annotations (Audit).
+func admit(ctx context.Context, plugin *validating.Plugin, policy *v1.ValidatingAdmissionPolicy, t *target.TargetInfo, o admission.ObjectInterfaces) (upstreamDecision, error) {
+	attr := &recordingAttributes{Attributes: newAdmissionAttributes(t), annotations: map[string]string{}}
+	warnings := &warningRecorder{}
+	evaluations := &evaluationRecorder{}
+
+	err := plugin.Validate(withEvaluationRecorder(warning.WithWarningRecorder(ctx, warnings), evaluations), attr, o)
+	decision := upstreamDecision{evaluated: evaluations.evaluated(), warnings: warnings.warnings}
+	for key, value := range attr.annotations {
+		if name, ok := strings.CutPrefix(key, policy.Name+"/"); ok {
+			if decision.annotations == nil {
+				decision.annotations = map[string]string{}
+			}
+			decision.annotations[name] = value
+		}
+	}
+	if denied, key, ok := evaluations.denied(); ok {
+		decision.failed = true
+		decision.message = denied.Message
+		decision.evaluationError = denied.Evaluation == validating.EvalError
+		decision.auditAnnotation = key
+	}
+
+	if err != nil {
+		var statusErr *apierrors.StatusError
+		if !errors.As(err, &statusErr) {
+			return decision, err
+		}
+		if !decision.failed {
+			// The plugin denies requests without running the validator when the policy or binding is
+			// misconfigured, e.g. when the params of the binding are missing.
+			decision.evaluated = true
+			decision.failed = true
+			decision.message = deniedMessage(statusErr)
+			decision.evaluationError = true
+			decision.configurationError = true
+		}
+		decision.actions = append(decision.actions, v1.Deny)
+		decision.reason = statusErr.ErrStatus.Reason
+		decision.code = statusErr.ErrStatus.Code
+	}
+	if len(warnings.warnings) > 0 {
+		decision.actions = append(decision.actions, v1.Warn)
+	}
+	if _, ok := attr.annotations[validationFailureAnnotation]; ok {
+		decision.actions = append(decision.actions, v1.Audit)
+	}
+	return decision, nil
+}
+
+// deniedMessage returns the message of a request denied by the plugin, without the policy and binding names
+// the plugin prefixes it with.
+func deniedMessage(err *apierrors.StatusError) string {
+	message := err.ErrStatus.Message
+	if details := err.ErrStatus.Details; details != nil && len(details.Causes) > 0 {
+		message = details.Causes[len(details.Causes)-1].Message
+	}
+	// e.g. ValidatingAdmissionPolicy 'p' with binding 'b' denied request: message
+	if _, after, ok := strings.Cut(message, " denied request: "); ok {
+		return after
+	}
+	return message
+}
+
+// recordingAttributes records the audit annotations added by the plugin.
+type recordingAttributes struct {
+	admission.Attributes
+	annotations map[string]string
+}
+
+func (a *recordingAttributes) AddAnnotation(key, value string) error {
+	a.annotations[key] = value
+	return nil
+}
+
+func (a *recordingAttributes) AddAnnotationWithLevel(key, value string, _ auditinternal.Level) error {
+	return a.AddAnnotation(key, value)
+}
+
+// warningRecorder records the warnings added by the plugin.
+type warningRecorder struct {
+	warnings []string
+}
+
+func (r *warningRecorder) AddWarning(_, text string) {
+	r.warnings = append(r.warnings, text)
+}
@@ -0,0 +1,738 @@
+package validator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yashirook/vaptest/pkg/coverage"
+	"github.com/yashirook/vaptest/pkg/defaults"
+	"github.com/yashirook/vaptest/pkg/target"
+	v1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+)
+
+func newDeployment(name, namespace string, labels map[string]string, replicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(replicas)},
+	}
+}
+
+func newDeploymentPolicy(name string, validations ...v1.Validation) *v1.ValidatingAdmissionPolicy {
+	return &v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			MatchConstraints: &v1.MatchResources{
+				ResourceRules: []v1.NamedRuleWithOperations{
+					namedRule([]v1.OperationType{v1.Create, v1.Update}, a("apps"), a("v1"), a("deployments"), nil),
+				},
+			},
+			Validations: validations,
+		},
+	}
+}
+
+func newBinding(name, policyName string, actions ...v1.ValidationAction) *v1.ValidatingAdmissionPolicyBinding {
+	return &v1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName:        policyName,
+			ValidationActions: actions,
+		},
+	}
+}
+
+func TestUpstreamValidatorValidate(t *testing.T) {
+	scheme := defaults.NewScheme()
+	labels := v1.Validation{Expression: "has(object.metadata.labels)", Message: "labels are required"}
+	replicas := v1.Validation{Expression: "object.spec.replicas <= 5"}
+
+	testCases := []struct {
+		name           string
+		objects        []runtime.Object
+		policies       []*v1.ValidatingAdmissionPolicy
+		bindings       []*v1.ValidatingAdmissionPolicyBinding
+		params         []runtime.Object
+		expectedResult []policyTarget
+		expectedErrors map[string][]ValidationError
+		// 対象ごとに期待する警告と監査アノテーション
+		expectedWarnings    map[string][]string
+		expectedAnnotations map[string]map[string]string
+	}{
+		{
+			name: "全てのバリデーションの失敗を報告する",
+			objects: []runtime.Object{
+				newDeployment("labeled", "default", map[string]string{"app": "a"}, 1),
+				newDeployment("unlabeled", "default", nil, 10),
+			},
+			policies: []*v1.ValidatingAdmissionPolicy{newDeploymentPolicy("deployments", labels, replicas)},
+			bindings: []*v1.ValidatingAdmissionPolicyBinding{newBinding("deployments-binding", "deployments", v1.Deny)},
+			expectedResult: []policyTarget{
+				{policy: "deployments", target: "labeled", success: true},
+				{policy: "deployments", target: "unlabeled", success: false},
+			},
+			expectedErrors: map[string][]ValidationError{
+				"unlabeled": {
+					{Index: 0, Message: "labels are required", CELExpr: "has(object.metadata.labels)", Actions: []v1.ValidationAction{v1.Deny}, Reason: metav1.StatusReasonInvalid, Code: 422},
+					{Index: 1, Message: "failed expression: object.spec.replicas <= 5", CELExpr: "object.spec.replicas <= 5", Actions: []v1.ValidationAction{v1.Deny}, Reason: metav1.StatusReasonInvalid, Code: 422},
+				},
+			},
+		},
+		{
+			name: "バインディングのないポリシーは評価しない",
+			objects: []runtime.Object{
+				newDeployment("unlabeled", "default", nil, 1),
+			},
+			policies: []*v1.ValidatingAdmissionPolicy{newDeploymentPolicy("deployments", labels)},
+		},
+		{
+			name: "Warnアクションの失敗を報告する",
+			objects: []runtime.Object{
+				newDeployment("unlabeled", "default", nil, 1),
+			},
+			policies: []*v1.ValidatingAdmissionPolicy{newDeploymentPolicy("deployments", labels)},
+			bindings: []*v1.ValidatingAdmissionPolicyBinding{newBinding("deployments-binding", "deployments", v1.Warn)},
+			expectedResult: []policyTarget{
+				{policy: "deployments", target: "unlabeled", success: false},
+			},
+			expectedErrors: map[string][]ValidationError{
+				"unlabeled": {{Message: "labels are required", CELExpr: "has(object.metadata.labels)", Actions: []v1.ValidationAction{v1.Warn}}},
+			},
+			expectedWarnings: map[string][]string{
+				"unlabeled": {"Validation failed for ValidatingAdmissionPolicy 'deployments' with binding 'deployments-binding': labels are required"},
+			},
+		},
+		{
+			name: "監査アノテーションの値を報告する",
+			objects: []runtime.Object{
+				newDeployment("labeled", "default", map[string]string{"app": "a"}, 3),
+				newDeployment("unlabeled", "default", nil, 3),
+			},
+			policies: []*v1.ValidatingAdmissionPolicy{
+				func() *v1.ValidatingAdmissionPolicy {
+					policy := newDeploymentPolicy("deployments", replicas)
+					policy.Spec.AuditAnnotations = []v1.AuditAnnotation{
+						{Key: "replicas", ValueExpression: "string(object.spec.replicas)"},
+						{Key: "app", ValueExpression: "string(object.metadata.labels.app)"},
+					}
+					return policy
+				}(),
+			},
+			bindings: []*v1.ValidatingAdmissionPolicyBinding{newBinding("deployments-binding", "deployments", v1.Deny)},
+			expectedResult: []policyTarget{
+				{policy: "deployments", target: "labeled", success: true},
+				{policy: "deployments", target: "unlabeled", success: false},
+			},
+			expectedErrors: map[string][]ValidationError{
+				// 評価できない監査アノテーションはfailurePolicyに従って拒否される
+				"unlabeled": {{
+					Index:           -1,
+					Message:         "expression 'string(object.metadata.labels.app)' resulted in error: no such key: labels",
+					CELExpr:         "string(object.metadata.labels.app)",
+					Actions:         []v1.ValidationAction{v1.Deny},
+					EvaluationError: true,
+					Reason:          metav1.StatusReasonInvalid,
+					Code:            422,
+				}},
+			},
+			expectedAnnotations: map[string]map[string]string{
+				"labeled":   {"replicas": "3", "app": "a"},
+				"unlabeled": {"replicas": "3"},
+			},
+		},
+		{
+			name: "Auditアクションの失敗を報告する",
+			objects: []runtime.Object{
+				newDeployment("unlabeled", "default", nil, 1),
+			},
+			policies: []*v1.ValidatingAdmissionPolicy{newDeploymentPolicy("deployments", labels)},
+			bindings: []*v1.ValidatingAdmissionPolicyBinding{newBinding("deployments-binding", "deployments", v1.Audit)},
+			expectedResult: []policyTarget{
+				{policy: "deployments", target: "unlabeled", success: false},
+			},
+			expectedErrors: map[string][]ValidationError{
+				"unlabeled": {{Message: "labels are required", CELExpr: "has(object.metadata.labels)", Actions: []v1.ValidationAction{v1.Audit}}},
+			},
+		},
+		{
+			name: "評価エラーを報告する",
+			objects: []runtime.Object{
+				newDeployment("unlabeled", "default", nil, 1),
+			},
+			policies: []*v1.ValidatingAdmissionPolicy{newDeploymentPolicy("deployments", v1.Validation{Expression: "object.metadata.labels.app == 'a'"})},
+			bindings: []*v1.ValidatingAdmissionPolicyBinding{newBinding("deployments-binding", "deployments", v1.Deny)},
+			expectedResult: []policyTarget{
+				{policy: "deployments", target: "unlabeled", success: false},
+			},
+			expectedErrors: map[string][]ValidationError{
+				"unlabeled": {{
+					Message:         "expression 'object.metadata.labels.app == 'a'' resulted in error: no such key: labels",
+					CELExpr:         "object.metadata.labels.app == 'a'",
+					Actions:         []v1.ValidationAction{v1.Deny},
+					EvaluationError: true,
+					Reason:          metav1.StatusReasonInvalid,
+					Code:            422,
+				}},
+			},
+		},
+		{
+			name: "複数行の式の評価エラーを報告する",
+			objects: []runtime.Object{
+				newDeployment("unlabeled", "default", nil, 1),
+			},
+			policies: []*v1.ValidatingAdmissionPolicy{newDeploymentPolicy("deployments", v1.Validation{Expression: "object.metadata.labels.app ==\n'a'\n"})},
+			bindings: []*v1.ValidatingAdmissionPolicyBinding{newBinding("deployments-binding", "deployments", v1.Deny)},
+			expectedResult: []policyTarget{
+				{policy: "deployments", target: "unlabeled", success: false},
+			},
+			expectedErrors: map[string][]ValidationError{
+				"unlabeled": {{
+					Message:         "expression 'object.metadata.labels.app ==\n'a'\n' resulted in error: no such key: labels",
+					CELExpr:         "object.metadata.labels.app ==\n'a'\n",
+					Actions:         []v1.ValidationAction{v1.Deny},
+					EvaluationError: true,
+					Reason:          metav1.StatusReasonInvalid,
+					Code:            422,
+				}},
+			},
+		},
+		{
+			name: "エラーのようなメッセージのバリデーションの失敗は評価エラーではない",
+			objects: []runtime.Object{
+				newDeployment("unlabeled", "default", nil, 1),
+			},
+			policies: []*v1.ValidatingAdmissionPolicy{newDeploymentPolicy("deployments", v1.Validation{
+				Expression: "has(object.metadata.labels)",
+				Message:    "expression 'object.metadata.labels' resulted in error: no such key: labels",
+			})},
+			bindings: []*v1.ValidatingAdmissionPolicyBinding{newBinding("deployments-binding", "deployments", v1.Deny, v1.Audit)},
+			expectedResult: []policyTarget{
+				{policy: "deployments", target: "unlabeled", success: false},
+			},
+			expectedErrors: map[string][]ValidationError{
+				"unlabeled": {{
+					Message: "expression 'object.metadata.labels' resulted in error: no such key: labels",
+					CELExpr: "has(object.metadata.labels)",
+					Actions: []v1.ValidationAction{v1.Deny, v1.Audit},
+					Reason:  metav1.StatusReasonInvalid,
+					Code:    422,
+				}},
+			},
+		},
+		{
+			name: "matchConditionsに一致しないリソースは評価しない",
+			objects: []runtime.Object{
+				newDeployment("labeled", "default", map[string]string{"app": "a"}, 1),
+				newDeployment("unlabeled", "default", nil, 1),
+			},
+			policies: []*v1.ValidatingAdmissionPolicy{
+				func() *v1.ValidatingAdmissionPolicy {
+					policy := newDeploymentPolicy("deployments", replicas)
+					policy.Spec.MatchConditions = []v1.MatchCondition{{Name: "labeled", Expression: "has(object.metadata.labels)"}}
+					return policy
+				}(),
+			},
+			bindings: []*v1.ValidatingAdmissionPolicyBinding{newBinding("deployments-binding", "deployments", v1.Deny)},
+			expectedResult: []policyTarget{
+				{policy: "deployments", target: "labeled", success: true},
+			},
+		},
+		{
+			name: "バインディングのparamRefで参照するパラメータを使う",
+			objects: []runtime.Object{
+				newDeployment("small", "default", nil, 2),
+				newDeployment("large", "default", nil, 10),
+			},
+			params: []runtime.Object{
+				&corev1.ConfigMap{
+					TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+					ObjectMeta: metav1.ObjectMeta{Name: "replica-limit", Namespace: "default"},
+					Data:       map[string]string{"maxReplicas": "5"},
+				},
+			},
+			policies: []*v1.ValidatingAdmissionPolicy{
+				func() *v1.ValidatingAdmissionPolicy {
+					policy := newDeploymentPolicy("deployments", v1.Validation{Expression: "object.spec.replicas <= int(params.data.maxReplicas)"})
+					policy.Spec.ParamKind = &v1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+					return policy
+				}(),
+			},
+			bindings: []*v1.ValidatingAdmissionPolicyBinding{
+				func() *v1.ValidatingAdmissionPolicyBinding {
+					binding := newBinding("deployments-binding", "deployments", v1.Deny)
+					binding.Spec.ParamRef = &v1.ParamRef{Name: "replica-limit", Namespace: "default"}
+					return binding
+				}(),
+			},
+			expectedResult: []policyTarget{
+				{policy: "deployments", target: "small", success: true},
+				{policy: "deployments", target: "large", success: false},
+			},
+		},
+		{
+			name: "パラメータが見つからない場合は設定エラーを報告する",
+			objects: []runtime.Object{
+				newDeployment("small", "default", nil, 2),
+			},
+			policies: []*v1.ValidatingAdmissionPolicy{
+				func() *v1.ValidatingAdmissionPolicy {
+					policy := newDeploymentPolicy("deployments", v1.Validation{Expression: "object.spec.replicas <= int(params.data.maxReplicas)"})
+					policy.Spec.ParamKind = &v1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+					return policy
+				}(),
+			},
+			bindings: []*v1.ValidatingAdmissionPolicyBinding{
+				func() *v1.ValidatingAdmissionPolicyBinding {
+					binding := newBinding("deployments-binding", "deployments", v1.Deny)
+					binding.Spec.ParamRef = &v1.ParamRef{Name: "replica-limit", Namespace: "default"}
+					return binding
+				}(),
+			},
+			expectedResult: []policyTarget{
+				{policy: "deployments", target: "small", success: false},
+			},
+			expectedErrors: map[string][]ValidationError{
+				"small": {{
+					Message:         "failed to configure binding: no params found for policy binding with `Deny` parameterNotFoundAction",
+					CELExpr:         "object.spec.replicas <= int(params.data.maxReplicas)",
+					Actions:         []v1.ValidationAction{v1.Deny},
+					EvaluationError: true,
+					Reason:          metav1.StatusReasonInvalid,
+					Code:            422,
+				}},
+			},
+		},
+		{
+			name: "バインディングのnamespaceSelectorに一致するリソースだけを評価する",
+			objects: []runtime.Object{
+				&corev1.Namespace{
+					TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+					ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"env": "prod"}},
+				},
+				newDeployment("in-prod", "prod", nil, 1),
+				newDeployment("in-dev", "dev", nil, 1),
+			},
+			policies: []*v1.ValidatingAdmissionPolicy{newDeploymentPolicy("deployments", labels)},
+			bindings: []*v1.ValidatingAdmissionPolicyBinding{
+				func() *v1.ValidatingAdmissionPolicyBinding {
+					binding := newBinding("prod-binding", "deployments", v1.Deny)
+					binding.Spec.MatchResources = &v1.MatchResources{
+						NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+					}
+					return binding
+				}(),
+			},
+			expectedResult: []policyTarget{
+				{policy: "deployments", target: "in-prod", success: false},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			targets, err := target.NewTargetInfoList(tc.objects, scheme)
+			require.NoError(t, err)
+
+			v, err := NewUpstreamValidator(targets, tc.policies, tc.bindings, scheme)
+			require.NoError(t, err)
+			v.Params = tc.params
+			results, err := v.ValidateContext(context.Background())
+			require.NoError(t, err)
+
+			got := make([]policyTarget, 0, len(results))
+			for _, r := range results {
+				got = append(got, policyTarget{policy: r.Policy.PolicyName, target: r.Target.ResourceName, success: r.Success})
+				if expected, ok := tc.expectedErrors[r.Target.ResourceName]; ok {
+					assert.Equal(t, expected, r.ValidationErrors)
+				}
+				assert.Equal(t, tc.expectedWarnings[r.Target.ResourceName], r.Warnings)
+				assert.Equal(t, tc.expectedAnnotations[r.Target.ResourceName], r.AuditAnnotations)
+			}
+			if tc.expectedResult == nil {
+				tc.expectedResult = []policyTarget{}
+			}
+			assert.Equal(t, tc.expectedResult, got)
+		})
+	}
+}
+
+func TestCompareResults(t *testing.T) {
+	deploy := target.TargetIdentifier{APIGroup: "apps", APIVersion: "v1", Resource: "deployments", ResourceName: "deploy", Namespace: "default"}
+	pod := target.TargetIdentifier{APIVersion: "v1", Resource: "pods", ResourceName: "pod", Namespace: "default"}
+	policy := PolicyIdentifier{PolicyName: "policy"}
+	fail := func(target target.TargetIdentifier, messages map[string]string) ValidationResult {
+		result := ValidationResult{Target: target, Policy: policy, IsValidated: true}
+		for expr, message := range messages {
+			result.ValidationErrors = append(result.ValidationErrors, ValidationError{Message: message, CELExpr: expr})
+		}
+		return result
+	}
+	pass := func(target target.TargetIdentifier) ValidationResult {
+		return ValidationResult{Target: target, Policy: policy, Success: true, IsValidated: true}
+	}
+
+	bound := []*v1.ValidatingAdmissionPolicy{newDeploymentPolicy("policy")}
+	bindings := []*v1.ValidatingAdmissionPolicyBinding{newBinding("binding", "policy", v1.Deny)}
+
+	testCases := []struct {
+		name     string
+		native   []ValidationResult
+		upstream []ValidationResult
+		policies []*v1.ValidatingAdmissionPolicy
+		unbound  bool
+		narrowed bool
+		expected []string
+	}{
+		{
+			name:     "同じ結果は一致する",
+			native:   []ValidationResult{pass(pod), fail(deploy, map[string]string{"a": "message"})},
+			upstream: []ValidationResult{pass(pod), fail(deploy, map[string]string{"a": "failed expression: a"})},
+		},
+		{
+			name:     "失敗したバリデーションが異なる",
+			native:   []ValidationResult{fail(deploy, map[string]string{"a": ""})},
+			upstream: []ValidationResult{fail(deploy, map[string]string{"b": ""})},
+			expected: []string{"policy policy, default/deployments/deploy: native=fail [a], upstream=fail [b]"},
+		},
+		{
+			name:     "片方のエンジンだけが評価した",
+			native:   []ValidationResult{pass(pod)},
+			upstream: []ValidationResult{pass(deploy)},
+			expected: []string{
+				"policy policy, default/pods/pod: native=pass, upstream=not evaluated",
+				"policy policy, default/deployments/deploy: native=not evaluated, upstream=pass",
+			},
+		},
+		{
+			name:     "ネイティブエンジンが無視する機能を使うポリシーは比較しない",
+			native:   []ValidationResult{pass(pod)},
+			upstream: []ValidationResult{},
+			policies: []*v1.ValidatingAdmissionPolicy{
+				func() *v1.ValidatingAdmissionPolicy {
+					policy := newDeploymentPolicy("policy")
+					policy.Spec.MatchConditions = []v1.MatchCondition{{Name: "labeled", Expression: "has(object.metadata.labels)"}}
+					return policy
+				}(),
+			},
+		},
+		{
+			name:     "バインディングのmatchResourcesで除外された対象は比較しない",
+			native:   []ValidationResult{pass(pod), fail(deploy, map[string]string{"a": ""})},
+			upstream: []ValidationResult{pass(deploy)},
+			narrowed: true,
+			expected: []string{"policy policy, default/deployments/deploy: native=fail [a], upstream=pass"},
+		},
+		{
+			name:     "バインディングのないポリシーはネイティブエンジンだけが評価した不一致になる",
+			native:   []ValidationResult{pass(pod), fail(deploy, map[string]string{"a": ""})},
+			upstream: []ValidationResult{},
+			unbound:  true,
+			expected: []string{
+				"policy policy, default/pods/pod: native=pass, upstream=not evaluated",
+				"policy policy, default/deployments/deploy: native=fail [a], upstream=not evaluated",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			policies, policyBindings := bound, bindings
+			if tc.policies != nil {
+				policies = tc.policies
+			}
+			if tc.unbound {
+				policyBindings = nil
+			}
+			if tc.narrowed {
+				binding := newBinding("binding", "policy", v1.Deny)
+				binding.Spec.MatchResources = &v1.MatchResources{NamespaceSelector: &metav1.LabelSelector{}}
+				policyBindings = []*v1.ValidatingAdmissionPolicyBinding{binding}
+			}
+			var got []string
+			for _, d := range CompareResults(tc.native, tc.upstream, policies, policyBindings) {
+				got = append(got, d.String())
+			}
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestUpstreamSession(t *testing.T) {
+	scheme := defaults.NewScheme()
+	policy := newDeploymentPolicy("team-a", v1.Validation{Expression: "namespaceObject.metadata.name == 'team-a' && object.spec.replicas <= 5"})
+	binding := newBinding("binding", "team-a", v1.Deny)
+	binding.Spec.MatchResources = &v1.MatchResources{NamespaceSelector: &metav1.LabelSelector{
+		MatchLabels: map[string]string{corev1.LabelMetadataName: "team-a"},
+	}}
+
+	targets, err := target.NewTargetInfoList([]runtime.Object{newDeployment("web", "default", nil, 1)}, scheme)
+	require.NoError(t, err)
+	v, err := NewUpstreamValidator(targets, []*v1.ValidatingAdmissionPolicy{policy}, []*v1.ValidatingAdmissionPolicyBinding{binding}, scheme)
+	require.NoError(t, err)
+	session, err := v.Start()
+	require.NoError(t, err)
+	defer session.Stop()
+
+	results, err := session.Evaluate(context.Background(), targets)
+	require.NoError(t, err)
+	assert.Empty(t, results[0])
+
+	// 起動後に現れた名前空間のリソースも評価する
+	targets, err = target.NewTargetInfoList([]runtime.Object{
+		newDeployment("small", "team-a", nil, 1),
+		newDeployment("large", "team-a", nil, 10),
+	}, scheme)
+	require.NoError(t, err)
+	results, err = session.Evaluate(context.Background(), targets)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Len(t, results[0], 1)
+	assert.True(t, results[0][0].Success)
+	require.Len(t, results[1], 1)
+	assert.False(t, results[1][0].Success)
+}
+
+func TestUpstreamValidatorCoverage(t *testing.T) {
+	scheme := defaults.NewScheme()
+	policy := newDeploymentPolicy("replicas",
+		v1.Validation{Expression: "!variables.isLarge"},
+		v1.Validation{Expression: "has(object.metadata.labels)"},
+		v1.Validation{Expression: "object.spec.replicas / 0 == 0"},
+	)
+	policy.Spec.MatchConditions = []v1.MatchCondition{{Name: "exclude-system", Expression: "object.metadata.namespace != 'kube-system'"}}
+	policy.Spec.Variables = []v1.Variable{
+		{Name: "replicas", Expression: "object.spec.replicas"},
+		{Name: "isLarge", Expression: "variables.replicas > 5"},
+	}
+	prodBinding := newBinding("prod-binding", "replicas", v1.Warn)
+	prodBinding.Spec.MatchResources = &v1.MatchResources{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+	}
+
+	targets, err := target.NewTargetInfoList([]runtime.Object{
+		newDeployment("small", "default", map[string]string{"app": "a"}, 1),
+		newDeployment("large", "default", nil, 10),
+		newDeployment("system", "kube-system", nil, 1),
+	}, scheme)
+	require.NoError(t, err)
+
+	v, err := NewUpstreamValidator(targets,
+		[]*v1.ValidatingAdmissionPolicy{policy, newDeploymentPolicy("unbound", v1.Validation{Expression: "has(object.metadata.labels)"})},
+		[]*v1.ValidatingAdmissionPolicyBinding{newBinding("binding", "replicas", v1.Deny), prodBinding},
+		scheme)
+	require.NoError(t, err)
+	v.Coverage = coverage.New()
+	_, err = v.ValidateContext(context.Background())
+	require.NoError(t, err)
+
+	type counts struct {
+		binding, path string
+		t, f, e       int
+	}
+	var got []counts
+	for _, e := range v.Coverage.Expressions {
+		got = append(got, counts{e.Binding, e.Policy + " " + e.Path(), e.True, e.False, e.Error})
+	}
+	assert.Equal(t, []counts{
+		// 条件はkube-systemのリソースでfalseになり、変数とバリデーションはそれ以外のリソースで評価される
+		{"binding", "replicas spec.matchConditions[0]", 2, 1, 0},
+		{"binding", "replicas spec.variables[0]", 2, 0, 0},
+		{"binding", "replicas spec.variables[1]", 1, 1, 0},
+		{"binding", "replicas spec.validations[0]", 1, 1, 0},
+		{"binding", "replicas spec.validations[1]", 1, 1, 0},
+		{"binding", "replicas spec.validations[2]", 0, 0, 2},
+		// namespaceSelectorに一致するリソースがないバインディングでは評価されない
+		{"prod-binding", "replicas spec.matchConditions[0]", 0, 0, 0},
+		{"prod-binding", "replicas spec.variables[0]", 0, 0, 0},
+		{"prod-binding", "replicas spec.variables[1]", 0, 0, 0},
+		{"prod-binding", "replicas spec.validations[0]", 0, 0, 0},
+		{"prod-binding", "replicas spec.validations[1]", 0, 0, 0},
+		{"prod-binding", "replicas spec.validations[2]", 0, 0, 0},
+		// バインディングのないポリシーも未評価として記録する
+		{"", "unbound spec.validations[0]", 0, 0, 0},
+	}, got)
+}
+
+func TestUpstreamValidatorCoverageMatchConditionError(t *testing.T) {
+	scheme := defaults.NewScheme()
+	policy := newDeploymentPolicy("team-a", v1.Validation{Expression: "object.spec.replicas <= 5"})
+	policy.Spec.FailurePolicy = ptr.To(v1.Ignore)
+	policy.Spec.MatchConditions = []v1.MatchCondition{{Name: "team-a", Expression: "object.metadata.labels.team == 'a'"}}
+
+	targets, err := target.NewTargetInfoList([]runtime.Object{
+		newDeployment("a", "default", map[string]string{"team": "a"}, 1),
+		newDeployment("b", "default", map[string]string{"team": "b"}, 1),
+		newDeployment("unlabeled", "default", nil, 1),
+	}, scheme)
+	require.NoError(t, err)
+
+	v, err := NewUpstreamValidator(targets, []*v1.ValidatingAdmissionPolicy{policy},
+		[]*v1.ValidatingAdmissionPolicyBinding{newBinding("binding", "team-a", v1.Deny)}, scheme)
+	require.NoError(t, err)
+	v.Coverage = coverage.New()
+	_, err = v.ValidateContext(context.Background())
+	require.NoError(t, err)
+
+	type counts struct {
+		path    string
+		t, f, e int
+	}
+	var got []counts
+	for _, e := range v.Coverage.Expressions {
+		got = append(got, counts{e.Path(), e.True, e.False, e.Error})
+	}
+	assert.Equal(t, []counts{
+		{"spec.matchConditions[0]", 1, 1, 1},
+		// 条件の評価エラーではバリデーションは評価されない
+		{"spec.validations[0]", 1, 0, 0},
+	}, got)
+}
+
+func TestUpstreamValidatorCoverageUnreferencedVariable(t *testing.T) {
+	scheme := defaults.NewScheme()
+	policy := newDeploymentPolicy("replica-limit", v1.Validation{Expression: "variables.replicas <= 5"})
+	policy.Spec.Variables = []v1.Variable{
+		{Name: "replicas", Expression: "object.spec.replicas"},
+		{Name: "unused", Expression: "object.spec.doesNotExist"},
+	}
+
+	targets, err := target.NewTargetInfoList([]runtime.Object{
+		newDeployment("small", "default", nil, 1),
+		newDeployment("large", "default", nil, 10),
+	}, scheme)
+	require.NoError(t, err)
+
+	v, err := NewUpstreamValidator(targets, []*v1.ValidatingAdmissionPolicy{policy},
+		[]*v1.ValidatingAdmissionPolicyBinding{newBinding("binding", "replica-limit", v1.Deny)}, scheme)
+	require.NoError(t, err)
+	v.Coverage = coverage.New()
+	_, err = v.ValidateContext(context.Background())
+	require.NoError(t, err)
+
+	type counts struct {
+		path    string
+		t, f, e int
+	}
+	var got []counts
+	for _, e := range v.Coverage.Expressions {
+		got = append(got, counts{e.Path(), e.True, e.False, e.Error})
+	}
+	assert.Equal(t, []counts{
+		{"spec.variables[0]", 2, 0, 0},
+		// 参照されない変数はプラグインと同じく評価されない
+		{"spec.variables[1]", 0, 0, 0},
+		{"spec.validations[0]", 1, 1, 0},
+	}, got)
+}
+
+func TestUpstreamValidatorBranchCoverage(t *testing.T) {
+	scheme := defaults.NewScheme()
+	policy := newDeploymentPolicy("exemptable",
+		v1.Validation{Expression: "object.metadata.namespace == 'exempt' || (variables.replicas <= 5 && object.metadata.labels.all(k, k.startsWith('app')))"},
+	)
+	policy.Spec.Variables = []v1.Variable{{Name: "replicas", Expression: "object.spec.replicas"}}
+
+	targets, err := target.NewTargetInfoList([]runtime.Object{
+		newDeployment("small", "default", map[string]string{"app": "a"}, 1),
+		newDeployment("mislabeled", "default", map[string]string{"team": "b"}, 1),
+		newDeployment("large", "default", nil, 10),
+		newDeployment("exempted", "exempt", nil, 10),
+	}, scheme)
+	require.NoError(t, err)
+
+	v, err := NewUpstreamValidator(targets, []*v1.ValidatingAdmissionPolicy{policy},
+		[]*v1.ValidatingAdmissionPolicyBinding{newBinding("binding", "exemptable", v1.Deny)}, scheme)
+	require.NoError(t, err)
+	v.Coverage = coverage.New()
+	_, err = v.ValidateContext(context.Background())
+	require.NoError(t, err)
+
+	type counts struct {
+		condition string
+		t, f      int
+	}
+	var validation *coverage.Expression
+	for _, e := range v.Coverage.Expressions {
+		if e.Kind == coverage.KindValidation {
+			validation = e
+		}
+	}
+	require.NotNil(t, validation)
+	var got []counts
+	for _, c := range validation.Conditions {
+		got = append(got, counts{c.Operator + " " + c.Text, c.True, c.False})
+	}
+	assert.Equal(t, []counts{
+		{"|| object.metadata.namespace == 'exempt'", 1, 3},
+		{"|| variables.replicas <= 5 && object.metadata.labels.all(k, k.startsWith('app'))", 1, 2},
+		// largeではレプリカ数の条件で評価が打ち切られる
+		{"&& variables.replicas <= 5", 2, 1},
+		{"&& object.metadata.labels.all(k, k.startsWith('app'))", 1, 1},
+		{"all k.startsWith('app')", 1, 1},
+	}, got)
+
+	// 除外の条件がtrueになるテストケースがなければ、その分岐が強調される
+	v, err = NewUpstreamValidator(targets[:3], []*v1.ValidatingAdmissionPolicy{policy},
+		[]*v1.ValidatingAdmissionPolicyBinding{newBinding("binding", "exemptable", v1.Deny)}, scheme)
+	require.NoError(t, err)
+	v.Coverage = coverage.New()
+	_, err = v.ValidateContext(context.Background())
+	require.NoError(t, err)
+	for _, e := range v.Coverage.Expressions {
+		if e.Kind == coverage.KindValidation {
+			assert.Equal(t, "«object.metadata.namespace == 'exempt'» || (variables.replicas <= 5 && object.metadata.labels.all(k, k.startsWith('app')))", e.Highlight())
+			assert.Equal(t, "never true", e.Conditions[0].Missing())
+		}
+	}
+}
+
+func TestEquivalentMatchPolicy(t *testing.T) {
+	scheme := defaults.NewScheme()
+	deployment := &extensionsv1beta1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "extensions/v1beta1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "default"},
+		Spec: extensionsv1beta1.DeploymentSpec{
+			Replicas:   ptr.To[int32](10),
+			RollbackTo: &extensionsv1beta1.RollbackConfig{Revision: 1},
+		},
+	}
+	targets, err := target.NewTargetInfoList([]runtime.Object{deployment}, scheme)
+	require.NoError(t, err)
+
+	newPolicy := func(name string, matchPolicy v1.MatchPolicyType) *v1.ValidatingAdmissionPolicy {
+		policy := newDeploymentPolicy(name,
+			v1.Validation{Expression: "object.apiVersion == 'apps/v1' && !has(object.spec.rollbackTo)", Message: "object is converted"},
+			v1.Validation{Expression: "request.kind.group == 'apps' && request.resource.version == 'v1'", Message: "request is converted"},
+			v1.Validation{Expression: "object.spec.replicas <= 5", Message: "too many replicas"},
+		)
+		policy.Spec.MatchConstraints.MatchPolicy = &matchPolicy
+		return policy
+	}
+	policies := []*v1.ValidatingAdmissionPolicy{newPolicy("equivalent", v1.Equivalent), newPolicy("exact", v1.Exact)}
+	bindings := []*v1.ValidatingAdmissionPolicyBinding{newBinding("equivalent", "equivalent", v1.Deny), newBinding("exact", "exact", v1.Deny)}
+
+	native, err := NewValidator(targets, policies, bindings, scheme)
+	require.NoError(t, err)
+	upstream, err := NewUpstreamValidator(targets, policies, bindings, scheme)
+	require.NoError(t, err)
+
+	for name, engine := range map[string]Engine{"native": &native, "upstream": &upstream} {
+		t.Run(name, func(t *testing.T) {
+			results, err := engine.ValidateContext(context.Background())
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.Equal(t, "equivalent", results[0].Policy.PolicyName)
+			assert.Equal(t, "extensions", results[0].Target.APIGroup)
+			require.Len(t, results[0].ValidationErrors, 1)
+			assert.Equal(t, "too many replicas", results[0].ValidationErrors[0].Message)
+		})
+	}
+}
@@ -1,14 +1,17 @@
 package validator
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	goruntime "runtime"
+	"sync"
 
-	"github.com/google/cel-go/cel"
 	"github.com/yashirook/vaptest/pkg/target"
 	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apiserver/pkg/cel/environment"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 )
 
 type Validator struct {
@@ -16,6 +19,14 @@ type Validator struct {
 	Policies       []*v1.ValidatingAdmissionPolicy
 	PolicyBindings []*v1.ValidatingAdmissionPolicyBinding
 	Scheme         *runtime.Scheme
+	// RESTMapper resolves the resources equivalent to those of targets, which policies with
+	// matchPolicy: Equivalent also match. Nil means the built-in API catalog.
+	RESTMapper meta.RESTMapper
+	// Concurrency is the number of targets evaluated in parallel. Zero means GOMAXPROCS.
+	Concurrency int
+
+	compiledPolicies []*CompiledPolicy
+	index            *policyIndex
 }
 
 func NewValidator(targets target.TargetInfoList, policies []*v1.ValidatingAdmissionPolicy, PolicyBindings []*v1.ValidatingAdmissionPolicyBinding, scheme *runtime.Scheme) (Validator, error) {
@@ -38,99 +49,223 @@ func NewValidator(targets target.TargetInfoList, policies []*v1.ValidatingAdmiss
 		}
 	}
 
+	compiledPolicies := make([]*CompiledPolicy, 0, len(policies))
+	for _, policy := range policies {
+		compiled, err := CompilePolicy(policy)
+		if err != nil {
+			return Validator{}, err
+		}
+		compiledPolicies = append(compiledPolicies, compiled)
+	}
+
 	return Validator{
-		TargetInfoList: targets,
-		Policies:       policies,
-		PolicyBindings: PolicyBindings,
+		TargetInfoList:   targets,
+		Policies:         policies,
+		PolicyBindings:   PolicyBindings,
+		Scheme:           scheme,
+		compiledPolicies: compiledPolicies,
+		index:            newPolicyIndex(compiledPolicies),
 	}, nil
 }
 
 func (v *Validator) Validate() ([]ValidationResult, error) {
-	results := make([]ValidationResult, 0)
-	for _, policy := range v.Policies {
-		res, err := v.validatePolicy(policy)
+	return v.ValidateContext(context.Background())
+}
+
+// ValidateContext evaluates all policies against their matching targets with up to Concurrency workers.
+// Compiled programs and targets are shared read-only between workers, and results are returned in
+// policy order, then target order, regardless of scheduling. Evaluation stops when ctx is cancelled.
+func (v *Validator) ValidateContext(ctx context.Context) ([]ValidationResult, error) {
+	if v.compiledPolicies == nil {
+		for _, policy := range v.Policies {
+			compiled, err := CompilePolicy(policy)
+			if err != nil {
+				return nil, err
+			}
+			v.compiledPolicies = append(v.compiledPolicies, compiled)
+		}
+		v.index = newPolicyIndex(v.compiledPolicies)
+	}
+
+	equivalents := equivalentResourceMapper(v.RESTMapper, v.Scheme)
+	// Only compare each target with the policies that could match its group and resource.
+	candidates := make([]target.TargetInfoList, len(v.compiledPolicies))
+	for i := range v.TargetInfoList {
+		t := &v.TargetInfoList[i]
+		for _, p := range v.index.lookup(t) {
+			candidates[p] = append(candidates[p], *t)
+		}
+	}
+
+	var jobs []evaluation
+	for i, policy := range v.compiledPolicies {
+		filteredTargets, err := filterTarget(policy.Policy, candidates[i], equivalents)
 		if err != nil {
-			return results, err
+			return nil, fmt.Errorf("failed to filter target: %w", err)
+		}
+		for _, t := range filteredTargets {
+			jobs = append(jobs, evaluation{policy: policy, target: t})
+		}
+	}
+
+	outcomes, err := runEvaluations(ctx, jobs, v.concurrency())
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ValidationResult, 0)
+	for _, o := range outcomes {
+		for _, msg := range o.evalErrors {
+			fmt.Print(msg)
+		}
+		if o.validated {
+			results = append(results, o.result)
 		}
-		results = append(results, res...)
 	}
 	return results, nil
 }
 
-func makeCELProgram(validation *v1.Validation) (cel.Program, error) {
-	celEnv := environment.MustBaseEnvSet(environment.DefaultCompatibilityVersion(), false)
-	env := celEnv.NewExpressionsEnv()
+// concurrency returns the number of evaluation workers, defaulting to GOMAXPROCS.
+func (v *Validator) concurrency() int {
+	if v.Concurrency > 0 {
+		return v.Concurrency
+	}
+	return goruntime.GOMAXPROCS(0)
+}
+
+// evaluation is a policy and target pair to evaluate.
+type evaluation struct {
+	policy *CompiledPolicy
+	target matchedTarget
+}
+
+// evaluationOutcome is the result of an evaluation.
+type evaluationOutcome struct {
+	result     ValidationResult
+	validated  bool
+	evalErrors []string
+}
+
+// runEvaluations evaluates jobs with a bounded pool of workers.
+// Each outcome is stored at the index of its job, so the order of outcomes does not depend on scheduling.
+func runEvaluations(ctx context.Context, jobs []evaluation, workers int) ([]evaluationOutcome, error) {
+	outcomes := make([]evaluationOutcome, len(jobs))
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
 
-	ast, issues := env.Parse(validation.Expression)
-	if issues != nil && issues.Err() != nil {
-		return nil, fmt.Errorf("CEL expression parse error: %w", issues.Err())
+	jobCh := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobCh {
+				outcomes[i] = evaluateTarget(jobs[i].policy, jobs[i].target)
+			}
+		}()
 	}
 
-	// todo: check implementation
-	// _, issues = env.Check(ast)
-	// if issues != nil && issues.Err() != nil {
-	// 	return nil, fmt.Errorf("CEL expression check error: %w", issues.Err())
-	// }
+dispatch:
+	for i := range jobs {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobCh <- i:
+		}
+	}
+	close(jobCh)
+	wg.Wait()
 
-	prog, err := env.Program(ast)
-	if err != nil {
-		return nil, fmt.Errorf("build CEL Program error: %w", err)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return outcomes, nil
+}
+
+// equivalentResourceMapper returns the EquivalentResourceMapper of the RESTMapper, defaulting to the built-in
+// API catalog, and to the client-go scheme without a scheme.
+func equivalentResourceMapper(mapper meta.RESTMapper, scheme *runtime.Scheme) *target.EquivalentResourceMapper {
+	if scheme == nil {
+		scheme = clientgoscheme.Scheme
+	}
+	if mapper == nil {
+		mapper = target.StaticRESTMapper(scheme)
 	}
+	return target.NewEquivalentResourceMapper(mapper, scheme)
+}
 
-	return prog, nil
+// Evaluate evaluates the policy against the targets it matches. It returns the results of the targets for
+// which at least one validation evaluated to a bool, and the messages of the expressions that failed to evaluate.
+// Equivalent resources are resolved with the built-in API catalog.
+func (c *CompiledPolicy) Evaluate(targets target.TargetInfoList) ([]ValidationResult, []string, error) {
+	return c.EvaluateWithMapper(targets, equivalentResourceMapper(nil, nil))
 }
 
-func (v *Validator) validatePolicy(policy *v1.ValidatingAdmissionPolicy) ([]ValidationResult, error) {
+// EvaluateWithMapper evaluates the policy like Evaluate, resolving equivalent resources with the given mapper.
+func (c *CompiledPolicy) EvaluateWithMapper(targets target.TargetInfoList, equivalents *target.EquivalentResourceMapper) ([]ValidationResult, []string, error) {
 	results := make([]ValidationResult, 0)
-	filteredTargets, err := filterTarget(policy, v.TargetInfoList)
+	filteredTargets, err := filterTarget(c.Policy, targets, equivalents)
 	if err != nil {
-		return results, fmt.Errorf("failed to filter target: %w", err)
+		return results, nil, fmt.Errorf("failed to filter target: %w", err)
 	}
-	var isValidated bool = false
 
+	var evalErrors []string
 	for _, t := range filteredTargets {
-		var success bool = true
-		validationErrors := make([]ValidationError, 0)
-		for _, validation := range policy.Spec.Validations {
-			prog, err := makeCELProgram(&validation)
-			if err != nil {
-				return results, fmt.Errorf("failed to make AST: %w", err)
-			}
-
-			activation := map[string]interface{}{
-				"object": t.Object,
-			}
-
-			out, _, err := prog.Eval(activation)
-			if err != nil {
-				fmt.Printf("eval error: resource=%s, policy=%s, expression=%s, error=%s\n", t.TargetIdentifier.ResourceName, policy.Name, validation.Expression, err)
-				continue
-			}
-			res, ok := out.Value().(bool)
-			if !ok {
-				continue
-			}
+		o := evaluateTarget(c, t)
+		evalErrors = append(evalErrors, o.evalErrors...)
+		if o.validated {
+			results = append(results, o.result)
+		}
+	}
+	return results, evalErrors, nil
+}
 
-			if !res {
-				success = false
-				validationErrors = append(validationErrors, ValidationError{
-					Message: validation.Message,
-					CELExpr: validation.Expression,
-				})
-			}
+// evaluateTarget evaluates all validations of the policy against the target, as converted for the policy.
+// A target is validated when at least one validation evaluated to a bool.
+func evaluateTarget(compiled *CompiledPolicy, matched matchedTarget) evaluationOutcome {
+	t := matched.target
+	policy := compiled.Policy
+	var outcome evaluationOutcome
+	var success bool = true
+	validationErrors := make([]ValidationError, 0)
+	activation := map[string]interface{}{
+		"object":  matched.versioned.Object,
+		"request": matched.versioned.RequestAttributes(),
+	}
+	for i, cv := range compiled.Validations {
+		validation := cv.Validation
 
-			isValidated = true
+		out, _, err := cv.Program.Eval(activation)
+		if err != nil {
+			outcome.evalErrors = append(outcome.evalErrors, fmt.Sprintf("eval error: resource=%s, policy=%s, expression=%s, error=%s\n", t.TargetIdentifier.ResourceName, policy.Name, validation.Expression, err))
+			continue
+		}
+		res, ok := out.Value().(bool)
+		if !ok {
+			continue
 		}
 
-		if isValidated {
-			results = appendResult(results, success, isValidated, policy, t, validationErrors)
+		if !res {
+			success = false
+			validationErrors = append(validationErrors, ValidationError{
+				Index:   i,
+				Message: validation.Message,
+				CELExpr: validation.Expression,
+			})
 		}
+
+		outcome.validated = true
 	}
-	return results, nil
+
+	if outcome.validated {
+		outcome.result = newResult(success, outcome.validated, policy, *t, validationErrors)
+	}
+	return outcome
 }
 
-func appendResult(results []ValidationResult, success bool, isValidated bool, policy *v1.ValidatingAdmissionPolicy, target target.TargetInfo, validationErrors []ValidationError) []ValidationResult {
-	return append(results, ValidationResult{
+func newResult(success bool, isValidated bool, policy *v1.ValidatingAdmissionPolicy, target target.TargetInfo, validationErrors []ValidationError) ValidationResult {
+	return ValidationResult{
 		Policy: PolicyIdentifier{
 			PolicyName: policy.Name,
 		},
@@ -138,5 +273,6 @@ func appendResult(results []ValidationResult, success bool, isValidated bool, po
 		IsValidated:      isValidated,
 		ValidationErrors: validationErrors,
 		Target:           target.TargetIdentifier,
-	})
+		DefaultedFields:  target.DefaultedFields,
+	}
 }
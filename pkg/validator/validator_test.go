@@ -1,6 +1,8 @@
 package validator
 
 import (
+	"context"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -169,6 +171,7 @@ func TestValidatePolicy(t *testing.T) {
 						ExcludeResourceRules: []v1.NamedRuleWithOperations{
 							{
 								RuleWithOperations: v1.RuleWithOperations{
+									Operations: []v1.OperationType{v1.OperationAll},
 									Rule: v1.Rule{
 										APIGroups:   []string{"excluded.group"},
 										APIVersions: []string{"v1"},
@@ -233,6 +236,7 @@ func TestValidatePolicy(t *testing.T) {
 						ResourceRules: []v1.NamedRuleWithOperations{
 							{
 								RuleWithOperations: v1.RuleWithOperations{
+									Operations: []v1.OperationType{v1.OperationAll},
 									Rule: v1.Rule{
 										APIGroups:   []string{"matched.group"},
 										APIVersions: []string{"v1"},
@@ -342,6 +346,7 @@ func TestValidatePolicy(t *testing.T) {
 					IsValidated: true,
 					ValidationErrors: []ValidationError{
 						{
+							Index:   1,
 							Message: "Name must end with 'object'",
 							CELExpr: "object.metadata.name.endsWith('object')",
 						},
@@ -356,8 +361,15 @@ func TestValidatePolicy(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			v := &Validator{TargetInfoList: tc.targetInfoList}
-			results, err := v.validatePolicy(tc.policy)
+			compiled, err := CompilePolicy(tc.policy)
+			if err != nil {
+				if tc.expectedError != "" {
+					assert.Contains(t, err.Error(), tc.expectedError)
+					return
+				}
+				t.Fatal(err)
+			}
+			results, _, err := compiled.Evaluate(tc.targetInfoList)
 
 			if tc.expectedError != "" {
 				assert.Error(t, err)
@@ -369,3 +381,97 @@ func TestValidatePolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateContext(t *testing.T) {
+	targets := make(target.TargetInfoList, 0)
+	for i := 0; i < 200; i++ {
+		name := fmt.Sprintf("test-object-%d", i)
+		if i%3 == 0 {
+			name = fmt.Sprintf("invalid-object-%d", i)
+		}
+		targets = append(targets, target.TargetInfo{
+			Object: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": name},
+			},
+			TargetIdentifier: target.TargetIdentifier{
+				APIGroup: "test.group", APIVersion: "v1", Resource: "test-objects", ResourceName: name,
+			},
+		})
+	}
+	policies := []*v1.ValidatingAdmissionPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "prefix-policy"},
+			Spec: v1.ValidatingAdmissionPolicySpec{
+				Validations: []v1.Validation{
+					{Expression: "object.metadata.name.startsWith('test')", Message: "Name must start with 'test'"},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "suffix-policy"},
+			Spec: v1.ValidatingAdmissionPolicySpec{
+				Validations: []v1.Validation{
+					{Expression: "object.metadata.name.endsWith('0')", Message: "Name must end with '0'"},
+				},
+			},
+		},
+	}
+
+	sequential, err := NewValidator(targets, policies, nil, nil)
+	assert.NoError(t, err)
+	sequential.Concurrency = 1
+	expected, err := sequential.ValidateContext(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, expected, 400)
+
+	t.Run("results are ordered regardless of concurrency", func(t *testing.T) {
+		for _, concurrency := range []int{2, 8, 64} {
+			v, err := NewValidator(targets, policies, nil, nil)
+			assert.NoError(t, err)
+			v.Concurrency = concurrency
+			results, err := v.ValidateContext(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, expected, results, "concurrency=%d", concurrency)
+		}
+	})
+
+	t.Run("cancelled context stops evaluation", func(t *testing.T) {
+		v, err := NewValidator(targets, policies, nil, nil)
+		assert.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = v.ValidateContext(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestCompiledPolicyEvaluate(t *testing.T) {
+	compiled, err := CompilePolicy(&v1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "label-policy"},
+		Spec: v1.ValidatingAdmissionPolicySpec{
+			Validations: []v1.Validation{
+				{Expression: "object.metadata.labels.app == 'web'", Message: "app label must be web"},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	targets := target.TargetInfoList{
+		{
+			Object:           map[string]interface{}{"metadata": map[string]interface{}{"name": "labeled", "labels": map[string]interface{}{"app": "api"}}},
+			TargetIdentifier: target.TargetIdentifier{Resource: "test-objects", ResourceName: "labeled"},
+		},
+		{
+			Object:           map[string]interface{}{"metadata": map[string]interface{}{"name": "unlabeled"}},
+			TargetIdentifier: target.TargetIdentifier{Resource: "test-objects", ResourceName: "unlabeled"},
+		},
+	}
+	results, evalErrors, err := compiled.Evaluate(targets)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "labeled", results[0].Target.ResourceName)
+	assert.Equal(t, []ValidationError{{Index: 0, Message: "app label must be web", CELExpr: "object.metadata.labels.app == 'web'"}}, results[0].ValidationErrors)
+	// 評価できなかった式は結果ではなくエラーメッセージとして返す
+	assert.Len(t, evalErrors, 1)
+	assert.Contains(t, evalErrors[0], "resource=unlabeled, policy=label-policy")
+}
@@ -0,0 +1,318 @@
+// Package vaptesting provides helpers to test ValidatingAdmissionPolicies from go test, evaluating typed
+// client-go objects or unstructured objects in process:
+//
+//	func TestReplicaLimit(t *testing.T) {
+//		policies := vaptesting.LoadPolicies(t, "../policies")
+//		deployment := &appsv1.Deployment{...}
+//		policies.AssertDenied(t, deployment, "replica-limit")
+//	}
+//
+// Failed assertions report the validations that failed, with their expression, validation actions and message.
+package vaptesting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yashirook/vaptest/pkg/defaults"
+	"github.com/yashirook/vaptest/pkg/loader"
+	"github.com/yashirook/vaptest/pkg/target"
+	"github.com/yashirook/vaptest/pkg/validation"
+	"github.com/yashirook/vaptest/pkg/validator"
+	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Policies are the policies and bindings objects are evaluated against. Objects are evaluated with the
+// validator package through the bindings of the policies, as in a cluster: a policy without a matching binding
+// does not evaluate an object, and the validationActions of bindings decide whether a failure denies the
+// request, warns or is audited.
+//
+// The policies are started on the first evaluation and kept running for the next ones, so the fields must not
+// be changed afterwards, except through LoadParams. Close stops them.
+type Policies struct {
+	Policies []*v1.ValidatingAdmissionPolicy
+	Bindings []*v1.ValidatingAdmissionPolicyBinding
+	// Params are the resources referenced by the paramRef of bindings.
+	Params []runtime.Object
+	// Scheme decodes the manifests and resolves the kinds of typed objects. Add the types of custom resources
+	// to it to evaluate typed custom resources.
+	Scheme *runtime.Scheme
+	// RESTMapper resolves resources. Nil means the built-in API catalog.
+	RESTMapper meta.RESTMapper
+	// Defaulting applies Kubernetes API defaulting to objects before evaluation.
+	Defaulting bool
+
+	mu      sync.Mutex
+	session *validator.UpstreamSession
+}
+
+// NewScheme returns a scheme with the built-in Kubernetes API types and their defaulting functions.
+func NewScheme() *runtime.Scheme {
+	return defaults.NewScheme()
+}
+
+// LoadPolicies loads the policies and bindings from the manifest files or directories, with API defaulting
+// enabled. The test fails immediately when they cannot be loaded or are invalid. The policies are closed when
+// the test and its subtests complete.
+func LoadPolicies(t testing.TB, paths ...string) *Policies {
+	t.Helper()
+	scheme := NewScheme()
+	ldr := loader.NewLoader(scheme)
+	policies, bindings, err := ldr.LoadPolicyFromPaths(paths)
+	if err != nil {
+		t.Fatalf("failed to load policy objects: %v", err)
+	}
+	if errs := validation.ValidatePolicyObjects(policies, bindings, scheme, ldr.Source); len(errs) > 0 {
+		t.Fatalf("invalid policy object: %v", errors.Join(errs...))
+	}
+	if len(policies) == 0 {
+		t.Fatalf("no policies found in %v", paths)
+	}
+	p := &Policies{Policies: policies, Bindings: bindings, Scheme: scheme, Defaulting: true}
+	t.Cleanup(p.Close)
+	return p
+}
+
+// LoadParams loads the params referenced by the bindings from the manifest files or directories. The test fails
+// immediately when they cannot be loaded.
+func (p *Policies) LoadParams(t testing.TB, paths ...string) *Policies {
+	t.Helper()
+	params, err := loader.NewLoader(p.Scheme).LoadObjectFromPaths(paths)
+	if err != nil {
+		t.Fatalf("failed to load params: %v", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Params = append(p.Params, params...)
+	// 次の評価でパラメータを含めて起動し直す
+	p.stop()
+	return p
+}
+
+// Close stops the policies started by evaluations. Evaluating an object again starts them again.
+func (p *Policies) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop()
+}
+
+func (p *Policies) stop() {
+	if p.session != nil {
+		p.session.Stop()
+		p.session = nil
+	}
+}
+
+// start returns the session evaluating objects, starting it on the first evaluation.
+func (p *Policies) start(mapper meta.RESTMapper) (*validator.UpstreamSession, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.session != nil {
+		return p.session, nil
+	}
+	v := validator.UpstreamValidator{
+		Policies:       p.Policies,
+		PolicyBindings: p.Bindings,
+		Scheme:         p.Scheme,
+		Params:         p.Params,
+		RESTMapper:     mapper,
+	}
+	session, err := v.Start()
+	if err != nil {
+		return nil, err
+	}
+	p.session = session
+	return session, nil
+}
+
+// Evaluate evaluates the policy against the CREATE request of the object. The result is nil when the policy
+// does not evaluate the object, e.g. because no binding matches it. The object is not modified.
+func (p *Policies) Evaluate(obj runtime.Object, policyName string) (*validator.ValidationResult, error) {
+	if !slices.ContainsFunc(p.Policies, func(policy *v1.ValidatingAdmissionPolicy) bool { return policy.Name == policyName }) {
+		return nil, fmt.Errorf("policy %s is not loaded", policyName)
+	}
+	obj, err := p.withKind(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	mapper := p.RESTMapper
+	if mapper == nil {
+		mapper = target.StaticRESTMapper(p.Scheme)
+	}
+	var opts []target.Option
+	if p.Defaulting {
+		opts = append(opts, target.WithDefaulting(p.Scheme))
+	}
+	info, err := target.NewTargetInfoWithMapper(obj, mapper, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create target info: %w", err)
+	}
+
+	session, err := p.start(mapper)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start policies: %w", err)
+	}
+	results, err := session.Evaluate(context.Background(), []target.TargetInfo{*info})
+	if err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	return validator.FindResult(results[0], policyName), nil
+}
+
+// withKind returns a copy of a typed object with its apiVersion and kind set from the scheme, as typed objects
+// built in Go code usually leave them empty.
+func (p *Policies) withKind(obj runtime.Object) (runtime.Object, error) {
+	obj = obj.DeepCopyObject()
+	if !obj.GetObjectKind().GroupVersionKind().Empty() {
+		return obj, nil
+	}
+	gvks, _, err := p.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the kind of the object: %w", err)
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvks[0])
+	return obj, nil
+}
+
+// AssertDenied asserts that the policy denies the request of the object: a validation failed with the Deny
+// validation action, or could not be evaluated under failurePolicy Fail.
+func (p *Policies) AssertDenied(t testing.TB, obj runtime.Object, policyName string) bool {
+	t.Helper()
+	result, ok := p.evaluate(t, obj, policyName)
+	if !ok {
+		return false
+	}
+	if denied(result) {
+		return true
+	}
+	t.Errorf("expected policy %s to deny %s, but %s", policyName, p.describeObject(obj), describeResult(result))
+	return false
+}
+
+// AssertAllowed asserts that the policy allows the request of the object: no validation failed with the Deny
+// validation action. An object the policy does not evaluate is allowed, and so is one it only warns about.
+func (p *Policies) AssertAllowed(t testing.TB, obj runtime.Object, policyName string) bool {
+	t.Helper()
+	result, ok := p.evaluate(t, obj, policyName)
+	if !ok {
+		return false
+	}
+	if !denied(result) {
+		return true
+	}
+	t.Errorf("expected policy %s to allow %s, but %s", policyName, p.describeObject(obj), describeResult(result))
+	return false
+}
+
+// AssertWarns asserts that a validation of the policy failed for the object with the Warn validation action,
+// returning a warning to the client.
+func (p *Policies) AssertWarns(t testing.TB, obj runtime.Object, policyName string) bool {
+	t.Helper()
+	result, ok := p.evaluate(t, obj, policyName)
+	if !ok {
+		return false
+	}
+	if result != nil && slices.ContainsFunc(result.ValidationErrors, func(e validator.ValidationError) bool {
+		return slices.Contains(e.Actions, v1.Warn)
+	}) {
+		return true
+	}
+	t.Errorf("expected policy %s to warn about %s, but %s", policyName, p.describeObject(obj), describeResult(result))
+	return false
+}
+
+// AssertMessage asserts that a validation of the policy failed for the object with the message, whatever its
+// validation action.
+func (p *Policies) AssertMessage(t testing.TB, obj runtime.Object, policyName, message string) bool {
+	t.Helper()
+	result, ok := p.evaluate(t, obj, policyName)
+	if !ok {
+		return false
+	}
+	if result != nil && slices.ContainsFunc(result.ValidationErrors, func(e validator.ValidationError) bool {
+		return e.Message == message
+	}) {
+		return true
+	}
+	t.Errorf("expected policy %s to fail %s with the message %q, but %s", policyName, p.describeObject(obj), message, describeResult(result))
+	return false
+}
+
+// evaluate evaluates the policy against the object, failing the test if it cannot be evaluated.
+func (p *Policies) evaluate(t testing.TB, obj runtime.Object, policyName string) (*validator.ValidationResult, bool) {
+	t.Helper()
+	result, err := p.Evaluate(obj, policyName)
+	if err != nil {
+		t.Errorf("failed to evaluate policy %s against %s: %v", policyName, p.describeObject(obj), err)
+		return nil, false
+	}
+	return result, true
+}
+
+// denied reports whether a validation failed with the Deny validation action.
+func denied(result *validator.ValidationResult) bool {
+	if result == nil {
+		return false
+	}
+	return slices.ContainsFunc(result.ValidationErrors, func(e validator.ValidationError) bool {
+		return slices.Contains(e.Actions, v1.Deny)
+	})
+}
+
+// describeObject returns the kind, namespace and name of the object, e.g. Deployment default/web.
+func (p *Policies) describeObject(obj runtime.Object) string {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if kind == "" {
+		if gvks, _, err := p.Scheme.ObjectKinds(obj); err == nil {
+			kind = gvks[0].Kind
+		}
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return kind
+	}
+	if accessor.GetNamespace() != "" {
+		return fmt.Sprintf("%s %s/%s", kind, accessor.GetNamespace(), accessor.GetName())
+	}
+	return fmt.Sprintf("%s %s", kind, accessor.GetName())
+}
+
+// describeResult describes what the policy did with the object, listing the failed validations, e.g.
+//
+//	it denied it:
+//	  spec.validations[0] (Deny) object.spec.replicas <= 5: replicas must be at most 5
+func describeResult(result *validator.ValidationResult) string {
+	switch {
+	case result == nil:
+		return "the policy did not evaluate it, as no binding or match constraint of the policy matches it"
+	case result.Success:
+		return "all validations passed"
+	}
+	var b strings.Builder
+	switch {
+	case denied(result):
+		b.WriteString("it denied it:")
+	default:
+		b.WriteString("it allowed it:")
+	}
+	for _, e := range result.ValidationErrors {
+		actions := make([]string, len(e.Actions))
+		for i, action := range e.Actions {
+			actions[i] = string(action)
+		}
+		if e.Index < 0 {
+			fmt.Fprintf(&b, "\n  spec.auditAnnotations: %s", e.Message)
+			continue
+		}
+		fmt.Fprintf(&b, "\n  spec.validations[%d] (%s) %s: %s", e.Index, strings.Join(actions, ", "), strings.Join(strings.Fields(e.CELExpr), " "), e.Message)
+	}
+	return b.String()
+}
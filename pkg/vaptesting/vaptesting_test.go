@@ -0,0 +1,163 @@
+package vaptesting
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+)
+
+// recorder records the failures of assertions instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func newDeployment(replicas int32, labels map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(replicas),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "nginx:1.27"}}},
+			},
+		},
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	policies := LoadPolicies(t, "testdata/policies.yaml")
+	owned := map[string]string{"owner": "team-a"}
+	unowned := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "settings", "namespace": "default"},
+	}}
+
+	tests := []struct {
+		name       string
+		assert     func(t testing.TB) bool
+		wantErrors []string
+	}{
+		{
+			name:   "型付きのオブジェクトが拒否される",
+			assert: func(t testing.TB) bool { return policies.AssertDenied(t, newDeployment(10, owned), "replica-limit") },
+		},
+		{
+			name:   "型付きのオブジェクトが許可される",
+			assert: func(t testing.TB) bool { return policies.AssertAllowed(t, newDeployment(3, owned), "replica-limit") },
+		},
+		{
+			name:   "警告だけなら許可される",
+			assert: func(t testing.TB) bool { return policies.AssertAllowed(t, newDeployment(3, nil), "owner-label") },
+		},
+		{
+			name:   "非構造化オブジェクトに警告する",
+			assert: func(t testing.TB) bool { return policies.AssertWarns(t, unowned, "owner-label") },
+		},
+		{
+			name: "失敗した検証のメッセージ",
+			assert: func(t testing.TB) bool {
+				return policies.AssertMessage(t, newDeployment(10, owned), "replica-limit", "replicas must be at most 5")
+			},
+		},
+		{
+			name:   "許可されるオブジェクトを拒否と期待すると失敗する",
+			assert: func(t testing.TB) bool { return policies.AssertDenied(t, newDeployment(3, owned), "replica-limit") },
+			wantErrors: []string{
+				"expected policy replica-limit to deny Deployment default/web, but all validations passed",
+			},
+		},
+		{
+			name:   "拒否されるオブジェクトを許可と期待すると、失敗した検証を示す",
+			assert: func(t testing.TB) bool { return policies.AssertAllowed(t, newDeployment(10, owned), "replica-limit") },
+			wantErrors: []string{
+				"expected policy replica-limit to allow Deployment default/web, but it denied it:\n" +
+					"  spec.validations[0] (Deny) object.spec.replicas <= 5: replicas must be at most 5",
+			},
+		},
+		{
+			name:   "警告は拒否ではない",
+			assert: func(t testing.TB) bool { return policies.AssertDenied(t, unowned, "owner-label") },
+			wantErrors: []string{
+				"expected policy owner-label to deny ConfigMap default/settings, but it allowed it:\n" +
+					"  spec.validations[0] (Warn) has(object.metadata.labels) && 'owner' in object.metadata.labels: an owner label is recommended",
+			},
+		},
+		{
+			name:   "一致しないオブジェクトは評価されない",
+			assert: func(t testing.TB) bool { return policies.AssertWarns(t, unowned, "replica-limit") },
+			wantErrors: []string{
+				"expected policy replica-limit to warn about ConfigMap default/settings, but the policy did not evaluate it, as no binding or match constraint of the policy matches it",
+			},
+		},
+		{
+			name: "メッセージが異なる",
+			assert: func(t testing.TB) bool {
+				return policies.AssertMessage(t, newDeployment(10, owned), "replica-limit", "too many replicas")
+			},
+			wantErrors: []string{
+				"expected policy replica-limit to fail Deployment default/web with the message \"too many replicas\", but it denied it:\n" +
+					"  spec.validations[0] (Deny) object.spec.replicas <= 5: replicas must be at most 5",
+			},
+		},
+		{
+			name:   "読み込まれていないポリシー",
+			assert: func(t testing.TB) bool { return policies.AssertAllowed(t, newDeployment(3, owned), "missing") },
+			wantErrors: []string{
+				"failed to evaluate policy missing against Deployment default/web: policy missing is not loaded",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			ok := tt.assert(r)
+			assert.Equal(t, tt.wantErrors, r.errors)
+			assert.Equal(t, len(tt.wantErrors) == 0, ok)
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	policies := LoadPolicies(t, "testdata/policies.yaml")
+	deployment := newDeployment(10, nil)
+
+	result, err := policies.Evaluate(deployment, "replica-limit")
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.False(t, result.Success)
+	assert.Empty(t, deployment.Kind, "元のオブジェクトは変更しない")
+	assert.Nil(t, deployment.Spec.ProgressDeadlineSeconds, "デフォルト値は元のオブジェクトに設定しない")
+
+	_, err = policies.Evaluate(&runtime.Unknown{}, "replica-limit")
+	assert.ErrorContains(t, err, "failed to find the kind of the object")
+
+	// 起動したポリシーは次の評価でも使う
+	session := policies.session
+	require.NotNil(t, session)
+	deployment.Namespace = "production"
+	result, err = policies.Evaluate(deployment, "replica-limit")
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.False(t, result.Success)
+	assert.Same(t, session, policies.session)
+
+	policies.Close()
+	assert.Nil(t, policies.session)
+}
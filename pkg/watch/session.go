@@ -0,0 +1,455 @@
+// Package watch re-runs the validation of targets against policies when their manifest files change,
+// re-evaluating only the policy and target pairs affected by the change.
+package watch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/yashirook/vaptest/pkg/loader"
+	"github.com/yashirook/vaptest/pkg/target"
+	"github.com/yashirook/vaptest/pkg/validation"
+	"github.com/yashirook/vaptest/pkg/validator"
+	v1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Status is the outcome of a policy for a target it matches.
+type Status string
+
+const (
+	StatusPass Status = "PASS"
+	StatusFail Status = "FAIL"
+	// StatusError is a target for which no validation of the policy could be evaluated.
+	StatusError Status = "ERROR"
+)
+
+// Row is the outcome of a policy for a target it matches.
+type Row struct {
+	Policy string
+	Target target.TargetIdentifier
+	// Path is the file the target was loaded from.
+	Path   string
+	Status Status
+	// Messages are the messages of the failed validations and of the expressions that failed to evaluate.
+	Messages []string
+	// Previous is the status in the previous run, empty if the policy did not evaluate the target then.
+	Previous Status
+	// Changed reports that the status or the messages differ from the previous run, and Removed that the
+	// policy no longer evaluates the target, e.g. because the target was deleted.
+	Changed bool
+	Removed bool
+}
+
+// Summary is the outcome of a run.
+type Summary struct {
+	// Run is the number of the run, starting at 1.
+	Run      int
+	Policies int
+	Targets  int
+	// Evaluated is the number of policy and target pairs evaluated in the run, the others being unchanged.
+	Evaluated int
+	// Rows are the outcomes of the pairs, sorted by policy, file and target, followed by the removed pairs.
+	Rows []Row
+	// Errors are the files that failed to load and the policies that failed to compile. Their policies and
+	// targets are left out of the run until they are fixed.
+	Errors   []error
+	Warnings []error
+}
+
+// Count returns the number of rows with the status, removed rows excluded.
+func (s *Summary) Count(status Status) int {
+	n := 0
+	for _, row := range s.Rows {
+		if row.Status == status && !row.Removed {
+			n++
+		}
+	}
+	return n
+}
+
+// Session evaluates the targets against the policies with the native engine, keeping the compiled policies,
+// the targets and the outcomes of the previous run to re-evaluate only the pairs whose policy or target changed.
+type Session struct {
+	Scheme      *runtime.Scheme
+	PolicyPaths []string
+	TargetPaths []string
+	// RESTMapper resolves resources. Nil means the built-in API catalog.
+	RESTMapper meta.RESTMapper
+	// Strict rejects unknown fields and duplicate fields in manifests, and reports them and values of the wrong
+	// type with the document and the field path, as loader.Loader.Strict does.
+	Strict bool
+	// Defaulting applies Kubernetes API defaulting to targets before evaluation.
+	Defaulting bool
+
+	run         int
+	policyFiles map[string]*policyFile
+	targetFiles map[string]*targetFile
+	compiled    map[string]*compiledPolicy
+	targets     map[targetKey]*target.TargetInfo
+	// rows are the outcomes of the previous run, including those of the pairs left out of it because of errors.
+	rows map[pairKey]Row
+}
+
+// policyFile holds the policies of a file. When the file fails to load, policies are those of its last
+// successful load.
+type policyFile struct {
+	policies []*v1.ValidatingAdmissionPolicy
+	warnings []error
+	err      error
+}
+
+// targetFile holds the targets of a file. When the file fails to load, targets are those of its last
+// successful load.
+type targetFile struct {
+	targets  []target.TargetInfo
+	warnings []error
+	err      error
+}
+
+// compiledPolicy is a policy with its compiled programs, or the error compiling it.
+type compiledPolicy struct {
+	policy   *v1.ValidatingAdmissionPolicy
+	compiled *validator.CompiledPolicy
+	err      error
+}
+
+// targetKey identifies a target across runs. n tells apart targets with the same identifier in a file.
+type targetKey struct {
+	path string
+	id   target.TargetIdentifier
+	n    int
+}
+
+type pairKey struct {
+	policy string
+	target targetKey
+}
+
+// NewSession creates a Session of the policies and targets at the paths with API defaulting enabled.
+func NewSession(scheme *runtime.Scheme, policyPaths, targetPaths []string) *Session {
+	return &Session{Scheme: scheme, PolicyPaths: policyPaths, TargetPaths: targetPaths, Defaulting: true}
+}
+
+// Run reloads the changed files, or all files on the first run, and evaluates the pairs of a changed policy or
+// a changed target. The other pairs keep the outcome of the previous run.
+func (s *Session) Run(changed []string) *Summary {
+	s.run++
+	if s.policyFiles == nil {
+		s.policyFiles = map[string]*policyFile{}
+		s.targetFiles = map[string]*targetFile{}
+		for _, path := range expand(s.PolicyPaths) {
+			s.loadPolicyFile(path)
+		}
+		for _, path := range expand(s.TargetPaths) {
+			s.loadTargetFile(path)
+		}
+	} else {
+		for _, path := range changed {
+			if under(path, s.PolicyPaths) {
+				s.loadPolicyFile(path)
+			}
+			if under(path, s.TargetPaths) {
+				s.loadTargetFile(path)
+			}
+		}
+	}
+
+	summary := &Summary{Run: s.run}
+	stalePolicies, dirtyPolicies := s.compilePolicies(summary)
+	staleFiles, dirtyTargets := s.collectTargets(summary)
+	summary.Policies = len(s.compiled)
+	summary.Targets = len(s.targets)
+
+	equivalents := target.NewEquivalentResourceMapper(s.mapper(), s.Scheme)
+	rows := map[pairKey]Row{}
+	for _, name := range sortedKeys(s.compiled) {
+		c := s.compiled[name]
+		if c.err != nil {
+			continue
+		}
+		for key, t := range s.targets {
+			pair := pairKey{name, key}
+			if !dirtyPolicies[name] && !dirtyTargets[key] {
+				if prev, ok := s.rows[pair]; ok {
+					rows[pair] = prev
+				}
+				continue
+			}
+			summary.Evaluated++
+			if row, ok := evaluate(c.compiled, key.path, t, equivalents); ok {
+				rows[pair] = row
+			}
+		}
+	}
+
+	var removed []Row
+	for pair, prev := range s.rows {
+		if _, ok := rows[pair]; ok {
+			continue
+		}
+		if stalePolicies[pair.policy] || staleFiles[pair.target.path] {
+			// エラーで評価できないペアは表示せず、修正後の比較のために前回の結果を残す
+			continue
+		}
+		removed = append(removed, Row{Policy: prev.Policy, Target: prev.Target, Path: prev.Path, Status: prev.Status, Messages: prev.Messages, Previous: prev.Status, Changed: true, Removed: true})
+	}
+	for pair, row := range rows {
+		prev, ok := s.rows[pair]
+		row.Previous = prev.Status
+		row.Changed = s.run > 1 && (!ok || prev.Status != row.Status || !slices.Equal(prev.Messages, row.Messages))
+		summary.Rows = append(summary.Rows, row)
+	}
+	sortRows(summary.Rows)
+	sortRows(removed)
+	summary.Rows = append(summary.Rows, removed...)
+
+	for pair, prev := range s.rows {
+		if _, ok := rows[pair]; !ok && (stalePolicies[pair.policy] || staleFiles[pair.target.path]) {
+			rows[pair] = prev
+		}
+	}
+	s.rows = rows
+	return summary
+}
+
+// compilePolicies compiles the policies of the files that changed since the previous run. It returns the names
+// of the policies left out of the run because of errors, and of the policies compiled in this run.
+func (s *Session) compilePolicies(summary *Summary) (stale, dirty map[string]bool) {
+	stale, dirty = map[string]bool{}, map[string]bool{}
+	policies := map[string]*v1.ValidatingAdmissionPolicy{}
+	sources := map[string]string{}
+	for _, path := range sortedKeys(s.policyFiles) {
+		f := s.policyFiles[path]
+		summary.Warnings = append(summary.Warnings, f.warnings...)
+		if f.err != nil {
+			summary.Errors = append(summary.Errors, f.err)
+			for _, policy := range f.policies {
+				stale[policy.Name] = true
+			}
+			continue
+		}
+		for _, policy := range f.policies {
+			if source, ok := sources[policy.Name]; ok {
+				summary.Errors = append(summary.Errors, fmt.Errorf("policy %s in %s is already defined in %s", policy.Name, path, source))
+				continue
+			}
+			policies[policy.Name] = policy
+			sources[policy.Name] = path
+		}
+	}
+
+	compiled := make(map[string]*compiledPolicy, len(policies))
+	for _, name := range sortedKeys(policies) {
+		policy := policies[name]
+		c, ok := s.compiled[name]
+		if !ok || !equality.Semantic.DeepEqual(c.policy.Spec, policy.Spec) {
+			c = &compiledPolicy{policy: policy}
+			c.compiled, c.err = validator.CompilePolicy(policy)
+			dirty[name] = true
+		}
+		if c.err != nil {
+			summary.Errors = append(summary.Errors, fmt.Errorf("%s: %w", sources[name], c.err))
+			stale[name] = true
+		}
+		compiled[name] = c
+	}
+	s.compiled = compiled
+	return stale, dirty
+}
+
+// collectTargets collects the targets of the files. It returns the files left out of the run because of
+// errors, and the targets that changed since the previous run.
+func (s *Session) collectTargets(summary *Summary) (stale map[string]bool, dirty map[targetKey]bool) {
+	stale, dirty = map[string]bool{}, map[targetKey]bool{}
+	targets := map[targetKey]*target.TargetInfo{}
+	for _, path := range sortedKeys(s.targetFiles) {
+		f := s.targetFiles[path]
+		summary.Warnings = append(summary.Warnings, f.warnings...)
+		if f.err != nil {
+			summary.Errors = append(summary.Errors, f.err)
+			stale[path] = true
+			continue
+		}
+		counts := map[target.TargetIdentifier]int{}
+		for i := range f.targets {
+			t := &f.targets[i]
+			key := targetKey{path: path, id: t.TargetIdentifier, n: counts[t.TargetIdentifier]}
+			counts[t.TargetIdentifier]++
+			targets[key] = t
+			if prev, ok := s.targets[key]; !ok || !reflect.DeepEqual(prev.Object, t.Object) {
+				dirty[key] = true
+			}
+		}
+	}
+	s.targets = targets
+	return stale, dirty
+}
+
+// loadPolicyFile loads the policies of the file, or forgets the file if it was removed.
+func (s *Session) loadPolicyFile(path string) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) && s.policyFiles[path] != nil {
+		delete(s.policyFiles, path)
+		return
+	}
+	ldr := loader.NewLoader(s.Scheme)
+	ldr.Strict = s.Strict
+	f := &policyFile{}
+	policies, bindings, err := ldr.LoadPolicyFromPaths([]string{path})
+	if err == nil {
+		if errs := validation.ValidatePolicyObjects(policies, bindings, s.Scheme, ldr.Source); len(errs) > 0 {
+			err = fmt.Errorf("invalid policy object: %w", errors.Join(errs...))
+		}
+	}
+	if err != nil {
+		f.err = err
+		if prev := s.policyFiles[path]; prev != nil {
+			f.policies = prev.policies
+		}
+	} else {
+		f.policies = policies
+	}
+	f.warnings = ldr.Warnings
+	s.policyFiles[path] = f
+}
+
+// loadTargetFile loads the targets of the file, or forgets the file if it was removed.
+func (s *Session) loadTargetFile(path string) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) && s.targetFiles[path] != nil {
+		delete(s.targetFiles, path)
+		return
+	}
+	ldr := loader.NewLoader(s.Scheme)
+	ldr.Strict = s.Strict
+	f := &targetFile{}
+	targets, err := s.loadTargets(ldr, path)
+	if err != nil {
+		f.err = err
+		if prev := s.targetFiles[path]; prev != nil {
+			f.targets = prev.targets
+		}
+	} else {
+		f.targets = targets
+	}
+	f.warnings = ldr.Warnings
+	s.targetFiles[path] = f
+}
+
+func (s *Session) loadTargets(ldr *loader.Loader, path string) (target.TargetInfoList, error) {
+	objects, err := ldr.LoadObjectFromPaths([]string{path})
+	if err != nil {
+		return nil, err
+	}
+	var opts []target.Option
+	if s.Defaulting {
+		opts = append(opts, target.WithDefaulting(s.Scheme))
+	}
+	targets, err := target.NewTargetInfoListWithMapper(objects, s.mapper(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to create target info list: %w", path, err)
+	}
+	return targets, nil
+}
+
+// mapper returns the RESTMapper of the session, or the built-in API catalog.
+func (s *Session) mapper() meta.RESTMapper {
+	if s.RESTMapper != nil {
+		return s.RESTMapper
+	}
+	return target.StaticRESTMapper(s.Scheme)
+}
+
+// evaluate evaluates the policy against the target. It returns false if the policy does not match the target.
+func evaluate(compiled *validator.CompiledPolicy, path string, t *target.TargetInfo, equivalents *target.EquivalentResourceMapper) (Row, bool) {
+	row := Row{Policy: compiled.Policy.Name, Target: t.TargetIdentifier, Path: path}
+	results, evalErrors, err := compiled.EvaluateWithMapper(target.TargetInfoList{*t}, equivalents)
+	if err != nil {
+		row.Status = StatusError
+		row.Messages = []string{err.Error()}
+		return row, true
+	}
+	if len(results) > 0 {
+		row.Status = StatusPass
+		for _, e := range results[0].ValidationErrors {
+			row.Status = StatusFail
+			message := e.Message
+			if message == "" {
+				message = "failed expression: " + strings.TrimSpace(e.CELExpr)
+			}
+			row.Messages = append(row.Messages, message)
+		}
+	}
+	for _, msg := range evalErrors {
+		row.Messages = append(row.Messages, strings.TrimSpace(msg))
+	}
+	if row.Status == "" {
+		if len(evalErrors) == 0 {
+			return Row{}, false
+		}
+		row.Status = StatusError
+	}
+	return row, true
+}
+
+// expand returns the files at the paths, listing the files directly in directories as the loader does.
+// Hidden and backup files in directories are left out. Paths that do not exist are returned as they are,
+// so that they are reported when they fail to load.
+func expand(paths []string) []string {
+	var files []string
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			files = append(files, path)
+			continue
+		}
+		for _, entry := range entries {
+			file := filepath.Join(path, entry.Name())
+			if !entry.IsDir() && !ignored(file) {
+				files = append(files, file)
+			}
+		}
+	}
+	return files
+}
+
+// under reports whether the file is one of the paths or directly in one of them.
+func under(file string, paths []string) bool {
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if file == path || filepath.Dir(file) == path {
+			return true
+		}
+	}
+	return false
+}
+
+func sortRows(rows []Row) {
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Policy != b.Policy {
+			return a.Policy < b.Policy
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return fmt.Sprint(a.Target) < fmt.Sprint(b.Target)
+	})
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,150 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yashirook/vaptest/pkg/defaults"
+)
+
+// copyFile copies the testdata file to the path, replacing old with new in its content.
+func copyFile(t *testing.T, name, path string, replacements ...string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	content := strings.NewReplacer(replacements...).Replace(string(data))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+type row struct {
+	policy  string
+	name    string
+	status  Status
+	result  string
+	changed bool
+}
+
+func rowsOf(summary *Summary) []row {
+	var rows []row
+	for _, r := range summary.Rows {
+		result := string(r.Previous)
+		if r.Removed {
+			result = "removed"
+		}
+		rows = append(rows, row{r.Policy, r.Target.ResourceName, r.Status, result, r.Changed})
+	}
+	return rows
+}
+
+func TestSessionRun(t *testing.T) {
+	dir := t.TempDir()
+	policy := filepath.Join(dir, "policy.yaml")
+	targets := filepath.Join(dir, "targets")
+	require.NoError(t, os.Mkdir(targets, 0o755))
+	deployments := filepath.Join(targets, "deployments.yaml")
+	copyFile(t, "policy.yaml", policy)
+	copyFile(t, "deployments.yaml", deployments)
+	// 隠しファイルは読み込まない
+	require.NoError(t, os.WriteFile(filepath.Join(targets, ".deployments.yaml.swp"), []byte("\x00"), 0o644))
+
+	session := NewSession(defaults.NewScheme(), []string{policy}, []string{targets})
+
+	summary := session.Run(nil)
+	assert.Empty(t, summary.Errors)
+	assert.Equal(t, 1, summary.Run)
+	assert.Equal(t, 1, summary.Policies)
+	assert.Equal(t, 2, summary.Targets)
+	assert.Equal(t, 2, summary.Evaluated)
+	assert.Equal(t, []row{
+		{"replica-limit", "api", StatusPass, "", false},
+		{"replica-limit", "web", StatusPass, "", false},
+	}, rowsOf(summary), "最初の実行では変更として扱わない")
+
+	// 変更されたターゲットだけを評価する
+	copyFile(t, "deployments.yaml", deployments, "replicas: 3", "replicas: 10")
+	summary = session.Run([]string{deployments})
+	assert.Equal(t, 1, summary.Evaluated)
+	assert.Equal(t, []row{
+		{"replica-limit", "api", StatusPass, "PASS", false},
+		{"replica-limit", "web", StatusFail, "PASS", true},
+	}, rowsOf(summary))
+	assert.Equal(t, []string{"replicas must be at most 5"}, summary.Rows[1].Messages)
+
+	// ディレクトリに追加されたファイルを読み込む
+	added := filepath.Join(targets, "added.yaml")
+	copyFile(t, "deployments.yaml", added, "name: web", "name: added", "name: api", "name: added-api")
+	summary = session.Run([]string{added})
+	assert.Equal(t, 2, summary.Evaluated)
+	assert.Equal(t, 4, summary.Targets)
+	assert.Equal(t, []row{
+		{"replica-limit", "added", StatusPass, "", true},
+		{"replica-limit", "added-api", StatusPass, "", true},
+		{"replica-limit", "api", StatusPass, "PASS", false},
+		{"replica-limit", "web", StatusFail, "FAIL", false},
+	}, rowsOf(summary))
+
+	// コンパイルエラーは終了せずに報告し、ポリシーの結果は削除として扱わない
+	copyFile(t, "policy.yaml", policy, "object.spec.replicas <= 5", "object.spec.((")
+	summary = session.Run([]string{policy})
+	require.Len(t, summary.Errors, 1)
+	assert.Contains(t, summary.Errors[0].Error(), "spec.validations[0].expression")
+	assert.Empty(t, summary.Rows)
+
+	// 修正後は前回の結果と比較する
+	copyFile(t, "policy.yaml", policy, "object.spec.replicas <= 5", "object.spec.replicas <= 2")
+	summary = session.Run([]string{policy})
+	assert.Empty(t, summary.Errors)
+	assert.Equal(t, 4, summary.Evaluated)
+	assert.Equal(t, []row{
+		{"replica-limit", "added", StatusFail, "PASS", true},
+		{"replica-limit", "added-api", StatusPass, "PASS", false},
+		{"replica-limit", "api", StatusPass, "PASS", false},
+		{"replica-limit", "web", StatusFail, "FAIL", false},
+	}, rowsOf(summary))
+
+	// 削除されたファイルのターゲットは削除として報告する
+	require.NoError(t, os.Remove(added))
+	summary = session.Run([]string{added})
+	assert.Equal(t, 0, summary.Evaluated)
+	assert.Equal(t, []row{
+		{"replica-limit", "api", StatusPass, "PASS", false},
+		{"replica-limit", "web", StatusFail, "FAIL", false},
+		{"replica-limit", "added", StatusFail, "removed", true},
+		{"replica-limit", "added-api", StatusPass, "removed", true},
+	}, rowsOf(summary))
+	assert.Equal(t, 1, summary.Count(StatusFail), "削除された行は数えない")
+}
+
+func TestSessionRunLoadError(t *testing.T) {
+	dir := t.TempDir()
+	policy := filepath.Join(dir, "policy.yaml")
+	deployments := filepath.Join(dir, "deployments.yaml")
+	copyFile(t, "policy.yaml", policy)
+	copyFile(t, "deployments.yaml", deployments)
+	session := NewSession(defaults.NewScheme(), []string{policy}, []string{deployments})
+	require.Len(t, session.Run(nil).Rows, 2)
+
+	// 読み込めないターゲットのファイルは前回の結果を残して評価から外す
+	require.NoError(t, os.WriteFile(deployments, []byte("kind: [\n"), 0o644))
+	summary := session.Run([]string{deployments})
+	require.Len(t, summary.Errors, 1)
+	assert.Contains(t, summary.Errors[0].Error(), deployments)
+	assert.Empty(t, summary.Rows)
+
+	copyFile(t, "deployments.yaml", deployments)
+	summary = session.Run([]string{deployments})
+	assert.Empty(t, summary.Errors)
+	assert.Equal(t, []row{
+		{"replica-limit", "api", StatusPass, "PASS", false},
+		{"replica-limit", "web", StatusPass, "PASS", false},
+	}, rowsOf(summary))
+
+	// 監視対象外のファイルの変更は無視する
+	summary = session.Run([]string{filepath.Join(t.TempDir(), "other.yaml")})
+	assert.Equal(t, 0, summary.Evaluated)
+	assert.Len(t, summary.Rows, 2)
+}
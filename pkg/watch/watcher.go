@@ -0,0 +1,121 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// settleDelay is how long the watcher waits for further events after one, so that an editor saving a file
+// in several steps triggers a single run.
+const settleDelay = 100 * time.Millisecond
+
+// Watcher reports changes of manifest files through inotify. A file path is watched through its directory, so
+// that files replaced by editors on save are still watched. A directory path is watched for changes of the
+// files directly in it, including files created after the watcher, as the loader reads directories without
+// descending into subdirectories.
+type Watcher struct {
+	watcher *fsnotify.Watcher
+	// files are the watched file paths and dirs the watched directory paths.
+	files map[string]bool
+	dirs  map[string]bool
+}
+
+// NewWatcher watches the file and directory paths.
+func NewWatcher(paths []string) (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+	w := &Watcher{watcher: fw, files: map[string]bool{}, dirs: map[string]bool{}}
+	added := map[string]bool{}
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		info, err := os.Stat(path)
+		if err != nil {
+			fw.Close()
+			return nil, fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		dir := path
+		if info.IsDir() {
+			w.dirs[path] = true
+		} else {
+			w.files[path] = true
+			dir = filepath.Dir(path)
+		}
+		if added[dir] {
+			continue
+		}
+		if err := fw.Add(dir); err != nil {
+			fw.Close()
+			return nil, fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+		added[dir] = true
+	}
+	return w, nil
+}
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	return w.watcher.Close()
+}
+
+// Next blocks until watched files change and returns their paths, sorted. Files created, written, removed or
+// renamed shortly after one another are returned together.
+func (w *Watcher) Next(ctx context.Context) ([]string, error) {
+	changed := map[string]bool{}
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return nil, fmt.Errorf("watcher closed")
+			}
+			return nil, fmt.Errorf("failed to watch files: %w", err)
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return nil, fmt.Errorf("watcher closed")
+			}
+			if event.Op == fsnotify.Chmod || !w.watched(event.Name) {
+				continue
+			}
+			changed[event.Name] = true
+			settle = time.After(settleDelay)
+		case <-settle:
+			paths := make([]string, 0, len(changed))
+			for path := range changed {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			return paths, nil
+		}
+	}
+}
+
+// watched reports whether the path is a watched file or a manifest file directly in a watched directory.
+func (w *Watcher) watched(path string) bool {
+	if w.files[path] {
+		return true
+	}
+	if !w.dirs[filepath.Dir(path)] || ignored(path) {
+		return false
+	}
+	info, err := os.Stat(path)
+	// 削除されたファイルも変更として扱う
+	return err != nil || !info.IsDir()
+}
+
+// ignored reports whether the file in a watched directory is a hidden file or a backup file, such as the swap
+// files editors write next to the file being edited.
+func ignored(path string) bool {
+	name := filepath.Base(path)
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~")
+}
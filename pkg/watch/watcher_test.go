@@ -0,0 +1,54 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcherNext(t *testing.T) {
+	dir := t.TempDir()
+	other := t.TempDir()
+	file := filepath.Join(other, "policy.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("a"), 0o644))
+
+	w, err := NewWatcher([]string{dir, file})
+	require.NoError(t, err)
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// ディレクトリに作成されたファイルと、監視するファイルの変更を報告する
+	created := filepath.Join(dir, "new.yaml")
+	require.NoError(t, os.WriteFile(created, []byte("a"), 0o644))
+	require.NoError(t, os.WriteFile(file, []byte("b"), 0o644))
+	// 隠しファイル、サブディレクトリ、監視するファイルと同じディレクトリの別のファイルは無視する
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".new.yaml.swp"), []byte("a"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(other, "other.yaml"), []byte("a"), 0o644))
+
+	changed, err := w.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{created, file}, changed)
+
+	// 削除されたファイルを報告する
+	require.NoError(t, os.Remove(created))
+	changed, err = w.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{created}, changed)
+
+	cancel()
+	_, err = w.Next(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestNewWatcherMissingPath(t *testing.T) {
+	_, err := NewWatcher([]string{filepath.Join(t.TempDir(), "missing.yaml")})
+	assert.ErrorContains(t, err, "failed to watch")
+}
@@ -0,0 +1,101 @@
+package e2e
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type FuzzE2ETest struct {
+	name                  string
+	policyPaths           []string
+	crdPaths              []string
+	expectedError         bool
+	expectedErrorMessages []string
+	expectedResults       []string
+	expectedFixtures      []string
+}
+
+func TestFuzz(t *testing.T) {
+	testCases := []FuzzE2ETest{
+		// 部品のないWidgetでの評価エラーを縮小したオブジェクトとともに報告する
+		{
+			name:          "fuzz_eval_error",
+			policyPaths:   []string{"testdata/17_fuzz/policy.yaml"},
+			crdPaths:      []string{"testdata/17_fuzz/crd.yaml"},
+			expectedError: true,
+			expectedResults: []string{
+				"eval-error  widget-parts  spec.validations[0]  Widget",
+				"1 findings in 20 objects of 1 kinds (seed 1)",
+			},
+			expectedFixtures: []string{"widget-parts-0-eval-error.yaml"},
+		},
+		// ガードされた式では問題が見つからない
+		{
+			name:          "fuzz_guarded",
+			policyPaths:   []string{"testdata/17_fuzz/guarded-policy.yaml"},
+			crdPaths:      []string{"testdata/17_fuzz/crd.yaml"},
+			expectedError: false,
+			expectedResults: []string{
+				"0 findings in 20 objects of 1 kinds (seed 1)",
+			},
+		},
+		// CRDがなければカスタムリソースのオブジェクトは生成できない
+		{
+			name:          "fuzz_without_crd",
+			policyPaths:   []string{"testdata/17_fuzz/policy.yaml"},
+			expectedError: false,
+			expectedErrorMessages: []string{
+				"warning: policy widget-parts: no matches for example.com/v1, Resource=widgets",
+			},
+			expectedResults: []string{
+				"0 findings in 0 objects of 0 kinds (seed 1)",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fixtures := filepath.Join(t.TempDir(), "fixtures")
+			args := []string{"fuzz", "--seed", "1", "--iterations", "20", "--fixtures", fixtures}
+			for _, p := range tc.policyPaths {
+				args = append(args, "--policies", p)
+			}
+			for _, p := range tc.crdPaths {
+				args = append(args, "--crds", p)
+			}
+
+			cmd := exec.Command("../../bin/vaptest", args...)
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+
+			for _, expectedError := range tc.expectedErrorMessages {
+				assert.Contains(t, stderr.String(), expectedError, "期待するエラーメッセージが含まれていること")
+			}
+			assert.NotContains(t, stderr.String(), "unknown resource type", "CRDの読み込みで警告しないこと")
+			for _, expectedResult := range tc.expectedResults {
+				assert.Contains(t, stdout.String(), expectedResult, "期待する出力が含まれていること")
+			}
+			if tc.expectedError {
+				assert.Error(t, err, "エラーが発生することを期待しています")
+			} else {
+				assert.NoError(t, err, "エラーが発生しないことを期待しています")
+			}
+
+			for _, name := range tc.expectedFixtures {
+				fixture, err := os.ReadFile(filepath.Join(fixtures, name))
+				require.NoError(t, err, "縮小したオブジェクトが書き出されること")
+				// 再現に必要な最小のオブジェクトであり、そのままvalidateで使える
+				assert.Contains(t, string(fixture), "kind: Widget")
+				assert.NotContains(t, string(fixture), "parts:")
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package e2e
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type GenerateE2ETest struct {
+	name                  string
+	policyPaths           []string
+	seedPaths             []string
+	expectedError         bool
+	expectedErrorMessages []string
+	expectedResults       []string
+}
+
+func TestGenerateCases(t *testing.T) {
+	testCases := []GenerateE2ETest{
+		// 検証ごとに成り立つケースと違反するケースを生成し、生成したスイートがそのまま成功する
+		{
+			name:          "generate_cases",
+			policyPaths:   []string{"testdata/16_generate_cases/policy.yaml"},
+			seedPaths:     []string{"testdata/16_generate_cases/seeds.yaml"},
+			expectedError: false,
+			expectedResults: []string{
+				"PASS  deployment-standards: all validations hold for patched Deployment default/api",
+				"PASS  deployment-standards: spec.validations[0] is violated by patched Deployment default/api",
+				"PASS  deployment-standards: spec.validations[1] is violated by patched Deployment default/api",
+				"PASS  deployment-standards: spec.validations[2] is violated by patched Deployment default/api",
+				"4 passed, 0 failed",
+			},
+		},
+		// ポリシーに一致しないシードからはケースを生成できない
+		{
+			name:          "generate_cases_unmatched_seed",
+			policyPaths:   []string{"testdata/16_generate_cases/policy.yaml"},
+			seedPaths:     []string{"testdata/16_generate_cases/configmap.yaml"},
+			expectedError: true,
+			expectedErrorMessages: []string{
+				"no case generated for policy deployment-standards spec.validations[0] holding: the policy is not evaluated for any seed resource",
+				"no test cases generated from the seeds",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "suite.yaml")
+			args := []string{"generate-cases", "--output", output}
+			for _, p := range tc.policyPaths {
+				args = append(args, "--policies", p)
+			}
+			for _, p := range tc.seedPaths {
+				args = append(args, "--seeds", p)
+			}
+
+			cmd := exec.Command("../../bin/vaptest", args...)
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+
+			for _, expectedError := range tc.expectedErrorMessages {
+				assert.Contains(t, stderr.String(), expectedError, "期待するエラーメッセージが含まれていること")
+			}
+			if tc.expectedError {
+				assert.Error(t, err, "エラーが発生することを期待しています")
+				return
+			}
+			require.NoError(t, err, "エラーが発生しないことを期待しています")
+			_, err = os.Stat(output)
+			require.NoError(t, err, "テストスイートが書き出されること")
+
+			// 生成したスイートを別のディレクトリからそのまま実行できる
+			var stdout bytes.Buffer
+			cmd = exec.Command("../../bin/vaptest", "test", output)
+			cmd.Stdout = &stdout
+			assert.NoError(t, cmd.Run(), "生成したスイートが成功すること")
+			for _, expectedResult := range tc.expectedResults {
+				assert.Contains(t, stdout.String(), expectedResult, "期待する出力が含まれていること")
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package e2e
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type LintE2ETest struct {
+	name            string
+	policyPaths     []string
+	failOn          string
+	expectedError   bool
+	expectedResults []string
+}
+
+func TestLint(t *testing.T) {
+	testCases := []LintE2ETest{
+		// 検証アクションのないバインディングはエラーとして失敗する
+		{
+			name:          "lint_findings",
+			policyPaths:   []string{"testdata/18_lint/policy.yaml"},
+			expectedError: true,
+			expectedResults: []string{
+				"warning   unused-variable             ValidatingAdmissionPolicy/team-label         spec.variables[1]               variable owner is not used by any expression",
+				"object.metadata.labels may be absent in Deployment, so accessing object.metadata.labels.team needs a has(object.metadata.labels) guard",
+				"missing-message",
+				"expression always evaluates to true, so the validation never fails",
+				"error     missing-validation-actions  ValidatingAdmissionPolicyBinding/team-label  spec.validationActions          binding has no validationActions",
+				"1 errors, 5 warnings in 1 policies and 1 bindings",
+			},
+		},
+		// 警告だけでは失敗しない
+		{
+			name:          "lint_fail_on_none",
+			policyPaths:   []string{"testdata/18_lint/policy.yaml"},
+			failOn:        "none",
+			expectedError: false,
+			expectedResults: []string{
+				"1 errors, 5 warnings in 1 policies and 1 bindings",
+			},
+		},
+		// 迂回できるマッチ範囲とコンテナの検査を説明とともに指摘する
+		{
+			name:          "lint_security",
+			policyPaths:   []string{"testdata/18_lint/security-policy.yaml"},
+			failOn:        "warning",
+			expectedError: true,
+			expectedResults: []string{
+				"rule matches CREATE but not UPDATE of pods, so an object created valid can be updated to violate the policy",
+				"matchPolicy is Exact, so requests through apps/v1beta1 deployments, apps/v1beta2 deployments, extensions/v1beta1 deployments, which serve the same objects, bypass the policy",
+				"namespace sandbox is excluded by name; if it does not exist, any user allowed to create namespaces can create it and bypass the policy",
+				"pods is matched but pods/ephemeralcontainers is not; UPDATE of pods/ephemeralcontainers adds containers to a running pod without the policy evaluating it",
+				"expressions check containers but not initContainers or ephemeralContainers",
+				"0 errors, 5 warnings in 1 policies and 1 bindings",
+			},
+		},
+		// ポリシーの注釈はバインディングの指摘も抑制する
+		{
+			name:          "lint_suppressed",
+			policyPaths:   []string{"testdata/18_lint/suppressed-policy.yaml"},
+			failOn:        "warning",
+			expectedError: false,
+			expectedResults: []string{
+				"0 errors, 0 warnings in 1 policies and 1 bindings",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := []string{"lint"}
+			for _, p := range tc.policyPaths {
+				args = append(args, "--policies", p)
+			}
+			if tc.failOn != "" {
+				args = append(args, "--fail-on", tc.failOn)
+			}
+
+			cmd := exec.Command("../../bin/vaptest", args...)
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+
+			for _, expectedResult := range tc.expectedResults {
+				assert.Contains(t, stdout.String(), expectedResult, "期待する出力が含まれていること")
+			}
+			if tc.expectedError {
+				assert.Error(t, err, "エラーが発生することを期待しています")
+			} else {
+				assert.NoError(t, err, "エラーが発生しないことを期待しています")
+			}
+		})
+	}
+}
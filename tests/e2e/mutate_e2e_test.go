@@ -0,0 +1,78 @@
+package e2e
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type MutateE2ETest struct {
+	name                  string
+	suitePaths            []string
+	flags                 []string
+	expectedError         bool
+	expectedErrorMessages []string
+	expectedResults       []string
+}
+
+func TestMutate(t *testing.T) {
+	testCases := []MutateE2ETest{
+		// テストケースが検出しなかった変異体を変更内容とともに報告する
+		{
+			name:          "mutate_survivors",
+			suitePaths:    []string{"testdata/15_mutation"},
+			expectedError: false,
+			expectedResults: []string{
+				`spec.validations[0].expression                     change-threshold   killed by "deployment over the limit is denied"`,
+				"spec.matchConstraints.resourceRules[0].operations  widen-rule         survived",
+				"SURVIVED MUTANTS",
+				"#4 replica-limit, spec.matchConstraints.resourceRules[0].operations (widen-rule):",
+				"  - [CREATE, UPDATE]",
+				"  + [*]",
+				"mutation score: 4/6 killed (66.7%)",
+			},
+		},
+		{
+			name:                  "mutate_min_score",
+			suitePaths:            []string{"testdata/15_mutation"},
+			flags:                 []string{"--min-score=80"},
+			expectedError:         true,
+			expectedErrorMessages: []string{"mutation score 66.7% is below the minimum of 80.0%"},
+		},
+		// 変異させる前のスイートが失敗する場合は実行しない
+		{
+			name:                  "mutate_failing_suite",
+			suitePaths:            []string{"testdata/invalid/04_test_suite_mismatch/suite.yaml"},
+			expectedError:         true,
+			expectedErrorMessages: []string{"must pass before mutation testing"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := append([]string{"mutate"}, tc.suitePaths...)
+			args = append(args, tc.flags...)
+
+			cmd := exec.Command("../../bin/vaptest", args...)
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+
+			err := cmd.Run()
+
+			if tc.expectedError {
+				assert.Error(t, err, "エラーが発生することを期待しています")
+			} else {
+				assert.NoError(t, err, "エラーが発生しないことを期待しています")
+			}
+			for _, expectedError := range tc.expectedErrorMessages {
+				assert.Contains(t, stderr.String(), expectedError, "期待するエラーメッセージが含まれていること")
+			}
+			for _, expectedResult := range tc.expectedResults {
+				assert.Contains(t, stdout.String(), expectedResult, "期待する出力が含まれていること")
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package e2e
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type REPLE2ETest struct {
+	name            string
+	args            []string
+	input           []string
+	expectedError   bool
+	expectedResults []string
+}
+
+func TestREPL(t *testing.T) {
+	testCases := []REPLE2ETest{
+		// 標準入力の式を順に評価し、値、型、コストを出力する
+		{
+			name: "repl_update_request",
+			args: []string{
+				"--object", "testdata/19_repl/deployment.yaml",
+				"--old-object", "testdata/19_repl/old-deployment.yaml",
+				"--params", "testdata/19_repl/params.yaml",
+			},
+			input: []string{
+				"object.spec.replicas > oldObject.spec.replicas",
+				"request.operation",
+				"int(params.data.maxReplicas) >= object.spec.replicas",
+				"namespaceObject.metadata.name",
+				"object.spec.nope",
+			},
+			expectedError: false,
+			expectedResults: []string{
+				"true\ntype: bool, cost: 7\n",
+				"\"UPDATE\"\ntype: string, cost: 2\n",
+				"true\ntype: bool, cost: 8\n",
+				"\"shop\"\ntype: string, cost: 3\n",
+				"error: no such key: nope\ntype: dyn, cost: 3\n",
+			},
+		},
+		// 選択したバージョンでまだ使えないライブラリはコンパイルエラーになる
+		{
+			name:            "repl_kubernetes_version",
+			args:            []string{"--object", "testdata/19_repl/deployment.yaml", "--kubernetes-version", "1.30"},
+			input:           []string{"isIP('10.0.0.1')", "request.operation"},
+			expectedError:   false,
+			expectedResults: []string{"undeclared reference to 'isIP'", "\"CREATE\"\n"},
+		},
+		// オブジェクトは必須
+		{
+			name:          "repl_without_object",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := exec.Command("../../bin/vaptest", append([]string{"repl"}, tc.args...)...)
+			cmd.Stdin = strings.NewReader(strings.Join(tc.input, "\n") + "\n")
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+
+			for _, expectedResult := range tc.expectedResults {
+				assert.Contains(t, stdout.String(), expectedResult, "期待する出力が含まれていること")
+			}
+			if tc.expectedError {
+				assert.Error(t, err, "エラーが発生することを期待しています")
+			} else {
+				assert.NoError(t, err, "エラーが発生しないことを期待しています")
+			}
+		})
+	}
+}
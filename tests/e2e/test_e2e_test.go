@@ -0,0 +1,142 @@
+package e2e
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type TestE2ETest struct {
+	name                  string
+	suitePaths            []string
+	flags                 []string
+	expectedError         bool
+	expectedErrorMessages []string
+	expectedResults       []string
+}
+
+func TestTest(t *testing.T) {
+	testCases := []TestE2ETest{
+		{
+			name:            "test_suite_pass",
+			suitePaths:      []string{"testdata/11_test_suite"},
+			expectedError:   false,
+			expectedResults: []string{"PASS  small deployment is allowed", "PASS  large deployment is denied", "PASS  scaled out small deployment is denied", "3 passed, 0 failed"},
+		},
+		// 期待と異なる結果があれば失敗を報告して終了コード1で終了する
+		{
+			name:          "test_suite_mismatch",
+			suitePaths:    []string{"testdata/invalid/04_test_suite_mismatch/suite.yaml"},
+			expectedError: true,
+			expectedResults: []string{
+				"PASS  small deployment is allowed",
+				"FAIL  large deployment is allowed: policy replica-limit, Deployment example-large-deployment: expected pass, got deny",
+				"replicasは5以下にする必要があります",
+				"FAIL  missing deployment is denied: resource Deployment missing not found in the suite",
+				// アサーションの不一致は期待値と実際の値の差分で報告する
+				"FAIL  large deployment is denied with the replicas message: policy replica-limit, Deployment example-large-deployment: assertions do not match",
+				"-  message: labels are required",
+				"+  message: 'failed expression: has(object.metadata.labels)'",
+				"1 passed, 3 failed",
+			},
+		},
+		// カバレッジはバインディングごとに各式の評価結果を数える
+		{
+			name:          "test_suite_coverage",
+			suitePaths:    []string{"testdata/13_coverage"},
+			flags:         []string{"--coverage"},
+			expectedError: false,
+			expectedResults: []string{
+				"2 passed, 0 failed",
+				"replica-limit  replica-limit-binding  matchConditions[0] exclude-system  2     0      0",
+				"replica-limit  replica-limit-binding  validations[0]                     1     1      0",
+				"replica-limit  prod-binding           validations[1]                     -     -      -",
+				"coverage: 4/8 expressions reached (50.0%)",
+			},
+		},
+		{
+			name:          "test_suite_coverage_lcov",
+			suitePaths:    []string{"testdata/13_coverage"},
+			flags:         []string{"--coverage", "--coverage-format=lcov"},
+			expectedError: false,
+			expectedResults: []string{
+				"SF:testdata/13_coverage/policy.yaml",
+				"DA:20,2",
+				"BRDA:20,0,0,1",
+				"BRDA:20,1,0,-",
+				"end_of_record",
+			},
+		},
+		// 式の中の条件ごとに、trueとfalseの両方の分岐を通ったかを報告する
+		{
+			name:          "test_suite_branch_coverage",
+			suitePaths:    []string{"testdata/14_branch_coverage"},
+			flags:         []string{"--coverage"},
+			expectedError: false,
+			expectedResults: []string{
+				"UNCOVERED BRANCHES",
+				"«object.metadata.namespace == 'sandbox'» ||",
+				"object.metadata.namespace == 'sandbox': never true",
+				"branches: 5/6 taken (83.3%)",
+			},
+		},
+		{
+			name:          "test_suite_branch_coverage_lcov",
+			suitePaths:    []string{"testdata/14_branch_coverage"},
+			flags:         []string{"--coverage", "--coverage-format=lcov"},
+			expectedError: false,
+			expectedResults: []string{
+				"BRDA:15,0,2,0",
+				"BRDA:15,0,3,2",
+				"BRDA:16,0,6,1",
+				"BRF:8",
+				"BRH:7",
+			},
+		},
+		// 最低カバレッジを下回ると未評価の式を報告して失敗する
+		{
+			name:          "test_suite_min_coverage",
+			suitePaths:    []string{"testdata/13_coverage"},
+			flags:         []string{"--min-coverage=80"},
+			expectedError: true,
+			expectedErrorMessages: []string{
+				"coverage 50.0% is below the minimum of 80.0%",
+				"never reached: policy replica-limit, binding prod-binding, spec.validations[0]: variables.replicas <= 5",
+			},
+		},
+		{
+			name:                  "test_suite_not_found",
+			suitePaths:            []string{"testdata/01_simple_policy"},
+			expectedError:         true,
+			expectedErrorMessages: []string{"no test suites found in [testdata/01_simple_policy]"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			args := append([]string{"test"}, tc.suitePaths...)
+			args = append(args, tc.flags...)
+
+			cmd := exec.Command("../../bin/vaptest", args...)
+			var stdout, stderr bytes.Buffer
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+
+			err := cmd.Run()
+
+			if tc.expectedError {
+				assert.Error(t, err, "エラーが発生することを期待しています")
+			} else {
+				assert.NoError(t, err, "エラーが発生しないことを期待しています")
+			}
+			for _, expectedError := range tc.expectedErrorMessages {
+				assert.Contains(t, stderr.String(), expectedError, "期待するエラーメッセージが含まれていること")
+			}
+			for _, expectedResult := range tc.expectedResults {
+				assert.Contains(t, stdout.String(), expectedResult, "期待する出力が含まれていること")
+			}
+		})
+	}
+}
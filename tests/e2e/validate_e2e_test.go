@@ -13,9 +13,11 @@ type ValidateE2ETest struct {
 	name                     string
 	targetPaths              []string
 	policyPaths              []string
+	flags                    []string
 	expectedError            bool
 	expectedErrorMessages    []string
 	expectedResults          []string
+	expectedWarnings         []string
 	expectedValidationErrors int
 }
 
@@ -106,6 +108,99 @@ func TestValidate(t *testing.T) {
 			expectedResults:          []string{"Deploymentの名前はappで終わる必要があります", "リソースにはラベルが必要です"},
 			expectedValidationErrors: 5,
 		},
+		// 未知のターゲットリソースは警告を出して評価を続ける
+		{
+			name: "unknown_target_resource",
+			targetPaths: []string{
+				"testdata/05_unknown_target_resource/target.yaml",
+			},
+			policyPaths: []string{
+				"testdata/05_unknown_target_resource/policy.yaml",
+			},
+			expectedError:            false,
+			expectedResults:          []string{"Deploymentにはラベルが必要です"},
+			expectedWarnings:         []string{"unknown resource type in file testdata/05_unknown_target_resource/target.yaml: kind=Test, version=test"},
+			expectedValidationErrors: 1,
+		},
+		{
+			name: "subresource_request",
+			targetPaths: []string{
+				"testdata/06_subresource_request/target.yaml",
+			},
+			policyPaths: []string{
+				"testdata/06_subresource_request/policy.yaml",
+			},
+			expectedError:            false,
+			expectedResults:          []string{"deployments/example-scaled-deployment", "replicasは5以下にする必要があります", "pods/example-pod", "ttyを使ったexecは禁止されています"},
+			expectedValidationErrors: 2,
+		},
+		// apiserverと同様にデフォルト値を設定してから評価する
+		{
+			name: "api_defaulting",
+			targetPaths: []string{
+				"testdata/07_api_defaulting/target.yaml",
+			},
+			policyPaths: []string{
+				"testdata/07_api_defaulting/policy.yaml",
+			},
+			expectedError:            false,
+			expectedResults:          []string{"high-availability", "replicasは2以上にする必要があります", "DEFAULTED FIELDS", "deployments/example-defaulted-deployment: ", "spec.replicas", "spec.template.spec.containers[0].imagePullPolicy"},
+			expectedValidationErrors: 1,
+		},
+		// ルールのscopeに一致するリソースだけを評価する
+		{
+			name: "rule_scope",
+			targetPaths: []string{
+				"testdata/08_rule_scope/target.yaml",
+			},
+			policyPaths: []string{
+				"testdata/08_rule_scope/policy.yaml",
+			},
+			expectedError:            false,
+			expectedResults:          []string{"configmaps/example-config", "namespaceスコープのリソースにはラベルが必要です"},
+			expectedValidationErrors: 1,
+		},
+		// resourceRulesはOR条件で評価し、excludeResourceRulesを優先する
+		{
+			name: "multiple_resource_rules",
+			targetPaths: []string{
+				"testdata/09_multiple_resource_rules/target.yaml",
+			},
+			policyPaths: []string{
+				"testdata/09_multiple_resource_rules/policy.yaml",
+			},
+			expectedError:            false,
+			expectedResults:          []string{"pods/example-pod", "deployments/example-deployment", "ワークロードにはラベルが必要です"},
+			expectedValidationErrors: 2,
+		},
+		// アップストリームのValidatingAdmissionPolicyプラグインで評価する
+		{
+			name: "upstream_engine",
+			targetPaths: []string{
+				"testdata/10_upstream_engine/target.yaml",
+			},
+			policyPaths: []string{
+				"testdata/10_upstream_engine",
+			},
+			flags:                    []string{"--engine=upstream"},
+			expectedError:            false,
+			expectedResults:          []string{"deployments/example-large-deployment", "replicasは5以下にする必要があります", "failed expression: has(object.metadata.labels)"},
+			expectedValidationErrors: 1,
+		},
+		// 両方のエンジンの結果が一致する
+		{
+			name: "engine_compare",
+			targetPaths: []string{
+				"testdata/10_upstream_engine/target.yaml",
+			},
+			policyPaths: []string{
+				"testdata/10_upstream_engine",
+			},
+			flags:                    []string{"--engine=compare"},
+			expectedError:            false,
+			expectedResults:          []string{"deployments/example-large-deployment", "replicasは5以下にする必要があります"},
+			expectedValidationErrors: 1,
+		},
 		// invalid case
 		{
 			name: "invalid_target",
@@ -161,7 +256,8 @@ func TestValidate(t *testing.T) {
 			},
 			expectedError: true,
 			expectedErrorMessages: []string{
-				"failed to create validator",
+				`ValidatingAdmissionPolicy "deployment-validator" in file testdata/invalid/01_invalid_policy/policy-without-validations.yaml is invalid`,
+				"spec.validations: Required value",
 			},
 		},
 		{
@@ -174,21 +270,106 @@ func TestValidate(t *testing.T) {
 			},
 			expectedError: true,
 			expectedErrorMessages: []string{
-				"failed to create validator",
+				`ValidatingAdmissionPolicy "deployment-validator" in file testdata/invalid/01_invalid_policy/policy-without-expression.yaml is invalid`,
+				"spec.validations[0].expression: Required value",
+			},
+		},
+		// apiserverと同じ検証ルールで全てのエラーを評価前にまとめて報告する
+		{
+			name: "invalid_policy_objects",
+			targetPaths: []string{
+				"testdata/01_simple_policy/valid-target.yaml",
+			},
+			policyPaths: []string{
+				"testdata/invalid/03_invalid_policy_objects",
+			},
+			expectedError: true,
+			expectedErrorMessages: []string{
+				`ValidatingAdmissionPolicy "invalid-policy" in file testdata/invalid/03_invalid_policy_objects/policy.yaml is invalid`,
+				"spec.matchConstraints.resourceRules[0].operations: Invalid value",
+				"spec.matchConstraints.resourceRules[0].resources: Invalid value",
+				"spec.matchConditions[0].name: Required value",
+				`spec.variables[1].name: Duplicate value: "replicas"`,
+				`spec.validations[0].reason: Unsupported value: "NotAReason"`,
+				`ValidatingAdmissionPolicyBinding "invalid-binding" in file testdata/invalid/03_invalid_policy_objects/binding.yaml is invalid`,
+				"spec.paramRef.name: Forbidden: name and selector are mutually exclusive",
+				"spec.validationActions: Invalid value",
 			},
 		},
-		// 対応していないターゲットリソース
+		// バインディングのないポリシーはアップストリームエンジンでは評価されず、不一致として報告する
 		{
-			name: "unsupported_target_resource",
+			name: "engine_compare_disagreement",
 			targetPaths: []string{
-				"testdata/invalid/02_unsupported_target_resource/target.yaml",
+				"testdata/10_upstream_engine/target.yaml",
 			},
 			policyPaths: []string{
-				"testdata/invalid/02_unsupported_target_resource/policy.yaml",
+				"testdata/10_upstream_engine",
+				"testdata/01_simple_policy/policy.yaml",
 			},
+			flags:         []string{"--engine=compare"},
 			expectedError: true,
 			expectedErrorMessages: []string{
-				"no kind \"Test\" is registered for version \"test\" in scheme",
+				"engine disagreement: policy deployment-validator, default/deployments/example-large-deployment: native=fail [has(object.metadata.labels)], upstream=not evaluated",
+			},
+		},
+		{
+			name: "unknown_engine",
+			targetPaths: []string{
+				"testdata/10_upstream_engine/target.yaml",
+			},
+			policyPaths: []string{
+				"testdata/10_upstream_engine",
+			},
+			flags:                 []string{"--engine=cluster"},
+			expectedError:         true,
+			expectedErrorMessages: []string{`unknown engine "cluster": must be native, upstream or compare`},
+		},
+		// スナップショットと結果が一致すれば成功する
+		{
+			name: "snapshot_match",
+			targetPaths: []string{
+				"testdata/10_upstream_engine/target.yaml",
+			},
+			policyPaths: []string{
+				"testdata/10_upstream_engine",
+			},
+			flags:                    []string{"--snapshot=testdata/12_snapshot/snapshot.yaml"},
+			expectedError:            false,
+			expectedResults:          []string{"replicasは5以下にする必要があります"},
+			expectedValidationErrors: 1,
+		},
+		// スナップショットと異なる結果は差分と共にエラーとして報告する
+		{
+			name: "snapshot_mismatch",
+			targetPaths: []string{
+				"testdata/10_upstream_engine/target.yaml",
+			},
+			policyPaths: []string{
+				"testdata/10_upstream_engine",
+			},
+			flags:         []string{"--snapshot=testdata/invalid/05_snapshot_mismatch/snapshot.yaml"},
+			expectedError: true,
+			expectedErrorMessages: []string{
+				"snapshot mismatch: changed: policy replica-limit, default/deployments/example-large-deployment",
+				"-  message: replicasは3以下にする必要があります",
+				"+  message: replicasは5以下にする必要があります",
+				"results differ from snapshot testdata/invalid/05_snapshot_mismatch/snapshot.yaml, run with --update-snapshots to accept the changes",
+			},
+		},
+		// strictモードでは未知のフィールドをエラーにする
+		{
+			name: "strict_unknown_field",
+			targetPaths: []string{
+				"testdata/invalid/02_strict_decoding/target.yaml",
+			},
+			policyPaths: []string{
+				"testdata/01_simple_policy/policy.yaml",
+			},
+			flags:         []string{"--strict"},
+			expectedError: true,
+			expectedErrorMessages: []string{
+				"strict decoding failed in file testdata/invalid/02_strict_decoding/target.yaml (document index 1, field spec.template.spec.contianers)",
+				`unknown field "spec.template.spec.contianers"`,
 			},
 		},
 	}
@@ -202,6 +383,7 @@ func TestValidate(t *testing.T) {
 			for _, pp := range tc.policyPaths {
 				args = append(args, "--policies", pp)
 			}
+			args = append(args, tc.flags...)
 
 			cmd := exec.Command("../../bin/vaptest", args...)
 			var stdout, stderr bytes.Buffer
@@ -222,8 +404,13 @@ func TestValidate(t *testing.T) {
 			for _, expectedResult := range tc.expectedResults {
 				assert.Contains(t, stdout.String(), expectedResult, "期待する出力が含まれていること")
 			}
+			for _, expectedWarning := range tc.expectedWarnings {
+				assert.Contains(t, stderr.String(), expectedWarning, "期待する警告が含まれていること")
+			}
 			if tc.expectedValidationErrors > 0 {
-				assert.Equal(t, tc.expectedValidationErrors+1, strings.Count(stdout.String(), "\n"), "期待する行数が含まれていること")
+				// 結果のテーブルは最初の空行までで、その後にデフォルト値が設定されたフィールドが続く
+				table, _, _ := strings.Cut(stdout.String(), "\n\n")
+				assert.Equal(t, tc.expectedValidationErrors+1, len(strings.Split(strings.TrimSpace(table), "\n")), "期待する行数が含まれていること")
 			}
 
 		})
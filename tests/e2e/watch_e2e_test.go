@@ -0,0 +1,110 @@
+package e2e
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitFor reads the output until a line contains the text, returning the lines read.
+func waitFor(t *testing.T, lines <-chan string, text string) string {
+	t.Helper()
+	var read strings.Builder
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("output ended before %q:\n%s", text, read.String())
+			}
+			read.WriteString(line + "\n")
+			if strings.Contains(line, text) {
+				return read.String()
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %q:\n%s", text, read.String())
+		}
+	}
+}
+
+func copyTestdata(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(dst, data, 0o644))
+}
+
+func TestValidateWatch(t *testing.T) {
+	dir := t.TempDir()
+	policy := filepath.Join(dir, "policy.yaml")
+	targets := filepath.Join(dir, "targets")
+	require.NoError(t, os.Mkdir(targets, 0o755))
+	copyTestdata(t, "testdata/01_simple_policy/policy.yaml", policy)
+	copyTestdata(t, "testdata/01_simple_policy/valid-target.yaml", filepath.Join(targets, "valid-target.yaml"))
+
+	cmd := exec.Command("../../bin/vaptest", "validate", "--watch", "--policies", policy, "--targets", targets)
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	defer func() {
+		_ = cmd.Process.Signal(os.Interrupt)
+		_ = cmd.Wait()
+	}()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	out := waitFor(t, lines, "waiting for changes")
+	assert.Contains(t, out, "run 1: 1 policies, 4 targets, 4 pairs evaluated")
+	assert.Contains(t, out, "4 passed, 0 failed, 0 errors")
+
+	// ディレクトリに追加されたファイルのターゲットだけを評価し、変更を示す
+	copyTestdata(t, "testdata/01_simple_policy/invalid-target.yaml", filepath.Join(targets, "invalid-target.yaml"))
+	out = waitFor(t, lines, "waiting for changes")
+	assert.Contains(t, out, "run 2: 1 policies, 7 targets, 3 pairs evaluated")
+	assert.Regexp(t, `\*\s+deployment-validator\s+deployments/example-deployment\s+FAIL \(new\)\s+Deploymentにはラベルが必要です`, out)
+	assert.Contains(t, out, "4 passed, 3 failed, 0 errors")
+
+	// コンパイルエラーを表示して監視を続ける
+	require.NoError(t, os.WriteFile(policy, bytes.Replace(mustRead(t, policy), []byte("has(object.metadata.labels)"), []byte("has(object.metadata.("), 1), 0o644))
+	out = waitFor(t, lines, "waiting for changes")
+	assert.Contains(t, out, "ERRORS")
+	assert.Contains(t, out, "spec.validations[0].expression")
+
+	copyTestdata(t, "testdata/01_simple_policy/policy.yaml", policy)
+	out = waitFor(t, lines, "waiting for changes")
+	assert.Contains(t, out, "run 4: 1 policies, 7 targets, 7 pairs evaluated")
+	assert.NotContains(t, out, "*", "エラーの前と結果が同じなら変更として示さない")
+}
+
+func TestValidateWatchUnsupportedEngine(t *testing.T) {
+	cmd := exec.Command("../../bin/vaptest", "validate", "--watch", "--engine", "upstream",
+		"--policies", "testdata/01_simple_policy/policy.yaml", "--targets", "testdata/01_simple_policy/valid-target.yaml")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	cmd.Stdout = io.Discard
+	assert.Error(t, cmd.Run())
+	assert.Contains(t, stderr.String(), "--watch supports only the native engine")
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return data
+}